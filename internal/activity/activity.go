@@ -0,0 +1,355 @@
+// Package activity reads the GPX and TCX tracks exported by GPS watches and
+// bike computers and summarizes them: distance, moving time, elevation,
+// per-kilometer splits and heart rate.
+package activity
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatGPX = "gpx"
+	FormatTCX = "tcx"
+	// FormatFIT files are decoded by the fit package into a Track.
+	FormatFIT = "fit"
+)
+
+// Point is one recorded trackpoint. Position, elevation, the device's own
+// cumulative distance and the sensor readings are all optional; the time
+// is not. NewSegment marks the first point after the recording was paused,
+// so no distance is counted across the gap.
+type Point struct {
+	Time       time.Time
+	Lat        *float64
+	Lon        *float64
+	Elevation  *float64
+	Distance   *float64
+	HeartRate  *int
+	Cadence    *int
+	Power      *int
+	NewSegment bool
+}
+
+type Track struct {
+	Format string
+	Name   string
+	// Sport is the activity type as the file names it, such as "running"
+	// in GPX or "Biking" in TCX.
+	Sport  string
+	Points []Point
+
+	segmentPending bool
+	lapTracks      int
+}
+
+// FormatError explains why a file was rejected, pointing at the line and,
+// where it applies, the trackpoint at fault.
+type FormatError struct {
+	Line  int
+	Point int
+	Msg   string
+}
+
+func (e *FormatError) Error() string {
+	switch {
+	case e.Point > 0:
+		return fmt.Sprintf("trackpoint %d (line %d): %s", e.Point, e.Line, e.Msg)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	default:
+		return e.Msg
+	}
+}
+
+// Parse reads a GPX or TCX document, telling them apart by the root
+// element, and checks that it describes a usable track: at least two
+// trackpoints, each with a time, valid coordinates and times that never go
+// backwards.
+func Parse(data []byte) (*Track, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, &FormatError{Msg: "the file is empty"}
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var track *Track
+	var stack []string
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, syntaxError(err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			line, _ := d.InputPos()
+			name := t.Name.Local
+			if track == nil {
+				switch name {
+				case "gpx":
+					track = &Track{Format: FormatGPX}
+				case "TrainingCenterDatabase":
+					track = &Track{Format: FormatTCX}
+				default:
+					return nil, &FormatError{Line: line, Msg: fmt.Sprintf("unsupported document <%s>, expected a GPX or TCX file", name)}
+				}
+			}
+
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			decoded, err := track.element(d, t, parent, line)
+			if err != nil {
+				return nil, err
+			}
+			if !decoded {
+				stack = append(stack, name)
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if track == nil {
+		return nil, &FormatError{Msg: "the file is not an XML document"}
+	}
+	if len(track.Points) < 2 {
+		return nil, &FormatError{Msg: fmt.Sprintf("the track needs at least two trackpoints, found %d", len(track.Points))}
+	}
+	return track, nil
+}
+
+func syntaxError(err error) error {
+	var syntax *xml.SyntaxError
+	if errors.As(err, &syntax) {
+		return &FormatError{Line: syntax.Line, Msg: "malformed XML: " + syntax.Msg}
+	}
+	return &FormatError{Msg: "malformed XML: " + err.Error()}
+}
+
+// element handles one start element and reports whether it consumed the
+// whole element, end tag included.
+func (t *Track) element(d *xml.Decoder, start xml.StartElement, parent string, line int) (bool, error) {
+	name := start.Name.Local
+	switch t.Format {
+	case FormatGPX:
+		switch {
+		case name == "trkseg":
+			t.startSegment()
+		case name == "name" && (parent == "trk" || parent == "metadata") && t.Name == "":
+			return true, d.DecodeElement(&t.Name, &start)
+		case name == "type" && parent == "trk":
+			return true, d.DecodeElement(&t.Sport, &start)
+		case name == "trkpt":
+			var p gpxPoint
+			if err := d.DecodeElement(&p, &start); err != nil {
+				return true, syntaxError(err)
+			}
+			return true, t.add(line, p.point)
+		}
+	case FormatTCX:
+		switch {
+		case name == "Activity":
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "Sport" && t.Sport == "" {
+					t.Sport = attr.Value
+				}
+			}
+		case name == "Lap":
+			t.lapTracks = 0
+		case name == "Track":
+			// laps follow each other seamlessly, but a second track within
+			// one lap means the recording was paused
+			if t.lapTracks > 0 {
+				t.startSegment()
+			}
+			t.lapTracks++
+		case name == "Trackpoint":
+			var p tcxPoint
+			if err := d.DecodeElement(&p, &start); err != nil {
+				return true, syntaxError(err)
+			}
+			return true, t.add(line, p.point)
+		}
+	}
+	return false, nil
+}
+
+// startSegment makes the next point start a new segment; the first point
+// of a track needs no marker.
+func (t *Track) startSegment() {
+	t.segmentPending = len(t.Points) > 0
+}
+
+func (t *Track) add(line int, convert func() (Point, error)) error {
+	n := len(t.Points) + 1
+	p, err := convert()
+	if err != nil {
+		return &FormatError{Line: line, Point: n, Msg: err.Error()}
+	}
+	if len(t.Points) > 0 && p.Time.Before(t.Points[len(t.Points)-1].Time) {
+		return &FormatError{Line: line, Point: n, Msg: "time goes backwards from the previous trackpoint"}
+	}
+	p.NewSegment = t.segmentPending
+	t.segmentPending = false
+	t.Points = append(t.Points, p)
+	return nil
+}
+
+type gpxPoint struct {
+	Lat   string `xml:"lat,attr"`
+	Lon   string `xml:"lon,attr"`
+	Ele   string `xml:"ele"`
+	Time  string `xml:"time"`
+	HR    string `xml:"extensions>TrackPointExtension>hr"`
+	Cad   string `xml:"extensions>TrackPointExtension>cad"`
+	Power string `xml:"extensions>power"`
+}
+
+func (g gpxPoint) point() (Point, error) {
+	var p Point
+	var err error
+	if p.Time, err = parseTime(g.Time); err != nil {
+		return p, err
+	}
+	if p.Lat, p.Lon, err = parsePosition(g.Lat, g.Lon, true); err != nil {
+		return p, err
+	}
+	if p.Elevation, err = parseFloat("ele", g.Ele); err != nil {
+		return p, err
+	}
+	if p.HeartRate, err = parseSensor("heart rate", g.HR); err != nil {
+		return p, err
+	}
+	if p.Cadence, err = parseSensor("cadence", g.Cad); err != nil {
+		return p, err
+	}
+	if p.Power, err = parseSensor("power", g.Power); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+type tcxPoint struct {
+	Time       string `xml:"Time"`
+	Lat        string `xml:"Position>LatitudeDegrees"`
+	Lon        string `xml:"Position>LongitudeDegrees"`
+	Altitude   string `xml:"AltitudeMeters"`
+	Distance   string `xml:"DistanceMeters"`
+	HR         string `xml:"HeartRateBpm>Value"`
+	Cadence    string `xml:"Cadence"`
+	RunCadence string `xml:"Extensions>TPX>RunCadence"`
+	Watts      string `xml:"Extensions>TPX>Watts"`
+}
+
+func (x tcxPoint) point() (Point, error) {
+	var p Point
+	var err error
+	if p.Time, err = parseTime(x.Time); err != nil {
+		return p, err
+	}
+	// indoor activities carry no position, only the device's distance
+	if p.Lat, p.Lon, err = parsePosition(x.Lat, x.Lon, false); err != nil {
+		return p, err
+	}
+	if p.Elevation, err = parseFloat("AltitudeMeters", x.Altitude); err != nil {
+		return p, err
+	}
+	if p.Distance, err = parseFloat("DistanceMeters", x.Distance); err != nil {
+		return p, err
+	}
+	if p.Distance != nil && *p.Distance < 0 {
+		return p, errors.New("DistanceMeters cannot be negative")
+	}
+	if p.HeartRate, err = parseSensor("heart rate", x.HR); err != nil {
+		return p, err
+	}
+	cadence := x.Cadence
+	if cadence == "" {
+		cadence = x.RunCadence
+	}
+	if p.Cadence, err = parseSensor("cadence", cadence); err != nil {
+		return p, err
+	}
+	if p.Power, err = parseSensor("power", x.Watts); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, errors.New("time is required")
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339", s)
+	}
+	return t, nil
+}
+
+func parsePosition(lat, lon string, required bool) (*float64, *float64, error) {
+	if strings.TrimSpace(lat) == "" && strings.TrimSpace(lon) == "" && !required {
+		return nil, nil, nil
+	}
+	latitude, err := parseFloat("latitude", lat)
+	if err != nil {
+		return nil, nil, err
+	}
+	longitude, err := parseFloat("longitude", lon)
+	if err != nil {
+		return nil, nil, err
+	}
+	if latitude == nil || longitude == nil {
+		return nil, nil, errors.New("latitude and longitude are required")
+	}
+	if *latitude < -90 || *latitude > 90 {
+		return nil, nil, fmt.Errorf("latitude %v is outside -90..90", *latitude)
+	}
+	if *longitude < -180 || *longitude > 180 {
+		return nil, nil, fmt.Errorf("longitude %v is outside -180..180", *longitude)
+	}
+	return latitude, longitude, nil
+}
+
+func parseFloat(field, s string) (*float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", field, s)
+	}
+	return &v, nil
+}
+
+// parseSensor reads an integer sensor value. Devices write 0 while a
+// sensor has no reading, which is treated as missing.
+func parseSensor(field, s string) (*int, error) {
+	v, err := parseFloat(field, s)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if *v < 0 {
+		return nil, fmt.Errorf("%s cannot be negative", field)
+	}
+	if *v == 0 {
+		return nil, nil
+	}
+	n := int(*v + 0.5)
+	return &n, nil
+}
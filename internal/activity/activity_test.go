@@ -0,0 +1,168 @@
+package activity
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gpxRun heads due north 0.0009° (about 100 m) every 30 seconds, climbing
+// 5 m per point for the first ten points and descending afterwards.
+func gpxRun(points int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <metadata><name>Morning Run</name></metadata>
+  <trk><type>running</type><trkseg>
+`)
+	start := time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC)
+	for i := range points {
+		ele := 100 + 5*min(i, 10) - 5*max(0, i-10)
+		fmt.Fprintf(&b, `    <trkpt lat="%.4f" lon="13.4000"><ele>%d</ele><time>%s</time>`+
+			`<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>%d</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+`, 52.5+0.0009*float64(i), ele, start.Add(time.Duration(i)*30*time.Second).Format(time.RFC3339), 140+i)
+	}
+	b.WriteString("  </trkseg></trk>\n</gpx>\n")
+	return b.String()
+}
+
+func TestHaversine(t *testing.T) {
+	// one degree of latitude
+	assert.InDelta(t, 111195, Haversine(0, 0, 1, 0), 1)
+	// Berlin to Paris
+	assert.InDelta(t, 877_500, Haversine(52.5200, 13.4050, 48.8566, 2.3522), 1000)
+}
+
+func TestParseGPX(t *testing.T) {
+	track, err := Parse([]byte(gpxRun(25)))
+	require.NoError(t, err)
+	assert.Equal(t, FormatGPX, track.Format)
+	assert.Equal(t, "Morning Run", track.Name)
+	assert.Equal(t, "Running", ExerciseName(track.Sport))
+	require.Len(t, track.Points, 25)
+	assert.Equal(t, 140, *track.Points[0].HeartRate)
+
+	summary := Summarize(track)
+	assert.InDelta(t, 2401.9, summary.DistanceMeters, 0.5)
+	assert.Equal(t, 720, summary.ElapsedSeconds)
+	assert.Equal(t, 720, summary.MovingSeconds)
+	assert.Equal(t, 50.0, *summary.ElevationGainMeters)
+	assert.Equal(t, 70.0, *summary.ElevationLossMeters)
+	assert.Equal(t, 152, *summary.AvgHeartRate)
+	assert.Equal(t, 164, *summary.MaxHeartRate)
+
+	require.Len(t, summary.Splits, 3)
+	assert.Equal(t, 1000.0, summary.Splits[0].DistanceMeters)
+	assert.InDelta(t, 299.8, *summary.Splits[0].PaceSecondsPerKm, 0.5)
+	assert.InDelta(t, 401.9, summary.Splits[2].DistanceMeters, 0.5)
+	assert.Equal(t, 720, summary.Splits[0].MovingSeconds+summary.Splits[1].MovingSeconds+summary.Splits[2].MovingSeconds)
+}
+
+func TestParseGPXSegmentsAndStops(t *testing.T) {
+	doc := `<gpx><trk><trkseg>
+<trkpt lat="52.5000" lon="13.4"><time>2025-06-01T07:00:00Z</time></trkpt>
+<trkpt lat="52.5009" lon="13.4"><time>2025-06-01T07:00:30Z</time></trkpt>
+<trkpt lat="52.5009" lon="13.4"><time>2025-06-01T07:02:30Z</time></trkpt>
+</trkseg><trkseg>
+<trkpt lat="52.6000" lon="13.4"><time>2025-06-01T07:30:00Z</time></trkpt>
+<trkpt lat="52.6009" lon="13.4"><time>2025-06-01T07:00:30.5Z</time></trkpt>
+</trkseg></trk></gpx>`
+	_, err := Parse([]byte(doc))
+	require.Error(t, err)
+	assert.Equal(t, "trackpoint 5 (line 7): time goes backwards from the previous trackpoint", err.Error())
+
+	doc = strings.Replace(doc, "07:00:30.5Z", "07:30:30Z", 1)
+	track, err := Parse([]byte(doc))
+	require.NoError(t, err)
+	assert.True(t, track.Points[3].NewSegment)
+
+	summary := Summarize(track)
+	// the jump between the segments is not distance, the two minute stop is not moving
+	assert.InDelta(t, 200.2, summary.DistanceMeters, 0.5)
+	assert.Equal(t, 60, summary.MovingSeconds)
+	assert.Equal(t, 1830, summary.ElapsedSeconds)
+	assert.Nil(t, summary.ElevationGainMeters)
+	assert.Equal(t, "Cardio", ExerciseName(track.Sport))
+}
+
+func TestParseTCX(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities>
+    <Activity Sport="Biking">
+      <Id>2025-06-01T18:00:00Z</Id>
+      <Lap StartTime="2025-06-01T18:00:00Z">
+        <Track>
+          <Trackpoint><Time>2025-06-01T18:00:00Z</Time><DistanceMeters>0</DistanceMeters><HeartRateBpm><Value>120</Value></HeartRateBpm><Cadence>85</Cadence>
+            <Extensions><TPX xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2"><Watts>180</Watts></TPX></Extensions></Trackpoint>
+          <Trackpoint><Time>2025-06-01T18:01:00Z</Time><DistanceMeters>600</DistanceMeters><HeartRateBpm><Value>140</Value></HeartRateBpm><Cadence>90</Cadence>
+            <Extensions><TPX xmlns="http://www.garmin.com/xmlschemas/ActivityExtension/v2"><Watts>220</Watts></TPX></Extensions></Trackpoint>
+        </Track>
+      </Lap>
+      <Lap StartTime="2025-06-01T18:01:00Z">
+        <Track>
+          <Trackpoint><Time>2025-06-01T18:02:00Z</Time><DistanceMeters>1250</DistanceMeters><HeartRateBpm><Value>150</Value></HeartRateBpm><Cadence>0</Cadence></Trackpoint>
+        </Track>
+      </Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`
+
+	track, err := Parse([]byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, FormatTCX, track.Format)
+	assert.Equal(t, "Cycling", ExerciseName(track.Sport))
+	assert.False(t, track.Points[2].NewSegment, "laps follow each other without a gap")
+	assert.Nil(t, track.Points[2].Cadence, "a zero reading means no reading")
+
+	summary := Summarize(track)
+	assert.Equal(t, 1250.0, summary.DistanceMeters)
+	assert.Equal(t, 120, summary.MovingSeconds)
+	assert.Equal(t, 137, *summary.AvgHeartRate)
+	assert.Equal(t, 88, *summary.AvgCadence)
+	assert.Equal(t, 220, *summary.MaxPower)
+	require.Len(t, summary.Splits, 2)
+	assert.InDelta(t, 250, summary.Splits[1].DistanceMeters, 0.001)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"empty", "  \n", "the file is empty"},
+		{"not xml", "lat,lon\n1,2\n", "the file is not an XML document"},
+		{"other document", `<kml><Document/></kml>`, "line 1: unsupported document <kml>, expected a GPX or TCX file"},
+		{"malformed", "<gpx>\n<trk>\n<trkseg>\n</trk>\n</gpx>", "line 4: malformed XML: element <trkseg> closed by </trk>"},
+		{"missing time", "<gpx><trk><trkseg>\n<trkpt lat=\"1\" lon=\"2\"></trkpt>\n</trkseg></trk></gpx>",
+			"trackpoint 1 (line 2): time is required"},
+		{"bad time", "<gpx><trk><trkseg>\n<trkpt lat=\"1\" lon=\"2\"><time>yesterday</time></trkpt>\n</trkseg></trk></gpx>",
+			`trackpoint 1 (line 2): invalid time "yesterday", expected RFC 3339`},
+		{"latitude out of range", "<gpx><trk><trkseg>\n<trkpt lat=\"1\" lon=\"2\"><time>2025-06-01T07:00:00Z</time></trkpt>\n" +
+			"<trkpt lat=\"91\" lon=\"2\"><time>2025-06-01T07:00:05Z</time></trkpt>\n</trkseg></trk></gpx>",
+			"trackpoint 2 (line 3): latitude 91 is outside -90..90"},
+		{"missing longitude", "<gpx><trk><trkseg>\n<trkpt lat=\"1\"><time>2025-06-01T07:00:00Z</time></trkpt>\n</trkseg></trk></gpx>",
+			"trackpoint 1 (line 2): latitude and longitude are required"},
+		{"bad heart rate", "<TrainingCenterDatabase><Activities><Activity><Lap><Track>\n" +
+			"<Trackpoint><Time>2025-06-01T07:00:00Z</Time><HeartRateBpm><Value>fast</Value></HeartRateBpm></Trackpoint>\n" +
+			"</Track></Lap></Activity></Activities></TrainingCenterDatabase>",
+			`trackpoint 1 (line 2): invalid heart rate "fast"`},
+		{"single point", "<gpx><trk><trkseg><trkpt lat=\"1\" lon=\"2\"><time>2025-06-01T07:00:00Z</time></trkpt></trkseg></trk></gpx>",
+			"the track needs at least two trackpoints, found 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			require.Error(t, err)
+			var formatErr *FormatError
+			assert.ErrorAs(t, err, &formatErr)
+			assert.Equal(t, tt.want, err.Error())
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package activity
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// earthRadius is the mean radius of the earth in meters.
+	earthRadius = 6371008.8
+	// MovingSpeed is the slowest speed, in m/s, that still counts as moving;
+	// anything slower is standing at a crossing or a paused watch.
+	MovingSpeed = 0.5
+	// ElevationThreshold is how far, in meters, the altitude has to move
+	// from the last turning point before a climb or descent is counted, so
+	// barometer and GPS jitter do not add up to phantom climbing.
+	ElevationThreshold = 2.0
+	SplitMeters        = 1000.0
+)
+
+// Split covers one kilometer of the track; the last split may be shorter.
+type Split struct {
+	Number           int      `json:"number"`
+	DistanceMeters   float64  `json:"distance_meters"`
+	MovingSeconds    int      `json:"moving_seconds"`
+	PaceSecondsPerKm *float64 `json:"pace_seconds_per_km"`
+	ElevationChange  *float64 `json:"elevation_change_meters"`
+	AvgHeartRate     *int     `json:"avg_heart_rate"`
+}
+
+// Lap is a lap as the device recorded it, from the lap button or its
+// auto-lap setting. Only FIT files carry laps.
+type Lap struct {
+	Number         int       `json:"number"`
+	StartedAt      time.Time `json:"started_at"`
+	DistanceMeters float64   `json:"distance_meters"`
+	ElapsedSeconds int       `json:"elapsed_seconds"`
+	MovingSeconds  int       `json:"moving_seconds"`
+	AvgHeartRate   *int      `json:"avg_heart_rate"`
+	MaxHeartRate   *int      `json:"max_heart_rate"`
+}
+
+type Summary struct {
+	Format              string    `json:"format"`
+	Name                string    `json:"name"`
+	Sport               string    `json:"sport"`
+	Points              int       `json:"points"`
+	StartedAt           time.Time `json:"started_at"`
+	EndedAt             time.Time `json:"ended_at"`
+	DistanceMeters      float64   `json:"distance_meters"`
+	ElapsedSeconds      int       `json:"elapsed_seconds"`
+	MovingSeconds       int       `json:"moving_seconds"`
+	ElevationGainMeters *float64  `json:"elevation_gain_meters"`
+	ElevationLossMeters *float64  `json:"elevation_loss_meters"`
+	AvgHeartRate        *int      `json:"avg_heart_rate"`
+	MaxHeartRate        *int      `json:"max_heart_rate"`
+	AvgCadence          *int      `json:"avg_cadence"`
+	AvgPower            *int      `json:"avg_power"`
+	MaxPower            *int      `json:"max_power"`
+	// Calories is what the recording device measured, when it did.
+	Calories *int    `json:"calories,omitempty"`
+	Splits   []Split `json:"splits"`
+	Laps     []Lap   `json:"laps,omitempty"`
+}
+
+// Haversine returns the great-circle distance in meters between two
+// coordinates given in degrees.
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// legDistance is the distance covered between two consecutive points:
+// along the great circle when both have a position, otherwise by the
+// device's own odometer.
+func legDistance(from, to *Point) float64 {
+	if from.Lat != nil && to.Lat != nil {
+		return Haversine(*from.Lat, *from.Lon, *to.Lat, *to.Lon)
+	}
+	if from.Distance != nil && to.Distance != nil {
+		return math.Max(0, *to.Distance-*from.Distance)
+	}
+	return 0
+}
+
+// mean accumulates sensor readings.
+type mean struct {
+	sum, n, max int
+}
+
+func (m *mean) add(v *int) {
+	if v == nil {
+		return
+	}
+	m.sum += *v
+	m.n++
+	m.max = max(m.max, *v)
+}
+
+func (m *mean) avg() *int {
+	if m.n == 0 {
+		return nil
+	}
+	v := int(math.Round(float64(m.sum) / float64(m.n)))
+	return &v
+}
+
+func (m *mean) maximum() *int {
+	if m.n == 0 {
+		return nil
+	}
+	v := m.max
+	return &v
+}
+
+type splitBuilder struct {
+	number   int
+	distance float64
+	moving   float64
+	startEle *float64
+	hr       mean
+}
+
+func (b *splitBuilder) finish(ele *float64) Split {
+	split := Split{
+		Number:         b.number,
+		DistanceMeters: round(b.distance, 1),
+		MovingSeconds:  int(math.Round(b.moving)),
+		AvgHeartRate:   b.hr.avg(),
+	}
+	if b.distance > 0 && b.moving > 0 {
+		pace := round(b.moving/(b.distance/1000), 1)
+		split.PaceSecondsPerKm = &pace
+	}
+	if b.startEle != nil && ele != nil {
+		change := round(*ele-*b.startEle, 1)
+		split.ElevationChange = &change
+	}
+	return split
+}
+
+// Summarize works out the totals and splits of a parsed track.
+func Summarize(t *Track) Summary {
+	first, last := t.Points[0], t.Points[len(t.Points)-1]
+	summary := Summary{
+		Format:         t.Format,
+		Name:           t.Name,
+		Sport:          t.Sport,
+		Points:         len(t.Points),
+		StartedAt:      first.Time,
+		EndedAt:        last.Time,
+		ElapsedSeconds: int(math.Round(last.Time.Sub(first.Time).Seconds())),
+		Splits:         []Split{},
+	}
+
+	var distance, moving float64
+	var hr, cadence, power mean
+	var gain, loss float64
+	var anchor, ele *float64
+
+	split := &splitBuilder{number: 1}
+	for i := range t.Points {
+		p := &t.Points[i]
+		if p.Elevation != nil {
+			if anchor == nil {
+				anchor = p.Elevation
+			} else if diff := *p.Elevation - *anchor; math.Abs(diff) >= ElevationThreshold {
+				if diff > 0 {
+					gain += diff
+				} else {
+					loss -= diff
+				}
+				anchor = p.Elevation
+			}
+			ele = p.Elevation
+		}
+		if i == 0 {
+			split.startEle = ele
+		}
+
+		if i > 0 && !p.NewSegment {
+			leg := legDistance(&t.Points[i-1], p)
+			seconds := p.Time.Sub(t.Points[i-1].Time).Seconds()
+			legMoving := 0.0
+			if seconds > 0 && leg/seconds >= MovingSpeed {
+				legMoving = seconds
+			}
+			distance += leg
+			moving += legMoving
+
+			// a leg can close one split, or several after a GPS jump
+			for leg > 0 && split.distance+leg >= SplitMeters {
+				share := (SplitMeters - split.distance) / leg
+				split.distance = SplitMeters
+				split.moving += legMoving * share
+				leg -= leg * share
+				legMoving -= legMoving * share
+				summary.Splits = append(summary.Splits, split.finish(ele))
+				split = &splitBuilder{number: split.number + 1, startEle: ele}
+			}
+			split.distance += leg
+			split.moving += legMoving
+		}
+
+		split.hr.add(p.HeartRate)
+		hr.add(p.HeartRate)
+		cadence.add(p.Cadence)
+		power.add(p.Power)
+	}
+	if split.distance >= 1 {
+		summary.Splits = append(summary.Splits, split.finish(ele))
+	}
+
+	summary.DistanceMeters = round(distance, 1)
+	summary.MovingSeconds = int(math.Round(moving))
+	if anchor != nil {
+		gain, loss = round(gain, 1), round(loss, 1)
+		summary.ElevationGainMeters = &gain
+		summary.ElevationLossMeters = &loss
+	}
+	summary.AvgHeartRate = hr.avg()
+	summary.MaxHeartRate = hr.maximum()
+	summary.AvgCadence = cadence.avg()
+	summary.AvgPower = power.avg()
+	summary.MaxPower = power.maximum()
+	return summary
+}
+
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
+
+// ExerciseName names the exercise a track's sport stands for, the way the
+// exercise catalog does.
+func ExerciseName(sport string) string {
+	s := strings.ToLower(strings.TrimSpace(sport))
+	switch {
+	// Strava writes its activity type codes into GPX files
+	case s == "9" || strings.Contains(s, "run"):
+		return "Running"
+	case s == "1" || strings.Contains(s, "bik") || strings.Contains(s, "cycl") || strings.Contains(s, "ride"):
+		return "Cycling"
+	case s == "10" || strings.Contains(s, "walk"):
+		return "Walking"
+	case s == "4" || strings.Contains(s, "hik"):
+		return "Hiking"
+	case strings.Contains(s, "swim"):
+		return "Swimming"
+	case strings.Contains(s, "row"):
+		return "Rowing"
+	default:
+		return "Cardio"
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/activity"
+	"github.com/fsrn12/fitness_tracker_go/internal/fit"
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+// maxActivityFileSize bounds uploads; a GPX of a full day's ride with one
+// point per second stays well below it.
+const maxActivityFileSize = 20 << 20
+
+var activityContentTypes = map[string]string{
+	activity.FormatGPX: "application/gpx+xml",
+	activity.FormatTCX: "application/vnd.garmin.tcx+xml",
+	activity.FormatFIT: "application/vnd.ant.fit",
+}
+
+type ActivityHandler struct {
+	activityStore   store.ActivityStore
+	exerciseStore   store.ExerciseStore
+	recordStore     store.RecordStore
+	bodyWeightStore store.BodyWeightStore
+	logger          *log.Logger
+}
+
+func NewActivityHandler(activityStore store.ActivityStore, exerciseStore store.ExerciseStore, recordStore store.RecordStore, bodyWeightStore store.BodyWeightStore, logger *log.Logger) *ActivityHandler {
+	return &ActivityHandler{
+		activityStore:   activityStore,
+		exerciseStore:   exerciseStore,
+		recordStore:     recordStore,
+		bodyWeightStore: bodyWeightStore,
+		logger:          logger,
+	}
+}
+
+// HandleImportActivity creates a workout from an uploaded GPX, TCX or FIT
+// file, sent as multipart/form-data in the file field. A FIT strength
+// activity becomes one entry per exercise with its sets; everything else
+// becomes a single cardio entry. The optional title and exercise_name
+// fields override what is read from the file. Like any new workout, it
+// answers with the personal records the workout sets.
+func (ah *ActivityHandler) HandleImportActivity(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxActivityFileSize)
+	err := r.ParseMultipartForm(maxActivityFileSize)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "activity files are limited to 20 MB"})
+			return
+		}
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "expected a multipart/form-data upload"})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "the file field is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		ah.logger.Printf("ERROR - reading activity upload: %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	var summary activity.Summary
+	var workout *store.Workout
+	if fit.IsFIT(data) {
+		decoded, err := fit.Decode(data)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		summary = decoded.Summary()
+		if decoded.Strength() {
+			workout = workoutFromFITSets(currentUser.ID, summary, decoded.Sets)
+		}
+	} else {
+		track, err := activity.Parse(data)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		summary = activity.Summarize(track)
+	}
+
+	if workout == nil {
+		exerciseName := strings.TrimSpace(r.FormValue("exercise_name"))
+		if exerciseName == "" {
+			exerciseName = activity.ExerciseName(summary.Sport)
+		}
+		workout = store.WorkoutFromSummary(currentUser.ID, summary, exerciseName)
+	}
+	if title := strings.TrimSpace(r.FormValue("title")); title != "" {
+		workout.Title = title
+	}
+
+	if len(workout.Entries) == 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "the activity holds no sets with reps or a duration"})
+		return
+	}
+	err = workout.ResolveTimes()
+	if err == nil {
+		err = validateEntries(workout.Entries)
+	}
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "the activity cannot be turned into a workout: " + err.Error()})
+		return
+	}
+
+	err = resolveExercises(ah.exerciseStore, currentUser.ID, workout.Entries)
+	if err != nil {
+		writeResolveError(w, ah.logger, err)
+		return
+	}
+	fillCalories(ah.bodyWeightStore, ah.logger, currentUser, workout)
+
+	filename := filepath.Base(header.Filename)
+	if filename == "." || filename == "/" || len(filename) > 255 {
+		filename = "activity." + summary.Format
+	}
+	activityTrack := &store.ActivityTrack{
+		Format:   summary.Format,
+		Filename: filename,
+		Summary:  summary,
+		Raw:      data,
+	}
+
+	err = ah.activityStore.ImportActivity(workout, activityTrack)
+	if err != nil {
+		ah.logger.Printf("ERROR - ImportActivity(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to import activity"})
+		return
+	}
+
+	newRecords := refreshRecords(ah.recordStore, ah.logger, currentUser.ID, workout.ID, nil)
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": workout, "activity": activityTrack, "personal_records": newRecords})
+}
+
+func (ah *ActivityHandler) HandleGetActivity(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.GetParamID(r)
+	if err != nil {
+		ah.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	track, err := ah.activityStore.GetActivityTrack(workoutID, currentUser.ID)
+	if err != nil {
+		ah.logger.Printf("ERROR - GetActivityTrack(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if track == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "this workout has no imported activity"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": track})
+}
+
+// HandleDownloadActivity returns the uploaded file byte for byte.
+func (ah *ActivityHandler) HandleDownloadActivity(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.GetParamID(r)
+	if err != nil {
+		ah.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	track, err := ah.activityStore.GetActivityFile(workoutID, currentUser.ID)
+	if err != nil {
+		ah.logger.Printf("ERROR - GetActivityFile(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if track == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "this workout has no imported activity"})
+		return
+	}
+
+	w.Header().Set("Content-Type", activityContentTypes[track.Format])
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": track.Filename}))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(track.Raw)
+	if err != nil {
+		ah.logger.Printf("ERROR - writing activity file: %v\n", err)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/strength"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+type AnalyticsHandler struct {
+	analyticsStore store.AnalyticsStore
+	exerciseStore  store.ExerciseStore
+	logger         *log.Logger
+}
+
+func NewAnalyticsHandler(analyticsStore store.AnalyticsStore, exerciseStore store.ExerciseStore, logger *log.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsStore: analyticsStore,
+		exerciseStore:  exerciseStore,
+		logger:         logger,
+	}
+}
+
+// readLocation picks the zone that decides bucket boundaries: the tz query
+// parameter when given, otherwise the user's own timezone.
+func readLocation(qs url.Values, user *store.User) (*time.Location, error) {
+	name := utils.ReadString(qs, "tz", user.Timezone)
+	if name == "" || name == "Local" {
+		name = "UTC"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("tz must be an IANA time zone name")
+	}
+	return loc, nil
+}
+
+// HandleGetStrengthTrend returns the best estimated one-rep max per day or
+// week for one exercise, picked either by catalog exercise_id or by the
+// free-text exercise name of unlinked entries.
+func (ah *AnalyticsHandler) HandleGetStrengthTrend(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	currentUser := middleware.GetUser(r)
+
+	loc, err := readLocation(qs, currentUser)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	from, to, err := utils.ReadTimeRange(qs, loc)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	formula, err := strength.ParseFormula(utils.ReadString(qs, "formula", string(strength.FormulaEpley)))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	bucket := utils.ReadString(qs, "bucket", strength.BucketDay)
+	if bucket != strength.BucketDay && bucket != strength.BucketWeek {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "bucket must be day or week"})
+		return
+	}
+
+	exerciseID, err := utils.ReadInt(qs, "exercise_id", 0)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	ref := store.ExerciseRef{Name: utils.ReadString(qs, "exercise", "")}
+	if exerciseID > 0 {
+		exercise, err := ah.exerciseStore.GetExerciseByID(int64(exerciseID), currentUser.ID)
+		if err != nil {
+			ah.logger.Printf("ERROR - GetExerciseByID(): %v\n", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if exercise == nil {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+			return
+		}
+		ref = store.ExerciseRef{ID: &exercise.ID, Name: exercise.Name}
+	}
+	if ref.ID == nil && ref.Name == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "exercise_id or exercise is required"})
+		return
+	}
+
+	history, err := ah.analyticsStore.GetPerformances(currentUser.ID, ref, from, to)
+	if err != nil {
+		ah.logger.Printf("ERROR - GetPerformances(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	points, err := strength.Trend(history, formula, bucket, loc)
+	if err != nil {
+		ah.logger.Printf("ERROR - strength.Trend(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": utils.Envelope{
+		"exercise_id":   ref.ID,
+		"exercise_name": ref.Name,
+		"formula":       formula,
+		"bucket":        bucket,
+		"time_zone":     loc.String(),
+		"points":        points,
+	}})
+}
+
+// HandleGetVolume totals sets, reps, tonnage and time under load per day,
+// week or month, optionally split by exercise or muscle group.
+func (ah *AnalyticsHandler) HandleGetVolume(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	currentUser := middleware.GetUser(r)
+
+	filter := store.VolumeFilter{
+		UserID:  currentUser.ID,
+		Bucket:  utils.ReadString(qs, "bucket", store.VolumeBucketWeek),
+		GroupBy: utils.ReadString(qs, "group_by", ""),
+	}
+
+	loc, err := readLocation(qs, currentUser)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	filter.TimeZone = loc.String()
+
+	filter.From, filter.To, err = utils.ReadTimeRange(qs, loc)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if !slices.Contains(store.VolumeBuckets, filter.Bucket) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("bucket must be one of %v", store.VolumeBuckets)})
+		return
+	}
+	if filter.GroupBy != "" && !slices.Contains(store.VolumeGroups, filter.GroupBy) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("group_by must be one of %v", store.VolumeGroups)})
+		return
+	}
+
+	points, err := ah.analyticsStore.GetVolume(filter)
+	if err != nil {
+		ah.logger.Printf("ERROR - GetVolume(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": utils.Envelope{
+		"bucket":    filter.Bucket,
+		"group_by":  filter.GroupBy,
+		"time_zone": filter.TimeZone,
+		"points":    points,
+	}})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+type BodyWeightHandler struct {
+	bodyWeightStore store.BodyWeightStore
+	logger          *log.Logger
+}
+
+func NewBodyWeightHandler(bodyWeightStore store.BodyWeightStore, logger *log.Logger) *BodyWeightHandler {
+	return &BodyWeightHandler{
+		bodyWeightStore: bodyWeightStore,
+		logger:          logger,
+	}
+}
+
+// HandleCreateBodyWeight logs a body weight in kilograms, measured now
+// unless measured_at says otherwise. Workout calories are estimated from the
+// weight closest before each workout.
+func (bh *BodyWeightHandler) HandleCreateBodyWeight(w http.ResponseWriter, r *http.Request) {
+	var weight store.BodyWeight
+	err := json.NewDecoder(r.Body).Decode(&weight)
+	if err != nil {
+		bh.logger.Printf("ERROR - createBodyWeightRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	weight.UserID = currentUser.ID
+
+	err = weight.Validate()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	createdWeight, err := bh.bodyWeightStore.CreateBodyWeight(&weight)
+	if err != nil {
+		bh.logger.Printf("ERROR - CreateBodyWeight(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to log body weight"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdWeight})
+}
+
+// HandleListBodyWeights lists the user's body weights, newest first,
+// optionally limited to the from/to range of measured_at.
+func (bh *BodyWeightHandler) HandleListBodyWeights(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	from, to, err := utils.ReadTimeRange(r.URL.Query(), userLocation(currentUser))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	weights, err := bh.bodyWeightStore.ListBodyWeights(currentUser.ID, from, to)
+	if err != nil {
+		bh.logger.Printf("ERROR - ListBodyWeights(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": weights})
+}
+
+func (bh *BodyWeightHandler) HandleDeleteBodyWeightByID(w http.ResponseWriter, r *http.Request) {
+	weightID, err := utils.GetParamID(r)
+	if err != nil {
+		bh.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid body weight id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = bh.bodyWeightStore.DeleteBodyWeight(weightID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "body weight not found"})
+		return
+	}
+	if err != nil {
+		bh.logger.Printf("ERROR - DeleteBodyWeight(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// The entries of a workout can be edited one at a time under
+// /workouts/{id}/entries. An entry is part of its workout: every change
+// saves the workout, moving it to its next version, so writes need the
+// version of the workout read, in an If-Match header or as ?version=, and
+// answer with the ETag of the new version.
+
+// findEntry returns the index of the entry of the {entryID} URL parameter
+// in the workout, answering the request itself and returning -1 when the
+// workout has no such entry.
+func findEntry(w http.ResponseWriter, r *http.Request, workout *store.Workout) int {
+	entryID, err := strconv.Atoi(chi.URLParam(r, "entryID"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid entry id"})
+		return -1
+	}
+
+	i := slices.IndexFunc(workout.Entries, func(entry store.WorkoutEntry) bool {
+		return entry.ID == entryID
+	})
+	if i < 0 {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "entry not found"})
+	}
+	return i
+}
+
+// checkEntryWriteVersion checks the version of the workout an entry write
+// is based on, given in an If-Match header or as ?version=.
+func checkEntryWriteVersion(w http.ResponseWriter, r *http.Request, workout *store.Workout) bool {
+	version, err := readVersion(r)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return false
+	}
+	return checkWorkoutVersion(w, r, workout, version)
+}
+
+// renumberEntries sets the order_index of the entries to their position.
+func renumberEntries(entries []store.WorkoutEntry) {
+	for i := range entries {
+		entries[i].OrderIndex = i + 1
+	}
+}
+
+// HandleAddEntry adds an entry to a workout, at the position of its
+// order_index or last without one. The entries from that position on move
+// down one place.
+func (wh *WorkoutHandler) HandleAddEntry(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	var entry store.WorkoutEntry
+	err := json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		wh.logger.Printf("ERROR - addEntryRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	entry.ID = 0
+	position := len(workout.Entries)
+	if entry.OrderIndex >= 1 && entry.OrderIndex <= len(workout.Entries) {
+		position = entry.OrderIndex - 1
+	}
+	workout.Entries = slices.Insert(workout.Entries, position, entry)
+	renumberEntries(workout.Entries)
+
+	currentUser := middleware.GetUser(r)
+	err = resolveExercises(wh.exerciseStore, currentUser.ID, workout.Entries[position:position+1])
+	if err != nil {
+		writeResolveError(w, wh.logger, err)
+		return
+	}
+	err = validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	newRecords, ok := wh.saveWorkout(w, r, workout, previous)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": workout.Entries[position], "personal_records": newRecords})
+}
+
+func (wh *WorkoutHandler) HandleGetEntry(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	i := findEntry(w, r, workout)
+	if i < 0 {
+		return
+	}
+
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workout.Entries[i]})
+}
+
+// HandlePatchEntry changes the fields of an entry present in the body and
+// leaves the others as they are; null clears a field. A new exercise_name
+// without an exercise_id unlinks the entry from the exercise it had. A
+// set_log replaces the whole log.
+func (wh *WorkoutHandler) HandlePatchEntry(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	i := findEntry(w, r, workout)
+	if i < 0 {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		wh.logger.Printf("ERROR - reading patchEntryRequest: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(body, &fields)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	workout.Entries[i], err = patchEntry(workout.Entries[i], fields)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	_, exerciseID := fields["exercise_id"]
+	_, exerciseName := fields["exercise_name"]
+	if exerciseID || exerciseName {
+		err = resolveExercises(wh.exerciseStore, middleware.GetUser(r).ID, workout.Entries[i:i+1])
+		if err != nil {
+			writeResolveError(w, wh.logger, err)
+			return
+		}
+	}
+	err = validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	newRecords, ok := wh.saveWorkout(w, r, workout, previous)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workout.Entries[i], "personal_records": newRecords})
+}
+
+// patchEntry returns the entry with the fields of a patch body replaced.
+// Each field is replaced whole: a set_log or group in the body takes the
+// place of the old one rather than being merged into it. The result is
+// decoded afresh, so the stored entry and the pointers it shares with the
+// rest of the workout are left as they were.
+func patchEntry(entry store.WorkoutEntry, fields map[string]json.RawMessage) (store.WorkoutEntry, error) {
+	stored, err := json.Marshal(entry)
+	if err != nil {
+		return store.WorkoutEntry{}, err
+	}
+	var doc map[string]json.RawMessage
+	err = json.Unmarshal(stored, &doc)
+	if err != nil {
+		return store.WorkoutEntry{}, err
+	}
+
+	_, exerciseID := fields["exercise_id"]
+	_, exerciseName := fields["exercise_name"]
+	if exerciseName && !exerciseID {
+		doc["exercise_id"] = json.RawMessage("null")
+	}
+	for name, value := range fields {
+		doc[name] = value
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return store.WorkoutEntry{}, err
+	}
+	var result store.WorkoutEntry
+	err = json.Unmarshal(patched, &result)
+	if err != nil {
+		return store.WorkoutEntry{}, err
+	}
+	result.ID, result.CreatedAt = entry.ID, entry.CreatedAt
+	return result, nil
+}
+
+func (wh *WorkoutHandler) HandleDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	i := findEntry(w, r, workout)
+	if i < 0 {
+		return
+	}
+
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	workout.Entries = slices.Delete(workout.Entries, i, i+1)
+	// the rest of a group may now be too small to be one
+	err := validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	_, ok := wh.saveWorkout(w, r, workout, previous)
+	if !ok {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleReorderEntries puts the entries of a workout in the order of
+// entry_ids, which must list every entry of the workout once. The body can
+// carry the version of the workout in place of an If-Match header.
+func (wh *WorkoutHandler) HandleReorderEntries(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	var req struct {
+		EntryIDs []int `json:"entry_ids"`
+		Version  *int  `json:"version"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		wh.logger.Printf("ERROR - reorderEntriesRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if !checkWorkoutVersion(w, r, workout, req.Version) {
+		return
+	}
+
+	entries, err := reorderEntries(workout.Entries, req.EntryIDs)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	workout.Entries = entries
+	err = validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	_, ok := wh.saveWorkout(w, r, workout, nil)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workout.Entries})
+}
+
+// reorderEntries returns the entries in the order of ids, renumbered.
+func reorderEntries(entries []store.WorkoutEntry, ids []int) ([]store.WorkoutEntry, error) {
+	errIncomplete := errors.New("entry_ids must list every entry of the workout once")
+	if len(ids) != len(entries) {
+		return nil, errIncomplete
+	}
+
+	ordered := make([]store.WorkoutEntry, 0, len(entries))
+	for _, id := range ids {
+		i := slices.IndexFunc(entries, func(entry store.WorkoutEntry) bool {
+			return entry.ID == id
+		})
+		if i < 0 || slices.Contains(ids[:len(ordered)], id) {
+			return nil, errIncomplete
+		}
+		ordered = append(ordered, entries[i])
+	}
+	renumberEntries(ordered)
+	return ordered, nil
+}
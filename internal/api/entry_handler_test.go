@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWorkoutStore holds a single workout; the methods a test does not
+// override panic through the nil interface.
+type fakeWorkoutStore struct {
+	store.WorkoutStore
+	workout *store.Workout
+}
+
+func (fs *fakeWorkoutStore) GetWorkoutByID(id int64, userID int) (*store.Workout, error) {
+	if fs.workout == nil || int64(fs.workout.ID) != id || fs.workout.UserID != userID {
+		return nil, nil
+	}
+	// a copy, as every read from the database is
+	data, err := json.Marshal(fs.workout)
+	if err != nil {
+		return nil, err
+	}
+	workout := &store.Workout{}
+	err = json.Unmarshal(data, workout)
+	return workout, err
+}
+
+func (fs *fakeWorkoutStore) UpdateWorkout(workout *store.Workout) error {
+	workout.Version++
+	fs.workout = workout
+	return nil
+}
+
+type fakeRecordStore struct {
+	store.RecordStore
+}
+
+func (fakeRecordStore) RecomputeForWorkout(userID int, workoutID int, previous []store.ExerciseRef) ([]*store.PersonalRecord, error) {
+	return []*store.PersonalRecord{}, nil
+}
+
+type fakeBodyWeightStore struct {
+	store.BodyWeightStore
+}
+
+func (fakeBodyWeightStore) LatestBodyWeight(userID int, at time.Time) (*store.BodyWeight, error) {
+	return nil, nil
+}
+
+func TestPatchEntryReplacesSetLog(t *testing.T) {
+	five, hundred := 5, 100.0
+	workouts := &fakeWorkoutStore{workout: &store.Workout{
+		ID:          7,
+		UserID:      3,
+		Title:       "Push",
+		PerformedAt: time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC),
+		Version:     2,
+		Entries: []store.WorkoutEntry{{
+			ID:           11,
+			ExerciseName: "Bench Press",
+			Kind:         store.EntryKindStrength,
+			OrderIndex:   1,
+			Group:        &store.EntryGroup{Label: "A", Type: store.GroupSuperset, Rounds: 1},
+			SetLog: []store.WorkoutSet{
+				{ID: 7, SetIndex: 1, SetType: store.SetTypeWorking, Reps: &five, Weight: &hundred, Completed: true},
+				{ID: 8, SetIndex: 2, SetType: store.SetTypeWorking, Reps: &five, Weight: &hundred, Completed: true},
+			},
+		}},
+	}}
+	require.NoError(t, workouts.workout.Entries[0].SummarizeSets())
+	handler := NewWorkoutHandler(workouts, nil, fakeRecordStore{}, fakeBodyWeightStore{}, log.New(io.Discard, "", 0))
+
+	router := chi.NewRouter()
+	router.Patch("/workouts/{id}/entries/{entryID}", handler.HandlePatchEntry)
+	body := `{"set_log": [{"reps": 3, "completed": true}], "group": null}`
+	r := httptest.NewRequest(http.MethodPatch, "/workouts/7/entries/11", strings.NewReader(body))
+	r.Header.Set("If-Match", `"2"`)
+	r = middleware.SetUser(r, &store.User{ID: 3})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+
+	entry := workouts.workout.Entries[0]
+	require.Len(t, entry.SetLog, 1, "the set log is replaced, not merged")
+	set := entry.SetLog[0]
+	assert.Zero(t, set.ID)
+	assert.Equal(t, 3, *set.Reps)
+	assert.Nil(t, set.Weight, "nothing is kept from the old first set")
+	assert.Nil(t, entry.Group)
+	assert.Equal(t, "Bench Press", entry.ExerciseName)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+type exerciseRequest struct {
+	Name             *string   `json:"name"`
+	Aliases          *[]string `json:"aliases"`
+	PrimaryMuscles   *[]string `json:"primary_muscles"`
+	SecondaryMuscles *[]string `json:"secondary_muscles"`
+	Equipment        *string   `json:"equipment"`
+	MovementType     *string   `json:"movement_type"`
+}
+
+type ExerciseHandler struct {
+	exerciseStore store.ExerciseStore
+	logger        *log.Logger
+}
+
+func NewExerciseHandler(exerciseStore store.ExerciseStore, logger *log.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		exerciseStore: exerciseStore,
+		logger:        logger,
+	}
+}
+
+func (eh *ExerciseHandler) applyExerciseRequest(exercise *store.Exercise, req *exerciseRequest) {
+	if req.Name != nil {
+		exercise.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Aliases != nil {
+		exercise.Aliases = *req.Aliases
+	}
+	if req.PrimaryMuscles != nil {
+		exercise.PrimaryMuscles = *req.PrimaryMuscles
+	}
+	if req.SecondaryMuscles != nil {
+		exercise.SecondaryMuscles = *req.SecondaryMuscles
+	}
+	if req.Equipment != nil {
+		exercise.Equipment = *req.Equipment
+	}
+	if req.MovementType != nil {
+		exercise.MovementType = *req.MovementType
+	}
+}
+
+func (eh *ExerciseHandler) validateExercise(exercise *store.Exercise) error {
+	if exercise.Name == "" || len(exercise.Name) > 255 {
+		return errors.New("name is required and must be at most 255 characters long")
+	}
+	if len(exercise.PrimaryMuscles) == 0 {
+		return errors.New("at least one primary muscle is required")
+	}
+	for _, muscle := range slices.Concat(exercise.PrimaryMuscles, exercise.SecondaryMuscles) {
+		if !store.ValidMuscleGroup(muscle) {
+			return fmt.Errorf("unknown muscle group %q, expected one of %s", muscle, strings.Join(store.MuscleGroups, ", "))
+		}
+	}
+	if !store.ValidEquipment(exercise.Equipment) {
+		return fmt.Errorf("unknown equipment %q, expected one of %s", exercise.Equipment, strings.Join(store.EquipmentTypes, ", "))
+	}
+	if !store.ValidMovementType(exercise.MovementType) {
+		return fmt.Errorf("unknown movement type %q, expected one of %s", exercise.MovementType, strings.Join(store.MovementTypes, ", "))
+	}
+	for _, alias := range exercise.Aliases {
+		if strings.TrimSpace(alias) == "" {
+			return errors.New("aliases cannot be empty")
+		}
+	}
+	return nil
+}
+
+func (eh *ExerciseHandler) HandleSearchExercises(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	currentUser := middleware.GetUser(r)
+
+	filter := store.ExerciseFilter{
+		UserID:       currentUser.ID,
+		Query:        utils.ReadString(qs, "q", ""),
+		Muscle:       utils.ReadString(qs, "muscle", ""),
+		Equipment:    utils.ReadString(qs, "equipment", ""),
+		MovementType: utils.ReadString(qs, "movement_type", ""),
+	}
+
+	var err error
+	filter.Limit, err = utils.ReadInt(qs, "limit", store.DefaultExerciseLimit)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if filter.Limit < 1 || filter.Limit > store.MaxExerciseLimit {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", store.MaxExerciseLimit)})
+		return
+	}
+
+	exercises, err := eh.exerciseStore.SearchExercises(filter)
+	if err != nil {
+		eh.logger.Printf("ERROR - SearchExercises(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": exercises})
+}
+
+func (eh *ExerciseHandler) HandleCreateExercise(w http.ResponseWriter, r *http.Request) {
+	var req exerciseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		eh.logger.Printf("ERROR - CreateExerciseRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	exercise := &store.Exercise{UserID: &currentUser.ID, Equipment: "none"}
+	eh.applyExerciseRequest(exercise, &req)
+
+	err = eh.validateExercise(exercise)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	createdExercise, err := eh.exerciseStore.CreateExercise(exercise)
+	if errors.Is(err, store.ErrDuplicateExercise) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		eh.logger.Printf("ERROR - CreateExercise(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create exercise"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdExercise})
+}
+
+func (eh *ExerciseHandler) HandleGetExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.GetParamID(r)
+	if err != nil {
+		eh.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	exercise, err := eh.exerciseStore.GetExerciseByID(exerciseID, currentUser.ID)
+	if err != nil {
+		eh.logger.Printf("ERROR - GetExerciseByID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if exercise == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": exercise})
+}
+
+func (eh *ExerciseHandler) HandleUpdateExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.GetParamID(r)
+	if err != nil {
+		eh.logger.Printf("ERROR: GetParamID => %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	exercise, err := eh.exerciseStore.GetExerciseByID(exerciseID, currentUser.ID)
+	if err != nil {
+		eh.logger.Printf("ERROR - GetExerciseByID() -> UpdateExercise(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if exercise == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return
+	}
+
+	if !exercise.Custom {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "built-in exercises cannot be modified"})
+		return
+	}
+
+	var req exerciseRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		eh.logger.Printf("ERROR - updateExerciseRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	eh.applyExerciseRequest(exercise, &req)
+	err = eh.validateExercise(exercise)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = eh.exerciseStore.UpdateExercise(exercise)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return
+	}
+	if errors.Is(err, store.ErrDuplicateExercise) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		eh.logger.Printf("ERROR - UpdateExercise(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update exercise"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": exercise})
+}
+
+func (eh *ExerciseHandler) HandleDeleteExerciseByID(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.GetParamID(r)
+	if err != nil {
+		eh.logger.Printf("ERROR - GetParamID: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = eh.exerciseStore.DeleteExercise(exerciseID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no custom exercise found"})
+		return
+	}
+
+	if err != nil {
+		eh.logger.Printf("ERROR - DeleteExercise(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
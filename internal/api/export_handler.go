@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+
+	// exportWriteTimeout is how long writing each workout of an export may
+	// take; the server's WriteTimeout would otherwise cut a long export off
+	exportWriteTimeout = 30 * time.Second
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:   "text/csv; charset=utf-8",
+	exportJSONL: "application/x-ndjson",
+}
+
+// exportColumns is the CSV layout of an export. Columns are only ever
+// appended, so spreadsheets and scripts built on an export keep working.
+var exportColumns = []string{
+	"workout_id", "performed_at", "started_at", "ended_at", "title", "description", "duration_minutes", "calories_burned",
+	"entry_id", "order_index", "exercise_id", "exercise_name", "kind", "section", "group_label", "group_type", "notes",
+	"sets", "distance", "distance_unit", "elevation_gain_meters", "avg_heart_rate", "max_heart_rate", "avg_cadence", "avg_power",
+	"set_index", "set_type", "reps", "duration_seconds", "weight", "rpe", "rir", "tempo", "rest_seconds", "completed",
+}
+
+type ExportHandler struct {
+	workoutStore store.WorkoutStore
+	logger       *log.Logger
+}
+
+func NewExportHandler(workoutStore store.WorkoutStore, logger *log.Logger) *ExportHandler {
+	return &ExportHandler{
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+// HandleExportWorkouts downloads the user's whole training log, oldest
+// workout first, optionally limited to the from/to range of performed_at.
+// The workouts are streamed from the database as they are written, so the
+// size of a history does not matter.
+//
+// format=jsonl writes one workout per line, shaped as GET /workouts/{id}
+// returns it. format=csv, the default, writes one row per logged set, with
+// the columns of exportColumns:
+//
+//   - workout_id to calories_burned describe the workout and repeat on
+//     every row of it; times are RFC 3339 in UTC
+//   - entry_id to avg_power describe the entry and repeat on every row of
+//     it; sets is the number of working sets
+//   - set_index to completed describe the set
+//
+// An entry without a set log takes one row, with its reps, duration_seconds
+// and weight in the set columns and set_index empty. A workout without
+// entries takes one row with only the workout columns filled in. Weights are
+// in kilograms and distances in distance_unit.
+func (eh *ExportHandler) HandleExportWorkouts(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	format := utils.ReadString(qs, "format", exportCSV)
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "format must be csv or jsonl"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	from, to, err := utils.ReadTimeRange(qs, userLocation(currentUser))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	// the deadline is pushed forward as the export goes, so that it only
+	// ends a connection that stalls
+	controller := http.NewResponseController(w)
+	extendDeadline := func() error {
+		err := controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	}
+
+	// the response starts with the first workout, so a query that fails
+	// outright can still be answered with an error
+	csvWriter := csv.NewWriter(w)
+	encoder := json.NewEncoder(w)
+	started := false
+	start := func() error {
+		started = true
+		filename := fmt.Sprintf("workouts-%s.%s", time.Now().UTC().Format(time.DateOnly), format)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		w.WriteHeader(http.StatusOK)
+		if format == exportCSV {
+			return csvWriter.Write(exportColumns)
+		}
+		return nil
+	}
+
+	err = eh.workoutStore.ExportWorkouts(r.Context(), currentUser.ID, from, to, func(workout *store.Workout) error {
+		err := extendDeadline()
+		if err != nil {
+			return err
+		}
+		if !started {
+			err = start()
+			if err != nil {
+				return err
+			}
+		}
+		if format == exportJSONL {
+			return encoder.Encode(workout)
+		}
+		for _, record := range exportRecords(workout) {
+			err := csvWriter.Write(record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = extendDeadline()
+	}
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		csvWriter.Flush()
+		err = csvWriter.Error()
+	}
+	if err == nil {
+		return
+	}
+
+	eh.logger.Printf("ERROR - ExportWorkouts(): %v\n", err)
+	if !started {
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	// the status is sent already; breaking the connection at least stops
+	// the client from taking a cut-off export for a complete one
+	panic(http.ErrAbortHandler)
+}
+
+// exportRecords lays a workout out as CSV rows of exportColumns.
+func exportRecords(workout *store.Workout) [][]string {
+	head := []string{
+		strconv.Itoa(workout.ID),
+		formatExportTime(&workout.PerformedAt),
+		formatExportTime(workout.StartedAt),
+		formatExportTime(workout.EndedAt),
+		workout.Title,
+		workout.Description,
+		strconv.Itoa(workout.DurationMinutes),
+		strconv.Itoa(workout.CaloriesBurned),
+	}
+	if len(workout.Entries) == 0 {
+		return [][]string{append(head, make([]string, len(exportColumns)-len(head))...)}
+	}
+
+	var records [][]string
+	for _, entry := range workout.Entries {
+		var groupLabel, groupType string
+		if entry.Group != nil {
+			groupLabel, groupType = entry.Group.Label, entry.Group.Type
+		}
+		entryColumns := []string{
+			strconv.Itoa(entry.ID),
+			strconv.Itoa(entry.OrderIndex),
+			formatExportInt(entry.ExerciseID),
+			entry.ExerciseName,
+			entry.Kind,
+			entry.Section,
+			groupLabel,
+			groupType,
+			entry.Notes,
+			strconv.Itoa(entry.Sets),
+			formatExportFloat(entry.Distance),
+			entry.DistanceUnit,
+			formatExportFloat(entry.ElevationGainMeters),
+			formatExportInt(entry.AvgHeartRate),
+			formatExportInt(entry.MaxHeartRate),
+			formatExportInt(entry.AvgCadence),
+			formatExportInt(entry.AvgPower),
+		}
+
+		if len(entry.SetLog) == 0 {
+			record := append(append([]string{}, head...), entryColumns...)
+			record = append(record, "", "", formatExportInt(entry.Reps), formatExportInt(entry.DurationSeconds),
+				formatExportFloat(entry.Weight), "", "", "", "", "")
+			records = append(records, record)
+			continue
+		}
+		for _, set := range entry.SetLog {
+			record := append(append([]string{}, head...), entryColumns...)
+			record = append(record,
+				strconv.Itoa(set.SetIndex),
+				set.SetType,
+				formatExportInt(set.Reps),
+				formatExportInt(set.DurationSeconds),
+				formatExportFloat(set.Weight),
+				formatExportFloat(set.RPE),
+				formatExportInt(set.RIR),
+				set.Tempo,
+				formatExportInt(set.RestSeconds),
+				strconv.FormatBool(set.Completed),
+			)
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func formatExportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatExportInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func formatExportFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
@@ -0,0 +1,308 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/ical"
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/tokens"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// feedTokenTTL is long on purpose: a subscribed calendar has no way to
+	// renew its URL, so feeds last until they are replaced or deleted.
+	feedTokenTTL = 10 * 365 * 24 * time.Hour
+	// the feed covers a year of logged workouts and the planned ones from
+	// a month back to half a year ahead
+	feedHistoryDays   = 365
+	feedPlanPastDays  = 30
+	feedPlanAheadDays = 180
+	// defaultEventMinutes is how long an event lasts when nothing says.
+	defaultEventMinutes = 60
+	feedRefresh         = time.Hour
+)
+
+type FeedHandler struct {
+	tokenStore    store.TokenStore
+	userStore     store.UserStore
+	workoutStore  store.WorkoutStore
+	planStore     store.PlanStore
+	templateStore store.TemplateStore
+	logger        *log.Logger
+}
+
+func NewFeedHandler(tokenStore store.TokenStore, userStore store.UserStore, workoutStore store.WorkoutStore, planStore store.PlanStore, templateStore store.TemplateStore, logger *log.Logger) *FeedHandler {
+	return &FeedHandler{
+		tokenStore:    tokenStore,
+		userStore:     userStore,
+		workoutStore:  workoutStore,
+		planStore:     planStore,
+		templateStore: templateStore,
+		logger:        logger,
+	}
+}
+
+// HandleCreateFeed issues the URL of the user's calendar feed. The URL holds
+// a token of its own, so it can be handed to a calendar app without sharing
+// the account. Issuing a new URL revokes the previous one.
+func (fh *FeedHandler) HandleCreateFeed(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	err := fh.tokenStore.DeleteAllTokensForUsers(currentUser.ID, tokens.ScopeFeed)
+	if err != nil {
+		fh.logger.Printf("ERROR - DeleteAllTokensForUsers(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	token, err := fh.tokenStore.CreateNewToken(currentUser.ID, feedTokenTTL, tokens.ScopeFeed)
+	if err != nil {
+		fh.logger.Printf("ERROR - CreateNewToken(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": map[string]any{
+		"url":    feedURL(r, token.Plaintext),
+		"expiry": token.Expiry,
+	}})
+}
+
+// HandleDeleteFeed revokes the user's calendar feed URL.
+func (fh *FeedHandler) HandleDeleteFeed(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	err := fh.tokenStore.DeleteAllTokensForUsers(currentUser.ID, tokens.ScopeFeed)
+	if err != nil {
+		fh.logger.Printf("ERROR - DeleteAllTokensForUsers(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func feedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/calendar/feed/" + token + ".ics"
+}
+
+// HandleGetFeed serves the iCalendar feed a feed token in the URL belongs
+// to: the workouts logged over the last year and the planned occurrences
+// from a month back to half a year ahead. Planned occurrences completed by
+// a logged workout are left out in favour of the workout, and skipped ones
+// are marked cancelled. Every event lists the entries done or planned.
+func (fh *FeedHandler) HandleGetFeed(w http.ResponseWriter, r *http.Request) {
+	user, err := fh.userStore.GetUserToken(tokens.ScopeFeed, chi.URLParam(r, "token"))
+	if err != nil {
+		fh.logger.Printf("ERROR - GetUserToken() -> GetFeed(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if user == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "calendar feed not found"})
+		return
+	}
+
+	now := time.Now()
+	events, err := fh.plannedEvents(user.ID, now)
+	if err != nil {
+		fh.logger.Printf("ERROR - plannedEvents(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	since := now.AddDate(0, 0, -feedHistoryDays)
+	err = fh.workoutStore.ExportWorkouts(r.Context(), user.ID, &since, nil, func(workout *store.Workout) error {
+		events = append(events, workoutEvent(workout))
+		return nil
+	})
+	if err != nil {
+		fh.logger.Printf("ERROR - ExportWorkouts() -> GetFeed(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	calendar := &ical.Calendar{
+		ProductID: "-//fitness_tracker_go//Training Calendar//EN",
+		Name:      "Training",
+		Timezone:  user.Timezone,
+		Refresh:   feedRefresh,
+		Events:    events,
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.WriteHeader(http.StatusOK)
+	err = calendar.Encode(w)
+	if err != nil {
+		fh.logger.Printf("ERROR - writing calendar feed: %v\n", err)
+	}
+}
+
+// plannedEvents expands the user's planned workouts over the feed's window.
+func (fh *FeedHandler) plannedEvents(userID int, now time.Time) ([]ical.Event, error) {
+	plans, err := fh.planStore.ListPlans(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	from := now.AddDate(0, 0, -feedPlanPastDays)
+	to := now.AddDate(0, 0, feedPlanAheadDays)
+	statuses, err := fh.planStore.ListOccurrenceStatuses(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	occurrences, _, err := store.BuildCalendar(plans, statuses, from, to, now)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*store.PlannedWorkout, len(plans))
+	templates := map[int]*store.WorkoutTemplate{}
+	for _, plan := range plans {
+		byID[plan.ID] = plan
+		if plan.TemplateID == nil {
+			continue
+		}
+		if _, ok := templates[*plan.TemplateID]; ok {
+			continue
+		}
+		// a template deleted since leaves the plan without entries to list
+		templates[*plan.TemplateID], err = fh.templateStore.GetTemplateByID(int64(*plan.TemplateID), userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	events := []ical.Event{}
+	for _, occurrence := range occurrences {
+		if occurrence.Status == store.OccurrenceCompleted && occurrence.WorkoutID != nil {
+			continue
+		}
+		plan := byID[occurrence.PlannedWorkoutID]
+		var template *store.WorkoutTemplate
+		if plan.TemplateID != nil {
+			template = templates[*plan.TemplateID]
+		}
+		events = append(events, occurrenceEvent(occurrence, plan, template))
+	}
+	return events, nil
+}
+
+func occurrenceEvent(occurrence store.Occurrence, plan *store.PlannedWorkout, template *store.WorkoutTemplate) ical.Event {
+	minutes := defaultEventMinutes
+	if occurrence.DurationMinutes != nil {
+		minutes = *occurrence.DurationMinutes
+	}
+
+	var lines []string
+	if plan.Notes != "" {
+		lines = append(lines, plan.Notes, "")
+	}
+	if template != nil {
+		for _, entry := range template.Entries {
+			lines = append(lines, describeTarget(entry.ExerciseName, entry.TargetSets, entry.TargetReps, entry.TargetDurationSeconds, entry.TargetWeight))
+		}
+	}
+
+	status := ical.StatusConfirmed
+	if occurrence.Status == store.OccurrenceSkipped {
+		status = ical.StatusCancelled
+	}
+	return ical.Event{
+		UID:         fmt.Sprintf("plan-%d-%s@fitness-tracker", plan.ID, occurrence.Date),
+		Stamp:       plan.UpdatedAt,
+		Start:       occurrence.StartsAt,
+		Duration:    time.Duration(minutes) * time.Minute,
+		Summary:     occurrence.Title,
+		Description: strings.TrimSpace(strings.Join(lines, "\n")),
+		Status:      status,
+	}
+}
+
+// workoutEvent places a logged workout at its start time, lasting from
+// started_at to ended_at when both were logged and duration_minutes
+// otherwise.
+func workoutEvent(workout *store.Workout) ical.Event {
+	start := workout.PerformedAt
+	if workout.StartedAt != nil {
+		start = *workout.StartedAt
+	}
+	duration := time.Duration(workout.DurationMinutes) * time.Minute
+	if workout.StartedAt != nil && workout.EndedAt != nil {
+		duration = workout.EndedAt.Sub(*workout.StartedAt)
+	}
+	if duration <= 0 {
+		duration = defaultEventMinutes * time.Minute
+	}
+
+	var lines []string
+	if workout.Description != "" {
+		lines = append(lines, workout.Description, "")
+	}
+	for _, entry := range workout.Entries {
+		lines = append(lines, describeEntry(entry))
+	}
+	return ical.Event{
+		UID:         fmt.Sprintf("workout-%d@fitness-tracker", workout.ID),
+		Stamp:       workout.UpdatedAt,
+		Start:       start,
+		Duration:    duration,
+		Summary:     workout.Title,
+		Description: strings.TrimSpace(strings.Join(lines, "\n")),
+		Status:      ical.StatusConfirmed,
+	}
+}
+
+// describeEntry sums an entry up in a line such as "Running: 5 km in 25:30".
+func describeEntry(entry store.WorkoutEntry) string {
+	if entry.Kind != store.EntryKindCardio {
+		return describeTarget(entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight)
+	}
+
+	var parts []string
+	if entry.Distance != nil {
+		parts = append(parts, strconv.FormatFloat(*entry.Distance, 'f', -1, 64)+" "+entry.DistanceUnit)
+	}
+	if entry.DurationSeconds != nil {
+		d := *entry.DurationSeconds
+		clock := fmt.Sprintf("%d:%02d", d/60, d%60)
+		if d >= 3600 {
+			clock = fmt.Sprintf("%d:%02d:%02d", d/3600, d%3600/60, d%60)
+		}
+		parts = append(parts, clock)
+	}
+	if len(parts) == 0 {
+		return entry.ExerciseName
+	}
+	return entry.ExerciseName + ": " + strings.Join(parts, " in ")
+}
+
+// describeTarget sums up sets of reps or of a duration, as in
+// "Bench Press: 3 × 5 @ 80 kg" or "Plank: 3 × 60 s".
+func describeTarget(name string, sets int, reps, seconds *int, weight *float64) string {
+	var effort string
+	switch {
+	case reps != nil:
+		effort = strconv.Itoa(*reps)
+	case seconds != nil:
+		effort = strconv.Itoa(*seconds) + " s"
+	default:
+		return name
+	}
+
+	line := fmt.Sprintf("%s: %d × %s", name, max(sets, 1), effort)
+	if weight != nil && *weight > 0 {
+		line += " @ " + strconv.FormatFloat(*weight, 'f', -1, 64) + " kg"
+	}
+	return line
+}
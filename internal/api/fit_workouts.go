@@ -0,0 +1,95 @@
+package api
+
+import (
+	"math"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/activity"
+	"github.com/fsrn12/fitness_tracker_go/internal/fit"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+)
+
+// FIT files are mapped to and from workouts here, so that the store knows
+// nothing of the format.
+
+// fitWorkout describes a template as a workout a watch can load, with one
+// step per entry at its target weight.
+func fitWorkout(template *store.WorkoutTemplate, name string) fit.Workout {
+	workout := fit.Workout{Name: name}
+	for _, entry := range template.Entries {
+		workout.Steps = append(workout.Steps, fit.Step{
+			Name:            entry.ExerciseName,
+			Notes:           entry.Notes,
+			Sets:            entry.TargetSets,
+			Reps:            entry.TargetReps,
+			DurationSeconds: entry.TargetDurationSeconds,
+			WeightKg:        entry.TargetWeight,
+		})
+	}
+	return workout
+}
+
+// workoutFromFITSets turns the sets of a FIT strength activity into a workout.
+// Consecutive sets of the same exercise become one entry with a set log;
+// the rest periods the watch records between sets become the rest of the
+// set before them.
+func workoutFromFITSets(userID int, summary activity.Summary, sets []fit.Set) *store.Workout {
+	title := summary.Name
+	if title == "" {
+		title = "Strength Training"
+	}
+
+	var entries []store.WorkoutEntry
+	category := -1
+	for _, s := range sets {
+		if !s.Active {
+			if len(entries) > 0 && s.DurationSeconds != nil {
+				log := entries[len(entries)-1].SetLog
+				rest := int(math.Round(*s.DurationSeconds))
+				log[len(log)-1].RestSeconds = &rest
+			}
+			continue
+		}
+
+		set := store.WorkoutSet{SetType: store.SetTypeWorking, Weight: s.WeightKg, Completed: true}
+		switch {
+		case s.Reps != nil:
+			set.Reps = s.Reps
+		case s.DurationSeconds != nil:
+			seconds := int(math.Round(*s.DurationSeconds))
+			set.DurationSeconds = &seconds
+		default:
+			continue
+		}
+
+		// a new exercise, or the same one switching between reps and time
+		if len(entries) == 0 || s.Category != category ||
+			(entries[len(entries)-1].SetLog[0].Reps == nil) != (set.Reps == nil) {
+			entries = append(entries, store.WorkoutEntry{
+				ExerciseName: fit.ExerciseName(s.Category),
+				OrderIndex:   len(entries) + 1,
+				Section:      store.SectionMain,
+			})
+			category = s.Category
+		}
+		entry := &entries[len(entries)-1]
+		entry.SetLog = append(entry.SetLog, set)
+	}
+
+	startedAt, endedAt := summary.StartedAt, summary.EndedAt
+	if startedAt.IsZero() && len(sets) > 0 {
+		startedAt = sets[0].StartedAt
+	}
+	workout := &store.Workout{
+		UserID:      userID,
+		Title:       title,
+		PerformedAt: startedAt,
+		Entries:     entries,
+	}
+	if summary.Calories != nil {
+		workout.CaloriesBurned = *summary.Calories
+	}
+	if endedAt.After(startedAt) {
+		workout.StartedAt, workout.EndedAt = &startedAt, &endedAt
+	}
+	return workout
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/activity"
+	"github.com/fsrn12/fitness_tracker_go/internal/fit"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutFromFITSets(t *testing.T) {
+	start := time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC)
+	summary := activity.Summary{Format: activity.FormatFIT, StartedAt: start, EndedAt: start.Add(20 * time.Minute)}
+	squat, plank := 28, 19
+	reps := func(n int) *int { return &n }
+	seconds := func(s float64) *float64 { return &s }
+	kg := func(w float64) *float64 { return &w }
+	sets := []fit.Set{
+		{Active: true, Reps: reps(5), WeightKg: kg(100), Category: squat},
+		{Active: false, DurationSeconds: seconds(119.6)},
+		{Active: true, Reps: reps(5), WeightKg: kg(105), Category: squat},
+		{Active: false, DurationSeconds: seconds(90)},
+		{Active: true, DurationSeconds: seconds(45.2), Category: plank},
+		{Active: true, Category: plank},
+		{Active: true, Reps: reps(12), Category: -1},
+	}
+
+	workout := workoutFromFITSets(7, summary, sets)
+	require.NoError(t, workout.ResolveTimes())
+	assert.Equal(t, "Strength Training", workout.Title)
+	assert.Equal(t, 20, workout.DurationMinutes)
+
+	require.Len(t, workout.Entries, 3)
+	for i := range workout.Entries {
+		require.NoError(t, workout.Entries[i].SummarizeSets())
+		require.NoError(t, workout.Entries[i].ValidateKind())
+	}
+
+	squats := workout.Entries[0]
+	assert.Equal(t, "Squat", squats.ExerciseName)
+	require.Len(t, squats.SetLog, 2)
+	assert.Equal(t, 120, *squats.SetLog[0].RestSeconds)
+	assert.Equal(t, 90, *squats.SetLog[1].RestSeconds)
+	assert.Equal(t, 105.0, *squats.Weight)
+
+	planks := workout.Entries[1]
+	assert.Equal(t, store.EntryKindTimed, planks.Kind)
+	require.Len(t, planks.SetLog, 1, "a set with neither reps nor a duration is dropped")
+	assert.Equal(t, 45, *planks.DurationSeconds)
+
+	assert.Equal(t, "Strength Training", workout.Entries[2].ExerciseName)
+	assert.Equal(t, 3, workout.Entries[2].OrderIndex)
+}
@@ -0,0 +1,348 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/csvimport"
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+// maxImportFileSize bounds uploads; ten years of daily training in any of
+// the supported apps exports to a few megabytes.
+const maxImportFileSize = 50 << 20
+
+type ImportHandler struct {
+	importStore     store.ImportStore
+	exerciseStore   store.ExerciseStore
+	recordStore     store.RecordStore
+	bodyWeightStore store.BodyWeightStore
+	logger          *log.Logger
+}
+
+func NewImportHandler(importStore store.ImportStore, exerciseStore store.ExerciseStore, recordStore store.RecordStore, bodyWeightStore store.BodyWeightStore, logger *log.Logger) *ImportHandler {
+	return &ImportHandler{
+		importStore:     importStore,
+		exerciseStore:   exerciseStore,
+		recordStore:     recordStore,
+		bodyWeightStore: bodyWeightStore,
+		logger:          logger,
+	}
+}
+
+// importPreviewWorkout is a workout a dry run found, as it would be saved.
+type importPreviewWorkout struct {
+	Row       int            `json:"row"`
+	Duplicate bool           `json:"duplicate"`
+	Workout   *store.Workout `json:"workout"`
+}
+
+// HandleImport imports the CSV export of Strong, Hevy or FitNotes, sent as
+// multipart/form-data in the file field. The other fields are optional:
+//
+//   - format: strong, hevy or fitnotes; detected from the header otherwise
+//   - weight_unit and distance_unit: the units of Strong's weight and
+//     distance columns, kg and km by default
+//   - tz: the time zone the export's times are in, the user's by default
+//   - exercise_names: a JSON object renaming exercises before they are
+//     matched to the catalog, as in {"Bench Press (Barbell)": "Bench Press"}
+//   - dry_run: true to get a preview of what would be imported
+//
+// A dry run answers right away with the workouts found. Otherwise the
+// import runs in the background as a job, answered with 202 and tracked at
+// /imports/{id}. Workouts imported before, from any of the apps, are
+// skipped as duplicates.
+func (ih *ImportHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	err := r.ParseMultipartForm(maxImportFileSize)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "import files are limited to 50 MB"})
+			return
+		}
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "expected a multipart/form-data upload"})
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "the file field is required"})
+		return
+	}
+	defer file.Close()
+
+	currentUser := middleware.GetUser(r)
+	opts, dryRun, err := readImportOptions(r, currentUser)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	result, err := csvimport.Parse(file, r.FormValue("format"), opts)
+	if err != nil {
+		var formatErr *csvimport.FormatError
+		if errors.As(err, &formatErr) {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": formatErr.Error()})
+			return
+		}
+		ih.logger.Printf("ERROR - csvimport.Parse(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	filename := filepath.Base(header.Filename)
+	if filename == "." || filename == "/" || len(filename) > 255 {
+		filename = result.Format + ".csv"
+	}
+	job := &store.ImportJob{
+		UserID:   currentUser.ID,
+		Source:   result.Format,
+		Filename: filename,
+		Status:   store.ImportRunning,
+		Rows:     result.Rows,
+		Workouts: len(result.Workouts),
+		Errors:   result.Errors,
+	}
+
+	ready, duplicates, err := ih.prepare(job, currentUser, result.Workouts)
+	if err != nil {
+		ih.logger.Printf("ERROR - preparing import: %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if dryRun {
+		job.Status = store.ImportPreview
+		job.Imported = len(ready)
+		previews := []importPreviewWorkout{}
+		for _, workout := range ready {
+			previews = append(previews, importPreviewWorkout{Row: workout.Row, Workout: workout.Workout})
+		}
+		for workout := range duplicates {
+			previews = append(previews, importPreviewWorkout{Row: workout.Row, Duplicate: true, Workout: workout.Workout})
+		}
+		slices.SortFunc(previews, func(a, b importPreviewWorkout) int {
+			return a.Row - b.Row
+		})
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": job, "workouts": previews})
+		return
+	}
+
+	err = ih.importStore.CreateImportJob(job)
+	if err != nil {
+		ih.logger.Printf("ERROR - CreateImportJob(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to start import"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"data": job})
+
+	running := *job
+	go ih.runImport(&running, ready)
+}
+
+func readImportOptions(r *http.Request, user *store.User) (csvimport.Options, bool, error) {
+	opts := csvimport.Options{
+		WeightUnit:   r.FormValue("weight_unit"),
+		DistanceUnit: r.FormValue("distance_unit"),
+	}
+	if opts.WeightUnit != "" && opts.WeightUnit != csvimport.UnitKilograms && opts.WeightUnit != csvimport.UnitPounds {
+		return opts, false, errors.New("weight_unit must be kg or lb")
+	}
+	if opts.DistanceUnit != "" && !slices.Contains(store.DistanceUnits(), opts.DistanceUnit) {
+		return opts, false, fmt.Errorf("distance_unit must be one of %v", store.DistanceUnits())
+	}
+
+	loc, err := readLocation(r.Form, user)
+	if err != nil {
+		return opts, false, err
+	}
+	opts.Location = loc
+
+	if names := r.FormValue("exercise_names"); names != "" {
+		err = json.Unmarshal([]byte(names), &opts.ExerciseNames)
+		if err != nil {
+			return opts, false, errors.New("exercise_names must be a JSON object of names")
+		}
+	}
+
+	dryRun := false
+	if s := r.FormValue("dry_run"); s != "" {
+		dryRun, err = strconv.ParseBool(s)
+		if err != nil {
+			return opts, false, errors.New("dry_run must be true or false")
+		}
+	}
+	return opts, dryRun, nil
+}
+
+// prepare links the workouts to the exercise catalog, validates them and
+// estimates their calories, counting the failures on the job. It returns the
+// workouts ready to be imported and, separately, those already imported
+// before.
+func (ih *ImportHandler) prepare(job *store.ImportJob, user *store.User, workouts []*csvimport.Workout) ([]*csvimport.Workout, map[*csvimport.Workout]bool, error) {
+	// one catalog lookup for the whole file rather than one per workout
+	var entries []store.WorkoutEntry
+	for _, workout := range workouts {
+		entries = append(entries, workout.Workout.Entries...)
+	}
+	err := resolveExercises(ih.exerciseStore, job.UserID, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// likewise one query for the body weights of the whole history
+	weights, err := ih.bodyWeightStore.ListBodyWeights(user.ID, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var valid []*csvimport.Workout
+	var fingerprints []string
+	for _, workout := range workouts {
+		workout.Workout.UserID = job.UserID
+		n := len(workout.Workout.Entries)
+		copy(workout.Workout.Entries, entries[:n])
+		entries = entries[n:]
+
+		err = workout.Workout.ResolveTimes()
+		if err == nil {
+			err = validateEntries(workout.Workout.Entries)
+		}
+		if err != nil {
+			job.Failed++
+			job.Errors = append(job.Errors, store.ImportRowError{
+				Row:     workout.Row,
+				Message: fmt.Sprintf("workout %q: %v", workout.Workout.Title, err),
+			})
+			continue
+		}
+		workout.Workout.FillCalories(user, store.NearestBodyWeight(weights, workout.Workout.PerformedAt))
+		valid = append(valid, workout)
+		fingerprints = append(fingerprints, workout.Fingerprint)
+	}
+	slices.SortStableFunc(job.Errors, func(a, b store.ImportRowError) int {
+		return a.Row - b.Row
+	})
+
+	imported, err := ih.importStore.ImportedFingerprints(job.UserID, fingerprints)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ready := []*csvimport.Workout{}
+	duplicates := map[*csvimport.Workout]bool{}
+	for _, workout := range valid {
+		if imported[workout.Fingerprint] {
+			duplicates[workout] = true
+			job.Duplicates++
+			continue
+		}
+		ready = append(ready, workout)
+	}
+	return ready, duplicates, nil
+}
+
+// runImport saves the workouts of a job one by one, so a failure loses a
+// single workout rather than the whole file, then rebuilds the personal
+// records of every exercise imported. It runs outside of any request, so a
+// panic is recovered here and fails the job instead of the server.
+func (ih *ImportHandler) runImport(job *store.ImportJob, workouts []*csvimport.Workout) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		ih.logger.Printf("ERROR - runImport(): panic: %v\n%s", recovered, debug.Stack())
+		job.Status = store.ImportFailed
+		job.Errors = append(job.Errors, store.ImportRowError{Message: "the import stopped unexpectedly"})
+		err := ih.importStore.FinishImportJob(job)
+		if err != nil {
+			ih.logger.Printf("ERROR - FinishImportJob(): %v\n", err)
+		}
+	}()
+
+	var entries []store.WorkoutEntry
+	for _, workout := range workouts {
+		err := ih.importStore.ImportWorkout(job.ID, workout.Fingerprint, workout.Workout)
+		switch {
+		case errors.Is(err, store.ErrDuplicateImport):
+			job.Duplicates++
+		case err != nil:
+			ih.logger.Printf("ERROR - ImportWorkout(): %v\n", err)
+			job.Failed++
+			job.Errors = append(job.Errors, store.ImportRowError{
+				Row:     workout.Row,
+				Message: fmt.Sprintf("workout %q could not be saved", workout.Workout.Title),
+			})
+		default:
+			job.Imported++
+			entries = append(entries, workout.Workout.Entries...)
+		}
+	}
+	slices.SortStableFunc(job.Errors, func(a, b store.ImportRowError) int {
+		return a.Row - b.Row
+	})
+
+	if len(entries) > 0 {
+		err := ih.recordStore.RecomputeExercises(job.UserID, store.EntryRefs(entries))
+		if err != nil {
+			ih.logger.Printf("ERROR - RecomputeExercises(): %v\n", err)
+		}
+	}
+
+	job.Status = store.ImportCompleted
+	if job.Imported == 0 && job.Failed > 0 {
+		job.Status = store.ImportFailed
+	}
+	err := ih.importStore.FinishImportJob(job)
+	if err != nil {
+		ih.logger.Printf("ERROR - FinishImportJob(): %v\n", err)
+	}
+}
+
+func (ih *ImportHandler) HandleListImports(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	jobs, err := ih.importStore.ListImportJobs(currentUser.ID)
+	if err != nil {
+		ih.logger.Printf("ERROR - ListImportJobs(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": jobs})
+}
+
+func (ih *ImportHandler) HandleGetImportByID(w http.ResponseWriter, r *http.Request) {
+	jobID, err := utils.GetParamID(r)
+	if err != nil {
+		ih.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid import id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	job, err := ih.importStore.GetImportJob(jobID, currentUser.ID)
+	if err != nil {
+		ih.logger.Printf("ERROR - GetImportJob(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if job == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "import not found"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": job})
+}
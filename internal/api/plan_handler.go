@@ -0,0 +1,436 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxCalendarDays bounds how far one calendar request expands recurrences.
+const maxCalendarDays = 366
+
+type planRequest struct {
+	TemplateID      *int       `json:"template_id"`
+	Title           *string    `json:"title"`
+	Notes           *string    `json:"notes"`
+	StartsAt        *time.Time `json:"starts_at"`
+	Timezone        *string    `json:"timezone"`
+	DurationMinutes *int       `json:"duration_minutes"`
+	RRule           *string    `json:"rrule"`
+	Exceptions      *[]string  `json:"exceptions"`
+}
+
+type occurrenceRequest struct {
+	Status    string `json:"status"`
+	WorkoutID *int   `json:"workout_id"`
+}
+
+type PlanHandler struct {
+	planStore     store.PlanStore
+	workoutStore  store.WorkoutStore
+	templateStore store.TemplateStore
+	logger        *log.Logger
+}
+
+func NewPlanHandler(planStore store.PlanStore, workoutStore store.WorkoutStore, templateStore store.TemplateStore, logger *log.Logger) *PlanHandler {
+	return &PlanHandler{
+		planStore:     planStore,
+		workoutStore:  workoutStore,
+		templateStore: templateStore,
+		logger:        logger,
+	}
+}
+
+func (ph *PlanHandler) applyPlanRequest(plan *store.PlannedWorkout, req *planRequest) {
+	if req.TemplateID != nil {
+		plan.TemplateID = req.TemplateID
+	}
+	if req.Title != nil {
+		plan.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Notes != nil {
+		plan.Notes = *req.Notes
+	}
+	if req.StartsAt != nil {
+		plan.StartsAt = *req.StartsAt
+	}
+	if req.Timezone != nil {
+		plan.Timezone = *req.Timezone
+	}
+	if req.DurationMinutes != nil {
+		plan.DurationMinutes = req.DurationMinutes
+	}
+	if req.RRule != nil {
+		plan.RRule = strings.TrimSpace(*req.RRule)
+	}
+	if req.Exceptions != nil {
+		plan.Exceptions = *req.Exceptions
+	}
+}
+
+// validatePlan checks the plan and that its template, if any, belongs to the
+// user. A template also provides the title when none was given.
+func (ph *PlanHandler) validatePlan(plan *store.PlannedWorkout) (int, error) {
+	if plan.TemplateID != nil {
+		template, err := ph.templateStore.GetTemplateByID(int64(*plan.TemplateID), plan.UserID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if template == nil {
+			return http.StatusBadRequest, fmt.Errorf("template %d does not exist", *plan.TemplateID)
+		}
+		if plan.Title == "" {
+			plan.Title = template.Name
+		}
+	}
+
+	if plan.Title == "" || len(plan.Title) > 255 {
+		return http.StatusBadRequest, errors.New("title is required and must be at most 255 characters long")
+	}
+
+	err := plan.Validate()
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+func (ph *PlanHandler) writeValidationError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusInternalServerError {
+		ph.logger.Printf("ERROR - validatePlan(): %v\n", err)
+		utils.WriteJSON(w, status, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	utils.WriteJSON(w, status, utils.Envelope{"error": err.Error()})
+}
+
+func (ph *PlanHandler) HandleListPlans(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	plans, err := ph.planStore.ListPlans(currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - ListPlans(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": plans})
+}
+
+func (ph *PlanHandler) HandleCreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req planRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.Printf("ERROR - CreatePlanRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	plan := &store.PlannedWorkout{UserID: currentUser.ID, Timezone: currentUser.Timezone}
+	ph.applyPlanRequest(plan, &req)
+
+	status, err := ph.validatePlan(plan)
+	if err != nil {
+		ph.writeValidationError(w, status, err)
+		return
+	}
+
+	createdPlan, err := ph.planStore.CreatePlan(plan)
+	if err != nil {
+		ph.logger.Printf("ERROR - CreatePlan(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create planned workout"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdPlan})
+}
+
+// getPlan loads the planned workout named by the URL for the current user
+// and writes the error response itself when there is none to return.
+func (ph *PlanHandler) getPlan(w http.ResponseWriter, r *http.Request) *store.PlannedWorkout {
+	planID, err := utils.GetParamID(r)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid planned workout id"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(r)
+	plan, err := ph.planStore.GetPlanByID(planID, currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetPlanByID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	if plan == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "planned workout not found"})
+		return nil
+	}
+	return plan
+}
+
+func (ph *PlanHandler) HandleGetPlanByID(w http.ResponseWriter, r *http.Request) {
+	plan := ph.getPlan(w, r)
+	if plan == nil {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": plan})
+}
+
+func (ph *PlanHandler) HandleUpdatePlanByID(w http.ResponseWriter, r *http.Request) {
+	plan := ph.getPlan(w, r)
+	if plan == nil {
+		return
+	}
+
+	var req planRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.Printf("ERROR - updatePlanRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	ph.applyPlanRequest(plan, &req)
+	status, err := ph.validatePlan(plan)
+	if err != nil {
+		ph.writeValidationError(w, status, err)
+		return
+	}
+
+	err = ph.planStore.UpdatePlan(plan)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "planned workout not found"})
+		return
+	}
+	if err != nil {
+		ph.logger.Printf("ERROR - UpdatePlan(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update planned workout"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": plan})
+}
+
+func (ph *PlanHandler) HandleDeletePlanByID(w http.ResponseWriter, r *http.Request) {
+	planID, err := utils.GetParamID(r)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetParamID: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid planned workout id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = ph.planStore.DeletePlan(planID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "planned workout not found"})
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("ERROR - DeletePlan(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readOccurrenceDate parses the {date} URL parameter and checks that the
+// plan actually has an occurrence on that day.
+func (ph *PlanHandler) readOccurrenceDate(w http.ResponseWriter, r *http.Request, plan *store.PlannedWorkout) (string, bool) {
+	date, err := time.Parse(time.DateOnly, chi.URLParam(r, "date"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "date must be a YYYY-MM-DD date"})
+		return "", false
+	}
+
+	occurs, err := plan.Occurs(date)
+	if err != nil {
+		ph.logger.Printf("ERROR - Occurs(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return "", false
+	}
+	if !occurs {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "the planned workout does not occur on this date"})
+		return "", false
+	}
+	return date.Format(time.DateOnly), true
+}
+
+// HandleSetOccurrence records an occurrence as completed by a logged workout
+// or as skipped. Sending a workout_id alone implies completed.
+func (ph *PlanHandler) HandleSetOccurrence(w http.ResponseWriter, r *http.Request) {
+	plan := ph.getPlan(w, r)
+	if plan == nil {
+		return
+	}
+
+	date, ok := ph.readOccurrenceDate(w, r, plan)
+	if !ok {
+		return
+	}
+
+	var req occurrenceRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.Printf("ERROR - occurrenceRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if req.Status == "" && req.WorkoutID != nil {
+		req.Status = store.OccurrenceCompleted
+	}
+
+	switch req.Status {
+	case store.OccurrenceCompleted:
+		if req.WorkoutID == nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "workout_id is required to complete an occurrence"})
+			return
+		}
+		workout, err := ph.workoutStore.GetWorkoutByID(int64(*req.WorkoutID), plan.UserID)
+		if err != nil {
+			ph.logger.Printf("ERROR - GetWorkoutByID() -> SetOccurrence(): %v\n", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if workout == nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("workout %d does not exist", *req.WorkoutID)})
+			return
+		}
+	case store.OccurrenceSkipped:
+		if req.WorkoutID != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "a skipped occurrence cannot have a workout_id"})
+			return
+		}
+	default:
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "status must be completed or skipped"})
+		return
+	}
+
+	status := &store.OccurrenceStatus{
+		PlannedWorkoutID: plan.ID,
+		Date:             date,
+		Status:           req.Status,
+		WorkoutID:        req.WorkoutID,
+	}
+	err = ph.planStore.SetOccurrenceStatus(status)
+	if err != nil {
+		ph.logger.Printf("ERROR - SetOccurrenceStatus(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": status})
+}
+
+// HandleClearOccurrence forgets what was recorded for an occurrence, making
+// it planned or missed again.
+func (ph *PlanHandler) HandleClearOccurrence(w http.ResponseWriter, r *http.Request) {
+	plan := ph.getPlan(w, r)
+	if plan == nil {
+		return
+	}
+
+	date, ok := ph.readOccurrenceDate(w, r, plan)
+	if !ok {
+		return
+	}
+
+	err := ph.planStore.ClearOccurrenceStatus(plan.ID, date)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "nothing is recorded for this occurrence"})
+		return
+	}
+	if err != nil {
+		ph.logger.Printf("ERROR - ClearOccurrenceStatus(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetCalendar expands every planned workout over the from/to range and
+// reports adherence. Both bounds are required; only their dates are used,
+// read in each plan's own timezone.
+func (ph *PlanHandler) HandleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	from, to, err := utils.ReadTimeRange(r.URL.Query(), userLocation(currentUser))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if from == nil || to == nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "from and to are required"})
+		return
+	}
+	if to.Sub(*from) > maxCalendarDays*24*time.Hour {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("the calendar can cover at most %d days", maxCalendarDays)})
+		return
+	}
+
+	plans, err := ph.planStore.ListPlans(currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - ListPlans() -> GetCalendar(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	statuses, err := ph.planStore.ListOccurrenceStatuses(currentUser.ID, *from, *to)
+	if err != nil {
+		ph.logger.Printf("ERROR - ListOccurrenceStatuses(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	occurrences, adherence, err := store.BuildCalendar(plans, statuses, *from, *to, time.Now())
+	if err != nil {
+		ph.logger.Printf("ERROR - BuildCalendar(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": occurrences, "adherence": adherence})
+}
+
+// HandleExportPlan downloads the template a planned workout follows as a
+// FIT workout file, named after the plan.
+func (ph *PlanHandler) HandleExportPlan(w http.ResponseWriter, r *http.Request) {
+	plan := ph.getPlan(w, r)
+	if plan == nil {
+		return
+	}
+
+	if plan.TemplateID == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "the planned workout has no template to export"})
+		return
+	}
+
+	template, err := ph.templateStore.GetTemplateByID(int64(*plan.TemplateID), plan.UserID)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetTemplateByID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if template == nil {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "the planned workout's template no longer exists"})
+		return
+	}
+
+	writeFITWorkout(w, ph.logger, fitWorkout(template, plan.Title))
+}
@@ -0,0 +1,478 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+const (
+	sessionNotStarted = "not_started"
+	sessionFinished   = "finished"
+	sessionRest       = "rest"
+	sessionTraining   = "training"
+)
+
+type programRequest struct {
+	Name               *string             `json:"name"`
+	Description        *string             `json:"description"`
+	Public             *bool               `json:"is_public"`
+	Weeks              *int                `json:"weeks"`
+	DeloadWeeks        *[]int              `json:"deload_weeks"`
+	DeloadLoadFactor   *float64            `json:"deload_load_factor"`
+	DeloadVolumeFactor *float64            `json:"deload_volume_factor"`
+	RoundTo            *float64            `json:"round_to"`
+	Days               *[]store.ProgramDay `json:"days"`
+}
+
+type enrollRequest struct {
+	StartDate string `json:"start_date"`
+}
+
+type ProgramHandler struct {
+	programStore  store.ProgramStore
+	exerciseStore store.ExerciseStore
+	logger        *log.Logger
+}
+
+func NewProgramHandler(programStore store.ProgramStore, exerciseStore store.ExerciseStore, logger *log.Logger) *ProgramHandler {
+	return &ProgramHandler{
+		programStore:  programStore,
+		exerciseStore: exerciseStore,
+		logger:        logger,
+	}
+}
+
+func (ph *ProgramHandler) applyProgramRequest(program *store.Program, req *programRequest) {
+	if req.Name != nil {
+		program.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		program.Description = *req.Description
+	}
+	if req.Public != nil {
+		program.Public = *req.Public
+	}
+	if req.Weeks != nil {
+		program.Weeks = *req.Weeks
+	}
+	if req.DeloadWeeks != nil {
+		program.DeloadWeeks = *req.DeloadWeeks
+	}
+	if req.DeloadLoadFactor != nil {
+		program.DeloadLoadFactor = *req.DeloadLoadFactor
+	}
+	if req.DeloadVolumeFactor != nil {
+		program.DeloadVolumeFactor = *req.DeloadVolumeFactor
+	}
+	if req.RoundTo != nil {
+		program.RoundTo = *req.RoundTo
+	}
+	if req.Days != nil {
+		program.Days = *req.Days
+	}
+}
+
+func (ph *ProgramHandler) validateProgram(program *store.Program) error {
+	if program.Name == "" || len(program.Name) > 255 {
+		return errors.New("name is required and must be at most 255 characters long")
+	}
+	for i := range program.Days {
+		program.Days[i].Title = strings.TrimSpace(program.Days[i].Title)
+	}
+	return program.Validate()
+}
+
+// resolveProgramExercises links every prescribed exercise to the catalog
+// the same way workout entries are linked.
+func (ph *ProgramHandler) resolveProgramExercises(userID int, program *store.Program) error {
+	var exercises []*store.ProgramExercise
+	for i := range program.Days {
+		for j := range program.Days[i].Exercises {
+			exercises = append(exercises, &program.Days[i].Exercises[j])
+		}
+	}
+
+	links := make([]store.WorkoutEntry, len(exercises))
+	for i, exercise := range exercises {
+		links[i] = store.WorkoutEntry{ExerciseID: exercise.ExerciseID, ExerciseName: exercise.ExerciseName}
+	}
+
+	err := resolveExercises(ph.exerciseStore, userID, links)
+	if err != nil {
+		return err
+	}
+
+	for i, link := range links {
+		exercises[i].ExerciseID = link.ExerciseID
+		exercises[i].ExerciseName = link.ExerciseName
+	}
+	return nil
+}
+
+func (ph *ProgramHandler) HandleListPrograms(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	programs, err := ph.programStore.ListPrograms(currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - ListPrograms(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": programs})
+}
+
+func (ph *ProgramHandler) HandleCreateProgram(w http.ResponseWriter, r *http.Request) {
+	var req programRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.Printf("ERROR - CreateProgramRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	program := &store.Program{
+		UserID:             currentUser.ID,
+		DeloadLoadFactor:   0.6,
+		DeloadVolumeFactor: 0.5,
+		RoundTo:            2.5,
+	}
+	ph.applyProgramRequest(program, &req)
+
+	err = ph.validateProgram(program)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ph.resolveProgramExercises(currentUser.ID, program)
+	if err != nil {
+		writeResolveError(w, ph.logger, err)
+		return
+	}
+
+	createdProgram, err := ph.programStore.CreateProgram(program)
+	if err != nil {
+		ph.logger.Printf("ERROR - CreateProgram(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create program"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdProgram})
+}
+
+// getProgram loads the program named by the URL if the current user may see
+// it and writes the error response itself when there is none to return.
+func (ph *ProgramHandler) getProgram(w http.ResponseWriter, r *http.Request) *store.Program {
+	programID, err := utils.GetParamID(r)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(r)
+	program, err := ph.programStore.GetProgramByID(programID, currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetProgramByID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	if program == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "program not found"})
+		return nil
+	}
+	return program
+}
+
+func (ph *ProgramHandler) HandleGetProgramByID(w http.ResponseWriter, r *http.Request) {
+	program := ph.getProgram(w, r)
+	if program == nil {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": program})
+}
+
+func (ph *ProgramHandler) HandleUpdateProgramByID(w http.ResponseWriter, r *http.Request) {
+	program := ph.getProgram(w, r)
+	if program == nil {
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if program.UserID != currentUser.ID {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "only the author of a program can modify it"})
+		return
+	}
+
+	var req programRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.Printf("ERROR - updateProgramRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	ph.applyProgramRequest(program, &req)
+	err = ph.validateProgram(program)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ph.resolveProgramExercises(currentUser.ID, program)
+	if err != nil {
+		writeResolveError(w, ph.logger, err)
+		return
+	}
+
+	err = ph.programStore.UpdateProgram(program)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "program not found"})
+		return
+	}
+	if err != nil {
+		ph.logger.Printf("ERROR - UpdateProgram(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update program"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": program})
+}
+
+func (ph *ProgramHandler) HandleDeleteProgramByID(w http.ResponseWriter, r *http.Request) {
+	programID, err := utils.GetParamID(r)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetParamID: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = ph.programStore.DeleteProgram(programID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no program of yours found"})
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("ERROR - DeleteProgram(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userLocation is the current user's timezone, UTC if it cannot be loaded.
+func userLocation(user *store.User) *time.Location {
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// HandleEnroll starts the current user on a program, today unless the body
+// names another start_date.
+func (ph *ProgramHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
+	program := ph.getProgram(w, r)
+	if program == nil {
+		return
+	}
+
+	var req enrollRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		ph.logger.Printf("ERROR - enrollRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if req.StartDate == "" {
+		req.StartDate = time.Now().In(userLocation(currentUser)).Format(time.DateOnly)
+	}
+	if _, err := time.Parse(time.DateOnly, req.StartDate); err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "start_date must be a YYYY-MM-DD date"})
+		return
+	}
+
+	enrollment, err := ph.programStore.CreateEnrollment(&store.Enrollment{
+		UserID:      currentUser.ID,
+		ProgramID:   program.ID,
+		ProgramName: program.Name,
+		StartDate:   req.StartDate,
+	})
+	if err != nil {
+		ph.logger.Printf("ERROR - CreateEnrollment(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to enroll"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": enrollment})
+}
+
+func (ph *ProgramHandler) HandleListEnrollments(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	enrollments, err := ph.programStore.ListEnrollments(currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - ListEnrollments(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": enrollments})
+}
+
+func (ph *ProgramHandler) HandleDeleteEnrollmentByID(w http.ResponseWriter, r *http.Request) {
+	enrollmentID, err := utils.GetParamID(r)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetParamID: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid enrollment id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = ph.programStore.DeleteEnrollment(enrollmentID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "enrollment not found"})
+		return
+	}
+
+	if err != nil {
+		ph.logger.Printf("ERROR - DeleteEnrollment(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetTodaysSession generates the workout an enrollment prescribes for
+// today, or for the date query parameter, in the user's timezone. The
+// workout is not saved; clients log it through POST /workouts once done.
+func (ph *ProgramHandler) HandleGetTodaysSession(w http.ResponseWriter, r *http.Request) {
+	enrollmentID, err := utils.GetParamID(r)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid enrollment id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	enrollment, err := ph.programStore.GetEnrollmentByID(enrollmentID, currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetEnrollmentByID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if enrollment == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "enrollment not found"})
+		return
+	}
+
+	program, err := ph.programStore.GetProgramByID(int64(enrollment.ProgramID), currentUser.ID)
+	if err != nil {
+		ph.logger.Printf("ERROR - GetProgramByID() -> GetTodaysSession(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if program == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "the program is no longer available"})
+		return
+	}
+
+	loc := userLocation(currentUser)
+	now := time.Now().In(loc)
+	date := now
+	if s := r.URL.Query().Get("date"); s != "" {
+		date, err = time.ParseInLocation(time.DateOnly, s, loc)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "date must be a YYYY-MM-DD date"})
+			return
+		}
+	}
+	start, err := time.ParseInLocation(time.DateOnly, enrollment.StartDate, loc)
+	if err != nil {
+		ph.logger.Printf("ERROR - parsing enrollment start date: %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	week, day := store.Position(start, date)
+	session := utils.Envelope{
+		"enrollment_id": enrollment.ID,
+		"program_id":    program.ID,
+		"date":          date.Format(time.DateOnly),
+		"week":          week,
+		"day":           day,
+		"deload":        program.IsDeload(week),
+		"workout":       nil,
+	}
+
+	programDay := program.DayFor(week, day)
+	switch {
+	case week < 1:
+		session["status"] = sessionNotStarted
+	case week > program.Weeks:
+		session["status"] = sessionFinished
+	case programDay == nil:
+		session["status"] = sessionRest
+	default:
+		session["status"] = sessionTraining
+	}
+	if session["status"] != sessionTraining {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": session})
+		return
+	}
+
+	performedAt := date
+	if date.Format(time.DateOnly) == now.Format(time.DateOnly) {
+		performedAt = now
+	}
+	description := fmt.Sprintf("Week %d, day %d of %s", week, day, program.Name)
+	if program.IsDeload(week) {
+		description += " (deload)"
+	}
+	workout := &store.Workout{
+		UserID:      currentUser.ID,
+		Title:       programDay.Title,
+		Description: description,
+		PerformedAt: performedAt,
+		Entries:     []store.WorkoutEntry{},
+	}
+
+	// only what was logged before the session's day counts, so a past or
+	// future session is prescribed the same way whenever it is asked for
+	sessionDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	for i := range programDay.Exercises {
+		exercise := &programDay.Exercises[i]
+		ref := store.ExerciseRef{ID: exercise.ExerciseID, Name: exercise.ExerciseName}
+		history, err := ph.programStore.GetExerciseHistory(currentUser.ID, ref, start, sessionDay)
+		if err != nil {
+			ph.logger.Printf("ERROR - GetExerciseHistory(): %v\n", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		workout.Entries = append(workout.Entries, program.Prescribe(exercise, week, start, history))
+	}
+
+	session["workout"] = workout
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": session})
+}
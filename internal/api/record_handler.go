@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+type RecordHandler struct {
+	recordStore store.RecordStore
+	logger      *log.Logger
+}
+
+func NewRecordHandler(recordStore store.RecordStore, logger *log.Logger) *RecordHandler {
+	return &RecordHandler{
+		recordStore: recordStore,
+		logger:      logger,
+	}
+}
+
+func (rh *RecordHandler) HandleListRecords(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	currentUser := middleware.GetUser(r)
+
+	filter := store.RecordFilter{
+		UserID:      currentUser.ID,
+		RecordType:  utils.ReadString(qs, "type", ""),
+		CurrentOnly: utils.ReadString(qs, "history", "false") != "true",
+	}
+
+	if filter.RecordType != "" && !slices.Contains(records.Types, filter.RecordType) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid record type"})
+		return
+	}
+
+	var err error
+	filter.ExerciseID, err = utils.ReadInt(qs, "exercise_id", 0)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	list, err := rh.recordStore.ListRecords(filter)
+	if err != nil {
+		rh.logger.Printf("ERROR - ListRecords(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": list})
+}
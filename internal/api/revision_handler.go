@@ -0,0 +1,178 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// Every version of a workout is kept as a revision under
+// /workouts/{id}/revisions/{revision}, numbered as the version it was.
+
+// getRevision loads a revision of the workout, answering the request itself
+// and returning nil when there is none.
+func (wh *WorkoutHandler) getRevision(w http.ResponseWriter, workout *store.Workout, version int) *store.WorkoutRevision {
+	revision, err := wh.workoutStore.GetRevision(int64(workout.ID), workout.UserID, version)
+	if err != nil {
+		wh.logger.Printf("ERROR - GetRevision(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	if revision == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": fmt.Sprintf("revision %d not found", version)})
+		return nil
+	}
+	return revision
+}
+
+// readRevision reads the {revision} URL parameter.
+func readRevision(w http.ResponseWriter, r *http.Request) (int, bool) {
+	version, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil || version < 1 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid revision"})
+		return 0, false
+	}
+	return version, true
+}
+
+func (wh *WorkoutHandler) HandleListRevisions(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	revisions, err := wh.workoutStore.ListRevisions(int64(workout.ID), workout.UserID)
+	if err != nil {
+		wh.logger.Printf("ERROR - ListRevisions(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": revisions})
+}
+
+func (wh *WorkoutHandler) HandleGetRevision(w http.ResponseWriter, r *http.Request) {
+	version, ok := readRevision(w, r)
+	if !ok {
+		return
+	}
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	revision := wh.getRevision(w, workout, version)
+	if revision == nil {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": revision})
+}
+
+// HandleDiffRevisions shows what changed between the revisions ?from= and
+// ?to=. to defaults to the current version and from to the one before it.
+func (wh *WorkoutHandler) HandleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	qs := r.URL.Query()
+	to, err := utils.ReadInt(qs, "to", workout.Version)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	from, err := utils.ReadInt(qs, "from", to-1)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	fromRevision := wh.getRevision(w, workout, from)
+	if fromRevision == nil {
+		return
+	}
+	toRevision := wh.getRevision(w, workout, to)
+	if toRevision == nil {
+		return
+	}
+
+	diff, err := store.DiffWorkouts(fromRevision.Snapshot, toRevision.Snapshot)
+	if err != nil {
+		wh.logger.Printf("ERROR - DiffWorkouts(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": diff})
+}
+
+// HandleRevertWorkout brings a workout back to an earlier revision by
+// saving that revision's content as a new version; the history in between
+// is kept. Exercises deleted since are unlinked, keeping their names. Like
+// every write of a workout, it needs the version read, in an If-Match
+// header or as ?version=.
+func (wh *WorkoutHandler) HandleRevertWorkout(w http.ResponseWriter, r *http.Request) {
+	version, ok := readRevision(w, r)
+	if !ok {
+		return
+	}
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	revision := wh.getRevision(w, workout, version)
+	if revision == nil {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	reverted := revision.Snapshot
+	reverted.ID, reverted.UserID = workout.ID, workout.UserID
+	reverted.Version = workout.Version
+	reverted.CreatedAt, reverted.UpdatedAt = workout.CreatedAt, workout.UpdatedAt
+	reverted.DeletedAt = nil
+
+	currentUser := middleware.GetUser(r)
+	for i := range reverted.Entries {
+		entry := &reverted.Entries[i]
+		if entry.ExerciseID == nil {
+			continue
+		}
+		exercise, err := wh.exerciseStore.GetExerciseByID(int64(*entry.ExerciseID), currentUser.ID)
+		if err != nil {
+			wh.logger.Printf("ERROR - GetExerciseByID(): %v\n", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if exercise == nil {
+			entry.ExerciseID = nil
+		}
+	}
+	err := resolveExercises(wh.exerciseStore, currentUser.ID, reverted.Entries)
+	if err != nil {
+		writeResolveError(w, wh.logger, err)
+		return
+	}
+	err = validateEntries(reverted.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	newRecords, ok := wh.saveWorkoutWith(w, r, reverted, previous, func(workout *store.Workout) error {
+		return wh.workoutStore.RevertWorkout(workout, version)
+	})
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": reverted, "personal_records": newRecords})
+}
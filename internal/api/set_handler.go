@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// The set log of an entry can be edited one set at a time under
+// /workouts/{id}/entries/{entryID}/sets. Set ids are given out anew each
+// time the workout is saved, so a set is addressed by its set_index, its
+// place in the log counting from 1. Like the entries, every change saves
+// the workout and needs the version read.
+
+// findSet returns the position in the entry's log of the set of the
+// {setIndex} URL parameter, answering the request itself and returning -1
+// when the log has no such set.
+func findSet(w http.ResponseWriter, r *http.Request, entry *store.WorkoutEntry) int {
+	setIndex, err := strconv.Atoi(chi.URLParam(r, "setIndex"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid set index"})
+		return -1
+	}
+
+	if setIndex < 1 || setIndex > len(entry.SetLog) {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "set not found"})
+		return -1
+	}
+	return setIndex - 1
+}
+
+func (wh *WorkoutHandler) HandleListSets(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	i := findEntry(w, r, workout)
+	if i < 0 {
+		return
+	}
+
+	sets := workout.Entries[i].SetLog
+	if sets == nil {
+		sets = []store.WorkoutSet{}
+	}
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": sets})
+}
+
+// HandleAddSet adds a set to the log of an entry, at the position of its
+// set_index or last without one. The entry's summary fields are worked out
+// again from the new log.
+func (wh *WorkoutHandler) HandleAddSet(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	i := findEntry(w, r, workout)
+	if i < 0 {
+		return
+	}
+
+	var set store.WorkoutSet
+	err := json.NewDecoder(r.Body).Decode(&set)
+	if err != nil {
+		wh.logger.Printf("ERROR - addSetRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	entry := &workout.Entries[i]
+	set.ID = 0
+	position := len(entry.SetLog)
+	if set.SetIndex >= 1 && set.SetIndex <= len(entry.SetLog) {
+		position = set.SetIndex - 1
+	}
+	entry.SetLog = slices.Insert(entry.SetLog, position, set)
+
+	err = validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	newRecords, ok := wh.saveWorkout(w, r, workout, previous)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": workout.Entries[i].SetLog[position], "personal_records": newRecords})
+}
+
+// HandlePatchSet changes the fields of a set present in the body and leaves
+// the others as they are; null clears a field. A set keeps its place in the
+// log whatever set_index the body gives.
+func (wh *WorkoutHandler) HandlePatchSet(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	i := findEntry(w, r, workout)
+	if i < 0 {
+		return
+	}
+	j := findSet(w, r, &workout.Entries[i])
+	if j < 0 {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		wh.logger.Printf("ERROR - reading patchSetRequest: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(body, &fields)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	entry := &workout.Entries[i]
+	entry.SetLog[j], err = patchSet(entry.SetLog[j], fields)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	err = validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	newRecords, ok := wh.saveWorkout(w, r, workout, previous)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workout.Entries[i].SetLog[j], "personal_records": newRecords})
+}
+
+// patchSet returns the set with the fields of a patch body replaced,
+// decoded afresh so that the stored set is left as it was.
+func patchSet(set store.WorkoutSet, fields map[string]json.RawMessage) (store.WorkoutSet, error) {
+	stored, err := json.Marshal(set)
+	if err != nil {
+		return store.WorkoutSet{}, err
+	}
+	var doc map[string]json.RawMessage
+	err = json.Unmarshal(stored, &doc)
+	if err != nil {
+		return store.WorkoutSet{}, err
+	}
+
+	for name, value := range fields {
+		doc[name] = value
+	}
+
+	patched, err := json.Marshal(doc)
+	if err != nil {
+		return store.WorkoutSet{}, err
+	}
+	var result store.WorkoutSet
+	err = json.Unmarshal(patched, &result)
+	if err != nil {
+		return store.WorkoutSet{}, err
+	}
+	result.ID, result.SetIndex = set.ID, set.SetIndex
+	return result, nil
+}
+
+// HandleDeleteSet removes a set from the log of an entry; the sets after it
+// move up one place. The last set of a log cannot go, as the entry would be
+// left with a summary of sets it no longer has: delete the entry instead.
+func (wh *WorkoutHandler) HandleDeleteSet(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+	i := findEntry(w, r, workout)
+	if i < 0 {
+		return
+	}
+	j := findSet(w, r, &workout.Entries[i])
+	if j < 0 {
+		return
+	}
+
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	entry := &workout.Entries[i]
+	if len(entry.SetLog) == 1 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "the last set of an entry cannot be deleted; delete the entry instead"})
+		return
+	}
+	entry.SetLog = slices.Delete(entry.SetLog, j, j+1)
+
+	err := validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	_, ok := wh.saveWorkout(w, r, workout, previous)
+	if !ok {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
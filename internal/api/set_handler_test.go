@@ -0,0 +1,81 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRoutes(t *testing.T) {
+	five, hundred := 5, 100.0
+	workouts := &fakeWorkoutStore{workout: &store.Workout{
+		ID:          7,
+		UserID:      3,
+		Title:       "Push",
+		PerformedAt: time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC),
+		Version:     1,
+		Entries: []store.WorkoutEntry{{
+			ID:           11,
+			ExerciseName: "Bench Press",
+			Kind:         store.EntryKindStrength,
+			OrderIndex:   1,
+			SetLog: []store.WorkoutSet{
+				{ID: 7, SetIndex: 1, SetType: store.SetTypeWorking, Reps: &five, Weight: &hundred, Completed: true},
+			},
+		}},
+	}}
+	require.NoError(t, workouts.workout.Entries[0].SummarizeSets())
+	handler := NewWorkoutHandler(workouts, nil, fakeRecordStore{}, fakeBodyWeightStore{}, log.New(io.Discard, "", 0))
+
+	router := chi.NewRouter()
+	router.Post("/workouts/{id}/entries/{entryID}/sets", handler.HandleAddSet)
+	router.Patch("/workouts/{id}/entries/{entryID}/sets/{setIndex}", handler.HandlePatchSet)
+	router.Delete("/workouts/{id}/entries/{entryID}/sets/{setIndex}", handler.HandleDeleteSet)
+	send := func(method, target, version, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("If-Match", version)
+		r = middleware.SetUser(r, &store.User{ID: 3})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// a warm-up goes in front and leaves the summary to the working set
+	w := send(http.MethodPost, "/workouts/7/entries/11/sets", `"1"`, `{"set_index": 1, "set_type": "warmup", "reps": 10, "weight": 60}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	entry := workouts.workout.Entries[0]
+	require.Len(t, entry.SetLog, 2)
+	assert.Equal(t, store.SetTypeWarmup, entry.SetLog[0].SetType)
+	assert.Equal(t, 2, entry.SetLog[1].SetIndex)
+	assert.Equal(t, 1, entry.Sets)
+	assert.Equal(t, 100.0, *entry.Weight)
+
+	w = send(http.MethodPatch, "/workouts/7/entries/11/sets/2", `"2"`, `{"weight": 105, "rpe": 9}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	entry = workouts.workout.Entries[0]
+	assert.Equal(t, 105.0, *entry.SetLog[1].Weight)
+	assert.Equal(t, 5, *entry.SetLog[1].Reps, "fields not in the body are kept")
+	assert.Equal(t, 105.0, *entry.Weight)
+
+	w = send(http.MethodDelete, "/workouts/7/entries/11/sets/3", `"3"`, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(http.MethodDelete, "/workouts/7/entries/11/sets/1", `"3"`, "")
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	entry = workouts.workout.Entries[0]
+	require.Len(t, entry.SetLog, 1)
+	assert.Equal(t, store.SetTypeWorking, entry.SetLog[0].SetType)
+
+	w = send(http.MethodDelete, "/workouts/7/entries/11/sets/1", `"4"`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the last set stays")
+}
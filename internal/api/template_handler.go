@@ -0,0 +1,366 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/activity"
+	"github.com/fsrn12/fitness_tracker_go/internal/fit"
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+type templateRequest struct {
+	Name        *string                `json:"name"`
+	Description *string                `json:"description"`
+	Entries     *[]store.TemplateEntry `json:"entries"`
+	// WorkoutID copies the entries of an existing workout; name, description
+	// and entries sent alongside it override what was copied.
+	WorkoutID *int64 `json:"workout_id"`
+}
+
+type startTemplateRequest struct {
+	Title       *string    `json:"title"`
+	PerformedAt *time.Time `json:"performed_at"`
+}
+
+type TemplateHandler struct {
+	templateStore   store.TemplateStore
+	workoutStore    store.WorkoutStore
+	exerciseStore   store.ExerciseStore
+	recordStore     store.RecordStore
+	bodyWeightStore store.BodyWeightStore
+	logger          *log.Logger
+}
+
+func NewTemplateHandler(templateStore store.TemplateStore, workoutStore store.WorkoutStore, exerciseStore store.ExerciseStore, recordStore store.RecordStore, bodyWeightStore store.BodyWeightStore, logger *log.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateStore:   templateStore,
+		workoutStore:    workoutStore,
+		exerciseStore:   exerciseStore,
+		recordStore:     recordStore,
+		bodyWeightStore: bodyWeightStore,
+		logger:          logger,
+	}
+}
+
+func (th *TemplateHandler) applyTemplateRequest(template *store.WorkoutTemplate, req *templateRequest) {
+	if req.Name != nil {
+		template.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.Entries != nil {
+		template.Entries = *req.Entries
+	}
+}
+
+func (th *TemplateHandler) validateTemplate(template *store.WorkoutTemplate) error {
+	if template.Name == "" || len(template.Name) > 255 {
+		return errors.New("name is required and must be at most 255 characters long")
+	}
+	if len(template.Entries) == 0 {
+		return errors.New("a template needs at least one entry")
+	}
+	for i := range template.Entries {
+		err := template.Entries[i].Validate()
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// resolveTemplateExercises links template entries to the exercise catalog
+// exactly the way workout entries are linked.
+func (th *TemplateHandler) resolveTemplateExercises(userID int, entries []store.TemplateEntry) error {
+	links := make([]store.WorkoutEntry, len(entries))
+	for i, entry := range entries {
+		links[i] = store.WorkoutEntry{ExerciseID: entry.ExerciseID, ExerciseName: entry.ExerciseName}
+	}
+
+	err := resolveExercises(th.exerciseStore, userID, links)
+	if err != nil {
+		return err
+	}
+
+	for i, link := range links {
+		entries[i].ExerciseID = link.ExerciseID
+		entries[i].ExerciseName = link.ExerciseName
+	}
+	return nil
+}
+
+func (th *TemplateHandler) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	templates, err := th.templateStore.ListTemplates(currentUser.ID)
+	if err != nil {
+		th.logger.Printf("ERROR - ListTemplates(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": templates})
+}
+
+func (th *TemplateHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req templateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.logger.Printf("ERROR - CreateTemplateRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	template := &store.WorkoutTemplate{UserID: currentUser.ID}
+	if req.WorkoutID != nil {
+		workout, err := th.workoutStore.GetWorkoutByID(*req.WorkoutID, currentUser.ID)
+		if err != nil {
+			th.logger.Printf("ERROR - GetWorkoutByID() -> CreateTemplate(): %v\n", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if workout == nil {
+			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+			return
+		}
+		template = store.TemplateFromWorkout(workout)
+	}
+	th.applyTemplateRequest(template, &req)
+
+	err = th.validateTemplate(template)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = th.resolveTemplateExercises(currentUser.ID, template.Entries)
+	if err != nil {
+		writeResolveError(w, th.logger, err)
+		return
+	}
+
+	createdTemplate, err := th.templateStore.CreateTemplate(template)
+	if errors.Is(err, store.ErrDuplicateTemplate) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR - CreateTemplate(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create template"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdTemplate})
+}
+
+// getTemplate loads the template named by the URL for the current user and
+// writes the error response itself when there is none to return.
+func (th *TemplateHandler) getTemplate(w http.ResponseWriter, r *http.Request) *store.WorkoutTemplate {
+	templateID, err := utils.GetParamID(r)
+	if err != nil {
+		th.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(r)
+	template, err := th.templateStore.GetTemplateByID(templateID, currentUser.ID)
+	if err != nil {
+		th.logger.Printf("ERROR - GetTemplateByID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	if template == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+		return nil
+	}
+	return template
+}
+
+func (th *TemplateHandler) HandleGetTemplateByID(w http.ResponseWriter, r *http.Request) {
+	template := th.getTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": template})
+}
+
+func (th *TemplateHandler) HandleUpdateTemplateByID(w http.ResponseWriter, r *http.Request) {
+	template := th.getTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	var req templateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.logger.Printf("ERROR - updateTemplateRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if req.WorkoutID != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "workout_id can only be used when creating a template"})
+		return
+	}
+
+	th.applyTemplateRequest(template, &req)
+	err = th.validateTemplate(template)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = th.resolveTemplateExercises(template.UserID, template.Entries)
+	if err != nil {
+		writeResolveError(w, th.logger, err)
+		return
+	}
+
+	err = th.templateStore.UpdateTemplate(template)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+		return
+	}
+	if errors.Is(err, store.ErrDuplicateTemplate) {
+		utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR - UpdateTemplate(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update template"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": template})
+}
+
+func (th *TemplateHandler) HandleDeleteTemplateByID(w http.ResponseWriter, r *http.Request) {
+	templateID, err := utils.GetParamID(r)
+	if err != nil {
+		th.logger.Printf("ERROR - GetParamID: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = th.templateStore.DeleteTemplate(templateID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+		return
+	}
+
+	if err != nil {
+		th.logger.Printf("ERROR - DeleteTemplate(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleStartTemplate logs a new workout from a template. Every entry starts
+// at the weight last used for its exercise, falling back to the template's
+// target weight for exercises never logged before. The body is optional.
+func (th *TemplateHandler) HandleStartTemplate(w http.ResponseWriter, r *http.Request) {
+	template := th.getTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	var req startTemplateRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		th.logger.Printf("ERROR - startTemplateRequestDecoding: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	refs := make([]store.ExerciseRef, len(template.Entries))
+	for i, entry := range template.Entries {
+		refs[i] = store.ExerciseRef{ID: entry.ExerciseID, Name: entry.ExerciseName}
+	}
+	lastWeights, err := th.templateStore.LastWeights(template.UserID, refs)
+	if err != nil {
+		th.logger.Printf("ERROR - LastWeights(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	performedAt := time.Now()
+	if req.PerformedAt != nil {
+		performedAt = *req.PerformedAt
+	}
+	workout := template.Instantiate(performedAt, lastWeights)
+	if req.Title != nil && strings.TrimSpace(*req.Title) != "" {
+		workout.Title = strings.TrimSpace(*req.Title)
+	}
+	// a template saved before a rule existed, or a start time sent with the
+	// request, can make a workout the create handler would turn down
+	err = workout.ResolveTimes()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	fillCalories(th.bodyWeightStore, th.logger, middleware.GetUser(r), workout)
+
+	createdWorkout, err := th.workoutStore.CreateWorkout(workout)
+	if err != nil {
+		th.logger.Printf("ERROR - CreateWorkout() -> StartTemplate(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"})
+		return
+	}
+
+	newRecords := refreshRecords(th.recordStore, th.logger, template.UserID, createdWorkout.ID, nil)
+	w.Header().Set("ETag", utils.ETag(createdWorkout.Version))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdWorkout, "personal_records": newRecords})
+}
+
+// HandleExportTemplate downloads the template as a FIT workout file to copy
+// onto a watch.
+func (th *TemplateHandler) HandleExportTemplate(w http.ResponseWriter, r *http.Request) {
+	template := th.getTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	writeFITWorkout(w, th.logger, fitWorkout(template, template.Name))
+}
+
+func writeFITWorkout(w http.ResponseWriter, logger *log.Logger, workout fit.Workout) {
+	data, err := fit.EncodeWorkout(workout, time.Now())
+	if err != nil {
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"error": "the workout cannot be exported: " + err.Error()})
+		return
+	}
+
+	filename := strings.NewReplacer("/", "-", `\`, "-").Replace(workout.Name) + ".fit"
+	w.Header().Set("Content-Type", activityContentTypes[activity.FormatFIT])
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		logger.Printf("ERROR - writing FIT workout: %v\n", err)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+// Deleted workouts go to the trash, where they can be listed, restored or
+// purged for good; whatever is left there is purged once the retention
+// period is over.
+
+func (wh *WorkoutHandler) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	workouts, err := wh.workoutStore.ListTrash(currentUser.ID)
+	if err != nil {
+		wh.logger.Printf("ERROR - ListTrash(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workouts})
+}
+
+// HandleRestoreWorkout takes a workout out of the trash as it was deleted.
+// Its exercises' personal records are recomputed, since it may hold some
+// again.
+func (wh *WorkoutHandler) HandleRestoreWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.GetParamID(r)
+	if err != nil {
+		wh.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = wh.workoutStore.RestoreWorkout(workoutID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found in the trash"})
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR - RestoreWorkout(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	err = wh.recordStore.RecomputeExercises(currentUser.ID, store.EntryRefs(workout.Entries))
+	if err != nil {
+		wh.logger.Printf("ERROR - RecomputeExercises(): %v\n", err)
+	}
+
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workout})
+}
+
+// HandlePurgeWorkout permanently deletes a workout in the trash. Workouts
+// that are not in the trash have to be deleted first.
+func (wh *WorkoutHandler) HandlePurgeWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.GetParamID(r)
+	if err != nil {
+		wh.logger.Printf("ERROR - GetParamID(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = wh.workoutStore.PurgeWorkout(workoutID, currentUser.ID)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found in the trash"})
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR - PurgeWorkout(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
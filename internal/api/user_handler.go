@@ -4,10 +4,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"time"
 
+	"github.com/fsrn12/fitness_tracker_go/internal/calories"
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
 	"github.com/fsrn12/fitness_tracker_go/internal/store"
 	"github.com/fsrn12/fitness_tracker_go/internal/utils"
 )
@@ -17,6 +21,21 @@ type registerUserRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Bio      string `json:"bio"`
+	Timezone string `json:"timezone"`
+	// sex and birth_date are optional; they let workout calories be
+	// estimated from heart rate
+	Sex       *string `json:"sex"`
+	BirthDate *string `json:"birth_date"`
+}
+
+// publicUser is what anyone but the user sees of a profile; the email,
+// timezone, sex and birth date are kept to the user.
+type publicUser struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type UserHandler struct {
@@ -54,6 +73,61 @@ func (uh *UserHandler) validateUserRegisterRequest(req *registerUserRequest) err
 		return err
 	}
 
+	if req.Timezone != "" {
+		err = validateTimezone(req.Timezone)
+		if err != nil {
+			return err
+		}
+	}
+
+	req.Sex, err = validateSex(req.Sex)
+	if err != nil {
+		return err
+	}
+	req.BirthDate, err = validateBirthDate(req.BirthDate)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateSex accepts "male" or "female"; an empty string clears the field.
+func validateSex(sex *string) (*string, error) {
+	if sex == nil || *sex == "" {
+		return nil, nil
+	}
+	if *sex != calories.SexMale && *sex != calories.SexFemale {
+		return nil, fmt.Errorf("sex must be %q or %q", calories.SexMale, calories.SexFemale)
+	}
+	return sex, nil
+}
+
+// validateBirthDate accepts a YYYY-MM-DD date in the past; an empty string
+// clears the field.
+func validateBirthDate(date *string) (*string, error) {
+	if date == nil || *date == "" {
+		return nil, nil
+	}
+	birth, err := time.Parse(time.DateOnly, *date)
+	if err != nil {
+		return nil, errors.New("birth_date must be a date in the format YYYY-MM-DD")
+	}
+	if !birth.Before(time.Now()) || birth.Year() < 1900 {
+		return nil, errors.New("birth_date must be a past date after 1900")
+	}
+	return date, nil
+}
+
+// validateTimezone accepts IANA zone names such as "Europe/Berlin". The empty
+// string and "Local" are rejected: both would silently mean the server's zone.
+func validateTimezone(name string) error {
+	if name == "" || name == "Local" {
+		return errors.New("timezone must be an IANA time zone name such as Europe/Berlin")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return errors.New("timezone must be an IANA time zone name such as Europe/Berlin")
+	}
 	return nil
 }
 
@@ -73,8 +147,11 @@ func (uh *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request
 	}
 
 	user := &store.User{
-		Username: req.Username,
-		Email:    req.Email,
+		Username:  req.Username,
+		Email:     req.Email,
+		Timezone:  req.Timezone,
+		Sex:       req.Sex,
+		BirthDate: req.BirthDate,
 	}
 	if req.Bio != "" {
 		user.Bio = req.Bio
@@ -119,17 +196,34 @@ func (uh *UserHandler) HandleGetUserByID(w http.ResponseWriter, r *http.Request)
 	userID, err := utils.GetParamID(r)
 	if err != nil {
 		uh.logger.Printf("ERROR: GetParamID => %v\n", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid user id"})
 		return
 	}
 
 	user, err := uh.userStore.GetUserByID(userID)
 	if err != nil {
-		uh.logger.Printf("ERROR - GetWorkoutByID(): %v\n", err)
+		uh.logger.Printf("ERROR - GetUserByID(): %v\n", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
+	if user == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "user not found"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if currentUser.IsAnonymous() || currentUser.ID != user.ID {
+		utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": publicUser{
+			ID:        user.ID,
+			Username:  user.Username,
+			Bio:       user.Bio,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+		}})
+		return
+	}
+
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": user})
 }
 
@@ -141,6 +235,12 @@ func (uh *UserHandler) HandleUpdateUserByID(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	currentUser := middleware.GetUser(r)
+	if currentUser.ID != int(userID) {
+		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "only the user can modify their profile"})
+		return
+	}
+
 	existingUser, err := uh.userStore.GetUserByID(userID)
 	if err != nil {
 		uh.logger.Printf("ERROR - GetUserByID() -> UpdateUser(): %v\n", err)
@@ -154,9 +254,12 @@ func (uh *UserHandler) HandleUpdateUserByID(w http.ResponseWriter, r *http.Reque
 	}
 
 	var updateUserRequest struct {
-		Username *string `json:"username"`
-		Email    *string `json:"email"`
-		Bio      *string `json:"bio"`
+		Username  *string `json:"username"`
+		Email     *string `json:"email"`
+		Bio       *string `json:"bio"`
+		Timezone  *string `json:"timezone"`
+		Sex       *string `json:"sex"`
+		BirthDate *string `json:"birth_date"`
 	}
 
 	err = json.NewDecoder(r.Body).Decode(&updateUserRequest)
@@ -175,6 +278,28 @@ func (uh *UserHandler) HandleUpdateUserByID(w http.ResponseWriter, r *http.Reque
 	if updateUserRequest.Bio != nil {
 		existingUser.Bio = *updateUserRequest.Bio
 	}
+	if updateUserRequest.Timezone != nil {
+		err = validateTimezone(*updateUserRequest.Timezone)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		existingUser.Timezone = *updateUserRequest.Timezone
+	}
+	if updateUserRequest.Sex != nil {
+		existingUser.Sex, err = validateSex(updateUserRequest.Sex)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+	if updateUserRequest.BirthDate != nil {
+		existingUser.BirthDate, err = validateBirthDate(updateUserRequest.BirthDate)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
 
 	err = uh.userStore.UpdateUser(existingUser)
 	if err != nil {
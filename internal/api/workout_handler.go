@@ -3,25 +3,207 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
 	"github.com/fsrn12/fitness_tracker_go/internal/store"
 	"github.com/fsrn12/fitness_tracker_go/internal/utils"
 )
 
 type WorkoutHandler struct {
-	workoutStore store.WorkoutStore
-	logger       *log.Logger
+	workoutStore    store.WorkoutStore
+	exerciseStore   store.ExerciseStore
+	recordStore     store.RecordStore
+	bodyWeightStore store.BodyWeightStore
+	logger          *log.Logger
 }
 
-func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *log.Logger) *WorkoutHandler {
+func NewWorkoutHandler(workoutStore store.WorkoutStore, exerciseStore store.ExerciseStore, recordStore store.RecordStore, bodyWeightStore store.BodyWeightStore, logger *log.Logger) *WorkoutHandler {
 	return &WorkoutHandler{
-		workoutStore: workoutStore,
-		logger:       logger,
+		workoutStore:    workoutStore,
+		exerciseStore:   exerciseStore,
+		recordStore:     recordStore,
+		bodyWeightStore: bodyWeightStore,
+		logger:          logger,
 	}
 }
 
+// refreshRecords recomputes personal records after a workout was saved. The
+// workout itself is already committed, so a failure here is logged rather
+// than failing the request; the next save of the exercise repairs it.
+func refreshRecords(recordStore store.RecordStore, logger *log.Logger, userID int, workoutID int, previous []store.ExerciseRef) []*store.PersonalRecord {
+	newRecords, err := recordStore.RecomputeForWorkout(userID, workoutID, previous)
+	if err != nil {
+		logger.Printf("ERROR - RecomputeForWorkout(): %v\n", err)
+		return []*store.PersonalRecord{}
+	}
+	return newRecords
+}
+
+// fillCalories keeps the calories a workout was given as measured ones and
+// estimates them otherwise, from the user's body weight at the time of the
+// workout. Calories are a nicety, so a failure to look the weight up is
+// logged and leaves the workout without them.
+func fillCalories(bodyWeightStore store.BodyWeightStore, logger *log.Logger, user *store.User, workout *store.Workout) {
+	weight, err := bodyWeightStore.LatestBodyWeight(user.ID, workout.PerformedAt)
+	if err != nil {
+		logger.Printf("ERROR - LatestBodyWeight(): %v\n", err)
+	}
+	workout.FillCalories(user, weight)
+}
+
+// checkWorkoutVersion makes sure a write is based on the version of the
+// workout the server holds, so that an edit made on a stale copy never
+// overwrites a newer one. The version read comes in the If-Match header or,
+// for clients that cannot set headers, as a version field. A stale If-Match
+// is answered with 412 and a stale version field with 409; both carry the
+// current workout so the client can merge and retry.
+func checkWorkoutVersion(w http.ResponseWriter, r *http.Request, workout *store.Workout, version *int) bool {
+	ifMatch := r.Header.Get("If-Match")
+	switch {
+	case ifMatch != "":
+		if utils.MatchETag(ifMatch, utils.ETag(workout.Version)) {
+			return true
+		}
+	case version != nil:
+		if *version == workout.Version {
+			return true
+		}
+	default:
+		utils.WriteJSON(w, http.StatusPreconditionRequired, utils.Envelope{"error": "an If-Match header or the version of the workout is required"})
+		return false
+	}
+	writeVersionConflict(w, r, workout)
+	return false
+}
+
+// readVersion reads the version query parameter of requests without a body
+// to carry it, nil when absent.
+func readVersion(r *http.Request) (*int, error) {
+	qs := r.URL.Query()
+	if qs.Get("version") == "" {
+		return nil, nil
+	}
+	version, err := utils.ReadInt(qs, "version", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// writeVersionConflict answers a write based on an outdated version with
+// the current workout.
+func writeVersionConflict(w http.ResponseWriter, r *http.Request, current *store.Workout) {
+	status := http.StatusConflict
+	if r.Header.Get("If-Match") != "" {
+		status = http.StatusPreconditionFailed
+	}
+	w.Header().Set("ETag", utils.ETag(current.Version))
+	utils.WriteJSON(w, status, utils.Envelope{"error": "the workout was modified since it was read", "data": current})
+}
+
+// refetchConflict answers a write that lost a race with another one between
+// its version check and the save.
+func refetchConflict(w http.ResponseWriter, r *http.Request, workoutStore store.WorkoutStore, logger *log.Logger, id int64, userID int) {
+	current, err := workoutStore.GetWorkoutByID(id, userID)
+	if err != nil {
+		logger.Printf("ERROR - GetWorkoutByID() -> version conflict: %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if current == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return
+	}
+	writeVersionConflict(w, r, current)
+}
+
+// errInvalidExercise marks exercise resolution failures caused by the client.
+type errInvalidExercise struct {
+	message string
+}
+
+func (e errInvalidExercise) Error() string {
+	return e.message
+}
+
+// resolveExercises links every entry to the exercise catalog. Entries that
+// carry an exercise_id must reference an exercise visible to the user; entries
+// that only carry a name are fuzzy-matched and keep their free-text name when
+// nothing matches confidently. Linked entries take the canonical name.
+func resolveExercises(exerciseStore store.ExerciseStore, userID int, entries []store.WorkoutEntry) error {
+	var names []string
+	var unmatched []int
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ExerciseID != nil {
+			exercise, err := exerciseStore.GetExerciseByID(int64(*entry.ExerciseID), userID)
+			if err != nil {
+				return err
+			}
+			if exercise == nil {
+				return errInvalidExercise{fmt.Sprintf("entry %d: exercise %d does not exist", i+1, *entry.ExerciseID)}
+			}
+			entry.ExerciseName = exercise.Name
+			continue
+		}
+
+		entry.ExerciseName = strings.TrimSpace(entry.ExerciseName)
+		if entry.ExerciseName == "" {
+			return errInvalidExercise{fmt.Sprintf("entry %d: exercise_id or exercise_name is required", i+1)}
+		}
+		names = append(names, entry.ExerciseName)
+		unmatched = append(unmatched, i)
+	}
+
+	matches, err := exerciseStore.MatchExercises(userID, names)
+	if err != nil {
+		return err
+	}
+	for j, exercise := range matches {
+		if exercise == nil {
+			continue
+		}
+		entry := &entries[unmatched[j]]
+		entry.ExerciseID = &exercise.ID
+		entry.ExerciseName = exercise.Name
+	}
+	return nil
+}
+
+// validateEntries checks each entry's set log, deriving the legacy
+// aggregate fields from it, the rules of its kind, and the sections and
+// groups of the entries.
+func validateEntries(entries []store.WorkoutEntry) error {
+	for i := range entries {
+		err := entries[i].SummarizeSets()
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		err = entries[i].ValidateKind()
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i+1, err)
+		}
+	}
+	return store.ValidateGroups(entries)
+}
+
+func writeResolveError(w http.ResponseWriter, logger *log.Logger, err error) {
+	var invalid errInvalidExercise
+	if errors.As(err, &invalid) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": invalid.Error()})
+		return
+	}
+
+	logger.Printf("ERROR - resolveExercises(): %v\n", err)
+	utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+}
+
 func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Request) {
 	var workout store.Workout
 	err := json.NewDecoder(r.Body).Decode(&workout)
@@ -31,6 +213,30 @@ func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	currentUser := middleware.GetUser(r)
+	workout.UserID = currentUser.ID
+	// the source is the server's to say
+	workout.CaloriesSource = ""
+
+	err = workout.ResolveTimes()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = validateEntries(workout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = resolveExercises(wh.exerciseStore, currentUser.ID, workout.Entries)
+	if err != nil {
+		writeResolveError(w, wh.logger, err)
+		return
+	}
+
+	fillCalories(wh.bodyWeightStore, wh.logger, currentUser, &workout)
 	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout)
 	if err != nil {
 		wh.logger.Printf("ERROR - CreateWorkout(): %v\n", err)
@@ -38,100 +244,247 @@ func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdWorkout})
+	newRecords := refreshRecords(wh.recordStore, wh.logger, currentUser.ID, createdWorkout.ID, nil)
+	w.Header().Set("ETag", utils.ETag(createdWorkout.Version))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"data": createdWorkout, "personal_records": newRecords})
 }
 
-func (wh *WorkoutHandler) HandleGetWorkoutByID(w http.ResponseWriter, r *http.Request) {
+// getWorkout loads the workout of the {id} URL parameter, answering the
+// request itself and returning nil when there is none.
+func (wh *WorkoutHandler) getWorkout(w http.ResponseWriter, r *http.Request) *store.Workout {
 	workoutID, err := utils.GetParamID(r)
 	if err != nil {
 		wh.logger.Printf("ERROR - GetParamID(): %v\n", err)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
-		return
+		return nil
 	}
 
-	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	currentUser := middleware.GetUser(r)
+	workout, err := wh.workoutStore.GetWorkoutByID(workoutID, currentUser.ID)
 	if err != nil {
 		wh.logger.Printf("ERROR - GetWorkoutByID(): %v\n", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	if workout == nil {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return nil
+	}
+	return workout
+}
+
+func (wh *WorkoutHandler) HandleGetWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
 		return
 	}
 
+	w.Header().Set("ETag", utils.ETag(workout.Version))
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workout})
 }
 
-func (wh *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.Request) {
-	workoutID, err := utils.GetParamID(r)
+func (wh *WorkoutHandler) HandleListWorkouts(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	currentUser := middleware.GetUser(r)
+
+	filter := store.WorkoutFilter{
+		UserID:   currentUser.ID,
+		Title:    utils.ReadString(qs, "title", ""),
+		Exercise: utils.ReadString(qs, "exercise", ""),
+		Sort:     utils.ReadString(qs, "sort", store.DefaultWorkoutSort),
+		Cursor:   utils.ReadString(qs, "cursor", ""),
+	}
+
+	var err error
+	filter.From, filter.To, err = utils.ReadTimeRange(qs, userLocation(currentUser))
 	if err != nil {
-		wh.logger.Printf("ERROR: GetParamID => %v\n", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	filter.ExerciseID, err = utils.ReadInt(qs, "exercise_id", 0)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
 		return
 	}
 
-	existingWorkout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	filter.Limit, err = utils.ReadInt(qs, "limit", store.DefaultWorkoutLimit)
 	if err != nil {
-		wh.logger.Printf("ERROR - GetWorkoutByID() -> UpdateWorkout(): %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if filter.Limit < 1 || filter.Limit > store.MaxWorkoutLimit {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", store.MaxWorkoutLimit)})
+		return
+	}
+
+	if !store.ValidWorkoutSort(filter.Sort) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid sort value"})
+		return
+	}
+
+	workouts, page, err := wh.workoutStore.ListWorkouts(filter)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid cursor"})
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR - ListWorkouts(): %v\n", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": workouts, "metadata": page})
+}
+
+func (wh *WorkoutHandler) HandleUpdateWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	existingWorkout := wh.getWorkout(w, r)
 	if existingWorkout == nil {
-		http.NotFound(w, r)
-		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
 		return
 	}
 
+	previousExercises := store.EntryRefs(existingWorkout.Entries)
+
 	var updateWorkoutRequest struct {
 		Title           *string              `json:"title"`
 		Description     *string              `json:"description"`
+		PerformedAt     *time.Time           `json:"performed_at"`
+		StartedAt       *time.Time           `json:"started_at"`
+		EndedAt         *time.Time           `json:"ended_at"`
 		DurationMinutes *int                 `json:"duration_minutes"`
 		CaloriesBurned  *int                 `json:"calories_burned"`
 		Entries         []store.WorkoutEntry `json:"entries"`
+		Version         *int                 `json:"version"`
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&updateWorkoutRequest)
+	err := json.NewDecoder(r.Body).Decode(&updateWorkoutRequest)
 	if err != nil {
 		wh.logger.Printf("ERROR - updateWorkoutRequestDecoding: %v\n", err)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
 		return
 	}
 
+	if !checkWorkoutVersion(w, r, existingWorkout, updateWorkoutRequest.Version) {
+		return
+	}
+
 	if updateWorkoutRequest.Title != nil {
 		existingWorkout.Title = *updateWorkoutRequest.Title
 	}
 	if updateWorkoutRequest.Description != nil {
 		existingWorkout.Description = *updateWorkoutRequest.Description
 	}
+	if updateWorkoutRequest.PerformedAt != nil {
+		existingWorkout.PerformedAt = *updateWorkoutRequest.PerformedAt
+	}
+	if updateWorkoutRequest.StartedAt != nil || updateWorkoutRequest.EndedAt != nil {
+		if updateWorkoutRequest.StartedAt != nil {
+			existingWorkout.StartedAt = updateWorkoutRequest.StartedAt
+		}
+		if updateWorkoutRequest.EndedAt != nil {
+			existingWorkout.EndedAt = updateWorkoutRequest.EndedAt
+		}
+		// re-derive the duration from the new times unless one was sent explicitly
+		if updateWorkoutRequest.DurationMinutes == nil && existingWorkout.StartedAt != nil && existingWorkout.EndedAt != nil {
+			existingWorkout.DurationMinutes = 0
+		}
+	}
 	if updateWorkoutRequest.DurationMinutes != nil {
 		existingWorkout.DurationMinutes = *updateWorkoutRequest.DurationMinutes
 	}
 	if updateWorkoutRequest.CaloriesBurned != nil {
 		existingWorkout.CaloriesBurned = *updateWorkoutRequest.CaloriesBurned
+		existingWorkout.CaloriesSource = ""
 	}
 	if updateWorkoutRequest.Entries != nil {
 		existingWorkout.Entries = updateWorkoutRequest.Entries
+		err = validateEntries(existingWorkout.Entries)
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+
+		currentUser := middleware.GetUser(r)
+		err = resolveExercises(wh.exerciseStore, currentUser.ID, existingWorkout.Entries)
+		if err != nil {
+			writeResolveError(w, wh.logger, err)
+			return
+		}
+	}
+
+	newRecords, ok := wh.saveWorkout(w, r, existingWorkout, previousExercises)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": existingWorkout, "personal_records": newRecords})
+}
+
+// saveWorkout saves a workout edited in place, as read at its version, and
+// sets the ETag of the new version. Calorie estimates are redone as the
+// workout changes, and the personal records of its exercises, including the
+// previous ones, are refreshed. It answers the request itself and returns
+// false when the workout could not be saved.
+func (wh *WorkoutHandler) saveWorkout(w http.ResponseWriter, r *http.Request, workout *store.Workout, previous []store.ExerciseRef) ([]*store.PersonalRecord, bool) {
+	return wh.saveWorkoutWith(w, r, workout, previous, wh.workoutStore.UpdateWorkout)
+}
+
+// saveWorkoutWith is saveWorkout with the store call that saves the workout.
+func (wh *WorkoutHandler) saveWorkoutWith(w http.ResponseWriter, r *http.Request, workout *store.Workout, previous []store.ExerciseRef, save func(*store.Workout) error) ([]*store.PersonalRecord, bool) {
+	err := workout.ResolveTimes()
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
 	}
+	currentUser := middleware.GetUser(r)
+	fillCalories(wh.bodyWeightStore, wh.logger, currentUser, workout)
 
-	err = wh.workoutStore.UpdateWorkout(existingWorkout)
+	err = save(workout)
+	if err == sql.ErrNoRows {
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return nil, false
+	}
+	if errors.Is(err, store.ErrVersionConflict) {
+		refetchConflict(w, r, wh.workoutStore, wh.logger, int64(workout.ID), currentUser.ID)
+		return nil, false
+	}
 	if err != nil {
 		wh.logger.Printf("ERROR - UpdateWorkout(): %v\n", err)
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Failed to update new workout"})
-		return
+		return nil, false
 	}
-	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": existingWorkout})
+
+	newRecords := refreshRecords(wh.recordStore, wh.logger, currentUser.ID, workout.ID, previous)
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	return newRecords, true
 }
 
+// HandleDeleteWorkoutByID moves a workout to the trash, from where it can be
+// restored until it is purged.
 func (wh *WorkoutHandler) HandleDeleteWorkoutByID(w http.ResponseWriter, r *http.Request) {
-	workoutID, err := utils.GetParamID(r)
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	// DELETE has no body, so the version comes as ?version=
+	version, err := readVersion(r)
 	if err != nil {
-		wh.logger.Printf("ERROR - GetParamID: %v\n", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !checkWorkoutVersion(w, r, workout, version) {
 		return
 	}
 
-	err = wh.workoutStore.DeleteWorkout(workoutID)
+	currentUser := middleware.GetUser(r)
+	err = wh.workoutStore.DeleteWorkout(int64(workout.ID), currentUser.ID, workout.Version)
 	if err == sql.ErrNoRows {
-		wh.logger.Printf("ERROR - DeleteWorkout(): %v\n", err)
-		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "no record found"})
+		utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return
+	}
+	if errors.Is(err, store.ErrVersionConflict) {
+		refetchConflict(w, r, wh.workoutStore, wh.logger, int64(workout.ID), currentUser.ID)
 		return
 	}
 
@@ -141,6 +494,12 @@ func (wh *WorkoutHandler) HandleDeleteWorkoutByID(w http.ResponseWriter, r *http
 		return
 	}
 
+	// records this workout held may now belong to an earlier performance
+	err = wh.recordStore.RecomputeExercises(currentUser.ID, store.EntryRefs(workout.Entries))
+	if err != nil {
+		wh.logger.Printf("ERROR - RecomputeExercises(): %v\n", err)
+	}
+
 	// w.WriteHeader(http.StatusNoContent)
 	utils.WriteJSON(w, http.StatusNoContent, utils.Envelope{"data": "workout deleted successfully"})
 }
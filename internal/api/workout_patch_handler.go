@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/jsonpatch"
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// HandlePatchWorkoutByID patches a workout as GET /workouts/{id} shows it,
+// with a JSON Merge Patch or a JSON Patch as the Content-Type says. Unlike
+// PUT, a patch can clear a field and address a single entry or set. The
+// patched workout is validated as a whole and saved in one transaction; the
+// server's own fields (id, version, timestamps, calories_source) are kept.
+// Like every write of a workout, it needs the version read, in an If-Match
+// header or as ?version=.
+func (wh *WorkoutHandler) HandlePatchWorkoutByID(w http.ResponseWriter, r *http.Request) {
+	var applyPatch func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchType:
+		applyPatch = jsonpatch.MergePatch
+	case jsonPatchType:
+		applyPatch = jsonpatch.Apply
+	default:
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		utils.WriteJSON(w, http.StatusUnsupportedMediaType, utils.Envelope{"error": "patches must be " + mergePatchType + " or " + jsonPatchType})
+		return
+	}
+
+	workout := wh.getWorkout(w, r)
+	if workout == nil {
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		wh.logger.Printf("ERROR - reading patchWorkoutRequest: %v\n", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+
+	if !checkEntryWriteVersion(w, r, workout) {
+		return
+	}
+	previous := store.EntryRefs(workout.Entries)
+
+	patchedWorkout, err := patchWorkout(workout, patch, applyPatch)
+	var patchErr *patchError
+	switch {
+	case errors.Is(err, jsonpatch.ErrInvalidPatch):
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	case errors.As(err, &patchErr):
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"error": err.Error()})
+		return
+	case err != nil:
+		wh.logger.Printf("ERROR - patchWorkout(): %v\n", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	err = validateEntries(patchedWorkout.Entries)
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	err = resolveExercises(wh.exerciseStore, currentUser.ID, patchedWorkout.Entries)
+	if err != nil {
+		writeResolveError(w, wh.logger, err)
+		return
+	}
+
+	newRecords, ok := wh.saveWorkout(w, r, patchedWorkout, previous)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"data": patchedWorkout, "personal_records": newRecords})
+}
+
+// derivedEntryFields are the entry fields the server works out when it
+// writes an entry; they are left out of the document a patch applies to.
+var derivedEntryFields = []string{"pace_seconds_per_km", "speed_kmh"}
+
+// patchError is the patch's fault: it cannot be applied to the workout, or
+// what it makes of the workout is not one.
+type patchError struct {
+	err error
+}
+
+func (e *patchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *patchError) Unwrap() error {
+	return e.err
+}
+
+// patchWorkout applies a patch to the stored workout as GET shows it and
+// returns the patched workout with the fields the patch has no say over put
+// back. Errors the patch is to blame for are *patchError; any other is the
+// server's.
+func patchWorkout(stored *store.Workout, patch []byte, applyPatch func(doc, patch []byte) ([]byte, error)) (*store.Workout, error) {
+	doc, err := patchDocument(stored)
+	if err != nil {
+		return nil, fmt.Errorf("patch document: %w", err)
+	}
+	patched, err := applyPatch(doc, patch)
+	if err != nil {
+		return nil, &patchError{err}
+	}
+
+	var workout store.Workout
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&workout)
+	if err != nil {
+		return nil, &patchError{fmt.Errorf("the patched workout is invalid: %w", err)}
+	}
+	keepServerFields(&workout, stored)
+	return &workout, nil
+}
+
+// patchDocument returns the workout as GET shows it, less the derived entry
+// fields and with empty lists in place of missing ones, so that a JSON
+// Patch can append to them.
+func patchDocument(workout *store.Workout) ([]byte, error) {
+	doc := *workout
+	doc.Entries = slices.Clone(workout.Entries)
+	if doc.Entries == nil {
+		doc.Entries = []store.WorkoutEntry{}
+	}
+	for i := range doc.Entries {
+		if doc.Entries[i].SetLog == nil {
+			doc.Entries[i].SetLog = []store.WorkoutSet{}
+		}
+	}
+
+	data, err := json.Marshal(&doc)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range fields["entries"].([]any) {
+		for _, name := range derivedEntryFields {
+			delete(entry.(map[string]any), name)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// keepServerFields puts back the fields of the stored workout a patch has no
+// say over, and resets the derived fields whose inputs it changed, as PUT
+// does: new times re-derive the duration unless it was patched too, new
+// calories become measured ones, and an entry renamed without a new
+// exercise_id is matched against the catalog again.
+func keepServerFields(patched, stored *store.Workout) {
+	patched.ID = stored.ID
+	patched.UserID = stored.UserID
+	patched.Version = stored.Version
+	patched.CreatedAt = stored.CreatedAt
+	patched.UpdatedAt = stored.UpdatedAt
+	patched.DeletedAt = stored.DeletedAt
+
+	patched.CaloriesSource = stored.CaloriesSource
+	if patched.CaloriesBurned != stored.CaloriesBurned {
+		patched.CaloriesSource = ""
+	}
+
+	timesChanged := !equalTime(patched.StartedAt, stored.StartedAt) || !equalTime(patched.EndedAt, stored.EndedAt)
+	if timesChanged && patched.DurationMinutes == stored.DurationMinutes && patched.StartedAt != nil && patched.EndedAt != nil {
+		patched.DurationMinutes = 0
+	}
+
+	for i := range patched.Entries {
+		entry := &patched.Entries[i]
+		j := slices.IndexFunc(stored.Entries, func(s store.WorkoutEntry) bool {
+			return entry.ID != 0 && s.ID == entry.ID
+		})
+		if j < 0 {
+			continue
+		}
+		original := stored.Entries[j]
+		if entry.ExerciseName != original.ExerciseName && equalInt(entry.ExerciseID, original.ExerciseID) {
+			entry.ExerciseID = nil
+		}
+	}
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/jsonpatch"
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cardioWorkout() *store.Workout {
+	distance, duration := 5.0, 1500
+	return &store.Workout{
+		ID:          7,
+		UserID:      3,
+		Title:       "Morning run",
+		Description: "Easy pace",
+		PerformedAt: time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC),
+		Version:     4,
+		Entries: []store.WorkoutEntry{{
+			ID:              11,
+			ExerciseName:    "Running",
+			Kind:            store.EntryKindCardio,
+			Distance:        &distance,
+			DistanceUnit:    "km",
+			DurationSeconds: &duration,
+			OrderIndex:      1,
+		}},
+	}
+}
+
+func TestPatchWorkout(t *testing.T) {
+	// the pace and speed GET shows for cardio entries are not patched back
+	patched, err := patchWorkout(cardioWorkout(), []byte(`{"description":null}`), jsonpatch.MergePatch)
+	require.NoError(t, err)
+	assert.Empty(t, patched.Description)
+	assert.Equal(t, "Morning run", patched.Title)
+	require.Len(t, patched.Entries, 1)
+	assert.Equal(t, 11, patched.Entries[0].ID)
+
+	patched, err = patchWorkout(cardioWorkout(), []byte(`[
+		{"op":"test","path":"/entries/0/distance","value":5},
+		{"op":"replace","path":"/entries/0/duration_seconds","value":1440},
+		{"op":"replace","path":"/version","value":1}
+	]`), jsonpatch.Apply)
+	require.NoError(t, err)
+	assert.Equal(t, 1440, *patched.Entries[0].DurationSeconds)
+	assert.Equal(t, 4, patched.Version, "the version is the server's")
+
+	var patchErr *patchError
+	_, err = patchWorkout(cardioWorkout(), []byte(`[{"op":"remove","path":"/entries/0/pace_seconds_per_km"}]`), jsonpatch.Apply)
+	assert.ErrorAs(t, err, &patchErr, "derived fields are not part of the document")
+
+	_, err = patchWorkout(cardioWorkout(), []byte(`{"titel":"typo"}`), jsonpatch.MergePatch)
+	assert.ErrorAs(t, err, &patchErr)
+	assert.ErrorContains(t, err, "unknown field")
+
+	_, err = patchWorkout(cardioWorkout(), []byte(`{"op":"replace"}`), jsonpatch.Apply)
+	assert.ErrorIs(t, err, jsonpatch.ErrInvalidPatch)
+}
@@ -14,12 +14,28 @@ import (
 )
 
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	Middleware     middleware.UserMiddleware
-	DB             *sql.DB
+	Logger            *log.Logger
+	WorkoutHandler    *api.WorkoutHandler
+	ExerciseHandler   *api.ExerciseHandler
+	RecordHandler     *api.RecordHandler
+	AnalyticsHandler  *api.AnalyticsHandler
+	TemplateHandler   *api.TemplateHandler
+	PlanHandler       *api.PlanHandler
+	ProgramHandler    *api.ProgramHandler
+	ActivityHandler   *api.ActivityHandler
+	ImportHandler     *api.ImportHandler
+	ExportHandler     *api.ExportHandler
+	FeedHandler       *api.FeedHandler
+	BodyWeightHandler *api.BodyWeightHandler
+	UserHandler       *api.UserHandler
+	TokenHandler      *api.TokenHandler
+	Middleware        middleware.UserMiddleware
+	Idempotency       middleware.IdempotencyMiddleware
+	DB                *sql.DB
+
+	workoutStore     store.WorkoutStore
+	importStore      store.ImportStore
+	idempotencyStore store.IdempotencyStore
 }
 
 func NewApplication() (*Application, error) {
@@ -39,19 +55,56 @@ func NewApplication() (*Application, error) {
 	workoutStore := store.NewPostgresWorkoutStore(pgDB)
 	userStore := store.NewPostgresUserStore(pgDB)
 	tokenStore := store.NewPostgresTokenStore(pgDB)
+	exerciseStore := store.NewPostgresExerciseStore(pgDB)
+	recordStore := store.NewPostgresRecordStore(pgDB)
+	analyticsStore := store.NewPostgresAnalyticsStore(pgDB)
+	templateStore := store.NewPostgresTemplateStore(pgDB)
+	planStore := store.NewPostgresPlanStore(pgDB)
+	programStore := store.NewPostgresProgramStore(pgDB)
+	activityStore := store.NewPostgresActivityStore(pgDB)
+	importStore := store.NewPostgresImportStore(pgDB)
+	bodyWeightStore := store.NewPostgresBodyWeightStore(pgDB)
+	idempotencyStore := store.NewPostgresIdempotencyStore(pgDB)
 
 	// handlers
-	workoutHandler := api.NewWorkoutHandler(workoutStore, logger)
+	workoutHandler := api.NewWorkoutHandler(workoutStore, exerciseStore, recordStore, bodyWeightStore, logger)
+	exerciseHandler := api.NewExerciseHandler(exerciseStore, logger)
+	recordHandler := api.NewRecordHandler(recordStore, logger)
+	analyticsHandler := api.NewAnalyticsHandler(analyticsStore, exerciseStore, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, workoutStore, exerciseStore, recordStore, bodyWeightStore, logger)
+	planHandler := api.NewPlanHandler(planStore, workoutStore, templateStore, logger)
+	programHandler := api.NewProgramHandler(programStore, exerciseStore, logger)
+	activityHandler := api.NewActivityHandler(activityStore, exerciseStore, recordStore, bodyWeightStore, logger)
+	importHandler := api.NewImportHandler(importStore, exerciseStore, recordStore, bodyWeightStore, logger)
+	exportHandler := api.NewExportHandler(workoutStore, logger)
+	feedHandler := api.NewFeedHandler(tokenStore, userStore, workoutStore, planStore, templateStore, logger)
+	bodyWeightHandler := api.NewBodyWeightHandler(bodyWeightStore, logger)
 	userHander := api.NewUserHandler(userStore, logger)
 	tokenHander := api.NewTokenHandler(tokenStore, userStore, logger)
 	middlewareHandler := middleware.UserMiddleware{UserStore: userStore}
+	idempotencyHandler := middleware.IdempotencyMiddleware{Store: idempotencyStore, TTL: middleware.DefaultIdempotencyTTL, Logger: logger}
 	app := &Application{
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		UserHandler:    userHander,
-		TokenHandler:   tokenHander,
-		Middleware:     middlewareHandler,
-		DB:             pgDB,
+		Logger:            logger,
+		WorkoutHandler:    workoutHandler,
+		ExerciseHandler:   exerciseHandler,
+		RecordHandler:     recordHandler,
+		AnalyticsHandler:  analyticsHandler,
+		TemplateHandler:   templateHandler,
+		PlanHandler:       planHandler,
+		ProgramHandler:    programHandler,
+		ActivityHandler:   activityHandler,
+		ImportHandler:     importHandler,
+		ExportHandler:     exportHandler,
+		FeedHandler:       feedHandler,
+		BodyWeightHandler: bodyWeightHandler,
+		UserHandler:       userHander,
+		TokenHandler:      tokenHander,
+		Middleware:        middlewareHandler,
+		Idempotency:       idempotencyHandler,
+		DB:                pgDB,
+		workoutStore:      workoutStore,
+		importStore:       importStore,
+		idempotencyStore:  idempotencyStore,
 	}
 	return app, nil
 }
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// every runs job once now and then every interval until ctx is done.
+func every(ctx context.Context, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeTrash permanently deletes the workouts that have been in the trash
+// for longer than retention, once now and then every interval until ctx is
+// done. A failed run is logged and retried at the next one.
+func (a *Application) PurgeTrash(ctx context.Context, retention, interval time.Duration) {
+	every(ctx, interval, func() {
+		purged, err := a.workoutStore.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			a.Logger.Printf("ERROR - PurgeTrash(): %v\n", err)
+		} else if purged > 0 {
+			a.Logger.Printf("purged %d workouts from the trash\n", purged)
+		}
+	})
+}
+
+// FailInterruptedImports marks the imports that were running when the
+// server last stopped as failed; nothing is left to finish them. It is meant
+// to run once at startup, before new imports can begin.
+func (a *Application) FailInterruptedImports() {
+	failed, err := a.importStore.FailRunningImportJobs("the import was interrupted by a server restart")
+	if err != nil {
+		a.Logger.Printf("ERROR - FailRunningImportJobs(): %v\n", err)
+	} else if failed > 0 {
+		a.Logger.Printf("marked %d interrupted imports as failed\n", failed)
+	}
+}
+
+// DeleteExpiredIdempotencyKeys clears out the idempotency keys past their
+// ttl every interval until ctx is done. Expired keys are free to reuse
+// whether or not they were deleted; this only keeps the table small.
+func (a *Application) DeleteExpiredIdempotencyKeys(ctx context.Context, interval time.Duration) {
+	every(ctx, interval, func() {
+		_, err := a.idempotencyStore.DeleteExpiredKeys()
+		if err != nil {
+			a.Logger.Printf("ERROR - DeleteExpiredKeys(): %v\n", err)
+		}
+	})
+}
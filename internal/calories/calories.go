@@ -0,0 +1,128 @@
+// Package calories estimates the energy spent in a workout. Each exercise is
+// costed with the heart-rate formula of Keytel et al. (2005) when its
+// average heart rate is known, along with the age and sex it needs, and with
+// a MET value from the Compendium of Physical Activities otherwise.
+package calories
+
+import "math"
+
+const (
+	SexMale   = "male"
+	SexFemale = "female"
+)
+
+// Profile is what the formulas need to know about the person training.
+// Age and Sex are optional; without them only MET values are used.
+type Profile struct {
+	WeightKg float64
+	Age      int
+	Sex      string
+}
+
+// Kinds mirror the entry kinds of a workout.
+const (
+	KindStrength = "strength"
+	KindCardio   = "cardio"
+	KindTimed    = "timed"
+)
+
+// Activity is one exercise of a workout. Seconds is how long it lasted, 0
+// when unknown; exercises without a time of their own share what is left of
+// the workout duration in proportion to their sets.
+type Activity struct {
+	Name           string
+	Kind           string
+	Sets           int
+	Seconds        int
+	DistanceMeters float64
+	AvgHeartRate   int
+}
+
+// Keytel's formula only holds for heart rates of actual exercise.
+const (
+	minKeytelHeartRate = 90
+	maxKeytelHeartRate = 200
+)
+
+// Keytel returns the kilocalories spent over the seconds at the average
+// heart rate, and false when the profile or heart rate does not allow the
+// formula.
+func Keytel(p Profile, heartRate, seconds int) (float64, bool) {
+	if p.WeightKg <= 0 || p.Age <= 0 || seconds <= 0 ||
+		heartRate < minKeytelHeartRate || heartRate > maxKeytelHeartRate {
+		return 0, false
+	}
+
+	hr, w, a := float64(heartRate), p.WeightKg, float64(p.Age)
+	var kJPerMin float64
+	switch p.Sex {
+	case SexMale:
+		kJPerMin = -55.0969 + 0.6309*hr + 0.1988*w + 0.2017*a
+	case SexFemale:
+		kJPerMin = -20.4022 + 0.4472*hr - 0.1263*w + 0.074*a
+	default:
+		return 0, false
+	}
+	if kJPerMin <= 0 {
+		return 0, false
+	}
+	return kJPerMin / 4.184 * float64(seconds) / 60, true
+}
+
+// Estimate returns the kilocalories of a workout lasting totalSeconds, and
+// false when nothing could be costed: without a body weight, or when neither
+// the workout nor its exercises have a duration.
+func Estimate(p Profile, totalSeconds int, activities []Activity) (int, bool) {
+	if p.WeightKg <= 0 {
+		return 0, false
+	}
+	if len(activities) == 0 {
+		if totalSeconds <= 0 {
+			return 0, false
+		}
+		return int(math.Round(metKcal(p, workoutMET, totalSeconds))), true
+	}
+
+	timed, untimedSets := 0, 0
+	for _, a := range activities {
+		if a.Seconds > 0 {
+			timed += a.Seconds
+		} else {
+			untimedSets += max(a.Sets, 1)
+		}
+	}
+	rest := max(totalSeconds-timed, 0)
+
+	var kcal float64
+	costed := false
+	for _, a := range activities {
+		seconds := a.Seconds
+		if seconds <= 0 {
+			if rest == 0 {
+				continue
+			}
+			seconds = rest * max(a.Sets, 1) / untimedSets
+		}
+		if seconds <= 0 {
+			continue
+		}
+
+		if a.AvgHeartRate > 0 {
+			if k, ok := Keytel(p, a.AvgHeartRate, seconds); ok {
+				kcal += k
+				costed = true
+				continue
+			}
+		}
+		kcal += metKcal(p, MET(a, seconds), seconds)
+		costed = true
+	}
+	if !costed {
+		return 0, false
+	}
+	return int(math.Round(kcal)), true
+}
+
+func metKcal(p Profile, met float64, seconds int) float64 {
+	return met * p.WeightKg * float64(seconds) / 3600
+}
@@ -0,0 +1,78 @@
+package calories
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeytel(t *testing.T) {
+	man := Profile{WeightKg: 80, Age: 35, Sex: SexMale}
+	kcal, ok := Keytel(man, 150, 3600)
+	assert.True(t, ok)
+	// (-55.0969 + 0.6309*150 + 0.1988*80 + 0.2017*35) / 4.184 * 60
+	assert.InDelta(t, 896.3, kcal, 0.1)
+
+	woman := Profile{WeightKg: 60, Age: 30, Sex: SexFemale}
+	kcal, ok = Keytel(woman, 150, 1800)
+	assert.True(t, ok)
+	assert.InDelta(t, 296.3, kcal, 0.1)
+
+	for _, tc := range []struct {
+		name    string
+		profile Profile
+		hr      int
+	}{
+		{"no sex", Profile{WeightKg: 80, Age: 35}, 150},
+		{"no age", Profile{WeightKg: 80, Sex: SexMale}, 150},
+		{"resting heart rate", man, 70},
+	} {
+		_, ok := Keytel(tc.profile, tc.hr, 3600)
+		assert.False(t, ok, tc.name)
+	}
+}
+
+func TestMET(t *testing.T) {
+	assert.Equal(t, 6.0, MET(Activity{Name: "Back Squat", Kind: KindStrength}, 0))
+	assert.Equal(t, 3.5, MET(Activity{Name: "Barbell Curl", Kind: KindStrength}, 0))
+	assert.Equal(t, 3.8, MET(Activity{Name: "Plank", Kind: KindTimed}, 60))
+	assert.Equal(t, 11.8, MET(Activity{Name: "Jump Rope", Kind: KindCardio}, 600))
+	assert.Equal(t, 7.0, MET(Activity{Name: "Battle Ropes", Kind: KindCardio}, 600))
+
+	// 10 km in 50 minutes is 12 km/h, between 11.3 km/h (11.0) and 12.1 km/h (11.8)
+	run := Activity{Name: "Running", Kind: KindCardio, DistanceMeters: 10000}
+	assert.InDelta(t, 11.7, MET(run, 3000), 0.01)
+	assert.Equal(t, 9.8, MET(Activity{Name: "Running", Kind: KindCardio}, 3000), "without a distance")
+	assert.Equal(t, 19.0, MET(run, 1500), "faster than the table holds")
+}
+
+func TestEstimate(t *testing.T) {
+	profile := Profile{WeightKg: 80}
+
+	_, ok := Estimate(Profile{}, 3600, nil)
+	assert.False(t, ok, "no body weight")
+	_, ok = Estimate(profile, 0, []Activity{{Name: "Bench Press", Kind: KindStrength, Sets: 3}})
+	assert.False(t, ok, "nothing has a duration")
+
+	kcal, ok := Estimate(profile, 3600, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 344, kcal, "4.3 MET for an hour")
+
+	// a 60 minute session: a 10 minute plank block, then the other 50
+	// minutes shared 3:2 between squats and curls
+	kcal, ok = Estimate(profile, 3600, []Activity{
+		{Name: "Plank", Kind: KindTimed, Sets: 2, Seconds: 600},
+		{Name: "Back Squat", Kind: KindStrength, Sets: 3},
+		{Name: "Barbell Curl", Kind: KindStrength, Sets: 2},
+	})
+	assert.True(t, ok)
+	// 3.8*80/6 + 6.0*80/2 + 3.5*80*(20/60)
+	assert.Equal(t, 384, kcal)
+
+	// heart rate wins over the MET value once age and sex are known
+	run := Activity{Name: "Running", Kind: KindCardio, Seconds: 3600, DistanceMeters: 10000, AvgHeartRate: 150}
+	kcal, _ = Estimate(profile, 3600, []Activity{run})
+	assert.Equal(t, 799, kcal, "10 km/h at 9.99 MET")
+	kcal, _ = Estimate(Profile{WeightKg: 80, Age: 35, Sex: SexMale}, 3600, []Activity{run})
+	assert.Equal(t, 896, kcal)
+}
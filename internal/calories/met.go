@@ -0,0 +1,112 @@
+package calories
+
+import (
+	"sort"
+	"strings"
+)
+
+// MET values from the 2011 Compendium of Physical Activities. One MET is the
+// energy spent sitting quietly, about 1 kcal per kilogram per hour.
+const (
+	// workoutMET costs a workout logged without any exercises, as general
+	// circuit training.
+	workoutMET = 4.3
+	// resistance training, 8 to 15 reps of multiple exercises
+	strengthMET = 3.5
+	// squats, deadlifts, Olympic lifts and the like, vigorous effort
+	heavyStrengthMET = 6.0
+	// planks, holds and other calisthenics done for time
+	timedMET = 3.8
+	// cardio the table does not know
+	cardioMET = 7.0
+)
+
+// speedPoint is the MET value of an activity at a speed in km/h.
+type speedPoint struct {
+	kmh float64
+	met float64
+}
+
+// cardioActivity is a row of the cardio table. With speeds, the MET value is
+// interpolated from the pace of the entry; without, or without a pace, met
+// applies.
+type cardioActivity struct {
+	keywords []string
+	met      float64
+	speeds   []speedPoint
+}
+
+// cardioActivities is searched in order, so more specific keywords come
+// before the ones they contain.
+var cardioActivities = []cardioActivity{
+	{keywords: []string{"jump rope", "skipping"}, met: 11.8},
+	{keywords: []string{"burpee"}, met: 8.0},
+	{keywords: []string{"rowing", "rower", "erg"}, met: 7.0},
+	{keywords: []string{"elliptical", "cross trainer"}, met: 5.0},
+	{keywords: []string{"stair", "step mill", "stepmill"}, met: 9.0},
+	{keywords: []string{"hike", "hiking"}, met: 6.0},
+	{keywords: []string{"swim"}, met: 7.0},
+	{keywords: []string{"ski"}, met: 9.0},
+	{keywords: []string{"walk"}, met: 3.5, speeds: []speedPoint{
+		{3.2, 2.8}, {4.0, 3.0}, {4.8, 3.5}, {5.6, 4.3}, {6.4, 5.0}, {7.2, 7.0},
+	}},
+	{keywords: []string{"run", "jog", "treadmill"}, met: 9.8, speeds: []speedPoint{
+		{6.4, 6.0}, {8.0, 8.3}, {8.4, 9.0}, {9.7, 9.8}, {10.8, 10.5}, {11.3, 11.0},
+		{12.1, 11.8}, {13.8, 12.3}, {14.5, 12.8}, {16.1, 14.5}, {17.7, 16.0}, {19.3, 19.0},
+	}},
+	{keywords: []string{"cycl", "bike", "biking", "spin"}, met: 7.5, speeds: []speedPoint{
+		{16.0, 4.0}, {17.7, 6.8}, {20.9, 8.0}, {24.1, 10.0}, {28.0, 12.0}, {32.2, 15.8},
+	}},
+}
+
+// heavyKeywords mark the lifts costed at heavyStrengthMET.
+var heavyKeywords = []string{"squat", "deadlift", "clean", "snatch", "jerk", "thruster", "swing", "lunge", "leg press"}
+
+// MET returns the MET value of an activity lasting the seconds, from the
+// kind and the keywords of its name.
+func MET(a Activity, seconds int) float64 {
+	name := strings.ToLower(a.Name)
+	switch a.Kind {
+	case KindCardio:
+		for _, c := range cardioActivities {
+			if !containsAny(name, c.keywords) {
+				continue
+			}
+			if len(c.speeds) > 0 && a.DistanceMeters > 0 && seconds > 0 {
+				return interpolate(c.speeds, a.DistanceMeters/1000/(float64(seconds)/3600))
+			}
+			return c.met
+		}
+		return cardioMET
+	case KindTimed:
+		return timedMET
+	default:
+		if containsAny(name, heavyKeywords) {
+			return heavyStrengthMET
+		}
+		return strengthMET
+	}
+}
+
+func containsAny(s string, keywords []string) bool {
+	for _, k := range keywords {
+		if strings.Contains(s, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// interpolate reads the MET value at the speed off the points, holding the
+// first and last values beyond them.
+func interpolate(points []speedPoint, kmh float64) float64 {
+	i := sort.Search(len(points), func(i int) bool { return points[i].kmh >= kmh })
+	switch i {
+	case 0:
+		return points[0].met
+	case len(points):
+		return points[len(points)-1].met
+	}
+	lo, hi := points[i-1], points[i]
+	return lo.met + (hi.met-lo.met)*(kmh-lo.kmh)/(hi.kmh-lo.kmh)
+}
@@ -0,0 +1,302 @@
+package csvimport
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+)
+
+// strongAdapter reads Strong's export: one row per set, sets of a workout
+// sharing its date and name, "W", "D" and "F" set orders marking warm-up,
+// drop and failure sets, and rest timers mixed in as rows of their own.
+type strongAdapter struct{}
+
+var strongSetTypes = map[string]string{
+	"w": store.SetTypeWarmup,
+	"d": store.SetTypeDrop,
+	"f": store.SetTypeFailure,
+}
+
+// strongDuration reads workout durations such as "1h 5m".
+var strongDuration = regexp.MustCompile(`(\d+)\s*([hms])`)
+
+var strongDurationUnits = map[string]time.Duration{"h": time.Hour, "m": time.Minute, "s": time.Second}
+
+func (strongAdapter) read(rec record, opts *Options) (*row, error) {
+	order := strings.ToLower(rec.get("set order"))
+	if order == "rest timer" {
+		return nil, errSkip
+	}
+
+	date := rec.get("date")
+	start, err := parseTime(date, opts.Location, "2006-01-02 15:04:05", "2006-01-02 15:04")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &row{
+		key:           date + "|" + rec.get("workout name"),
+		title:         rec.get("workout name"),
+		notes:         rec.get("workout notes"),
+		start:         start,
+		exercise:      rec.get("exercise name"),
+		exerciseNotes: rec.get("notes"),
+		set:           store.WorkoutSet{SetType: store.SetTypeWorking, Completed: true},
+	}
+	if r.exercise == "" {
+		return nil, errors.New("exercise name is required")
+	}
+	for _, m := range strongDuration.FindAllStringSubmatch(rec.get("duration"), -1) {
+		n, _ := strconv.Atoi(m[1])
+		r.duration += time.Duration(n) * strongDurationUnits[m[2]]
+	}
+
+	if setType, ok := strongSetTypes[order]; ok {
+		r.set.SetType = setType
+	} else if _, err := strconv.Atoi(order); err != nil {
+		return nil, fmt.Errorf("unknown set order %q", rec.get("set order"))
+	}
+
+	wUnit := opts.WeightUnit
+	if s := rec.get("weight unit"); s != "" {
+		var ok bool
+		if wUnit, ok = weightUnit(s); !ok {
+			return nil, fmt.Errorf("unknown weight unit %q", s)
+		}
+	}
+	dUnit := opts.DistanceUnit
+	if s := rec.get("distance unit"); s != "" {
+		var ok bool
+		if dUnit, ok = distanceUnit(s); !ok {
+			return nil, fmt.Errorf("unknown distance unit %q", s)
+		}
+	}
+	return r, readSet(r, rec, setColumns{
+		weight: "weight", weightUnit: wUnit,
+		reps:     "reps",
+		distance: "distance", distanceUnit: dUnit,
+		seconds: "seconds",
+		rpe:     "rpe",
+	})
+}
+
+func (strongAdapter) title(rows []*row) string {
+	return "Strong Workout"
+}
+
+// hevyAdapter reads Hevy's export, which names the unit in the weight and
+// distance column headers and ties supersets together by superset_id.
+type hevyAdapter struct{}
+
+var hevySetTypes = map[string]string{
+	"normal":  store.SetTypeWorking,
+	"warmup":  store.SetTypeWarmup,
+	"dropset": store.SetTypeDrop,
+	"failure": store.SetTypeFailure,
+}
+
+var hevyLayouts = []string{"2 Jan 2006, 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+func (hevyAdapter) read(rec record, opts *Options) (*row, error) {
+	startText := rec.get("start_time")
+	start, err := parseTime(startText, opts.Location, hevyLayouts...)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &row{
+		key:           startText + "|" + rec.get("title"),
+		title:         rec.get("title"),
+		notes:         rec.get("description"),
+		start:         start,
+		exercise:      rec.get("exercise_title"),
+		exerciseNotes: rec.get("exercise_notes"),
+		superset:      rec.get("superset_id"),
+		set:           store.WorkoutSet{Completed: true},
+	}
+	if r.exercise == "" {
+		return nil, errors.New("exercise_title is required")
+	}
+	if s := rec.get("end_time"); s != "" {
+		end, err := parseTime(s, opts.Location, hevyLayouts...)
+		if err != nil {
+			return nil, err
+		}
+		r.end = &end
+	}
+
+	setType, ok := hevySetTypes[strings.ToLower(rec.get("set_type"))]
+	if !ok {
+		return nil, fmt.Errorf("unknown set_type %q", rec.get("set_type"))
+	}
+	r.set.SetType = setType
+
+	columns := setColumns{
+		weight: "weight_kg", weightUnit: UnitKilograms,
+		reps:     "reps",
+		distance: "distance_km", distanceUnit: "km",
+		seconds: "duration_seconds",
+		rpe:     "rpe",
+	}
+	if _, ok := rec.cols["weight_lbs"]; ok {
+		columns.weight, columns.weightUnit = "weight_lbs", UnitPounds
+	}
+	if _, ok := rec.cols["distance_miles"]; ok {
+		columns.distance, columns.distanceUnit = "distance_miles", "mi"
+	}
+	return r, readSet(r, rec, columns)
+}
+
+func (hevyAdapter) title(rows []*row) string {
+	return "Hevy Workout"
+}
+
+// fitNotesAdapter reads FitNotes' export, which has dates without a time,
+// so a day's sets make one workout, and names its weight unit in the
+// header, as in "Weight (kgs)".
+type fitNotesAdapter struct{}
+
+var fitNotesTime = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{2})$`)
+
+func (fitNotesAdapter) read(rec record, opts *Options) (*row, error) {
+	date := rec.get("date")
+	start, err := parseTime(date, opts.Location, "2006-01-02")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &row{
+		key:           date,
+		start:         start,
+		category:      rec.get("category"),
+		exercise:      rec.get("exercise"),
+		exerciseNotes: rec.get("comment"),
+		set:           store.WorkoutSet{SetType: store.SetTypeWorking, Completed: true},
+	}
+	if r.exercise == "" {
+		return nil, errors.New("exercise is required")
+	}
+
+	columns := setColumns{reps: "reps", distance: "distance"}
+	for name := range rec.cols {
+		inner, ok := strings.CutPrefix(name, "weight (")
+		if !ok {
+			continue
+		}
+		unit, ok := weightUnit(strings.TrimSuffix(inner, ")"))
+		if !ok {
+			return nil, fmt.Errorf("unknown weight unit in column %q", name)
+		}
+		columns.weight, columns.weightUnit = name, unit
+	}
+	if s := rec.get("distance unit"); s != "" {
+		var ok bool
+		if columns.distanceUnit, ok = distanceUnit(s); !ok {
+			return nil, fmt.Errorf("unknown distance unit %q", s)
+		}
+	} else {
+		columns.distanceUnit = opts.DistanceUnit
+	}
+
+	err = readSet(r, rec, columns)
+	if err != nil {
+		return nil, err
+	}
+	if s := rec.get("time"); s != "" {
+		m := fitNotesTime.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf("time %q is not h:mm:ss", s)
+		}
+		h, _ := strconv.Atoi("0" + m[1])
+		mins, _ := strconv.Atoi(m[2])
+		secs, _ := strconv.Atoi(m[3])
+		if total := h*3600 + mins*60 + secs; total > 0 && r.set.Reps == nil {
+			r.set.DurationSeconds = &total
+		}
+	}
+	if r.set.Reps == nil && r.set.DurationSeconds == nil && !r.cardio() {
+		return nil, errors.New("the set has no reps, time or distance")
+	}
+	return r, nil
+}
+
+// title names a FitNotes workout after the categories it trained, as in
+// "Chest, Triceps".
+func (fitNotesAdapter) title(rows []*row) string {
+	var categories []string
+	for _, r := range rows {
+		if r.category != "" && !containsFold(categories, r.category) {
+			categories = append(categories, r.category)
+		}
+	}
+	if len(categories) == 0 {
+		return "FitNotes Workout"
+	}
+	return strings.Join(categories, ", ")
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// setColumns names the columns a format keeps a set's numbers in.
+type setColumns struct {
+	weight       string
+	weightUnit   string
+	reps         string
+	distance     string
+	distanceUnit string
+	seconds      string
+	rpe          string
+}
+
+// readSet fills in the set of a row: reps make it a strength set, a
+// distance a cardio row, and a time alone a timed set.
+func readSet(r *row, rec record, c setColumns) error {
+	reps, err := rec.count(c.reps)
+	if err != nil {
+		return err
+	}
+	var weight, distance, secs, rpe *float64
+	for _, field := range []struct {
+		column string
+		value  **float64
+	}{{c.weight, &weight}, {c.distance, &distance}, {c.seconds, &secs}, {c.rpe, &rpe}} {
+		if field.column == "" {
+			continue
+		}
+		*field.value, err = rec.positive(field.column)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.set.RPE = rpe
+	switch {
+	case distance != nil:
+		r.distance, r.distanceUnit = distance, c.distanceUnit
+		r.set.DurationSeconds = seconds(secs)
+	case reps != nil:
+		r.set.Reps = reps
+		r.set.Weight = weightKg(weight, c.weightUnit)
+	case secs != nil:
+		r.set.DurationSeconds = seconds(secs)
+		r.set.Weight = weightKg(weight, c.weightUnit)
+	case c.seconds == "":
+		// FitNotes keeps the time in its own format; the caller reads it
+		r.set.Weight = weightKg(weight, c.weightUnit)
+	default:
+		return errors.New("the set has no reps, time or distance")
+	}
+	return nil
+}
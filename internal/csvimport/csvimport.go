@@ -0,0 +1,511 @@
+// Package csvimport reads the workout history other apps export as CSV and
+// turns it into workouts. Each supported app has an adapter that reads its
+// columns into rows; the rows of one workout are then grouped into entries
+// the same way for every app.
+package csvimport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+)
+
+const (
+	FormatStrong   = "strong"
+	FormatHevy     = "hevy"
+	FormatFitNotes = "fitnotes"
+)
+
+var Formats = []string{FormatStrong, FormatHevy, FormatFitNotes}
+
+const (
+	UnitKilograms = "kg"
+	UnitPounds    = "lb"
+	poundKg       = 0.45359237
+)
+
+// Options covers what an export leaves unsaid.
+type Options struct {
+	// Location is the time zone the export's local timestamps are read in.
+	Location *time.Location
+	// WeightUnit is the unit of Strong's weight column, which the export
+	// does not name. Hevy and FitNotes put the unit in the column header.
+	WeightUnit string
+	// DistanceUnit is the unit of Strong's distance column.
+	DistanceUnit string
+	// ExerciseNames renames exercises before they are matched against the
+	// exercise catalog, for names the fuzzy matching gets wrong.
+	ExerciseNames map[string]string
+}
+
+// FormatError rejects the file as a whole.
+type FormatError struct {
+	Msg string
+}
+
+func (e *FormatError) Error() string {
+	return e.Msg
+}
+
+// Workout is one workout read from the export, with the line of its first
+// row so errors found later can point into the file.
+type Workout struct {
+	Row         int
+	Fingerprint string
+	Workout     *store.Workout
+}
+
+// Result is everything read from an export. Rows that could not be read
+// are listed in Errors and left out of the workouts.
+type Result struct {
+	Format   string
+	Rows     int
+	Workouts []*Workout
+	Errors   []store.ImportRowError
+}
+
+// row is one set as an adapter reads it.
+type row struct {
+	line int
+	// key tells the workouts apart; rows with the same key belong to one
+	// workout.
+	key      string
+	title    string
+	notes    string
+	start    time.Time
+	end      *time.Time
+	duration time.Duration
+	category string
+
+	exercise      string
+	exerciseNotes string
+	superset      string
+	set           store.WorkoutSet
+	// a row with a distance is cardio; its time is in set.DurationSeconds
+	distance     *float64
+	distanceUnit string
+}
+
+func (r *row) cardio() bool {
+	return r.distance != nil
+}
+
+// errSkip marks rows that are not sets, such as Strong's rest timers.
+var errSkip = errors.New("skip")
+
+type adapter interface {
+	// read reads one record; it returns errSkip for rows that are not sets.
+	read(rec record, opts *Options) (*row, error)
+	// title names a workout the export left untitled.
+	title(rows []*row) string
+}
+
+// columns maps lowercased header names to their position.
+type columns map[string]int
+
+type record struct {
+	cols   columns
+	fields []string
+	comma  rune
+}
+
+func (r record) get(name string) string {
+	i, ok := r.cols[name]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+	return strings.TrimSpace(r.fields[i])
+}
+
+// number reads an optional number; exports written with a semicolon as the
+// separator use a decimal comma.
+func (r record) number(name string) (*float64, error) {
+	s := r.get(name)
+	if s == "" {
+		return nil, nil
+	}
+	if r.comma == ';' {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("%s %q is not a number", name, s)
+	}
+	return &v, nil
+}
+
+// count reads an optional whole number such as reps, which some exports
+// write as "8.0". Zero reads as nothing.
+func (r record) count(name string) (*int, error) {
+	v, err := r.number(name)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if *v != math.Trunc(*v) || *v < 0 {
+		return nil, fmt.Errorf("%s %q is not a whole number", name, r.get(name))
+	}
+	if *v == 0 {
+		return nil, nil
+	}
+	n := int(*v)
+	return &n, nil
+}
+
+// positive reads an optional number where zero means nothing was logged.
+func (r record) positive(name string) (*float64, error) {
+	v, err := r.number(name)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if *v < 0 {
+		return nil, fmt.Errorf("%s cannot be negative", name)
+	}
+	if *v == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+var adapters = map[string]adapter{
+	FormatStrong:   strongAdapter{},
+	FormatHevy:     hevyAdapter{},
+	FormatFitNotes: fitNotesAdapter{},
+}
+
+// required lists the columns each format cannot do without, which also
+// tells the formats apart.
+var required = map[string][]string{
+	FormatStrong:   {"date", "workout name", "exercise name", "set order", "weight", "reps"},
+	FormatHevy:     {"title", "start_time", "exercise_title", "set_type", "reps"},
+	FormatFitNotes: {"date", "exercise", "category", "reps"},
+}
+
+// Parse reads an export. An empty format is detected from the header.
+func Parse(r io.Reader, format string, opts Options) (*Result, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.WeightUnit == "" {
+		opts.WeightUnit = UnitKilograms
+	}
+	if opts.DistanceUnit == "" {
+		opts.DistanceUnit = "km"
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, &FormatError{Msg: "the file is empty"}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, &FormatError{Msg: "the file is not a CSV file: " + err.Error()}
+	}
+	cols := columns{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	format, err = detect(cols, format)
+	if err != nil {
+		return nil, err
+	}
+	adapter := adapters[format]
+
+	result := &Result{Format: format, Errors: []store.ImportRowError{}}
+	var rows []*row
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &FormatError{Msg: fmt.Sprintf("line %d: malformed CSV: %v", parseErr.Line, parseErr.Err)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(fields) == 1 && strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+		result.Rows++
+
+		rw, err := adapter.read(record{cols: cols, fields: fields, comma: reader.Comma}, &opts)
+		if errors.Is(err, errSkip) {
+			continue
+		}
+		if err == nil && !rw.cardio() {
+			err = rw.set.Validate()
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, store.ImportRowError{Row: line, Message: err.Error()})
+			continue
+		}
+		rw.line = line
+		rw.exercise = exerciseName(rw.exercise, opts.ExerciseNames)
+		rows = append(rows, rw)
+	}
+
+	result.Workouts = build(rows, adapter)
+	return result, nil
+}
+
+func detect(cols columns, format string) (string, error) {
+	missing := func(f string) string {
+		for _, name := range required[f] {
+			if _, ok := cols[name]; !ok {
+				return name
+			}
+		}
+		return ""
+	}
+
+	if format != "" {
+		if !slices.Contains(Formats, format) {
+			return "", &FormatError{Msg: fmt.Sprintf("format must be one of %v", Formats)}
+		}
+		if name := missing(format); name != "" {
+			return "", &FormatError{Msg: fmt.Sprintf("the file is not a %s export: the %q column is missing", format, name)}
+		}
+		return format, nil
+	}
+
+	for _, f := range Formats {
+		if missing(f) == "" {
+			return f, nil
+		}
+	}
+	return "", &FormatError{Msg: fmt.Sprintf("the file is not an export of a supported app, expected one of %v", Formats)}
+}
+
+var equipmentSuffix = regexp.MustCompile(`^(.+?)\s*\(([^()]+)\)$`)
+
+// exerciseName applies the caller's renames, then turns the "Bench Press
+// (Barbell)" style of Strong and Hevy into "Barbell Bench Press", which is
+// how the exercise catalog names exercises.
+func exerciseName(name string, renames map[string]string) string {
+	for from, to := range renames {
+		if strings.EqualFold(strings.TrimSpace(from), name) {
+			return strings.TrimSpace(to)
+		}
+	}
+	if m := equipmentSuffix.FindStringSubmatch(name); m != nil {
+		return m[2] + " " + m[1]
+	}
+	return name
+}
+
+// build groups rows into workouts by key and, within a workout, runs of
+// rows for the same exercise into entries.
+func build(rows []*row, a adapter) []*Workout {
+	var keys []string
+	byKey := map[string][]*row{}
+	for _, r := range rows {
+		if _, ok := byKey[r.key]; !ok {
+			keys = append(keys, r.key)
+		}
+		byKey[r.key] = append(byKey[r.key], r)
+	}
+
+	workouts := make([]*Workout, 0, len(keys))
+	for _, key := range keys {
+		workouts = append(workouts, buildWorkout(byKey[key], a))
+	}
+	return workouts
+}
+
+func buildWorkout(rows []*row, a adapter) *Workout {
+	first := rows[0]
+	workout := &store.Workout{
+		Title:       first.title,
+		Description: first.notes,
+		PerformedAt: first.start,
+		Entries:     []store.WorkoutEntry{},
+	}
+	if workout.Title == "" {
+		workout.Title = a.title(rows)
+	}
+	end := first.end
+	if end == nil && first.duration > 0 {
+		t := first.start.Add(first.duration)
+		end = &t
+	}
+	if end != nil && end.After(first.start) {
+		start := first.start
+		workout.StartedAt, workout.EndedAt = &start, end
+	}
+
+	var supersets []string
+	var last *row
+	for _, r := range rows {
+		if last == nil || !sameEntry(last, r) {
+			workout.Entries = append(workout.Entries, store.WorkoutEntry{
+				ExerciseName: r.exercise,
+				OrderIndex:   len(workout.Entries) + 1,
+				Section:      store.SectionMain,
+			})
+			supersets = append(supersets, r.superset)
+		}
+		entry := &workout.Entries[len(workout.Entries)-1]
+		addRow(entry, r)
+		last = r
+	}
+	groupSupersets(workout.Entries, supersets)
+
+	return &Workout{Row: first.line, Fingerprint: Fingerprint(workout), Workout: workout}
+}
+
+func sameEntry(a, b *row) bool {
+	return a.exercise == b.exercise && a.superset == b.superset && a.cardio() == b.cardio() &&
+		a.distanceUnit == b.distanceUnit && (a.set.Reps == nil) == (b.set.Reps == nil)
+}
+
+func addRow(entry *store.WorkoutEntry, r *row) {
+	if r.exerciseNotes != "" && !strings.Contains(entry.Notes, r.exerciseNotes) {
+		if entry.Notes != "" {
+			entry.Notes += "\n"
+		}
+		entry.Notes += r.exerciseNotes
+	}
+
+	if !r.cardio() {
+		entry.SetLog = append(entry.SetLog, r.set)
+		return
+	}
+
+	// cardio has no set log, the rows add up to one distance and time
+	entry.Kind = store.EntryKindCardio
+	entry.Sets = 1
+	entry.DistanceUnit = r.distanceUnit
+	distance := *r.distance
+	if entry.Distance != nil {
+		distance += *entry.Distance
+	}
+	distance = math.Round(distance*1000) / 1000
+	entry.Distance = &distance
+	if r.set.DurationSeconds != nil {
+		seconds := *r.set.DurationSeconds
+		if entry.DurationSeconds != nil {
+			seconds += *entry.DurationSeconds
+		}
+		entry.DurationSeconds = &seconds
+	}
+}
+
+// groupSupersets turns runs of adjacent entries sharing a superset id into
+// superset groups labelled A, B, C and so on. A superset of one exercise is
+// just an exercise.
+func groupSupersets(entries []store.WorkoutEntry, ids []string) {
+	label := 'A'
+	for i := 0; i < len(entries); {
+		j := i + 1
+		for j < len(entries) && ids[i] != "" && ids[j] == ids[i] {
+			j++
+		}
+		if j-i >= 2 {
+			rounds := 1
+			for k := i; k < j; k++ {
+				rounds = max(rounds, len(entries[k].SetLog))
+			}
+			for k := i; k < j; k++ {
+				entries[k].Group = &store.EntryGroup{Label: string(label), Type: store.GroupSuperset, Rounds: rounds}
+			}
+			if label < 'Z' {
+				label++
+			}
+		}
+		i = j
+	}
+}
+
+// Fingerprint identifies a workout across imports, whichever app it was
+// exported from: the minute it started and its title.
+func Fingerprint(w *store.Workout) string {
+	sum := sha256.Sum256([]byte(w.PerformedAt.UTC().Truncate(time.Minute).Format(time.RFC3339) + "|" +
+		strings.ToLower(strings.TrimSpace(w.Title))))
+	return hex.EncodeToString(sum[:])
+}
+
+// weightKg converts a weight to kilograms, rounded to the gram.
+func weightKg(v *float64, unit string) *float64 {
+	if v == nil {
+		return nil
+	}
+	kg := *v
+	if unit == UnitPounds {
+		kg *= poundKg
+	}
+	kg = math.Round(kg*1000) / 1000
+	return &kg
+}
+
+// weightUnit reads the unit names exports use.
+func weightUnit(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "kg", "kgs", "kilograms":
+		return UnitKilograms, true
+	case "lb", "lbs", "pounds":
+		return UnitPounds, true
+	}
+	return "", false
+}
+
+// distanceUnit reads the unit names exports use into the units workout
+// entries store.
+func distanceUnit(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "km", "kms", "kilometers", "kilometres":
+		return "km", true
+	case "m", "meters", "metres":
+		return "m", true
+	case "mi", "mile", "miles":
+		return "mi", true
+	case "yd", "yds", "yards":
+		return "yd", true
+	}
+	return "", false
+}
+
+// parseTime tries the layouts an app has used over the years.
+func parseTime(s string, loc *time.Location, layouts ...string) (time.Time, error) {
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func seconds(v *float64) *int {
+	if v == nil {
+		return nil
+	}
+	s := int(math.Round(*v))
+	return &s
+}
@@ -0,0 +1,179 @@
+package csvimport
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var berlin, _ = time.LoadLocation("Europe/Berlin")
+
+func validate(t *testing.T, w *store.Workout) {
+	t.Helper()
+	require.NoError(t, w.ResolveTimes())
+	for i := range w.Entries {
+		require.NoError(t, w.Entries[i].SummarizeSets())
+		require.NoError(t, w.Entries[i].ValidateKind())
+	}
+	require.NoError(t, store.ValidateGroups(w.Entries))
+}
+
+func TestParseStrong(t *testing.T) {
+	export := `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+2024-03-04 18:02:11,Push Day,1h 5m,Bench Press (Barbell),W,45,10,0,0,,felt strong,
+2024-03-04 18:02:11,Push Day,1h 5m,Bench Press (Barbell),1,225,5,0,0,paused,felt strong,8
+2024-03-04 18:02:11,Push Day,1h 5m,Bench Press (Barbell),Rest Timer,0,0,0,90,,felt strong,
+2024-03-04 18:02:11,Push Day,1h 5m,Bench Press (Barbell),2,225,5,0,0,,felt strong,8.5
+2024-03-04 18:02:11,Push Day,1h 5m,Plank,1,0,0,0,60,,felt strong,
+2024-03-04 18:02:11,Push Day,1h 5m,Running (Treadmill),1,0,0,3.2,1200,,felt strong,
+2024-03-06 07:30:00,Legs,45m,Squat (Barbell),1,275,five,0,0,,,
+2024-03-06 07:30:00,Legs,45m,Squat (Barbell),2,275,5,0,0,,,
+`
+	result, err := Parse(strings.NewReader(export), "", Options{Location: berlin, WeightUnit: UnitPounds, DistanceUnit: "mi"})
+	require.NoError(t, err)
+	assert.Equal(t, FormatStrong, result.Format)
+	assert.Equal(t, 8, result.Rows)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, store.ImportRowError{Row: 8, Message: `reps "five" is not a number`}, result.Errors[0])
+
+	require.Len(t, result.Workouts, 2)
+	push := result.Workouts[0]
+	assert.Equal(t, 2, push.Row)
+	w := push.Workout
+	validate(t, w)
+	assert.Equal(t, "Push Day", w.Title)
+	assert.Equal(t, "felt strong", w.Description)
+	assert.Equal(t, time.Date(2024, 3, 4, 17, 2, 11, 0, time.UTC), w.PerformedAt.UTC())
+	assert.Equal(t, 65, w.DurationMinutes)
+
+	require.Len(t, w.Entries, 3)
+	bench := w.Entries[0]
+	assert.Equal(t, "Barbell Bench Press", bench.ExerciseName)
+	assert.Equal(t, "paused", bench.Notes)
+	require.Len(t, bench.SetLog, 3, "the rest timer is not a set")
+	assert.Equal(t, store.SetTypeWarmup, bench.SetLog[0].SetType)
+	assert.Equal(t, 102.058, *bench.SetLog[1].Weight, "pounds are converted to kilograms")
+	assert.Equal(t, 8.5, *bench.SetLog[2].RPE)
+	assert.Equal(t, 2, bench.Sets)
+
+	plank := w.Entries[1]
+	assert.Equal(t, store.EntryKindTimed, plank.Kind)
+	assert.Nil(t, plank.SetLog[0].Weight)
+
+	run := w.Entries[2]
+	assert.Equal(t, store.EntryKindCardio, run.Kind)
+	assert.Equal(t, 3.2, *run.Distance)
+	assert.Equal(t, "mi", run.DistanceUnit)
+	assert.Equal(t, 1200, *run.DurationSeconds)
+
+	legs := result.Workouts[1].Workout
+	validate(t, legs)
+	require.Len(t, legs.Entries[0].SetLog, 1)
+}
+
+func TestParseHevy(t *testing.T) {
+	export := `"title","start_time","end_time","description","exercise_title","superset_id","exercise_notes","set_index","set_type","weight_lbs","reps","distance_miles","duration_seconds","rpe"
+"Upper","15 Jan 2024, 08:30","15 Jan 2024, 09:40","","Pull Up","0","",0,"normal",,8,,,
+"Upper","15 Jan 2024, 08:30","15 Jan 2024, 09:40","","Pull Up","0","",1,"failure",,6,,,
+"Upper","15 Jan 2024, 08:30","15 Jan 2024, 09:40","","Bench Press (Dumbbell)","0","slow eccentric",0,"normal",60,10,,,
+"Upper","15 Jan 2024, 08:30","15 Jan 2024, 09:40","","Face Pull","","",0,"warmup",30,15,,,
+"Upper","15 Jan 2024, 08:30","15 Jan 2024, 09:40","","Face Pull","","",1,"superset",30,15,,,
+`
+	result, err := Parse(strings.NewReader(export), FormatHevy, Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 6, result.Errors[0].Row)
+	assert.Equal(t, `unknown set_type "superset"`, result.Errors[0].Message)
+
+	require.Len(t, result.Workouts, 1)
+	w := result.Workouts[0].Workout
+	validate(t, w)
+	assert.Equal(t, 70, w.DurationMinutes)
+
+	require.Len(t, w.Entries, 3)
+	pullUps, bench, facePull := w.Entries[0], w.Entries[1], w.Entries[2]
+	require.NotNil(t, pullUps.Group)
+	assert.Equal(t, "A", pullUps.Group.Label)
+	assert.Equal(t, store.GroupSuperset, pullUps.Group.Type)
+	assert.Equal(t, 2, pullUps.Group.Rounds)
+	assert.Equal(t, *pullUps.Group, *bench.Group)
+	assert.Equal(t, store.SetTypeFailure, pullUps.SetLog[1].SetType)
+	assert.Equal(t, "Dumbbell Bench Press", bench.ExerciseName)
+	assert.Equal(t, "slow eccentric", bench.Notes)
+	assert.Equal(t, 27.216, *bench.SetLog[0].Weight)
+	assert.Nil(t, facePull.Group)
+}
+
+func TestParseFitNotes(t *testing.T) {
+	export := `Date;Exercise;Category;Weight (kgs);Reps;Distance;Distance Unit;Time;Comment
+2024-05-01;Flat Barbell Bench Press;Chest;80,5;8;;;;
+2024-05-01;Flat Barbell Bench Press;Chest;80,5;7;;;;last rep grindy
+2024-05-01;Rope Push Down;Triceps;25;12;;;;
+2024-05-01;Cycling;Cardio;;;12,5;km;0:35:10;
+2024-05-03;Plank;Abs;;;;;1:30;
+2024-05-03;Plank;Abs;;;;;;
+`
+	result, err := Parse(strings.NewReader(export), "", Options{
+		Location:      berlin,
+		ExerciseNames: map[string]string{"rope push down": "Triceps Pushdown"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, FormatFitNotes, result.Format)
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, store.ImportRowError{Row: 7, Message: "the set has no reps, time or distance"}, result.Errors[0])
+
+	require.Len(t, result.Workouts, 2)
+	w := result.Workouts[0].Workout
+	validate(t, w)
+	assert.Equal(t, "Chest, Triceps, Cardio", w.Title)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, berlin), w.PerformedAt)
+	require.Len(t, w.Entries, 3)
+	assert.Equal(t, 80.5, *w.Entries[0].Weight)
+	assert.Equal(t, "last rep grindy", w.Entries[0].Notes)
+	assert.Equal(t, "Triceps Pushdown", w.Entries[1].ExerciseName)
+	assert.Equal(t, 12.5, *w.Entries[2].Distance)
+	assert.Equal(t, 2110, *w.Entries[2].DurationSeconds)
+
+	plank := result.Workouts[1].Workout
+	validate(t, plank)
+	assert.Equal(t, 90, *plank.Entries[0].DurationSeconds)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		export string
+		format string
+		want   string
+	}{
+		{"empty", "\n", "", "the file is empty"},
+		{"unknown app", "a,b,c\n1,2,3\n", "", "the file is not an export of a supported app, expected one of [strong hevy fitnotes]"},
+		{"wrong format", "Date,Exercise,Category,Reps\n", FormatStrong, `the file is not a strong export: the "workout name" column is missing`},
+		{"unknown format", "Date,Exercise,Category,Reps\n", "jefit", "format must be one of [strong hevy fitnotes]"},
+		{"malformed", "Date,Exercise,Category,Reps\n2024-05-01,\"Squat,Legs,5\n", "", "line 2: malformed CSV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.export), tt.format, Options{})
+			require.Error(t, err)
+			var formatErr *FormatError
+			assert.ErrorAs(t, err, &formatErr)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	performedAt := time.Date(2024, 3, 4, 18, 2, 11, 0, berlin)
+	a := &store.Workout{Title: "Push Day", PerformedAt: performedAt}
+	b := &store.Workout{Title: " push day ", PerformedAt: performedAt.Add(30 * time.Second).UTC()}
+	c := &store.Workout{Title: "Push Day", PerformedAt: performedAt.Add(24 * time.Hour)}
+
+	assert.Equal(t, Fingerprint(a), Fingerprint(b), "seconds, time zones and case do not matter")
+	assert.NotEqual(t, Fingerprint(a), Fingerprint(c))
+	assert.Len(t, Fingerprint(a), 64)
+}
@@ -0,0 +1,566 @@
+package fit
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/activity"
+)
+
+// Session holds the totals the device worked out for one sport of the
+// activity; a triathlon has three, almost everything else one.
+type Session struct {
+	Sport          string
+	StartedAt      time.Time
+	ElapsedSeconds float64
+	TimerSeconds   float64
+	DistanceMeters *float64
+	AscentMeters   *float64
+	DescentMeters  *float64
+	AvgHeartRate   *int
+	MaxHeartRate   *int
+	AvgCadence     *int
+	AvgPower       *int
+	MaxPower       *int
+	Calories       *int
+	strength       bool
+}
+
+// Set is one set of a strength activity. Rest periods between sets are
+// sets too, with Active false.
+type Set struct {
+	StartedAt       time.Time
+	DurationSeconds *float64
+	Active          bool
+	Reps            *int
+	WeightKg        *float64
+	// Category is the FIT exercise category, -1 when the watch did not
+	// record one.
+	Category int
+}
+
+// Activity is a decoded activity file. Its records become the points of
+// Track, so the same summary as for GPX and TCX files can be worked out.
+type Activity struct {
+	Track    activity.Track
+	Sessions []Session
+	Laps     []activity.Lap
+	Sets     []Set
+}
+
+type fieldDef struct {
+	num  byte
+	size byte
+	base byte
+}
+
+type definition struct {
+	global    uint16
+	bigEndian bool
+	fields    []fieldDef
+	devSize   int
+}
+
+// message holds the valid numeric and string fields of a data message.
+type message struct {
+	offset  int
+	values  map[byte]int64
+	strings map[byte]string
+}
+
+func (m *message) int(num byte) *int {
+	v, ok := m.values[num]
+	if !ok {
+		return nil
+	}
+	i := int(v)
+	return &i
+}
+
+// sensor reads a heart rate, cadence or power field; like the XML formats,
+// a zero reading means no reading.
+func (m *message) sensor(num byte) *int {
+	v := m.int(num)
+	if v == nil || *v == 0 {
+		return nil
+	}
+	return v
+}
+
+// scaled reads a field stored as value*scale + offset*scale.
+func (m *message) scaled(num byte, scale, offset float64) *float64 {
+	v, ok := m.values[num]
+	if !ok {
+		return nil
+	}
+	f := float64(v)/scale - offset
+	return &f
+}
+
+func (m *message) time(num byte) (time.Time, bool) {
+	v, ok := m.values[num]
+	if !ok {
+		return time.Time{}, false
+	}
+	return timestamp(v), true
+}
+
+// invalids holds the raw value that marks a field as not recorded, by base
+// type number. Strings and floats are not read as numbers.
+var invalids = [...]uint64{
+	0xFF, 0x7F, 0xFF, 0x7FFF, 0xFFFF, 0x7FFFFFFF, 0xFFFFFFFF, 0, 0, 0,
+	0, 0, 0, 0xFF, 0x7FFFFFFFFFFFFFFF, 0xFFFFFFFFFFFFFFFF, 0,
+}
+
+// readValue reads the first element of a numeric field, reporting false
+// when the field is a string, a float or holds the invalid value.
+func readValue(b []byte, base byte, bigEndian bool) (int64, bool) {
+	num := int(base & 0x1F)
+	if num >= len(baseSizes) || num == 7 || num == 8 || num == 9 {
+		return 0, false
+	}
+	size := baseSizes[num]
+	if len(b) < size {
+		return 0, false
+	}
+
+	bo := order(bigEndian)
+	var raw uint64
+	switch size {
+	case 1:
+		raw = uint64(b[0])
+	case 2:
+		raw = uint64(bo.Uint16(b))
+	case 4:
+		raw = uint64(bo.Uint32(b))
+	case 8:
+		raw = bo.Uint64(b)
+	}
+	if raw == invalids[num] {
+		return 0, false
+	}
+
+	switch num {
+	case 1:
+		return int64(int8(raw)), true
+	case 3:
+		return int64(int16(raw)), true
+	case 5:
+		return int64(int32(raw)), true
+	}
+	return int64(raw), true
+}
+
+// Decode reads a FIT activity file, checking its CRCs and every message
+// header on the way.
+func Decode(data []byte) (*Activity, error) {
+	if !IsFIT(data) {
+		return nil, &FormatError{Msg: "the file is not a FIT file"}
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || headerSize > len(data) {
+		return nil, &FormatError{Msg: fmt.Sprintf("invalid header size %d", headerSize)}
+	}
+	if headerSize >= 14 {
+		sum := binary.LittleEndian.Uint16(data[12:14])
+		if sum != 0 && sum != crc(data[:12]) {
+			return nil, &FormatError{Offset: 12, Msg: "the header checksum does not match"}
+		}
+	}
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := headerSize + dataSize
+	if end+2 > len(data) {
+		return nil, &FormatError{Offset: len(data), Msg: fmt.Sprintf("the file is truncated, the header announces %d bytes of data", dataSize)}
+	}
+	if binary.LittleEndian.Uint16(data[end:end+2]) != crc(data[:end]) {
+		return nil, &FormatError{Offset: end, Msg: "the file checksum does not match, the file is damaged"}
+	}
+
+	d := decoder{data: data[:end], pos: headerSize}
+	a := &Activity{Track: activity.Track{Format: activity.FormatFIT}}
+	fileType := int64(-1)
+	for d.pos < end {
+		global, msg, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			continue
+		}
+
+		switch global {
+		case mesgFileID:
+			v, ok := msg.values[0]
+			if !ok {
+				return nil, &FormatError{Offset: msg.offset, Msg: "the file_id message has no file type"}
+			}
+			fileType = v
+		case mesgSession:
+			a.Sessions = append(a.Sessions, readSession(msg))
+		case mesgLap:
+			a.Laps = append(a.Laps, readLap(msg, len(a.Laps)+1))
+		case mesgRecord:
+			err = a.addRecord(msg)
+		case mesgSet:
+			a.Sets = append(a.Sets, readSet(msg))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case fileType == -1:
+		return nil, &FormatError{Offset: headerSize, Msg: "the file has no file_id message"}
+	case fileType != fileActivity:
+		return nil, &FormatError{Offset: headerSize, Msg: fmt.Sprintf("FIT file type %d is not an activity", fileType)}
+	case len(a.Sessions) == 0 && len(a.Track.Points) == 0 && len(a.Sets) == 0:
+		return nil, &FormatError{Offset: end, Msg: "the file holds no sessions, records or sets"}
+	}
+	if len(a.Sessions) > 0 {
+		a.Track.Sport = a.Sessions[0].Sport
+	}
+	return a, nil
+}
+
+type decoder struct {
+	data        []byte
+	pos         int
+	definitions [16]*definition
+	// timestamp is the last full timestamp seen, which compressed
+	// timestamp headers count from.
+	timestamp     int64
+	haveTimestamp bool
+}
+
+func (d *decoder) need(n int, what string) error {
+	if d.pos+n > len(d.data) {
+		return &FormatError{Offset: d.pos, Msg: what + " runs past the end of the data"}
+	}
+	return nil
+}
+
+// next reads one record, returning the decoded message when it was a data
+// message and nil after a definition.
+func (d *decoder) next() (uint16, *message, error) {
+	start := d.pos
+	header := d.data[d.pos]
+	d.pos++
+
+	switch {
+	case header&0x80 != 0:
+		if !d.haveTimestamp {
+			return 0, nil, &FormatError{Offset: start, Msg: "compressed timestamp before any full timestamp"}
+		}
+		offset := int64(header & 0x1F)
+		ts := d.timestamp&^0x1F | offset
+		if offset < d.timestamp&0x1F {
+			ts += 0x20
+		}
+		global, msg, err := d.dataMessage(start, int((header>>5)&0x03))
+		if msg != nil {
+			msg.values[fieldTimestamp] = ts
+			d.timestamp = ts
+		}
+		return global, msg, err
+	case header&0x40 != 0:
+		return 0, nil, d.definitionMessage(int(header&0x0F), header&0x20 != 0)
+	default:
+		return d.dataMessage(start, int(header&0x0F))
+	}
+}
+
+func (d *decoder) definitionMessage(local int, developer bool) error {
+	err := d.need(5, "definition message")
+	if err != nil {
+		return err
+	}
+	arch := d.data[d.pos+1]
+	if arch > 1 {
+		return &FormatError{Offset: d.pos + 1, Msg: fmt.Sprintf("unknown architecture %d", arch)}
+	}
+	def := &definition{bigEndian: arch == 1}
+	def.global = order(def.bigEndian).Uint16(d.data[d.pos+2:])
+	count := int(d.data[d.pos+4])
+	d.pos += 5
+
+	err = d.need(3*count, "definition message")
+	if err != nil {
+		return err
+	}
+	for range count {
+		field := fieldDef{num: d.data[d.pos], size: d.data[d.pos+1], base: d.data[d.pos+2]}
+		if int(field.base&0x1F) >= len(baseSizes) {
+			return &FormatError{Offset: d.pos + 2, Msg: fmt.Sprintf("unknown base type 0x%02X", field.base)}
+		}
+		def.fields = append(def.fields, field)
+		d.pos += 3
+	}
+
+	if developer {
+		err = d.need(1, "definition message")
+		if err != nil {
+			return err
+		}
+		count = int(d.data[d.pos])
+		d.pos++
+		err = d.need(3*count, "definition message")
+		if err != nil {
+			return err
+		}
+		for range count {
+			def.devSize += int(d.data[d.pos+1])
+			d.pos += 3
+		}
+	}
+
+	d.definitions[local] = def
+	return nil
+}
+
+func (d *decoder) dataMessage(start, local int) (uint16, *message, error) {
+	def := d.definitions[local]
+	if def == nil {
+		return 0, nil, &FormatError{Offset: start, Msg: fmt.Sprintf("data message for undefined local message type %d", local)}
+	}
+
+	msg := &message{offset: start, values: map[byte]int64{}, strings: map[byte]string{}}
+	for _, field := range def.fields {
+		err := d.need(int(field.size), "data message")
+		if err != nil {
+			return 0, nil, err
+		}
+		b := d.data[d.pos : d.pos+int(field.size)]
+		d.pos += int(field.size)
+
+		if field.base&0x1F == baseString {
+			if s := cString(b); s != "" {
+				msg.strings[field.num] = s
+			}
+			continue
+		}
+		if v, ok := readValue(b, field.base, def.bigEndian); ok {
+			msg.values[field.num] = v
+		}
+	}
+	err := d.need(def.devSize, "data message")
+	if err != nil {
+		return 0, nil, err
+	}
+	d.pos += def.devSize
+
+	if ts, ok := msg.values[fieldTimestamp]; ok {
+		d.timestamp, d.haveTimestamp = ts, true
+	}
+	return def.global, msg, nil
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+func readSession(msg *message) Session {
+	s := Session{
+		Sport:          sports[msg.values[5]],
+		DistanceMeters: msg.scaled(9, 100, 0),
+		AscentMeters:   msg.scaled(22, 1, 0),
+		DescentMeters:  msg.scaled(23, 1, 0),
+		AvgHeartRate:   msg.sensor(16),
+		MaxHeartRate:   msg.sensor(17),
+		AvgCadence:     msg.sensor(18),
+		AvgPower:       msg.sensor(20),
+		MaxPower:       msg.sensor(21),
+		Calories:       msg.int(11),
+		strength:       msg.values[5] == sportTraining && msg.values[6] == subSportStrengthTraining,
+	}
+	s.StartedAt, _ = msg.time(2)
+	if v := msg.scaled(7, 1000, 0); v != nil {
+		s.ElapsedSeconds = *v
+	}
+	if v := msg.scaled(8, 1000, 0); v != nil {
+		s.TimerSeconds = *v
+	}
+	return s
+}
+
+func readLap(msg *message, number int) activity.Lap {
+	lap := activity.Lap{
+		Number:       number,
+		AvgHeartRate: msg.sensor(15),
+		MaxHeartRate: msg.sensor(16),
+	}
+	lap.StartedAt, _ = msg.time(2)
+	if v := msg.scaled(9, 100, 0); v != nil {
+		lap.DistanceMeters = math.Round(*v*10) / 10
+	}
+	if v := msg.scaled(7, 1000, 0); v != nil {
+		lap.ElapsedSeconds = int(math.Round(*v))
+	}
+	if v := msg.scaled(8, 1000, 0); v != nil {
+		lap.MovingSeconds = int(math.Round(*v))
+	}
+	return lap
+}
+
+// semicircles converts a FIT position to degrees.
+const semicircles = 180 / float64(1<<31)
+
+func (a *Activity) addRecord(msg *message) error {
+	t, ok := msg.time(fieldTimestamp)
+	if !ok {
+		// a record without a time cannot be placed on the track
+		return nil
+	}
+	points := a.Track.Points
+	if len(points) > 0 && t.Before(points[len(points)-1].Time) {
+		return &FormatError{Offset: msg.offset, Msg: fmt.Sprintf("record %d: time goes backwards from the previous record", len(points)+1)}
+	}
+
+	p := activity.Point{
+		Time:      t,
+		Distance:  msg.scaled(5, 100, 0),
+		HeartRate: msg.sensor(3),
+		Cadence:   msg.sensor(4),
+		Power:     msg.sensor(7),
+		Elevation: msg.scaled(78, 5, 500),
+	}
+	if p.Elevation == nil {
+		p.Elevation = msg.scaled(2, 5, 500)
+	}
+	lat, latOK := msg.values[0]
+	lon, lonOK := msg.values[1]
+	if latOK && lonOK {
+		la, lo := float64(lat)*semicircles, float64(lon)*semicircles
+		if la < -90 || la > 90 || lo < -180 || lo > 180 {
+			return &FormatError{Offset: msg.offset, Msg: fmt.Sprintf("record %d: position %.5f,%.5f is out of range", len(points)+1, la, lo)}
+		}
+		p.Lat, p.Lon = &la, &lo
+	}
+	a.Track.Points = append(points, p)
+	return nil
+}
+
+func readSet(msg *message) Set {
+	set := Set{
+		DurationSeconds: msg.scaled(0, 1000, 0),
+		Active:          msg.values[5] == 1,
+		Reps:            msg.int(3),
+		WeightKg:        msg.scaled(4, 16, 0),
+		Category:        -1,
+	}
+	set.StartedAt, _ = msg.time(6)
+	if v, ok := msg.values[7]; ok {
+		set.Category = int(v)
+	}
+	return set
+}
+
+// Strength reports whether the activity is a strength workout made of
+// sets rather than a distance covered.
+func (a *Activity) Strength() bool {
+	for _, s := range a.Sessions {
+		if s.strength {
+			return true
+		}
+	}
+	for _, s := range a.Sets {
+		if s.Active {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary works out the same summary as for GPX and TCX files from the
+// records, then prefers the device's own session totals, which know about
+// auto-pause and filtered GPS noise.
+func (a *Activity) Summary() activity.Summary {
+	var s activity.Summary
+	if len(a.Track.Points) >= 2 {
+		s = activity.Summarize(&a.Track)
+	} else {
+		s = activity.Summary{Format: activity.FormatFIT, Sport: a.Track.Sport, Points: len(a.Track.Points), Splits: []activity.Split{}}
+		if len(a.Track.Points) == 1 {
+			s.StartedAt, s.EndedAt = a.Track.Points[0].Time, a.Track.Points[0].Time
+		}
+	}
+	s.Laps = a.Laps
+
+	if len(a.Sessions) == 0 {
+		return s
+	}
+	first, last := a.Sessions[0], a.Sessions[len(a.Sessions)-1]
+	if !first.StartedAt.IsZero() {
+		s.StartedAt = first.StartedAt
+		end := last.StartedAt.Add(time.Duration(last.ElapsedSeconds * float64(time.Second)))
+		if end.After(s.EndedAt) {
+			s.EndedAt = end
+		}
+	}
+
+	var elapsed, timer, distance, ascent, descent float64
+	var haveDistance, haveAscent bool
+	for _, session := range a.Sessions {
+		elapsed += session.ElapsedSeconds
+		timer += session.TimerSeconds
+		if session.DistanceMeters != nil {
+			distance += *session.DistanceMeters
+			haveDistance = true
+		}
+		if session.AscentMeters != nil && session.DescentMeters != nil {
+			ascent += *session.AscentMeters
+			descent += *session.DescentMeters
+			haveAscent = true
+		}
+	}
+	if elapsed > 0 {
+		s.ElapsedSeconds = int(math.Round(elapsed))
+	}
+	if timer > 0 {
+		s.MovingSeconds = int(math.Round(timer))
+	}
+	if haveDistance {
+		s.DistanceMeters = math.Round(distance*10) / 10
+	}
+	if haveAscent {
+		s.ElevationGainMeters, s.ElevationLossMeters = &ascent, &descent
+	}
+	if len(a.Sessions) == 1 {
+		s.AvgHeartRate = prefer(first.AvgHeartRate, s.AvgHeartRate)
+		s.AvgCadence = prefer(first.AvgCadence, s.AvgCadence)
+		s.AvgPower = prefer(first.AvgPower, s.AvgPower)
+	}
+	for _, session := range a.Sessions {
+		s.MaxHeartRate = larger(s.MaxHeartRate, session.MaxHeartRate)
+		s.MaxPower = larger(s.MaxPower, session.MaxPower)
+		if session.Calories != nil {
+			calories := *session.Calories
+			if s.Calories != nil {
+				calories += *s.Calories
+			}
+			s.Calories = &calories
+		}
+	}
+	return s
+}
+
+func prefer(v, fallback *int) *int {
+	if v != nil {
+		return v
+	}
+	return fallback
+}
+
+func larger(a, b *int) *int {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,216 @@
+package fit
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// profileVersion is the FIT SDK profile the written files follow.
+	profileVersion = 2132
+	// manufacturerDevelopment is what FIT has apps that are not a device
+	// maker write into file_id.
+	manufacturerDevelopment = 255
+	// maxSteps is how many workout steps watches accept in one workout.
+	maxSteps = 100
+)
+
+// workout step duration types
+const (
+	durationTime   = 0
+	durationOpen   = 5
+	durationRepeat = 6
+	durationReps   = 29
+)
+
+const (
+	targetOpen       = 2
+	intensityActive  = 0
+	intensityRest    = 1
+	weightKilograms  = 0
+	stepNameSize     = 32
+	stepNotesSize    = 64
+	workoutNameSize  = 64
+	fileIDLocal      = 0
+	workoutLocal     = 1
+	workoutStepLocal = 2
+)
+
+// Workout is a structured workout to load onto a watch.
+type Workout struct {
+	Name  string
+	Steps []Step
+}
+
+// Step is one exercise of a workout, done for Sets sets of either Reps
+// repetitions or DurationSeconds seconds. Between sets the watch counts
+// rest until the lap button is pressed.
+type Step struct {
+	Name            string
+	Notes           string
+	Sets            int
+	Reps            *int
+	DurationSeconds *int
+	WeightKg        *float64
+}
+
+// encoder writes data records; finish wraps them into a file.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) define(local byte, global uint16, fields []fieldDef) {
+	e.buf = append(e.buf, 0x40|local, 0, 0)
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, global)
+	e.buf = append(e.buf, byte(len(fields)))
+	for _, f := range fields {
+		e.buf = append(e.buf, f.num, f.size, f.base)
+	}
+}
+
+// write appends a data message with one value per field: a string, an
+// integer of any width, or nil for the field's invalid value.
+func (e *encoder) write(local byte, fields []fieldDef, values ...any) {
+	e.buf = append(e.buf, local)
+	for i, f := range fields {
+		e.buf = appendValue(e.buf, f, values[i])
+	}
+}
+
+func appendValue(buf []byte, f fieldDef, v any) []byte {
+	num := f.base & 0x1F
+	if num == baseString {
+		s, _ := v.(string)
+		b := make([]byte, f.size)
+		copy(b[:f.size-1], truncate(s, int(f.size)-1))
+		return append(buf, b...)
+	}
+
+	var raw uint64
+	switch n := v.(type) {
+	case nil:
+		raw = invalids[num]
+	case int:
+		raw = uint64(n)
+	case int64:
+		raw = uint64(n)
+	case uint64:
+		raw = n
+	default:
+		panic(fmt.Sprintf("fit: cannot encode %T", v))
+	}
+	switch f.size {
+	case 1:
+		return append(buf, byte(raw))
+	case 2:
+		return binary.LittleEndian.AppendUint16(buf, uint16(raw))
+	case 4:
+		return binary.LittleEndian.AppendUint32(buf, uint32(raw))
+	default:
+		return binary.LittleEndian.AppendUint64(buf, raw)
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func (e *encoder) finish() []byte {
+	header := []byte{14, 0x20, 0, 0, 0, 0, 0, 0, '.', 'F', 'I', 'T'}
+	binary.LittleEndian.PutUint16(header[2:], profileVersion)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(e.buf)))
+	header = binary.LittleEndian.AppendUint16(header, crc(header))
+
+	file := append(header, e.buf...)
+	return binary.LittleEndian.AppendUint16(file, crc(file))
+}
+
+var (
+	fileIDFields = []fieldDef{
+		{0, 1, baseEnum},   // type
+		{1, 2, baseUint16}, // manufacturer
+		{2, 2, baseUint16}, // product
+		{4, 4, baseUint32}, // time_created
+	}
+	workoutFields = []fieldDef{
+		{8, workoutNameSize, baseString}, // wkt_name
+		{4, 1, baseEnum},                 // sport
+		{11, 1, baseEnum},                // sub_sport
+		{6, 2, baseUint16},               // num_valid_steps
+	}
+	workoutStepFields = []fieldDef{
+		{254, 2, baseUint16},           // message_index
+		{0, stepNameSize, baseString},  // wkt_step_name
+		{1, 1, baseEnum},               // duration_type
+		{2, 4, baseUint32},             // duration_value
+		{3, 1, baseEnum},               // target_type
+		{4, 4, baseUint32},             // target_value
+		{7, 1, baseEnum},               // intensity
+		{8, stepNotesSize, baseString}, // notes
+		{10, 2, baseUint16},            // exercise_category
+		{12, 2, baseUint16},            // exercise_weight
+		{13, 1, baseEnum},              // weight_display_unit
+	}
+)
+
+// EncodeWorkout writes a strength workout file. Every step with more than
+// one set becomes the exercise, an open rest and a repeat of both.
+func EncodeWorkout(w Workout, created time.Time) ([]byte, error) {
+	if len(w.Steps) == 0 {
+		return nil, errors.New("the workout has no steps")
+	}
+
+	e := &encoder{}
+	e.define(fileIDLocal, mesgFileID, fileIDFields)
+	e.write(fileIDLocal, fileIDFields, fileWorkout, manufacturerDevelopment, 0, created.Unix()-epoch.Unix())
+
+	var steps [][]any
+	for i, s := range w.Steps {
+		if s.Sets < 1 {
+			return nil, fmt.Errorf("step %d: sets must be at least 1", i+1)
+		}
+
+		first := len(steps)
+		active := []any{len(steps), s.Name, nil, nil, targetOpen, nil, intensityActive, s.Notes, int(Category(s.Name)), nil, nil}
+		switch {
+		case s.Reps != nil:
+			active[2], active[3] = durationReps, *s.Reps
+		case s.DurationSeconds != nil:
+			active[2], active[3] = durationTime, *s.DurationSeconds*1000
+		default:
+			active[2] = durationOpen
+		}
+		if s.WeightKg != nil {
+			active[9], active[10] = int(*s.WeightKg*100+0.5), weightKilograms
+		}
+		steps = append(steps, active)
+
+		if s.Sets > 1 {
+			steps = append(steps,
+				[]any{len(steps), "Rest", durationOpen, nil, targetOpen, nil, intensityRest, "", nil, nil, nil},
+				[]any{len(steps) + 1, "", durationRepeat, first, nil, s.Sets, nil, "", nil, nil, nil},
+			)
+		}
+	}
+	if len(steps) > maxSteps {
+		return nil, fmt.Errorf("the workout needs %d steps, watches accept at most %d", len(steps), maxSteps)
+	}
+
+	e.define(workoutLocal, mesgWorkout, workoutFields)
+	e.write(workoutLocal, workoutFields, w.Name, sportTraining, subSportStrengthTraining, len(steps))
+	e.define(workoutStepLocal, mesgWorkoutStep, workoutStepFields)
+	for _, step := range steps {
+		e.write(workoutStepLocal, workoutStepFields, step...)
+	}
+	return e.finish(), nil
+}
@@ -0,0 +1,88 @@
+package fit
+
+import "strings"
+
+// categoryUnknown is the exercise category for an exercise FIT has no
+// category for.
+const categoryUnknown = 65534
+
+// categories names FIT's exercise categories, indexed by their number.
+var categories = [...]string{
+	"Bench Press", "Calf Raise", "Cardio", "Carry", "Chop", "Core", "Crunch",
+	"Curl", "Deadlift", "Flye", "Hip Raise", "Hip Stability", "Hip Swing",
+	"Hyperextension", "Lateral Raise", "Leg Curl", "Leg Raise", "Lunge",
+	"Olympic Lift", "Plank", "Plyo", "Pull Up", "Push Up", "Row",
+	"Shoulder Press", "Shoulder Stability", "Shrug", "Sit Up", "Squat",
+	"Total Body", "Triceps Extension", "Warm Up", "Run",
+}
+
+// categoryKeywords finds the category of an exercise by name. More
+// specific keywords come first: a leg curl is not a curl.
+var categoryKeywords = []struct {
+	keyword  string
+	category uint16
+}{
+	{"bench", 0},
+	{"calf", 1},
+	{"leg curl", 15},
+	{"hamstring curl", 15},
+	{"curl", 7},
+	{"deadlift", 8},
+	{"rdl", 8},
+	{"fly", 9},
+	{"flye", 9},
+	{"hip thrust", 10},
+	{"glute bridge", 10},
+	{"lateral raise", 14},
+	{"leg raise", 16},
+	{"lunge", 17},
+	{"split squat", 17},
+	{"clean", 18},
+	{"snatch", 18},
+	{"plank", 19},
+	{"pull-up", 21},
+	{"pull up", 21},
+	{"pullup", 21},
+	{"chin-up", 21},
+	{"chin up", 21},
+	{"push-up", 22},
+	{"push up", 22},
+	{"pushup", 22},
+	{"row", 23},
+	{"overhead press", 24},
+	{"shoulder press", 24},
+	{"military press", 24},
+	{"shrug", 26},
+	{"crunch", 6},
+	{"sit-up", 27},
+	{"sit up", 27},
+	{"squat", 28},
+	{"triceps", 30},
+	{"tricep", 30},
+	{"pushdown", 30},
+	{"skull", 30},
+	{"farmer", 3},
+	{"carry", 3},
+	{"run", 32},
+}
+
+// ExerciseName names a FIT exercise category the way the exercise catalog
+// names exercises.
+func ExerciseName(category int) string {
+	if category >= 0 && category < len(categories) {
+		return categories[category]
+	}
+	return "Strength Training"
+}
+
+// Category finds the FIT exercise category for an exercise name, so the
+// watch shows a fitting animation.
+func Category(name string) uint16 {
+	n := strings.ToLower(name)
+	for _, k := range categoryKeywords {
+		if strings.Contains(n, k.keyword) {
+			return k.category
+		}
+	}
+	return categoryUnknown
+}
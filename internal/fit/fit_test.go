@@ -0,0 +1,235 @@
+package fit
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC)
+
+func fitTime(t time.Time) int64 {
+	return t.Unix() - epoch.Unix()
+}
+
+var (
+	activityIDFields = []fieldDef{{0, 1, baseEnum}, {4, 4, baseUint32}}
+	recordFields     = []fieldDef{
+		{253, 4, baseUint32}, {0, 4, baseSint32}, {1, 4, baseSint32}, {78, 4, baseUint32},
+		{5, 4, baseUint32}, {3, 1, baseUint8},
+	}
+	lapFields     = []fieldDef{{2, 4, baseUint32}, {7, 4, baseUint32}, {8, 4, baseUint32}, {9, 4, baseUint32}, {15, 1, baseUint8}}
+	sessionFields = []fieldDef{
+		{2, 4, baseUint32}, {5, 1, baseEnum}, {6, 1, baseEnum}, {7, 4, baseUint32}, {8, 4, baseUint32},
+		{9, 4, baseUint32}, {16, 1, baseUint8}, {17, 1, baseUint8}, {22, 2, baseUint16}, {23, 2, baseUint16},
+		{11, 2, baseUint16},
+	}
+	setFields = []fieldDef{{6, 4, baseUint32}, {0, 4, baseUint32}, {3, 2, baseUint16}, {4, 2, baseUint16}, {5, 1, baseUint8}, {7, 4, baseUint16}}
+)
+
+// degrees converts back to semicircles.
+func degrees(d float64) int64 {
+	return int64(d / semicircles)
+}
+
+// runFile records a run due north, about 100 m every 30 seconds, and a
+// first 900 m lap. The second record uses a compressed timestamp
+// header the way devices do for frequent messages.
+func runFile(points int) []byte {
+	e := &encoder{}
+	e.define(0, mesgFileID, activityIDFields)
+	e.write(0, activityIDFields, fileActivity, fitTime(start))
+
+	e.define(1, mesgRecord, recordFields)
+	for i := range points {
+		ts := fitTime(start.Add(time.Duration(i) * 30 * time.Second))
+		values := []any{ts, degrees(52.5 + 0.0009*float64(i)), degrees(13.4), (100 + 500) * 5, i * 10000, 140 + i}
+		if i == 1 {
+			// compressed header for local type 2; no timestamp field follows
+			e.define(2, mesgRecord, recordFields[1:])
+			e.buf = append(e.buf, 0x80|2<<5|byte(ts&0x1F))
+			for j, f := range recordFields[1:] {
+				e.buf = appendValue(e.buf, f, values[j+1])
+			}
+			continue
+		}
+		e.write(1, recordFields, values...)
+	}
+
+	e.define(3, mesgLap, lapFields)
+	e.write(3, lapFields, fitTime(start), 270_000, 270_000, 90_000, 145)
+	e.define(4, mesgSession, sessionFields)
+	e.write(4, sessionFields, fitTime(start), 1, nil, (points-1)*30_000, (points-1)*30_000-5_000, (points-1)*10000, 150, 168, 12, 3, 180)
+	return e.finish()
+}
+
+func TestDecodeRun(t *testing.T) {
+	a, err := Decode(runFile(25))
+	require.NoError(t, err)
+	require.Len(t, a.Track.Points, 25)
+	assert.Equal(t, "running", a.Track.Sport)
+	assert.False(t, a.Strength())
+
+	second := a.Track.Points[1]
+	assert.Equal(t, start.Add(30*time.Second), second.Time, "the compressed timestamp counts from the previous one")
+	assert.InDelta(t, 52.5009, *second.Lat, 1e-6)
+	assert.Equal(t, 100.0, *second.Elevation)
+	assert.Equal(t, 141, *second.HeartRate)
+
+	summary := a.Summary()
+	assert.Equal(t, "fit", summary.Format)
+	assert.Equal(t, 2400.0, summary.DistanceMeters, "the session total wins over the track")
+	assert.Equal(t, 720, summary.ElapsedSeconds)
+	assert.Equal(t, 715, summary.MovingSeconds)
+	assert.Equal(t, 12.0, *summary.ElevationGainMeters)
+	assert.Equal(t, 150, *summary.AvgHeartRate)
+	assert.Equal(t, 168, *summary.MaxHeartRate)
+	assert.Equal(t, 180, *summary.Calories)
+	assert.Len(t, summary.Splits, 3)
+	require.Len(t, summary.Laps, 1)
+	assert.Equal(t, 900.0, summary.Laps[0].DistanceMeters)
+	assert.Equal(t, 145, *summary.Laps[0].AvgHeartRate)
+}
+
+func TestDecodeStrength(t *testing.T) {
+	e := &encoder{}
+	e.define(0, mesgFileID, activityIDFields)
+	e.write(0, activityIDFields, fileActivity, fitTime(start))
+	e.define(1, mesgSet, setFields)
+	e.write(1, setFields, fitTime(start), 40_000, 5, 100*16, 1, 28)
+	e.write(1, setFields, fitTime(start.Add(40*time.Second)), 120_000, nil, nil, 0, nil)
+	e.write(1, setFields, fitTime(start.Add(160*time.Second)), 30_000, nil, nil, 1, 19)
+	e.define(2, mesgSession, sessionFields)
+	e.write(2, sessionFields, fitTime(start), sportTraining, subSportStrengthTraining, 190_000, 190_000, nil, 110, 130, nil, nil, nil)
+
+	a, err := Decode(e.finish())
+	require.NoError(t, err)
+	assert.True(t, a.Strength())
+	require.Len(t, a.Sets, 3)
+
+	squat := a.Sets[0]
+	assert.True(t, squat.Active)
+	assert.Equal(t, 5, *squat.Reps)
+	assert.Equal(t, 100.0, *squat.WeightKg)
+	assert.Equal(t, "Squat", ExerciseName(squat.Category))
+	assert.False(t, a.Sets[1].Active)
+	assert.Equal(t, 120.0, *a.Sets[1].DurationSeconds)
+	assert.Nil(t, a.Sets[2].Reps)
+	assert.Equal(t, "Plank", ExerciseName(a.Sets[2].Category))
+
+	summary := a.Summary()
+	assert.Equal(t, start, summary.StartedAt)
+	assert.Equal(t, start.Add(190*time.Second), summary.EndedAt)
+	assert.Equal(t, 0.0, summary.DistanceMeters)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := runFile(3)
+
+	damaged := append([]byte(nil), valid...)
+	damaged[20] ^= 0xFF
+
+	truncated := valid[:len(valid)-10]
+
+	e := &encoder{}
+	e.define(0, mesgFileID, activityIDFields)
+	e.write(0, activityIDFields, fileWorkout, fitTime(start))
+	workout := e.finish()
+
+	e = &encoder{}
+	e.define(0, mesgFileID, activityIDFields)
+	e.write(3, activityIDFields, fileActivity, fitTime(start))
+	undefined := e.finish()
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not fit", []byte("<gpx></gpx>"), "byte 0: the file is not a FIT file"},
+		{"damaged", damaged, "the file checksum does not match, the file is damaged"},
+		{"truncated", truncated, "the file is truncated"},
+		{"workout file", workout, "FIT file type 5 is not an activity"},
+		{"undefined local type", undefined, "byte 26: data message for undefined local message type 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			require.Error(t, err)
+			var formatErr *FormatError
+			assert.ErrorAs(t, err, &formatErr)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestEncodeWorkout(t *testing.T) {
+	reps, seconds, weight := 5, 60, 102.5
+	data, err := EncodeWorkout(Workout{
+		Name: "Strength A",
+		Steps: []Step{
+			{Name: "Back Squat", Sets: 3, Reps: &reps, WeightKg: &weight, Notes: "belt on"},
+			{Name: "Plank", Sets: 1, DurationSeconds: &seconds},
+		},
+	}, start)
+	require.NoError(t, err)
+	require.True(t, IsFIT(data))
+	assert.Equal(t, crc(data[:len(data)-2]), binary.LittleEndian.Uint16(data[len(data)-2:]))
+
+	d := decoder{data: data[:len(data)-2], pos: 14}
+	var workout *message
+	var steps []*message
+	for d.pos < len(d.data) {
+		global, msg, err := d.next()
+		require.NoError(t, err)
+		switch {
+		case msg == nil:
+		case global == mesgFileID:
+			assert.Equal(t, int64(fileWorkout), msg.values[0])
+		case global == mesgWorkout:
+			workout = msg
+		case global == mesgWorkoutStep:
+			steps = append(steps, msg)
+		}
+	}
+
+	require.NotNil(t, workout)
+	assert.Equal(t, "Strength A", workout.strings[8])
+	assert.Equal(t, int64(4), workout.values[6])
+	require.Len(t, steps, 4)
+
+	squat := steps[0]
+	assert.Equal(t, "Back Squat", squat.strings[0])
+	assert.Equal(t, int64(durationReps), squat.values[1])
+	assert.Equal(t, int64(5), squat.values[2])
+	assert.Equal(t, int64(10250), squat.values[12])
+	assert.Equal(t, int64(28), squat.values[10])
+	assert.Equal(t, "belt on", squat.strings[8])
+
+	assert.Equal(t, int64(intensityRest), steps[1].values[7])
+	repeat := steps[2]
+	assert.Equal(t, int64(durationRepeat), repeat.values[1])
+	assert.Equal(t, int64(0), repeat.values[2], "the repeat goes back to the squat")
+	assert.Equal(t, int64(3), repeat.values[4])
+
+	plank := steps[3]
+	assert.Equal(t, int64(3), plank.values[254])
+	assert.Equal(t, int64(durationTime), plank.values[1])
+	assert.Equal(t, int64(60_000), plank.values[2])
+	_, weighted := plank.values[12]
+	assert.False(t, weighted)
+
+	_, err = EncodeWorkout(Workout{Name: "Empty"}, start)
+	assert.Error(t, err)
+}
+
+func TestCategory(t *testing.T) {
+	assert.Equal(t, uint16(15), Category("Lying Leg Curl"))
+	assert.Equal(t, uint16(7), Category("Dumbbell Curl"))
+	assert.Equal(t, uint16(0), Category("Incline Bench Press"))
+	assert.Equal(t, uint16(categoryUnknown), Category("Machine Chest Press"))
+	assert.Equal(t, "Strength Training", ExerciseName(-1))
+}
@@ -0,0 +1,158 @@
+// Package fuzzy scores how closely two short names match. It is tuned for
+// exercise names typed by hand, where "BB bench" and "Barbell Bench Press"
+// should be considered the same thing.
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// abbreviations commonly used in gym logs, expanded before comparison
+var abbreviations = map[string]string{
+	"bb":  "barbell",
+	"db":  "dumbbell",
+	"kb":  "kettlebell",
+	"bw":  "bodyweight",
+	"sl":  "single leg",
+	"sa":  "single arm",
+	"alt": "alternating",
+}
+
+// Normalize lowercases s, replaces punctuation with spaces, expands common
+// abbreviations, drops a trailing plural "s" from each word and collapses
+// whitespace.
+func Normalize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, s)
+
+	words := strings.Fields(s)
+	out := make([]string, 0, len(words))
+	for _, word := range words {
+		if expanded, ok := abbreviations[word]; ok {
+			word = expanded
+		}
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = strings.TrimSuffix(word, "s")
+		}
+		out = append(out, word)
+	}
+	return strings.Join(out, " ")
+}
+
+// Score returns a similarity between 0 and 1 for two names. Both are
+// normalized first; identical names score 1.
+func Score(a, b string) float64 {
+	a, b = Normalize(a), Normalize(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	compactA := strings.ReplaceAll(a, " ", "")
+	compactB := strings.ReplaceAll(b, " ", "")
+	if compactA == compactB {
+		return 0.99
+	}
+
+	editScore := 1 - float64(levenshtein(compactA, compactB))/float64(max(len([]rune(compactA)), len([]rune(compactB))))
+	return max(editScore, tokenScore(strings.Fields(a), strings.Fields(b)))
+}
+
+// tokenScore rewards names whose words overlap, weighting how much of the
+// query is covered by the candidate as heavily as the overall overlap.
+func tokenScore(query, candidate []string) float64 {
+	candidateSet := make(map[string]bool, len(candidate))
+	for _, word := range candidate {
+		candidateSet[word] = true
+	}
+
+	querySet := make(map[string]bool, len(query))
+	shared := 0
+	for _, word := range query {
+		if querySet[word] {
+			continue
+		}
+		querySet[word] = true
+		if candidateSet[word] {
+			shared++
+		}
+	}
+
+	union := len(querySet) + len(candidateSet) - shared
+	if union == 0 {
+		return 0
+	}
+	containment := float64(shared) / float64(len(querySet))
+	jaccard := float64(shared) / float64(union)
+	return 0.5*containment + 0.5*jaccard
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// Candidate is one matchable item together with every name it is known by.
+type Candidate struct {
+	Index int
+	Names []string
+}
+
+// Threshold is the minimum score a match must reach to be accepted.
+const Threshold = 0.75
+
+// ambiguityMargin is how far ahead of the runner-up a non-exact best match
+// must be; closer results are treated as no match rather than a guess.
+const ambiguityMargin = 0.05
+
+// Best returns the index of the candidate that best matches query, or -1
+// when nothing scores above Threshold or the top two are too close to call.
+func Best(query string, candidates []Candidate) int {
+	best, bestScore, runnerUp := -1, 0.0, 0.0
+	for _, candidate := range candidates {
+		score := 0.0
+		for _, name := range candidate.Names {
+			score = max(score, Score(query, name))
+		}
+
+		switch {
+		case score > bestScore:
+			runnerUp = bestScore
+			best, bestScore = candidate.Index, score
+		case score > runnerUp:
+			runnerUp = score
+		}
+	}
+
+	if bestScore < Threshold {
+		return -1
+	}
+	if bestScore < 1 && bestScore-runnerUp < ambiguityMargin {
+		return -1
+	}
+	return best
+}
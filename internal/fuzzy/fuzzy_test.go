@@ -0,0 +1,44 @@
+package fuzzy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "barbell bench press", Normalize("BB  Bench-Press"))
+	assert.Equal(t, "dumbbell curl", Normalize("DB Curls"))
+	assert.Equal(t, "cross", Normalize("Cross"))
+}
+
+func TestBest(t *testing.T) {
+	catalog := []Candidate{
+		{Index: 0, Names: []string{"Barbell Bench Press", "bench press", "bench"}},
+		{Index: 1, Names: []string{"Incline Barbell Bench Press", "incline bench"}},
+		{Index: 2, Names: []string{"Back Squat", "squat", "barbell squat"}},
+		{Index: 3, Names: []string{"Leg Press"}},
+		{Index: 4, Names: []string{"Overhead Press", "ohp"}},
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: "Bench Press", want: 0},
+		{query: "bench", want: 0},
+		{query: "BB bench", want: 0},
+		{query: "Incline bench", want: 1},
+		{query: "squats", want: 2},
+		{query: "Back Sqaut", want: 2},
+		{query: "OHP", want: 4},
+		{query: "press", want: -1},
+		{query: "Zercher carry", want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assert.Equal(t, tt.want, Best(tt.query, catalog))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package ical writes iCalendar (RFC 5545) feeds that calendar apps can
+// subscribe to. Event times are written in UTC: every occurrence is expanded
+// by the caller in its own time zone, so daylight saving time is already
+// accounted for and no VTIMEZONE definitions are needed.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+// maxLineOctets is the longest content line RFC 5545 allows before folding.
+const maxLineOctets = 75
+
+const utcLayout = "20060102T150405Z"
+
+type Event struct {
+	UID         string
+	Stamp       time.Time
+	Start       time.Time
+	Duration    time.Duration
+	Summary     string
+	Description string
+	// Status is one of the Status constants, left out when empty.
+	Status string
+}
+
+// Calendar is a feed of events. Timezone is only a hint of the zone to show
+// the events in, for the apps that honour X-WR-TIMEZONE.
+type Calendar struct {
+	ProductID string
+	Name      string
+	Timezone  string
+	// Refresh is how often subscribers should fetch the feed again.
+	Refresh time.Duration
+	Events  []Event
+}
+
+// Encode writes the calendar with CRLF line endings, folding long lines.
+func (c *Calendar) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeLine(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", c.ProductID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", Escape(c.Name))
+	}
+	if c.Timezone != "" {
+		line("X-WR-TIMEZONE", c.Timezone)
+	}
+	if c.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", FormatDuration(c.Refresh))
+		line("X-PUBLISHED-TTL", FormatDuration(c.Refresh))
+	}
+
+	for _, event := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.UID)
+		line("DTSTAMP", event.Stamp.UTC().Format(utcLayout))
+		line("DTSTART", event.Start.UTC().Format(utcLayout))
+		line("DURATION", FormatDuration(event.Duration))
+		line("SUMMARY", Escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION", Escape(event.Description))
+		}
+		if event.Status != "" {
+			line("STATUS", event.Status)
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeLine writes a content line, folding it into lines of at most 75
+// octets continued by a leading space, without splitting a UTF-8 sequence.
+func writeLine(w *bufio.Writer, s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// the leading space counts towards the continuation line
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// Escape escapes a TEXT value: backslashes, semicolons, commas and line
+// breaks.
+func Escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(s)
+}
+
+// FormatDuration writes d as an RFC 5545 duration such as PT1H30M, rounded
+// to the second.
+func FormatDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds <= 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	b.WriteString("P")
+	if days := seconds / 86400; days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		seconds %= 86400
+	}
+	if seconds > 0 {
+		b.WriteString("T")
+		if h := seconds / 3600; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m := seconds % 3600 / 60; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if s := seconds % 60; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	calendar := &Calendar{
+		ProductID: "-//test//EN",
+		Name:      "Training",
+		Timezone:  "Europe/Berlin",
+		Refresh:   time.Hour,
+		Events: []Event{{
+			UID:         "workout-1@test",
+			Stamp:       time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			Start:       time.Date(2024, 7, 1, 18, 30, 0, 0, berlin),
+			Duration:    75 * time.Minute,
+			Summary:     "Push, heavy; day",
+			Description: "Bench Press: 3 × 5\nDips",
+			Status:      StatusConfirmed,
+		}},
+	}
+
+	var b strings.Builder
+	require.NoError(t, calendar.Encode(&b))
+	assert.Equal(t, strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Training",
+		"X-WR-TIMEZONE:Europe/Berlin",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H",
+		"X-PUBLISHED-TTL:PT1H",
+		"BEGIN:VEVENT",
+		"UID:workout-1@test",
+		"DTSTAMP:20240301T120000Z",
+		"DTSTART:20240701T163000Z",
+		"DURATION:PT1H15M",
+		`SUMMARY:Push\, heavy\; day`,
+		`DESCRIPTION:Bench Press: 3 × 5\nDips`,
+		"STATUS:CONFIRMED",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n"), b.String())
+}
+
+func TestWriteLineFolds(t *testing.T) {
+	calendar := &Calendar{Events: []Event{{Summary: strings.Repeat("é", 60)}}}
+	var b strings.Builder
+	require.NoError(t, calendar.Encode(&b))
+
+	var summary []string
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		assert.LessOrEqual(t, len(line), maxLineOctets)
+		if strings.HasPrefix(line, "SUMMARY:") || (len(summary) > 0 && strings.HasPrefix(line, " ")) {
+			summary = append(summary, line)
+		}
+	}
+	require.Len(t, summary, 2)
+	unfolded := summary[0] + strings.TrimPrefix(summary[1], " ")
+	assert.Equal(t, "SUMMARY:"+strings.Repeat("é", 60), unfolded, "no UTF-8 sequence is split")
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                  "PT0S",
+		45 * time.Second:                   "PT45S",
+		time.Hour:                          "PT1H",
+		90 * time.Minute:                   "PT1H30M",
+		26*time.Hour + 5*time.Second:       "P1DT2H5S",
+		48 * time.Hour:                     "P2D",
+		time.Minute + 400*time.Millisecond: "PT1M",
+	}
+	for d, want := range tests {
+		assert.Equal(t, want, FormatDuration(d), d.String())
+	}
+}
@@ -0,0 +1,376 @@
+// Package jsonpatch applies the two patch formats of JSON documents: JSON
+// Merge Patch (RFC 7396), which describes the result by example, and JSON
+// Patch (RFC 6902), a list of operations addressed by JSON Pointers
+// (RFC 6901). Documents are decoded with json.Number, so numbers the patch
+// does not touch come back exactly as they went in.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPatch is wrapped by the errors of malformed patch documents, as
+// opposed to patches that do not apply to the document.
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// MergePatch applies a JSON Merge Patch to the document: members of a patch
+// object replace those of the document, null removes them, and anything
+// other than an object replaces the document as a whole.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	d, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergeValue(d, p))
+}
+
+func mergeValue(doc, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]any)
+	if !ok {
+		docObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(docObject, key)
+			continue
+		}
+		docObject[key] = mergeValue(docObject[key], value)
+	}
+	return docObject
+}
+
+// Operation is one step of a JSON Patch.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies a JSON Patch to the document. The operations apply in
+// order and all or nothing: the first one that fails, including a failed
+// test, fails the patch.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	err := json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, fmt.Errorf("%w: a JSON Patch is an array of operations", ErrInvalidPatch)
+	}
+
+	d, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		d, err = op.apply(d)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i+1, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(d)
+}
+
+func (op Operation) apply(doc any) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		// a null value is kept as "null", so only a missing one is empty
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%w: %s needs a value", ErrInvalidPatch, op.Op)
+		}
+		value, err = decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" && isPrefix(from, path) && len(from) < len(path) {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		value, err = get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			doc, err = remove(doc, from)
+		} else {
+			value = deepCopy(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+	}
+
+	switch op.Op {
+	case "add", "move", "copy":
+		return add(doc, path, value)
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	default:
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	}
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex reads an array index token; "-" means past the end, which only
+// add accepts.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if token == "-" && end {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	limit := length - 1
+	if end {
+		limit = length
+	}
+	if i > limit {
+		return 0, fmt.Errorf("index %d is out of range", i)
+	}
+	return i, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%q cannot be looked up in a scalar", token)
+		}
+	}
+	return doc, nil
+}
+
+// add returns the document with the value added at the path, replacing a
+// member of an object or inserting into an array.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		node[token] = value
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(token, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node[:i], append([]any{value}, node[i:]...)...)
+		return set(doc, path[:len(path)-1], node)
+	default:
+		return nil, fmt.Errorf("%q cannot be added to a scalar", token)
+	}
+}
+
+func remove(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("the whole document cannot be removed")
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		if _, ok := node[token]; !ok {
+			return nil, fmt.Errorf("member %q does not exist", token)
+		}
+		delete(node, token)
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node[:i:i], node[i+1:]...)
+		return set(doc, path[:len(path)-1], node)
+	default:
+		return nil, fmt.Errorf("%q cannot be removed from a scalar", token)
+	}
+}
+
+// set stores a value at a path known to exist. Arrays change length when
+// added to or removed from, so their new slice is stored in their parent.
+func set(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		node[token] = value
+	case []any:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = value
+	}
+	return doc, nil
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, member := range v {
+			out[key] = deepCopy(member)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, element := range v {
+			out[i] = deepCopy(element)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// equal compares JSON values as test requires: numbers by value, objects
+// regardless of member order.
+func equal(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		m, okX := new(big.Float).SetString(x.String())
+		n, okY := new(big.Float).SetString(y.String())
+		return okX && okY && m.Cmp(n) == 0
+	default:
+		return a == b
+	}
+}
+
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
@@ -0,0 +1,102 @@
+package jsonpatch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	// the examples of RFC 7396, appendix A
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, tt.want, string(got), "%s patched with %s", tt.doc, tt.patch)
+	}
+
+	_, err := MergePatch([]byte(`{}`), []byte(`{"a":`))
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+}
+
+func TestApply(t *testing.T) {
+	// mostly the examples of RFC 6902, appendix A
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"add a member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add to an array", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"append to an array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"remove a member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove from an array", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move a member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move in an array", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"copy", `{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`, `{"a":{"b":[1]},"c":{"b":[1,2]}}`},
+		{"escaped pointers", `{"a/b":1,"m~n":2}`, `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`},
+		{"test by value", `{"baz":"qux","foo":["a",2,"c"],"n":1.0}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2},{"op":"test","path":"/n","value":1}]`,
+			`{"baz":"qux","foo":["a",2,"c"],"n":1.0}`},
+		{"add null", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"baz":null,"foo":"bar"}`},
+		{"replace the document", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	doc := []byte(`{"foo":"bar","list":[1,2]}`)
+	tests := []struct {
+		name, patch string
+		invalid     bool
+	}{
+		{"not an array", `{"op":"add"}`, true},
+		{"unknown op", `[{"op":"frobnicate","path":"/foo"}]`, true},
+		{"missing value", `[{"op":"add","path":"/baz"}]`, true},
+		{"relative path", `[{"op":"remove","path":"foo"}]`, true},
+		{"missing member", `[{"op":"remove","path":"/baz"}]`, false},
+		{"missing parent", `[{"op":"add","path":"/baz/bat","value":"qux"}]`, false},
+		{"index out of range", `[{"op":"add","path":"/list/3","value":3}]`, false},
+		{"leading zero", `[{"op":"replace","path":"/list/01","value":3}]`, false},
+		{"failed test", `[{"op":"test","path":"/foo","value":"baz"}]`, false},
+		{"move into itself", `[{"op":"move","from":"/list","path":"/list/0"}]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Apply(doc, []byte(tt.patch))
+			require.Error(t, err)
+			assert.Equal(t, tt.invalid, errors.Is(err, ErrInvalidPatch), err.Error())
+		})
+	}
+
+	// a failing operation leaves nothing half applied
+	_, err := Apply(doc, []byte(`[{"op":"replace","path":"/foo","value":"baz"},{"op":"test","path":"/foo","value":"bar"}]`))
+	assert.Error(t, err)
+	assert.JSONEq(t, `{"foo":"bar","list":[1,2]}`, string(doc))
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/fsrn12/fitness_tracker_go/internal/utils"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// DefaultIdempotencyTTL is how long a key is kept unless told otherwise.
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	maxIdempotencyKeyLength = 255
+	// the body is read whole to fingerprint it; this is above the largest
+	// upload any handler accepts
+	maxIdempotentBodySize = 64 << 20
+)
+
+// IdempotencyMiddleware makes writes sent with an Idempotency-Key header
+// safe to retry. The first request with a key runs and its response is
+// kept; a retry with the same key and the same request gets that response
+// replayed, marked with an Idempotent-Replayed header, instead of running
+// again. Keys belong to a user and expire after TTL.
+type IdempotencyMiddleware struct {
+	Store  store.IdempotencyStore
+	TTL    time.Duration
+	Logger *log.Logger
+}
+
+func (im *IdempotencyMiddleware) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		user := GetUser(r)
+		if key == "" || !isWrite(r.Method) || user.IsAnonymous() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "request body too large"})
+			return
+		}
+		if err != nil {
+			utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+		held, err := im.Store.ClaimKey(user.ID, key, fingerprint, im.TTL)
+		if err != nil {
+			im.Logger.Printf("ERROR - ClaimKey(): %v\n", err)
+			utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if held != nil {
+			switch {
+			case held.Fingerprint != fingerprint:
+				utils.WriteJSON(w, http.StatusUnprocessableEntity, utils.Envelope{"error": "Idempotency-Key was already used for a different request"})
+			case held.Response == nil:
+				utils.WriteJSON(w, http.StatusConflict, utils.Envelope{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				replayResponse(w, held.Response)
+			}
+			return
+		}
+
+		// the key is freed unless a response was kept, so that a request
+		// that failed or panicked can be retried
+		kept := false
+		defer func() {
+			if kept {
+				return
+			}
+			err := im.Store.ReleaseKey(user.ID, key)
+			if err != nil {
+				im.Logger.Printf("ERROR - ReleaseKey(): %v\n", err)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		response := recorder.response()
+		// server errors are not the request's fault; retries run it again
+		if response.StatusCode >= http.StatusInternalServerError {
+			return
+		}
+		err = im.Store.SaveResponse(user.ID, key, response)
+		if err != nil {
+			im.Logger.Printf("ERROR - SaveResponse(): %v\n", err)
+			return
+		}
+		kept = true
+	})
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestFingerprint tells requests apart by method, URL and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, response *store.StoredResponse) {
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(response.StatusCode)
+	w.Write(response.Body)
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+		rr.header = rr.Header().Clone()
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.WriteHeader(http.StatusOK)
+	}
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
+
+func (rr *responseRecorder) response() *store.StoredResponse {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+		rr.header = rr.Header().Clone()
+	}
+	return &store.StoredResponse{StatusCode: rr.status, Header: rr.header, Body: rr.body.Bytes()}
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type heldKey struct {
+	userID int
+	key    string
+}
+
+// fakeIdempotencyStore keeps keys in memory, the way the Postgres store
+// keeps them in a table.
+type fakeIdempotencyStore struct {
+	keys map[heldKey]*store.IdempotencyKey
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{keys: map[heldKey]*store.IdempotencyKey{}}
+}
+
+func (fs *fakeIdempotencyStore) ClaimKey(userID int, key, fingerprint string, ttl time.Duration) (*store.IdempotencyKey, error) {
+	held, ok := fs.keys[heldKey{userID, key}]
+	if ok && held.ExpiresAt.After(time.Now()) {
+		claimed := *held
+		return &claimed, nil
+	}
+	fs.keys[heldKey{userID, key}] = &store.IdempotencyKey{Key: key, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(ttl)}
+	return nil, nil
+}
+
+func (fs *fakeIdempotencyStore) SaveResponse(userID int, key string, response *store.StoredResponse) error {
+	held, ok := fs.keys[heldKey{userID, key}]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	held.Response = response
+	return nil
+}
+
+func (fs *fakeIdempotencyStore) ReleaseKey(userID int, key string) error {
+	held, ok := fs.keys[heldKey{userID, key}]
+	if ok && held.Response == nil {
+		delete(fs.keys, heldKey{userID, key})
+	}
+	return nil
+}
+
+func (fs *fakeIdempotencyStore) DeleteExpiredKeys() (int64, error) {
+	return 0, nil
+}
+
+func newIdempotencyMiddleware(keys store.IdempotencyStore) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		Store:  keys,
+		TTL:    time.Hour,
+		Logger: log.New(io.Discard, "", 0),
+	}
+}
+
+func idempotentRequest(key, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/workouts", strings.NewReader(body))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	return SetUser(r, &store.User{ID: 1})
+}
+
+func serve(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	runs := 0
+	handler := newIdempotencyMiddleware(keys).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"data":{"id":1}}`))
+	}))
+
+	first := serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	retry := serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, `{"data":{"id":1}}`, retry.Body.String())
+	assert.Equal(t, 1, runs, "a replay does not run the request again")
+
+	// without a key, or for another user, the request runs as usual
+	r := idempotentRequest("", `{"title":"Push"}`)
+	assert.Equal(t, http.StatusCreated, serve(handler, r).Code)
+	r = SetUser(idempotentRequest("retry-1", `{"title":"Push"}`), &store.User{ID: 2})
+	assert.Empty(t, serve(handler, r).Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 3, runs)
+}
+
+func TestIdempotentFingerprintMismatch(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	runs := 0
+	handler := newIdempotencyMiddleware(keys).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+	w := serve(handler, idempotentRequest("retry-1", `{"title":"Pull"}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, runs)
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	var handler http.Handler
+	var duplicate *httptest.ResponseRecorder
+	handler = newIdempotencyMiddleware(keys).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a retry arrives while the first request is still running
+		if duplicate == nil {
+			duplicate = serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	w := serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	require.NotNil(t, duplicate)
+	assert.Equal(t, http.StatusConflict, duplicate.Code)
+}
+
+func TestIdempotentReleasesKey(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	runs := 0
+	status, panics := http.StatusInternalServerError, false
+	handler := newIdempotencyMiddleware(keys).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		if panics {
+			panic("handler failed")
+		}
+		w.WriteHeader(status)
+	}))
+
+	w := serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, keys.keys, "a server error frees the key")
+
+	panics = true
+	assert.Panics(t, func() {
+		serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+	})
+	assert.Empty(t, keys.keys, "a panic frees the key")
+
+	status, panics = http.StatusCreated, false
+	w = serve(handler, idempotentRequest("retry-1", `{"title":"Push"}`))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 3, runs, "each retry after a failure runs the request")
+	assert.NotNil(t, keys.keys[heldKey{1, "retry-1"}].Response, "a success keeps the response")
+}
@@ -40,7 +40,7 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		headerParts := strings.Split(authHeader, "")
+		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid authorization header"})
 			return
@@ -63,3 +63,15 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 
 	})
 }
+
+func (um *UserMiddleware) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUser(r)
+		if user.IsAnonymous() {
+			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "you must be logged in to access this route"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,157 @@
+// Package records finds personal records in a lifter's history of one
+// exercise. It is pure: callers load the history and persist the result.
+package records
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	TypeMaxWeight    = "max_weight"
+	TypeMaxReps      = "max_reps"
+	TypeMaxVolume    = "max_volume"
+	TypeEstimated1RM = "estimated_1rm"
+)
+
+var Types = []string{TypeMaxWeight, TypeMaxReps, TypeMaxVolume, TypeEstimated1RM}
+
+// maxEstimateReps is the highest rep count trusted for a 1RM estimate; past
+// this point the formulas drift too far from reality to call anything a PR.
+const maxEstimateReps = 12
+
+// Set is one completed working set. Weight is zero for bodyweight work.
+type Set struct {
+	Weight float64
+	Reps   int
+	RPE    *float64
+}
+
+// Performance is one logged entry of the exercise.
+type Performance struct {
+	WorkoutID   int
+	EntryID     int
+	PerformedAt time.Time
+	Sets        []Set
+}
+
+// Record is a personal best set by one performance. Weight and Reps describe
+// the set that earned it where that is meaningful; for max_reps Weight is the
+// load the rep record belongs to.
+type Record struct {
+	Type       string
+	WorkoutID  int
+	EntryID    int
+	AchievedAt time.Time
+	Value      float64
+	Weight     *float64
+	Reps       *int
+}
+
+// Detect replays history in chronological order and returns every record it
+// sets along the way, including the ones superseded later. The first time an
+// exercise is performed establishes its initial records, except max_reps:
+// reps at a weight are only a record once they beat an earlier set at that
+// weight.
+func Detect(history []Performance, estimate func(weight float64, reps int) float64) []Record {
+	history = append([]Performance(nil), history...)
+	sort.SliceStable(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if !a.PerformedAt.Equal(b.PerformedAt) {
+			return a.PerformedAt.Before(b.PerformedAt)
+		}
+		if a.WorkoutID != b.WorkoutID {
+			return a.WorkoutID < b.WorkoutID
+		}
+		return a.EntryID < b.EntryID
+	})
+
+	var records []Record
+	var bestWeight, bestVolume, bestEstimate float64
+	bestRepsAt := map[float64]int{}
+
+	for _, performance := range history {
+		record := func(recordType string, value float64, weight float64, reps int) {
+			records = append(records, Record{
+				Type:       recordType,
+				WorkoutID:  performance.WorkoutID,
+				EntryID:    performance.EntryID,
+				AchievedAt: performance.PerformedAt,
+				Value:      value,
+				Weight:     &weight,
+				Reps:       &reps,
+			})
+		}
+
+		var topSet *Set
+		var estimateSet *Set
+		var topEstimate, volume float64
+		repsAt := map[float64]int{}
+		for i := range performance.Sets {
+			set := &performance.Sets[i]
+			if set.Reps <= 0 {
+				continue
+			}
+			weight := roundWeight(set.Weight)
+
+			volume += weight * float64(set.Reps)
+			if topSet == nil || weight > roundWeight(topSet.Weight) ||
+				(weight == roundWeight(topSet.Weight) && set.Reps > topSet.Reps) {
+				topSet = set
+			}
+			if set.Reps > repsAt[weight] {
+				repsAt[weight] = set.Reps
+			}
+			if weight > 0 && set.Reps <= maxEstimateReps {
+				if e := estimate(weight, set.Reps); e > topEstimate {
+					topEstimate, estimateSet = e, set
+				}
+			}
+		}
+		if topSet == nil {
+			continue
+		}
+
+		if w := roundWeight(topSet.Weight); w > 0 && w > bestWeight {
+			bestWeight = w
+			record(TypeMaxWeight, w, w, topSet.Reps)
+		}
+
+		weights := make([]float64, 0, len(repsAt))
+		for weight := range repsAt {
+			weights = append(weights, weight)
+		}
+		sort.Float64s(weights)
+		for _, weight := range weights {
+			reps := repsAt[weight]
+			best, seen := bestRepsAt[weight]
+			if seen && reps <= best {
+				continue
+			}
+			bestRepsAt[weight] = reps
+			// the first time at a weight only sets the mark to beat;
+			// otherwise every new load would count as a rep record
+			if seen {
+				record(TypeMaxReps, float64(reps), weight, reps)
+			}
+		}
+
+		if volume = roundWeight(volume); volume > 0 && volume > bestVolume {
+			bestVolume = volume
+			record(TypeMaxVolume, volume, roundWeight(topSet.Weight), topSet.Reps)
+		}
+
+		if topEstimate = roundWeight(topEstimate); estimateSet != nil && topEstimate > bestEstimate {
+			bestEstimate = topEstimate
+			record(TypeEstimated1RM, topEstimate, roundWeight(estimateSet.Weight), estimateSet.Reps)
+		}
+	}
+
+	return records
+}
+
+// roundWeight keeps float noise from turning equal loads into different ones.
+func roundWeight(w float64) float64 {
+	return math.Round(w*100) / 100
+}
@@ -0,0 +1,54 @@
+package records
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func epley(weight float64, reps int) float64 {
+	if reps == 1 {
+		return weight
+	}
+	return weight * (1 + float64(reps)/30)
+}
+
+func TestDetect(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 18, 0, 0, 0, time.UTC) }
+
+	history := []Performance{
+		// listed out of order on purpose; Detect sorts by date
+		{WorkoutID: 3, EntryID: 30, PerformedAt: day(10), Sets: []Set{{Weight: 100, Reps: 5}, {Weight: 100, Reps: 6}}},
+		{WorkoutID: 1, EntryID: 10, PerformedAt: day(1), Sets: []Set{{Weight: 100, Reps: 5}, {Weight: 100, Reps: 5}}},
+		{WorkoutID: 2, EntryID: 20, PerformedAt: day(5), Sets: []Set{{Weight: 90, Reps: 8}, {Weight: 90, Reps: 8}, {Weight: 90, Reps: 8}}},
+	}
+
+	got := map[int][]string{}
+	for _, record := range Detect(history, epley) {
+		got[record.WorkoutID] = append(got[record.WorkoutID], record.Type)
+	}
+
+	// the first time at a weight sets no rep record, there being nothing
+	// to beat
+	assert.ElementsMatch(t, []string{TypeMaxWeight, TypeMaxVolume, TypeEstimated1RM}, got[1])
+	// 3x8 at 90 is more volume than 2x5 at 100, but its e1RM of 114 stays
+	// below the 116.7 from day one
+	assert.ElementsMatch(t, []string{TypeMaxVolume}, got[2])
+	// 6 reps at 100 beats 5 reps at 100 and lifts the e1RM to 120
+	assert.ElementsMatch(t, []string{TypeMaxReps, TypeEstimated1RM}, got[3])
+}
+
+func TestDetectBodyweight(t *testing.T) {
+	history := []Performance{
+		{WorkoutID: 1, EntryID: 1, PerformedAt: time.Unix(0, 0), Sets: []Set{{Reps: 8}, {Reps: 7}}},
+		{WorkoutID: 2, EntryID: 2, PerformedAt: time.Unix(100, 0), Sets: []Set{{Reps: 10}}},
+	}
+
+	records := Detect(history, epley)
+	assert.Len(t, records, 1)
+	assert.Equal(t, TypeMaxReps, records[0].Type)
+	assert.Equal(t, 2, records[0].WorkoutID)
+	assert.Equal(t, 0.0, *records[0].Weight)
+	assert.Equal(t, 10.0, records[0].Value)
+}
@@ -9,18 +9,98 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 	r := chi.NewRouter()
 	r.Group(func(r chi.Router) {
 		r.Use(app.Middleware.Authenticate)
-		r.Post("/workouts", app.WorkoutHandler.HandleCreateWorkout)
-		r.Get("/workouts/{id}", app.WorkoutHandler.HandleGetWorkoutByID)
-		r.Put("/workouts/{id}", app.WorkoutHandler.HandleUpdateWorkoutByID)
-		r.Delete("/workouts/{id}", app.WorkoutHandler.HandleDeleteWorkoutByID)
+
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequireUser)
+			r.Use(app.Idempotency.Idempotent)
+			r.Get("/workouts", app.WorkoutHandler.HandleListWorkouts)
+			r.Post("/workouts", app.WorkoutHandler.HandleCreateWorkout)
+			r.Get("/workouts/{id}", app.WorkoutHandler.HandleGetWorkoutByID)
+			r.Put("/workouts/{id}", app.WorkoutHandler.HandleUpdateWorkoutByID)
+			r.Patch("/workouts/{id}", app.WorkoutHandler.HandlePatchWorkoutByID)
+			r.Delete("/workouts/{id}", app.WorkoutHandler.HandleDeleteWorkoutByID)
+			r.Get("/workouts/trash", app.WorkoutHandler.HandleListTrash)
+			r.Post("/workouts/{id}/restore", app.WorkoutHandler.HandleRestoreWorkout)
+			r.Delete("/workouts/trash/{id}", app.WorkoutHandler.HandlePurgeWorkout)
+			r.Get("/workouts/{id}/revisions", app.WorkoutHandler.HandleListRevisions)
+			r.Get("/workouts/{id}/revisions/diff", app.WorkoutHandler.HandleDiffRevisions)
+			r.Get("/workouts/{id}/revisions/{revision}", app.WorkoutHandler.HandleGetRevision)
+			r.Post("/workouts/{id}/revisions/{revision}/revert", app.WorkoutHandler.HandleRevertWorkout)
+			r.Post("/workouts/{id}/entries", app.WorkoutHandler.HandleAddEntry)
+			r.Put("/workouts/{id}/entries/order", app.WorkoutHandler.HandleReorderEntries)
+			r.Get("/workouts/{id}/entries/{entryID}", app.WorkoutHandler.HandleGetEntry)
+			r.Patch("/workouts/{id}/entries/{entryID}", app.WorkoutHandler.HandlePatchEntry)
+			r.Delete("/workouts/{id}/entries/{entryID}", app.WorkoutHandler.HandleDeleteEntry)
+			r.Get("/workouts/{id}/entries/{entryID}/sets", app.WorkoutHandler.HandleListSets)
+			r.Post("/workouts/{id}/entries/{entryID}/sets", app.WorkoutHandler.HandleAddSet)
+			r.Patch("/workouts/{id}/entries/{entryID}/sets/{setIndex}", app.WorkoutHandler.HandlePatchSet)
+			r.Delete("/workouts/{id}/entries/{entryID}/sets/{setIndex}", app.WorkoutHandler.HandleDeleteSet)
+			r.Get("/workouts/{id}/activity", app.ActivityHandler.HandleGetActivity)
+			r.Get("/workouts/{id}/activity/file", app.ActivityHandler.HandleDownloadActivity)
+			r.Post("/workouts/import/activity", app.ActivityHandler.HandleImportActivity)
+			r.Get("/workouts/export", app.ExportHandler.HandleExportWorkouts)
+
+			r.Get("/imports", app.ImportHandler.HandleListImports)
+			r.Post("/imports", app.ImportHandler.HandleImport)
+			r.Get("/imports/{id}", app.ImportHandler.HandleGetImportByID)
+
+			r.Get("/exercises", app.ExerciseHandler.HandleSearchExercises)
+			r.Post("/exercises", app.ExerciseHandler.HandleCreateExercise)
+			r.Get("/exercises/{id}", app.ExerciseHandler.HandleGetExerciseByID)
+			r.Put("/exercises/{id}", app.ExerciseHandler.HandleUpdateExerciseByID)
+			r.Delete("/exercises/{id}", app.ExerciseHandler.HandleDeleteExerciseByID)
+
+			r.Get("/templates", app.TemplateHandler.HandleListTemplates)
+			r.Post("/templates", app.TemplateHandler.HandleCreateTemplate)
+			r.Get("/templates/{id}", app.TemplateHandler.HandleGetTemplateByID)
+			r.Put("/templates/{id}", app.TemplateHandler.HandleUpdateTemplateByID)
+			r.Delete("/templates/{id}", app.TemplateHandler.HandleDeleteTemplateByID)
+			r.Post("/templates/{id}/start", app.TemplateHandler.HandleStartTemplate)
+			r.Get("/templates/{id}/fit", app.TemplateHandler.HandleExportTemplate)
+
+			r.Get("/plans", app.PlanHandler.HandleListPlans)
+			r.Post("/plans", app.PlanHandler.HandleCreatePlan)
+			r.Get("/plans/{id}", app.PlanHandler.HandleGetPlanByID)
+			r.Put("/plans/{id}", app.PlanHandler.HandleUpdatePlanByID)
+			r.Delete("/plans/{id}", app.PlanHandler.HandleDeletePlanByID)
+			r.Get("/plans/{id}/fit", app.PlanHandler.HandleExportPlan)
+			r.Put("/plans/{id}/occurrences/{date}", app.PlanHandler.HandleSetOccurrence)
+			r.Delete("/plans/{id}/occurrences/{date}", app.PlanHandler.HandleClearOccurrence)
+			r.Get("/calendar", app.PlanHandler.HandleGetCalendar)
+			r.Post("/calendar/feed", app.FeedHandler.HandleCreateFeed)
+			r.Delete("/calendar/feed", app.FeedHandler.HandleDeleteFeed)
+
+			r.Get("/programs", app.ProgramHandler.HandleListPrograms)
+			r.Post("/programs", app.ProgramHandler.HandleCreateProgram)
+			r.Get("/programs/{id}", app.ProgramHandler.HandleGetProgramByID)
+			r.Put("/programs/{id}", app.ProgramHandler.HandleUpdateProgramByID)
+			r.Delete("/programs/{id}", app.ProgramHandler.HandleDeleteProgramByID)
+			r.Post("/programs/{id}/enrollments", app.ProgramHandler.HandleEnroll)
+			r.Get("/enrollments", app.ProgramHandler.HandleListEnrollments)
+			r.Delete("/enrollments/{id}", app.ProgramHandler.HandleDeleteEnrollmentByID)
+			r.Get("/enrollments/{id}/today", app.ProgramHandler.HandleGetTodaysSession)
+
+			r.Get("/records", app.RecordHandler.HandleListRecords)
+
+			r.Get("/body-weights", app.BodyWeightHandler.HandleListBodyWeights)
+			r.Post("/body-weights", app.BodyWeightHandler.HandleCreateBodyWeight)
+			r.Delete("/body-weights/{id}", app.BodyWeightHandler.HandleDeleteBodyWeightByID)
+
+			r.Get("/analytics/e1rm", app.AnalyticsHandler.HandleGetStrengthTrend)
+			r.Get("/analytics/volume", app.AnalyticsHandler.HandleGetVolume)
+
+			r.Put("/users/{id}", app.UserHandler.HandleUpdateUserByID)
+		})
+
 		r.Get("/users/{id}", app.UserHandler.HandleGetUserByID)
-		r.Put("/users/{id}", app.UserHandler.HandleUpdateUserByID)
 		r.Delete("/users/{id}", app.UserHandler.HandleDeleteUserByID)
 	})
 
 	r.Get("/health", app.HealthCheck)
 	r.Post("/users", app.UserHandler.HandleRegisterUser)
 	r.Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
+	// the feed token in the URL authenticates calendar apps, which cannot send headers
+	r.Get("/calendar/feed/{token}.ics", app.FeedHandler.HandleGetFeed)
 	// r.Post("/users", app.UserHandler.HandleCreateUser)
 	return r
 }
@@ -0,0 +1,374 @@
+// Package rrule parses and expands the subset of RFC 5545 recurrence rules a
+// training calendar needs: DAILY, WEEKLY, MONTHLY and YEARLY rules with
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST. Every rule
+// yields at most one occurrence per day, at the wall-clock time of its start,
+// so occurrences keep their local time across daylight saving changes.
+//
+// One simplification: in YEARLY rules BYDAY and BYMONTHDAY apply within the
+// BYMONTH months, or within the month of the start when BYMONTH is absent.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum is a BYDAY value such as MO, 2TU or -1FR. N is zero when the
+// rule means every such weekday in the period.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// maxPeriods bounds expansion of rules that can never match, such as
+// FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30.
+const maxPeriods = 10000
+
+// Parse reads an RRULE value, with or without the "RRULE:" prefix. Parts
+// outside the supported subset are rejected rather than silently ignored.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("rrule is empty")
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("rrule part %q must look like NAME=VALUE", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(value))
+			if !slices.Contains([]Frequency{Daily, Weekly, Monthly, Yearly}, rule.Freq) {
+				return nil, fmt.Errorf("FREQ=%s is not supported, expected DAILY, WEEKLY, MONTHLY or YEARLY", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 {
+				return nil, errors.New("INTERVAL must be a positive integer")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count < 1 {
+				return nil, errors.New("COUNT must be a positive integer")
+			}
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekdayNum, err := parseWeekdayNum(day)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, weekdayNum)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("BYMONTHDAY value %q must be between 1 and 31 or -31 and -1", day)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(month)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("BYMONTH value %q must be between 1 and 12", month)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			weekday, ok := weekdays[strings.ToUpper(value)]
+			if !ok {
+				return nil, fmt.Errorf("WKST value %q is not a weekday", value)
+			}
+			rule.WeekStart = weekday
+		default:
+			return nil, fmt.Errorf("rrule part %s is not supported", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, errors.New("numbered BYDAY values such as 2MO need FREQ=MONTHLY or FREQ=YEARLY")
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq == Weekly {
+		return nil, errors.New("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	return rule, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("BYDAY value %q is not a weekday", s)
+	}
+
+	weekday, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("BYDAY value %q is not a weekday", s)
+	}
+
+	var n int
+	if prefix := s[:len(s)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("BYDAY value %q must be numbered between 1 and 5 or -5 and -1", s)
+		}
+	}
+	return WeekdayNum{N: n, Weekday: weekday}, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if layout == "20060102" {
+				// a date-only UNTIL includes that whole day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("UNTIL value %q must look like 20250131 or 20250131T235959Z", s)
+}
+
+// String formats the rule back into RRULE syntax, without the prefix.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (d WeekdayNum) String() string {
+	if d.N == 0 {
+		return weekdayCode(d.Weekday)
+	}
+	return strconv.Itoa(d.N) + weekdayCode(d.Weekday)
+}
+
+func weekdayCode(weekday time.Weekday) string {
+	for code, day := range weekdays {
+		if day == weekday {
+			return code
+		}
+	}
+	return ""
+}
+
+// Between returns the occurrences of the rule starting at dtstart that fall
+// within [from, to), in dtstart's location. COUNT is applied from dtstart,
+// not from the beginning of the window.
+func (r *Rule) Between(dtstart, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	count := 0
+	for period := 0; period < maxPeriods; period++ {
+		for _, t := range r.candidates(dtstart, period) {
+			if t.Before(dtstart) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return occurrences
+			}
+			if !t.Before(to) {
+				return occurrences
+			}
+			count++
+			if !t.Before(from) {
+				occurrences = append(occurrences, t)
+			}
+			if r.Count > 0 && count >= r.Count {
+				return occurrences
+			}
+		}
+	}
+	return occurrences
+}
+
+// candidates lists the matching days of the n-th period after dtstart,
+// in order, at dtstart's time of day.
+func (r *Rule) candidates(dtstart time.Time, n int) []time.Time {
+	y, m, d := dtstart.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, dtstart.Location())
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case Daily:
+		day := at(y, m, d+n*r.Interval)
+		if r.matchesMonth(day) && r.matchesMonthDay(day) && r.matchesWeekday(day) {
+			days = append(days, day)
+		}
+	case Weekly:
+		offset := (int(dtstart.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := at(y, m, d-offset+n*r.Interval*7)
+		for i := range 7 {
+			day := at(weekStart.Year(), weekStart.Month(), weekStart.Day()+i)
+			if !r.matchesMonth(day) {
+				continue
+			}
+			if len(r.ByDay) == 0 && day.Weekday() != dtstart.Weekday() {
+				continue
+			}
+			if len(r.ByDay) > 0 && !r.matchesWeekday(day) {
+				continue
+			}
+			days = append(days, day)
+		}
+	case Monthly:
+		month := at(y, m+time.Month(n*r.Interval), 1)
+		if r.matchesMonth(month) {
+			days = r.daysOfMonth(month, d, at)
+		}
+	case Yearly:
+		year := y + n*r.Interval
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			days = append(days, r.daysOfMonth(at(year, month, 1), d, at)...)
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	}
+	return days
+}
+
+// daysOfMonth expands BYMONTHDAY and BYDAY within one month. Without either,
+// the rule repeats on dtstart's day of month and skips months too short for it.
+func (r *Rule) daysOfMonth(first time.Time, defaultDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	year, month := first.Year(), first.Month()
+	length := at(year, month+1, 0).Day()
+
+	var days []time.Time
+	for day := 1; day <= length; day++ {
+		t := at(year, month, day)
+		switch {
+		case len(r.ByMonthDay) == 0 && len(r.ByDay) == 0:
+			if day != defaultDay {
+				continue
+			}
+		case len(r.ByMonthDay) > 0 && !r.matchesMonthDay(t):
+			continue
+		case len(r.ByDay) > 0 && !r.matchesWeekday(t):
+			continue
+		}
+		days = append(days, t)
+	}
+	return days
+}
+
+func (r *Rule) matchesMonth(t time.Time) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, t.Month())
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	length := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, day := range r.ByMonthDay {
+		if day == t.Day() || (day < 0 && length+day+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday checks BYDAY; numbered values count weekdays within the month.
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	length := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	nth := (t.Day()-1)/7 + 1
+	nthFromEnd := -((length-t.Day())/7 + 1)
+	for _, day := range r.ByDay {
+		if day.Weekday != t.Weekday() {
+			continue
+		}
+		if day.N == 0 || day.N == nth || day.N == nthFromEnd {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dates(times []time.Time) []string {
+	out := make([]string, len(times))
+	for i, t := range times {
+		out[i] = t.Format(time.DateOnly)
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	rule, err := Parse("RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;INTERVAL=2")
+	require.NoError(t, err)
+	assert.Equal(t, Weekly, rule.Freq)
+	assert.Equal(t, 2, rule.Interval)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR", rule.String())
+
+	rule, err = Parse("FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20251231")
+	require.NoError(t, err)
+	assert.Equal(t, []WeekdayNum{{N: -1, Weekday: time.Friday}}, rule.ByDay)
+
+	for _, bad := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20250101",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=WEEKLY;BYSETPOS=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+	} {
+		_, err := Parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	// Wednesday 1 January 2025, 07:00 UTC
+	start := time.Date(2025, 1, 1, 7, 0, 0, 0, time.UTC)
+	window := func(from, to string) (time.Time, time.Time) {
+		f, _ := time.Parse(time.DateOnly, from)
+		t, _ := time.Parse(time.DateOnly, to)
+		return f, t
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		from  string
+		to    string
+		want  []string
+		start time.Time
+	}{
+		{
+			name: "mon wed fri",
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			from: "2025-01-01", to: "2025-01-11",
+			want: []string{"2025-01-01", "2025-01-03", "2025-01-06", "2025-01-08", "2025-01-10"},
+		},
+		{
+			name: "every other week on the start weekday",
+			rule: "FREQ=WEEKLY;INTERVAL=2",
+			from: "2025-01-01", to: "2025-02-01",
+			want: []string{"2025-01-01", "2025-01-15", "2025-01-29"},
+		},
+		{
+			name: "count is applied from the start, not the window",
+			rule: "FREQ=DAILY;COUNT=5",
+			from: "2025-01-04", to: "2025-02-01",
+			want: []string{"2025-01-04", "2025-01-05"},
+		},
+		{
+			name: "until is inclusive",
+			rule: "FREQ=DAILY;INTERVAL=3;UNTIL=20250107",
+			from: "2025-01-01", to: "2025-02-01",
+			want: []string{"2025-01-01", "2025-01-04", "2025-01-07"},
+		},
+		{
+			name: "last friday of the month",
+			rule: "FREQ=MONTHLY;BYDAY=-1FR",
+			from: "2025-01-01", to: "2025-04-01",
+			want: []string{"2025-01-31", "2025-02-28", "2025-03-28"},
+		},
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY",
+			start: time.Date(2025, 1, 31, 7, 0, 0, 0, time.UTC),
+			from:  "2025-01-01", to: "2025-06-01",
+			want: []string{"2025-01-31", "2025-03-31", "2025-05-31"},
+		},
+		{
+			name: "yearly in march and september on the first monday",
+			rule: "FREQ=YEARLY;BYMONTH=3,9;BYDAY=1MO",
+			from: "2025-01-01", to: "2026-12-31",
+			want: []string{"2025-03-03", "2025-09-01", "2026-03-02", "2026-09-07"},
+		},
+		{
+			name: "impossible rules end",
+			rule: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			from: "2025-01-01", to: "2030-01-01",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			require.NoError(t, err)
+			dtstart := start
+			if !tt.start.IsZero() {
+				dtstart = tt.start
+			}
+			from, to := window(tt.from, tt.to)
+			assert.Equal(t, tt.want, dates(rule.Between(dtstart, from, to)))
+		})
+	}
+}
+
+func TestBetweenKeepsWallClockAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=SA")
+	require.NoError(t, err)
+
+	// Berlin moves to summer time on 30 March 2025
+	start := time.Date(2025, 3, 29, 9, 0, 0, 0, berlin)
+	occurrences := rule.Between(start, start, start.AddDate(0, 0, 14))
+	require.Len(t, occurrences, 2)
+	for _, occurrence := range occurrences {
+		assert.Equal(t, 9, occurrence.Hour())
+	}
+	assert.Equal(t, 7*24*time.Hour-time.Hour, occurrences[1].Sub(occurrences[0]))
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/activity"
+)
+
+// ActivityTrack is the GPS or sensor recording a workout was imported from.
+// Raw is only loaded for downloads.
+type ActivityTrack struct {
+	ID        int              `json:"id"`
+	WorkoutID int              `json:"workout_id"`
+	UserID    int              `json:"user_id"`
+	Format    string           `json:"format"`
+	Filename  string           `json:"filename"`
+	Summary   activity.Summary `json:"summary"`
+	Raw       []byte           `json:"-"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// WorkoutFromSummary turns an imported track into a workout holding a
+// single cardio entry. The entry's duration is the moving time; the
+// workout's start and end span the whole recording.
+func WorkoutFromSummary(userID int, summary activity.Summary, exerciseName string) *Workout {
+	title := summary.Name
+	if title == "" {
+		title = exerciseName
+	}
+	startedAt, endedAt := summary.StartedAt, summary.EndedAt
+
+	entry := WorkoutEntry{
+		ExerciseName:        exerciseName,
+		Kind:                EntryKindCardio,
+		Sets:                1,
+		ElevationGainMeters: summary.ElevationGainMeters,
+		AvgHeartRate:        summary.AvgHeartRate,
+		MaxHeartRate:        summary.MaxHeartRate,
+		AvgCadence:          summary.AvgCadence,
+		AvgPower:            summary.AvgPower,
+		OrderIndex:          1,
+		Section:             SectionMain,
+	}
+	if summary.DistanceMeters > 0 {
+		km := math.Round(summary.DistanceMeters) / 1000
+		entry.Distance = &km
+		entry.DistanceUnit = "km"
+	}
+	seconds := summary.MovingSeconds
+	if seconds == 0 {
+		seconds = summary.ElapsedSeconds
+	}
+	if seconds > 0 {
+		entry.DurationSeconds = &seconds
+	}
+
+	workout := &Workout{
+		UserID:      userID,
+		Title:       title,
+		PerformedAt: startedAt,
+		Entries:     []WorkoutEntry{entry},
+	}
+	if summary.Calories != nil {
+		workout.CaloriesBurned = *summary.Calories
+	}
+	if endedAt.After(startedAt) {
+		workout.StartedAt, workout.EndedAt = &startedAt, &endedAt
+	}
+	return workout
+}
+
+type PostgresActivityStore struct {
+	db *sql.DB
+}
+
+func NewPostgresActivityStore(db *sql.DB) *PostgresActivityStore {
+	return &PostgresActivityStore{db: db}
+}
+
+type ActivityStore interface {
+	// ImportActivity saves the workout and the track it came from together.
+	ImportActivity(workout *Workout, track *ActivityTrack) error
+	GetActivityTrack(workoutID int64, userID int) (*ActivityTrack, error)
+	GetActivityFile(workoutID int64, userID int) (*ActivityTrack, error)
+}
+
+func (pg *PostgresActivityStore) ImportActivity(workout *Workout, track *ActivityTrack) error {
+	err := workout.ResolveTimes()
+	if err != nil {
+		return err
+	}
+
+	summary, err := json.Marshal(track.Summary)
+	if err != nil {
+		return err
+	}
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = insertWorkout(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	track.WorkoutID = workout.ID
+	track.UserID = workout.UserID
+	query := `
+	INSERT INTO activity_tracks (workout_id, user_id, format, filename, raw, summary)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at
+	`
+	err = tx.QueryRow(query, track.WorkoutID, track.UserID, track.Format, track.Filename, track.Raw, summary).Scan(&track.ID, &track.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresActivityStore) GetActivityTrack(workoutID int64, userID int) (*ActivityTrack, error) {
+	return pg.getActivityTrack(workoutID, userID, false)
+}
+
+func (pg *PostgresActivityStore) GetActivityFile(workoutID int64, userID int) (*ActivityTrack, error) {
+	return pg.getActivityTrack(workoutID, userID, true)
+}
+
+func (pg *PostgresActivityStore) getActivityTrack(workoutID int64, userID int, withRaw bool) (*ActivityTrack, error) {
+	raw := `NULL::bytea`
+	if withRaw {
+		raw = `t.raw`
+	}
+	query := `
+	SELECT t.id, t.workout_id, t.user_id, t.format, t.filename, t.summary, ` + raw + `, t.created_at
+	FROM activity_tracks t
+	INNER JOIN workouts w ON w.id = t.workout_id
+	WHERE t.workout_id = $1 AND t.user_id = $2 AND w.deleted_at IS NULL
+	`
+	track := &ActivityTrack{}
+	var summary []byte
+	err := pg.db.QueryRow(query, workoutID, userID).Scan(
+		&track.ID,
+		&track.WorkoutID,
+		&track.UserID,
+		&track.Format,
+		&track.Filename,
+		&summary,
+		&track.Raw,
+		&track.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(summary, &track.Summary)
+	if err != nil {
+		return nil, err
+	}
+	return track, nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/activity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSummary() activity.Summary {
+	start := time.Date(2025, 6, 1, 7, 0, 0, 0, time.UTC)
+	return activity.Summary{
+		Format:              activity.FormatGPX,
+		Sport:               "running",
+		Points:              600,
+		StartedAt:           start,
+		EndedAt:             start.Add(52 * time.Minute),
+		DistanceMeters:      10012.4,
+		ElapsedSeconds:      3120,
+		MovingSeconds:       3000,
+		ElevationGainMeters: FloatPtr(85),
+		AvgHeartRate:        IntPtr(151),
+		MaxHeartRate:        IntPtr(176),
+		Splits:              []activity.Split{{Number: 1, DistanceMeters: 1000, MovingSeconds: 298}},
+	}
+}
+
+func TestWorkoutFromSummary(t *testing.T) {
+	workout := WorkoutFromSummary(7, testSummary(), "Running")
+	require.NoError(t, workout.ResolveTimes())
+	assert.Equal(t, "Running", workout.Title)
+	assert.Equal(t, 52, workout.DurationMinutes)
+
+	require.Len(t, workout.Entries, 1)
+	entry := workout.Entries[0]
+	require.NoError(t, entry.ValidateKind())
+	assert.Equal(t, 10.012, *entry.Distance)
+	assert.Equal(t, 3000, *entry.DurationSeconds, "the entry takes the moving time")
+	assert.Equal(t, 176, *entry.MaxHeartRate)
+}
+
+func TestImportActivity(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	activityStore := NewPostgresActivityStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	workout := WorkoutFromSummary(owner.ID, testSummary(), "Running")
+	track := &ActivityTrack{Format: activity.FormatGPX, Filename: "run.gpx", Summary: testSummary(), Raw: []byte("<gpx/>")}
+	require.NoError(t, activityStore.ImportActivity(workout, track))
+	assert.Equal(t, workout.ID, track.WorkoutID)
+
+	fetched, err := activityStore.GetActivityTrack(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Nil(t, fetched.Raw)
+	assert.Equal(t, 10012.4, fetched.Summary.DistanceMeters)
+	require.Len(t, fetched.Summary.Splits, 1)
+
+	file, err := activityStore.GetActivityFile(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("<gpx/>"), file.Raw)
+
+	hidden, err := activityStore.GetActivityFile(int64(workout.ID), stranger.ID)
+	require.NoError(t, err)
+	assert.Nil(t, hidden)
+
+	saved, err := NewPostgresWorkoutStore(db).GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, saved.Entries, 1)
+	assert.Equal(t, EntryKindCardio, saved.Entries[0].Kind)
+}
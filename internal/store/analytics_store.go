@@ -0,0 +1,182 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+)
+
+type PostgresAnalyticsStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAnalyticsStore(db *sql.DB) *PostgresAnalyticsStore {
+	return &PostgresAnalyticsStore{db: db}
+}
+
+type AnalyticsStore interface {
+	// GetPerformances returns the user's completed working sets of one
+	// exercise performed within [from, to), oldest first.
+	GetPerformances(userID int, ref ExerciseRef, from, to *time.Time) ([]records.Performance, error)
+	// GetVolume totals training volume per bucket, oldest first.
+	GetVolume(filter VolumeFilter) ([]VolumePoint, error)
+}
+
+func (pg *PostgresAnalyticsStore) GetPerformances(userID int, ref ExerciseRef, from, to *time.Time) ([]records.Performance, error) {
+	return loadPerformances(pg.db, userID, ref, from, to)
+}
+
+const (
+	VolumeBucketDay   = "day"
+	VolumeBucketWeek  = "week"
+	VolumeBucketMonth = "month"
+
+	VolumeGroupExercise = "exercise"
+	VolumeGroupMuscle   = "muscle"
+)
+
+var (
+	VolumeBuckets = []string{VolumeBucketDay, VolumeBucketWeek, VolumeBucketMonth}
+	VolumeGroups  = []string{VolumeGroupExercise, VolumeGroupMuscle}
+)
+
+// UnclassifiedMuscle groups the volume of entries that are not linked to a
+// catalog exercise, so their muscles are unknown.
+const UnclassifiedMuscle = "unclassified"
+
+// VolumeFilter selects the workouts that count towards volume. TimeZone is
+// an IANA zone name deciding where days, weeks (starting Monday) and months
+// begin. GroupBy is empty for one total per bucket.
+type VolumeFilter struct {
+	UserID   int
+	From     *time.Time
+	To       *time.Time
+	Bucket   string
+	GroupBy  string
+	TimeZone string
+}
+
+// VolumePoint is the work done within one bucket, optionally for a single
+// exercise or muscle group. Sets only count completed working sets; entries
+// logged without a set log count as sets x reps x weight. Time under load
+// comes from timed sets and from the tempo of rep sets, so rep sets without
+// a tempo add nothing to it. Muscle group volume credits each primary muscle
+// of the exercise in full.
+type VolumePoint struct {
+	Period               string  `json:"period"`
+	ExerciseID           *int    `json:"exercise_id,omitempty"`
+	ExerciseName         string  `json:"exercise_name,omitempty"`
+	Muscle               string  `json:"muscle,omitempty"`
+	Workouts             int     `json:"workouts"`
+	Sets                 int     `json:"sets"`
+	Reps                 int     `json:"reps"`
+	Tonnage              float64 `json:"tonnage"`
+	TimeUnderLoadSeconds int     `json:"time_under_load_seconds"`
+}
+
+// entryVolumeQuery reduces every entry of the user's workouts to its volume,
+// reading the set log when there is one and the legacy aggregate otherwise.
+// A tempo's phases add up to the seconds one rep takes, "X" (explosive)
+// counting as one.
+const entryVolumeQuery = `
+	SELECT w.id AS workout_id, w.performed_at, e.exercise_id,
+		COALESCE(x.name, e.exercise_name) AS exercise_name, x.primary_muscles,
+		v.sets, v.reps, v.tonnage, v.tul
+	FROM workouts w
+	INNER JOIN workout_entries e ON e.workout_id = w.id
+	LEFT JOIN exercises x ON x.id = e.exercise_id
+	CROSS JOIN LATERAL (
+		SELECT COUNT(*) AS sets,
+			COALESCE(SUM(s.reps), 0) AS reps,
+			COALESCE(SUM(COALESCE(s.reps, 0) * COALESCE(s.weight, 0)), 0) AS tonnage,
+			COALESCE(SUM(COALESCE(s.duration_seconds, s.reps * (
+				SELECT SUM(CASE WHEN phase = 'X' THEN 1 ELSE phase::int END)
+				FROM regexp_split_to_table(replace(s.tempo, '-', ''), '') AS phase
+			), 0)), 0) AS tul
+		FROM workout_sets s
+		WHERE s.entry_id = e.id AND s.completed AND s.set_type <> 'warmup'
+		HAVING COUNT(*) > 0
+		UNION ALL
+		SELECT e.sets,
+			e.sets * COALESCE(e.reps, 0),
+			e.sets * COALESCE(e.reps, 0) * COALESCE(e.weight, 0),
+			e.sets * COALESCE(e.duration_seconds, 0)
+		WHERE NOT EXISTS (SELECT 1 FROM workout_sets s WHERE s.entry_id = e.id)
+	) v
+`
+
+func (pg *PostgresAnalyticsStore) GetVolume(filter VolumeFilter) ([]VolumePoint, error) {
+	args := []any{filter.UserID, filter.Bucket, filter.TimeZone}
+	// distance work is not lifting volume
+	conditions := []string{"w.user_id = $1", "w.deleted_at IS NULL", "e.kind <> 'cardio'"}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("w.performed_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("w.performed_at < $%d", len(args)))
+	}
+
+	// the group columns come from a fixed list, never from the request
+	var groupColumns, groupFrom string
+	switch filter.GroupBy {
+	case VolumeGroupExercise:
+		groupColumns = "ev.exercise_id, ev.exercise_name"
+	case VolumeGroupMuscle:
+		groupColumns = "m.muscle"
+		groupFrom = fmt.Sprintf(
+			" CROSS JOIN LATERAL unnest(COALESCE(NULLIF(ev.primary_muscles, '{}'), ARRAY['%s'])) AS m(muscle)",
+			UnclassifiedMuscle,
+		)
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown volume grouping %q", filter.GroupBy)
+	}
+
+	selectGroup, groupBy := "", ""
+	if groupColumns != "" {
+		selectGroup = groupColumns + ", "
+		groupBy = ", " + groupColumns
+	}
+
+	query := `
+	WITH ev AS (` + entryVolumeQuery + `	WHERE ` + strings.Join(conditions, " AND ") + `
+	)
+	SELECT to_char(date_trunc($2, ev.performed_at AT TIME ZONE $3), 'YYYY-MM-DD') AS period, ` + selectGroup + `
+		COUNT(DISTINCT ev.workout_id), SUM(ev.sets)::int, SUM(ev.reps)::int, SUM(ev.tonnage)::float8, SUM(ev.tul)::int
+	FROM ev` + groupFrom + `
+	GROUP BY period` + groupBy + `
+	ORDER BY period` + groupBy
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []VolumePoint{}
+	for rows.Next() {
+		var point VolumePoint
+		dest := []any{&point.Period}
+		switch filter.GroupBy {
+		case VolumeGroupExercise:
+			dest = append(dest, &point.ExerciseID, &point.ExerciseName)
+		case VolumeGroupMuscle:
+			dest = append(dest, &point.Muscle)
+		}
+		dest = append(dest, &point.Workouts, &point.Sets, &point.Reps, &point.Tonnage, &point.TimeUnderLoadSeconds)
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		point.Tonnage = math.Round(point.Tonnage*100) / 100
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
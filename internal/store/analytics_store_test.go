@@ -0,0 +1,75 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVolume(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	analyticsStore := NewPostgresAnalyticsStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	// Monday 02:00 UTC is still Sunday evening in New York
+	_, err := workoutStore.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Legacy squats",
+		PerformedAt:     time.Date(2025, 3, 3, 2, 0, 0, 0, time.UTC),
+		DurationMinutes: 30,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Back Squat", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = workoutStore.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Logged squats",
+		PerformedAt:     time.Date(2025, 3, 4, 12, 0, 0, 0, time.UTC),
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Back Squat", OrderIndex: 1, SetLog: []WorkoutSet{
+				{SetType: SetTypeWarmup, Reps: IntPtr(5), Weight: FloatPtr(60), Completed: true},
+				{SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(100), Tempo: "3010", Completed: true},
+				{SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(100), Completed: true},
+			}},
+			{ExerciseName: "Plank", OrderIndex: 2, SetLog: []WorkoutSet{
+				{SetType: SetTypeWorking, DurationSeconds: IntPtr(60), Completed: true},
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	points, err := analyticsStore.GetVolume(VolumeFilter{UserID: owner.ID, Bucket: VolumeBucketWeek, TimeZone: "UTC"})
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, VolumePoint{
+		Period:               "2025-03-03",
+		Workouts:             2,
+		Sets:                 6,
+		Reps:                 25,
+		Tonnage:              2500,
+		TimeUnderLoadSeconds: 80,
+	}, points[0])
+
+	points, err = analyticsStore.GetVolume(VolumeFilter{UserID: owner.ID, Bucket: VolumeBucketWeek, TimeZone: "America/New_York"})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, "2025-02-24", points[0].Period)
+	assert.Equal(t, 1500.0, points[0].Tonnage)
+	assert.Equal(t, "2025-03-03", points[1].Period)
+
+	points, err = analyticsStore.GetVolume(VolumeFilter{UserID: owner.ID, Bucket: VolumeBucketMonth, GroupBy: VolumeGroupExercise, TimeZone: "UTC"})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, "Back Squat", points[0].ExerciseName)
+	assert.Equal(t, 2500.0, points[0].Tonnage)
+	assert.Equal(t, "Plank", points[1].ExerciseName)
+	assert.Equal(t, 60, points[1].TimeUnderLoadSeconds)
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type BodyWeight struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	WeightKg   float64   `json:"weight_kg"`
+	MeasuredAt time.Time `json:"measured_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (b *BodyWeight) Validate() error {
+	if b.WeightKg <= 0 || b.WeightKg >= 1000 {
+		return errors.New("weight_kg must be between 0 and 1000")
+	}
+	if b.MeasuredAt.IsZero() {
+		b.MeasuredAt = time.Now()
+	}
+	return nil
+}
+
+// NearestBodyWeight picks what LatestBodyWeight would out of weights already
+// loaded: the last taken at or before at, else the first one after it.
+func NearestBodyWeight(weights []*BodyWeight, at time.Time) *BodyWeight {
+	var before, after *BodyWeight
+	for _, weight := range weights {
+		if !weight.MeasuredAt.After(at) {
+			if before == nil || weight.MeasuredAt.After(before.MeasuredAt) {
+				before = weight
+			}
+		} else if after == nil || weight.MeasuredAt.Before(after.MeasuredAt) {
+			after = weight
+		}
+	}
+	if before != nil {
+		return before
+	}
+	return after
+}
+
+type PostgresBodyWeightStore struct {
+	db *sql.DB
+}
+
+func NewPostgresBodyWeightStore(db *sql.DB) *PostgresBodyWeightStore {
+	return &PostgresBodyWeightStore{db: db}
+}
+
+type BodyWeightStore interface {
+	CreateBodyWeight(*BodyWeight) (*BodyWeight, error)
+	// ListBodyWeights returns the user's measurements within the optional
+	// [from, to) range, newest first.
+	ListBodyWeights(userID int, from, to *time.Time) ([]*BodyWeight, error)
+	DeleteBodyWeight(id int64, userID int) error
+	// LatestBodyWeight returns the last measurement taken at or before at,
+	// or the first one after it when there is none before; nil when the
+	// user never logged a weight.
+	LatestBodyWeight(userID int, at time.Time) (*BodyWeight, error)
+}
+
+const bodyWeightColumns = `b.id, b.user_id, b.weight_kg, b.measured_at, b.created_at`
+
+func scanBodyWeight(row rowScanner) (*BodyWeight, error) {
+	weight := &BodyWeight{}
+	err := row.Scan(&weight.ID, &weight.UserID, &weight.WeightKg, &weight.MeasuredAt, &weight.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return weight, nil
+}
+
+func (pg *PostgresBodyWeightStore) CreateBodyWeight(weight *BodyWeight) (*BodyWeight, error) {
+	query := `
+	INSERT INTO body_weights (user_id, weight_kg, measured_at)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at
+	`
+	err := pg.db.QueryRow(query, weight.UserID, weight.WeightKg, weight.MeasuredAt).Scan(&weight.ID, &weight.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return weight, nil
+}
+
+func (pg *PostgresBodyWeightStore) ListBodyWeights(userID int, from, to *time.Time) ([]*BodyWeight, error) {
+	query := `
+	SELECT ` + bodyWeightColumns + `
+	FROM body_weights b
+	WHERE b.user_id = $1
+		AND ($2::timestamptz IS NULL OR b.measured_at >= $2)
+		AND ($3::timestamptz IS NULL OR b.measured_at < $3)
+	ORDER BY b.measured_at DESC, b.id DESC
+	`
+	rows, err := pg.db.Query(query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weights := []*BodyWeight{}
+	for rows.Next() {
+		weight, err := scanBodyWeight(rows)
+		if err != nil {
+			return nil, err
+		}
+		weights = append(weights, weight)
+	}
+	return weights, rows.Err()
+}
+
+func (pg *PostgresBodyWeightStore) DeleteBodyWeight(id int64, userID int) error {
+	query := `DELETE FROM body_weights WHERE id = $1 AND user_id = $2`
+
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (pg *PostgresBodyWeightStore) LatestBodyWeight(userID int, at time.Time) (*BodyWeight, error) {
+	query := `
+	SELECT ` + bodyWeightColumns + `
+	FROM body_weights b
+	WHERE b.user_id = $1
+	ORDER BY b.measured_at > $2::timestamptz, abs(extract(epoch FROM b.measured_at - $2::timestamptz)), b.id DESC
+	LIMIT 1
+	`
+	weight, err := scanBodyWeight(pg.db.QueryRow(query, userID, at))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return weight, nil
+}
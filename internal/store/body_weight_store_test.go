@@ -0,0 +1,98 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillCalories(t *testing.T) {
+	user := &User{}
+	weight := &BodyWeight{WeightKg: 80}
+
+	workout := &Workout{DurationMinutes: 60}
+	workout.FillCalories(user, weight)
+	assert.Equal(t, CaloriesEstimated, workout.CaloriesSource)
+	assert.Equal(t, 344, workout.CaloriesBurned)
+
+	// an estimate is redone, and dropped once there is no weight to go on
+	workout.DurationMinutes = 30
+	workout.FillCalories(user, weight)
+	assert.Equal(t, 172, workout.CaloriesBurned)
+	workout.FillCalories(user, nil)
+	assert.Equal(t, CaloriesNone, workout.CaloriesSource)
+	assert.Zero(t, workout.CaloriesBurned)
+
+	measured := &Workout{DurationMinutes: 60, CaloriesBurned: 610}
+	measured.FillCalories(user, weight)
+	assert.Equal(t, CaloriesMeasured, measured.CaloriesSource)
+	assert.Equal(t, 610, measured.CaloriesBurned)
+}
+
+func TestCalorieProfile(t *testing.T) {
+	sex, birthDate := "female", "1990-06-15"
+	user := &User{Sex: &sex, BirthDate: &birthDate}
+
+	profile := user.CalorieProfile(62, time.Date(2025, 6, 14, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 34, profile.Age)
+	assert.Equal(t, "female", profile.Sex)
+	assert.Equal(t, 35, user.CalorieProfile(62, time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)).Age)
+	assert.Zero(t, (&User{}).CalorieProfile(62, time.Now()).Age)
+}
+
+func TestLatestBodyWeight(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresBodyWeightStore(db)
+	owner := createTestUser(t, db, "owner")
+	other := createTestUser(t, db, "other")
+
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 8, 0, 0, 0, time.UTC) }
+	for _, weight := range []*BodyWeight{
+		{UserID: owner.ID, WeightKg: 81.5, MeasuredAt: day(10)},
+		{UserID: owner.ID, WeightKg: 80.2, MeasuredAt: day(20)},
+		{UserID: other.ID, WeightKg: 64, MeasuredAt: day(15)},
+	} {
+		_, err := store.CreateBodyWeight(weight)
+		require.NoError(t, err)
+	}
+
+	all, err := store.ListBodyWeights(owner.ID, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, 80.2, all[0].WeightKg, "newest first")
+
+	for _, tc := range []struct {
+		at   time.Time
+		want float64
+	}{
+		{day(15), 81.5},
+		{day(20), 80.2},
+		{day(25), 80.2},
+		{day(1), 81.5},
+	} {
+		latest, err := store.LatestBodyWeight(owner.ID, tc.at)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, latest.WeightKg, tc.at)
+		assert.Equal(t, tc.want, NearestBodyWeight(all, tc.at).WeightKg, tc.at)
+	}
+
+	none, err := store.LatestBodyWeight(createTestUser(t, db, "new").ID, day(15))
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	// the calories and where they came from survive a round trip
+	workouts := NewPostgresWorkoutStore(db)
+	workout := &Workout{UserID: owner.ID, Title: "Easy run", PerformedAt: day(15), DurationMinutes: 30}
+	workout.FillCalories(owner, NearestBodyWeight(all, workout.PerformedAt))
+	_, err = workouts.CreateWorkout(workout)
+	require.NoError(t, err)
+
+	retrieved, err := workouts.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, CaloriesEstimated, retrieved.CaloriesSource)
+	assert.Equal(t, workout.CaloriesBurned, retrieved.CaloriesBurned)
+}
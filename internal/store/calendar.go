@@ -0,0 +1,107 @@
+package store
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	OccurrencePlanned = "planned"
+	OccurrenceMissed  = "missed"
+)
+
+// Occurrence is one expanded session of a planned workout. Status is
+// completed or skipped when recorded, otherwise missed once its local day
+// has passed and planned before that.
+type Occurrence struct {
+	PlannedWorkoutID int       `json:"planned_workout_id"`
+	TemplateID       *int      `json:"template_id"`
+	Title            string    `json:"title"`
+	Date             string    `json:"date"`
+	StartsAt         time.Time `json:"starts_at"`
+	DurationMinutes  *int      `json:"duration_minutes"`
+	Status           string    `json:"status"`
+	WorkoutID        *int      `json:"workout_id"`
+}
+
+// Adherence compares planned and done training. Rate is the share of due
+// occurrences (completed, skipped or missed) that were completed, and nil
+// while nothing is due yet.
+type Adherence struct {
+	Planned   int      `json:"planned"`
+	Completed int      `json:"completed"`
+	Skipped   int      `json:"skipped"`
+	Missed    int      `json:"missed"`
+	Upcoming  int      `json:"upcoming"`
+	Rate      *float64 `json:"rate"`
+}
+
+// BuildCalendar expands every plan over the local days [fromDate, toDate),
+// attaches the recorded statuses and tallies adherence as of now.
+func BuildCalendar(plans []*PlannedWorkout, statuses []OccurrenceStatus, fromDate, toDate, now time.Time) ([]Occurrence, Adherence, error) {
+	type key struct {
+		planID int
+		date   string
+	}
+	recorded := make(map[key]OccurrenceStatus, len(statuses))
+	for _, status := range statuses {
+		recorded[key{status.PlannedWorkoutID, status.Date}] = status
+	}
+
+	occurrences := []Occurrence{}
+	var adherence Adherence
+	for _, plan := range plans {
+		times, err := plan.Occurrences(fromDate, toDate)
+		if err != nil {
+			return nil, Adherence{}, err
+		}
+		loc, _ := plan.location()
+		today := now.In(loc).Format(time.DateOnly)
+
+		for _, t := range times {
+			occurrence := Occurrence{
+				PlannedWorkoutID: plan.ID,
+				TemplateID:       plan.TemplateID,
+				Title:            plan.Title,
+				Date:             t.Format(time.DateOnly),
+				StartsAt:         t,
+				DurationMinutes:  plan.DurationMinutes,
+				Status:           OccurrencePlanned,
+			}
+
+			if status, ok := recorded[key{plan.ID, occurrence.Date}]; ok {
+				occurrence.Status = status.Status
+				occurrence.WorkoutID = status.WorkoutID
+			} else if occurrence.Date < today {
+				occurrence.Status = OccurrenceMissed
+			}
+
+			adherence.Planned++
+			switch occurrence.Status {
+			case OccurrenceCompleted:
+				adherence.Completed++
+			case OccurrenceSkipped:
+				adherence.Skipped++
+			case OccurrenceMissed:
+				adherence.Missed++
+			default:
+				adherence.Upcoming++
+			}
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	if due := adherence.Completed + adherence.Skipped + adherence.Missed; due > 0 {
+		rate := math.Round(float64(adherence.Completed)/float64(due)*1000) / 1000
+		adherence.Rate = &rate
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if !occurrences[i].StartsAt.Equal(occurrences[j].StartsAt) {
+			return occurrences[i].StartsAt.Before(occurrences[j].StartsAt)
+		}
+		return occurrences[i].PlannedWorkoutID < occurrences[j].PlannedWorkoutID
+	})
+	return occurrences, adherence, nil
+}
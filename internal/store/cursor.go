@@ -0,0 +1,44 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the keyset position a list query resumes from. It is handed to
+// clients as an opaque base64 string so the format can change freely.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	js, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string, sort string) (*cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	// a cursor is only meaningful for the ordering it was produced by
+	if c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/fuzzy"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var MuscleGroups = []string{
+	"chest", "back", "lats", "traps", "shoulders", "biceps", "triceps", "forearms",
+	"abs", "obliques", "lower_back", "glutes", "quadriceps", "hamstrings", "calves",
+	"adductors", "abductors", "full_body",
+}
+
+var EquipmentTypes = []string{
+	"none", "barbell", "dumbbell", "kettlebell", "machine", "cable", "bodyweight", "band", "other",
+}
+
+var MovementTypes = []string{
+	"push", "pull", "squat", "hinge", "lunge", "carry", "core", "isolation", "cardio", "other",
+}
+
+var ErrDuplicateExercise = errors.New("an exercise with this name already exists")
+
+type Exercise struct {
+	ID               int       `json:"id"`
+	UserID           *int      `json:"user_id"`
+	Name             string    `json:"name"`
+	Aliases          []string  `json:"aliases"`
+	PrimaryMuscles   []string  `json:"primary_muscles"`
+	SecondaryMuscles []string  `json:"secondary_muscles"`
+	Equipment        string    `json:"equipment"`
+	MovementType     string    `json:"movement_type"`
+	Custom           bool      `json:"custom"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type ExerciseFilter struct {
+	UserID       int
+	Query        string
+	Muscle       string
+	Equipment    string
+	MovementType string
+	Limit        int
+}
+
+const (
+	DefaultExerciseLimit = 50
+	MaxExerciseLimit     = 200
+)
+
+type PostgresExerciseStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExerciseStore(db *sql.DB) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: db}
+}
+
+type ExerciseStore interface {
+	CreateExercise(*Exercise) (*Exercise, error)
+	GetExerciseByID(id int64, userID int) (*Exercise, error)
+	UpdateExercise(*Exercise) error
+	DeleteExercise(id int64, userID int) error
+	SearchExercises(filter ExerciseFilter) ([]*Exercise, error)
+	// MatchExercises resolves free-text names against the exercises visible
+	// to the user. The result is aligned with names; unmatched names are nil.
+	MatchExercises(userID int, names []string) ([]*Exercise, error)
+}
+
+const exerciseColumns = `x.id, x.user_id, x.name, x.aliases, x.primary_muscles, x.secondary_muscles,
+	x.equipment, x.movement_type, x.created_at, x.updated_at`
+
+func scanExercise(row rowScanner) (*Exercise, error) {
+	exercise := &Exercise{}
+	var userID sql.NullInt64
+	m := pgtype.NewMap()
+	err := row.Scan(
+		&exercise.ID,
+		&userID,
+		&exercise.Name,
+		m.SQLScanner(&exercise.Aliases),
+		m.SQLScanner(&exercise.PrimaryMuscles),
+		m.SQLScanner(&exercise.SecondaryMuscles),
+		&exercise.Equipment,
+		&exercise.MovementType,
+		&exercise.CreatedAt,
+		&exercise.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID.Valid {
+		id := int(userID.Int64)
+		exercise.UserID = &id
+		exercise.Custom = true
+	}
+	return exercise, nil
+}
+
+func (pg *PostgresExerciseStore) CreateExercise(exercise *Exercise) (*Exercise, error) {
+	query := `
+	INSERT INTO exercises (user_id, name, aliases, primary_muscles, secondary_muscles, equipment, movement_type)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at, updated_at
+	`
+	err := pg.db.QueryRow(query,
+		exercise.UserID,
+		exercise.Name,
+		nonNilStrings(exercise.Aliases),
+		nonNilStrings(exercise.PrimaryMuscles),
+		nonNilStrings(exercise.SecondaryMuscles),
+		exercise.Equipment,
+		exercise.MovementType,
+	).Scan(&exercise.ID, &exercise.CreatedAt, &exercise.UpdatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateExercise
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	exercise.Custom = exercise.UserID != nil
+	return exercise, nil
+}
+
+// GetExerciseByID returns a built-in exercise or one of the user's own.
+func (pg *PostgresExerciseStore) GetExerciseByID(id int64, userID int) (*Exercise, error) {
+	query := `
+	SELECT ` + exerciseColumns + `
+	FROM exercises x
+	WHERE x.id = $1 AND (x.user_id IS NULL OR x.user_id = $2)
+	`
+	exercise, err := scanExercise(pg.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exercise, nil
+}
+
+// UpdateExercise only ever touches custom exercises owned by exercise.UserID;
+// the built-in library is read-only.
+func (pg *PostgresExerciseStore) UpdateExercise(exercise *Exercise) error {
+	if exercise.UserID == nil {
+		return sql.ErrNoRows
+	}
+
+	query := `
+	UPDATE exercises
+	SET name = $1, aliases = $2, primary_muscles = $3, secondary_muscles = $4,
+		equipment = $5, movement_type = $6, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $7 AND user_id = $8
+	RETURNING updated_at
+	`
+	err := pg.db.QueryRow(query,
+		exercise.Name,
+		nonNilStrings(exercise.Aliases),
+		nonNilStrings(exercise.PrimaryMuscles),
+		nonNilStrings(exercise.SecondaryMuscles),
+		exercise.Equipment,
+		exercise.MovementType,
+		exercise.ID,
+		*exercise.UserID,
+	).Scan(&exercise.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateExercise
+	}
+	return err
+}
+
+func (pg *PostgresExerciseStore) DeleteExercise(id int64, userID int) error {
+	query := `DELETE FROM exercises WHERE id = $1 AND user_id = $2`
+
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresExerciseStore) SearchExercises(filter ExerciseFilter) ([]*Exercise, error) {
+	if filter.Limit <= 0 || filter.Limit > MaxExerciseLimit {
+		filter.Limit = DefaultExerciseLimit
+	}
+
+	conditions := []string{"(x.user_id IS NULL OR x.user_id = $1)"}
+	args := []any{filter.UserID}
+	addCondition := func(format string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Query != "" {
+		addCondition(`(x.name ILIKE $%[1]d OR EXISTS (
+			SELECT 1 FROM unnest(x.aliases) a WHERE a ILIKE $%[1]d
+		))`, "%"+escapeLike(filter.Query)+"%")
+	}
+	if filter.Muscle != "" {
+		addCondition("($%[1]d = ANY(x.primary_muscles) OR $%[1]d = ANY(x.secondary_muscles))", filter.Muscle)
+	}
+	if filter.Equipment != "" {
+		addCondition("x.equipment = $%d", filter.Equipment)
+	}
+	if filter.MovementType != "" {
+		addCondition("x.movement_type = $%d", filter.MovementType)
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM exercises x
+	WHERE %s
+	ORDER BY x.user_id IS NULL, x.name
+	LIMIT $%d
+	`, exerciseColumns, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []*Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+	return exercises, rows.Err()
+}
+
+func (pg *PostgresExerciseStore) MatchExercises(userID int, names []string) ([]*Exercise, error) {
+	matches := make([]*Exercise, len(names))
+	if len(names) == 0 {
+		return matches, nil
+	}
+
+	query := `
+	SELECT ` + exerciseColumns + `
+	FROM exercises x
+	WHERE x.user_id IS NULL OR x.user_id = $1
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []*Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]fuzzy.Candidate, len(exercises))
+	for i, exercise := range exercises {
+		candidates[i] = fuzzy.Candidate{
+			Index: i,
+			Names: append([]string{exercise.Name}, exercise.Aliases...),
+		}
+	}
+
+	for i, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		if best := fuzzy.Best(name, candidates); best >= 0 {
+			matches[i] = exercises[best]
+		}
+	}
+	return matches, nil
+}
+
+func ValidMuscleGroup(muscle string) bool {
+	return slices.Contains(MuscleGroups, muscle)
+}
+
+func ValidEquipment(equipment string) bool {
+	return slices.Contains(EquipmentTypes, equipment)
+}
+
+func ValidMovementType(movementType string) bool {
+	return slices.Contains(MovementTypes, movementType)
+}
+
+// nonNilStrings keeps NOT NULL array columns from receiving a NULL.
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchExercises(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresExerciseStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	custom, err := store.CreateExercise(&Exercise{
+		UserID:         &owner.ID,
+		Name:           "Zercher Squat",
+		PrimaryMuscles: []string{"quadriceps"},
+		Equipment:      "barbell",
+		MovementType:   "squat",
+	})
+	require.NoError(t, err)
+
+	_, err = store.CreateExercise(&Exercise{
+		UserID:         &owner.ID,
+		Name:           "zercher squat",
+		PrimaryMuscles: []string{"quadriceps"},
+		Equipment:      "barbell",
+		MovementType:   "squat",
+	})
+	assert.ErrorIs(t, err, ErrDuplicateExercise)
+
+	matches, err := store.MatchExercises(owner.ID, []string{"Bench Press", "bench", "BB bench", "zercher squats", "Underwater Basket Weaving"})
+	require.NoError(t, err)
+	require.Len(t, matches, 5)
+	for _, match := range matches[:3] {
+		require.NotNil(t, match)
+		assert.Equal(t, "Barbell Bench Press", match.Name)
+	}
+	require.NotNil(t, matches[3])
+	assert.Equal(t, custom.ID, matches[3].ID)
+	assert.Nil(t, matches[4])
+
+	// custom exercises are private to their owner
+	matches, err = store.MatchExercises(stranger.ID, []string{"zercher squats"})
+	require.NoError(t, err)
+	if matches[0] != nil {
+		assert.NotEqual(t, custom.ID, matches[0].ID)
+	}
+
+	found, err := store.GetExerciseByID(int64(custom.ID), stranger.ID)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
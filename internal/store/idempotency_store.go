@@ -0,0 +1,148 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKey is an Idempotency-Key a user sent with a write, with the
+// fingerprint of the request that first used it.
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	// Response is nil while the first request with the key is in progress.
+	Response  *StoredResponse
+	ExpiresAt time.Time
+}
+
+// StoredResponse is a response kept to be replayed.
+type StoredResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type PostgresIdempotencyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotencyStore(db *sql.DB) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{db: db}
+}
+
+type IdempotencyStore interface {
+	// ClaimKey takes a key for a request with the given fingerprint until
+	// ttl is over, returning nil when it was free or had expired, and the
+	// key as held otherwise.
+	ClaimKey(userID int, key, fingerprint string, ttl time.Duration) (*IdempotencyKey, error)
+	// SaveResponse keeps the response to the request that claimed a key.
+	SaveResponse(userID int, key string, response *StoredResponse) error
+	// ReleaseKey frees a key whose request ended without a response worth
+	// replaying, so that a retry runs again.
+	ReleaseKey(userID int, key string) error
+	// DeleteExpiredKeys deletes the keys past their ttl, returning how many
+	// it deleted.
+	DeleteExpiredKeys() (int64, error)
+}
+
+func (pg *PostgresIdempotencyStore) ClaimKey(userID int, key, fingerprint string, ttl time.Duration) (*IdempotencyKey, error) {
+	query := `
+	INSERT INTO idempotency_keys (user_id, key, fingerprint, expires_at)
+	VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))
+	ON CONFLICT (user_id, key) DO UPDATE
+	SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, response_header = NULL, response_body = NULL,
+		created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+	WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+	`
+	// a held key can expire and be deleted between the two statements, in
+	// which case it is free to claim again
+	for range 3 {
+		result, err := pg.db.Exec(query, userID, key, fingerprint, ttl.Seconds())
+		if err != nil {
+			return nil, err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if rowsAffected == 1 {
+			return nil, nil
+		}
+
+		held, err := pg.getKey(userID, key)
+		if err != nil || held != nil {
+			return held, err
+		}
+	}
+	return nil, errors.New("idempotency key could not be claimed")
+}
+
+func (pg *PostgresIdempotencyStore) getKey(userID int, key string) (*IdempotencyKey, error) {
+	query := `
+	SELECT key, fingerprint, status_code, response_header, response_body, expires_at
+	FROM idempotency_keys
+	WHERE user_id = $1 AND key = $2
+	`
+	held := &IdempotencyKey{}
+	var statusCode *int
+	var header, body []byte
+	err := pg.db.QueryRow(query, userID, key).Scan(&held.Key, &held.Fingerprint, &statusCode, &header, &body, &held.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != nil {
+		held.Response = &StoredResponse{StatusCode: *statusCode, Body: body}
+		err = json.Unmarshal(header, &held.Response.Header)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return held, nil
+}
+
+func (pg *PostgresIdempotencyStore) SaveResponse(userID int, key string, response *StoredResponse) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE idempotency_keys
+	SET status_code = $3, response_header = $4, response_body = $5
+	WHERE user_id = $1 AND key = $2
+	`
+	result, err := pg.db.Exec(query, userID, key, response.StatusCode, header, response.Body)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresIdempotencyStore) ReleaseKey(userID int, key string) error {
+	_, err := pg.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND status_code IS NULL`, userID, key)
+	return err
+}
+
+func (pg *PostgresIdempotencyStore) DeleteExpiredKeys() (int64, error) {
+	result, err := pg.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresIdempotencyStore(db)
+	owner := createTestUser(t, db, "owner")
+	other := createTestUser(t, db, "other")
+
+	held, err := store.ClaimKey(owner.ID, "retry-1", "fingerprint-a", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, held, "a new key is claimed")
+
+	// while the first request runs, the key is held without a response
+	held, err = store.ClaimKey(owner.ID, "retry-1", "fingerprint-a", time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, held)
+	assert.Nil(t, held.Response)
+
+	response := &StoredResponse{
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       []byte(`{"data":{"id":1}}`),
+	}
+	require.NoError(t, store.SaveResponse(owner.ID, "retry-1", response))
+	held, err = store.ClaimKey(owner.ID, "retry-1", "fingerprint-b", time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, held)
+	assert.Equal(t, "fingerprint-a", held.Fingerprint)
+	assert.Equal(t, response, held.Response)
+
+	// keys are per user
+	held, err = store.ClaimKey(other.ID, "retry-1", "fingerprint-b", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, held)
+
+	// a released key is free again; one with a response is not released
+	require.NoError(t, store.ReleaseKey(other.ID, "retry-1"))
+	held, err = store.ClaimKey(other.ID, "retry-1", "fingerprint-c", time.Hour)
+	require.NoError(t, err)
+	assert.Nil(t, held)
+	require.NoError(t, store.ReleaseKey(owner.ID, "retry-1"))
+	held, err = store.ClaimKey(owner.ID, "retry-1", "fingerprint-a", time.Hour)
+	require.NoError(t, err)
+	assert.NotNil(t, held)
+
+	// an expired key is claimed anew, and the cleanup deletes expired ones
+	_, err = store.ClaimKey(owner.ID, "retry-2", "fingerprint-a", -time.Minute)
+	require.NoError(t, err)
+	held, err = store.ClaimKey(owner.ID, "retry-2", "fingerprint-b", -time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, held)
+	deleted, err := store.DeleteExpiredKeys()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	assert.ErrorIs(t, store.SaveResponse(owner.ID, "retry-2", response), sql.ErrNoRows)
+}
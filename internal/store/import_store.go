@@ -0,0 +1,252 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrDuplicateImport is returned when a workout was already imported.
+var ErrDuplicateImport = errors.New("this workout was already imported")
+
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+	// ImportPreview marks the unsaved job a dry run reports.
+	ImportPreview = "preview"
+)
+
+// ImportRowError points at the line of an imported file that could not be
+// turned into a set or a workout.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// ImportJob tracks the import of one file from another app. Workouts counts
+// the workouts found in the file, which end up imported, skipped as
+// duplicates of earlier imports or failed.
+type ImportJob struct {
+	ID         int              `json:"id"`
+	UserID     int              `json:"user_id"`
+	Source     string           `json:"source"`
+	Filename   string           `json:"filename"`
+	Status     string           `json:"status"`
+	Rows       int              `json:"rows"`
+	Workouts   int              `json:"workouts"`
+	Imported   int              `json:"imported"`
+	Duplicates int              `json:"duplicates"`
+	Failed     int              `json:"failed"`
+	Errors     []ImportRowError `json:"errors"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at"`
+}
+
+type PostgresImportStore struct {
+	db *sql.DB
+}
+
+func NewPostgresImportStore(db *sql.DB) *PostgresImportStore {
+	return &PostgresImportStore{db: db}
+}
+
+type ImportStore interface {
+	CreateImportJob(job *ImportJob) error
+	// FinishImportJob saves the final counts and errors of a job.
+	FinishImportJob(job *ImportJob) error
+	// FailRunningImportJobs marks every job still running as failed, with
+	// message as its last error, returning how many it marked. Jobs run
+	// inside the server, so at startup any job still running was cut off.
+	FailRunningImportJobs(message string) (int64, error)
+	GetImportJob(id int64, userID int) (*ImportJob, error)
+	ListImportJobs(userID int) ([]*ImportJob, error)
+	// ImportedFingerprints returns which of the fingerprints belong to
+	// workouts the user already imported.
+	ImportedFingerprints(userID int, fingerprints []string) (map[string]bool, error)
+	// ImportWorkout saves a workout under its fingerprint, returning
+	// ErrDuplicateImport when the fingerprint is taken.
+	ImportWorkout(jobID int, fingerprint string, workout *Workout) error
+}
+
+const importJobColumns = `j.id, j.user_id, j.source, j.filename, j.status, j.total_rows, j.total_workouts,
+	j.imported_workouts, j.duplicate_workouts, j.failed_workouts, j.errors, j.created_at, j.finished_at`
+
+func scanImportJob(row rowScanner) (*ImportJob, error) {
+	job := &ImportJob{}
+	var errs []byte
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Source,
+		&job.Filename,
+		&job.Status,
+		&job.Rows,
+		&job.Workouts,
+		&job.Imported,
+		&job.Duplicates,
+		&job.Failed,
+		&errs,
+		&job.CreatedAt,
+		&job.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(errs, &job.Errors)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (pg *PostgresImportStore) CreateImportJob(job *ImportJob) error {
+	if job.Errors == nil {
+		job.Errors = []ImportRowError{}
+	}
+	errs, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO import_jobs (user_id, source, filename, status, total_rows, total_workouts,
+		imported_workouts, duplicate_workouts, failed_workouts, errors)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id, created_at
+	`
+	return pg.db.QueryRow(query, job.UserID, job.Source, job.Filename, job.Status, job.Rows, job.Workouts,
+		job.Imported, job.Duplicates, job.Failed, errs).Scan(&job.ID, &job.CreatedAt)
+}
+
+func (pg *PostgresImportStore) FinishImportJob(job *ImportJob) error {
+	errs, err := json.Marshal(job.Errors)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE import_jobs
+	SET status = $1, imported_workouts = $2, duplicate_workouts = $3, failed_workouts = $4,
+		errors = $5, finished_at = CURRENT_TIMESTAMP
+	WHERE id = $6 AND user_id = $7
+	RETURNING finished_at
+	`
+	return pg.db.QueryRow(query, job.Status, job.Imported, job.Duplicates, job.Failed, errs, job.ID, job.UserID).Scan(&job.FinishedAt)
+}
+
+func (pg *PostgresImportStore) FailRunningImportJobs(message string) (int64, error) {
+	query := `
+	UPDATE import_jobs
+	SET status = $1, errors = errors || jsonb_build_array(jsonb_build_object('row', 0, 'message', $2::text)),
+		finished_at = CURRENT_TIMESTAMP
+	WHERE status = $3
+	`
+	result, err := pg.db.Exec(query, ImportFailed, message, ImportRunning)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (pg *PostgresImportStore) GetImportJob(id int64, userID int) (*ImportJob, error) {
+	query := `
+	SELECT ` + importJobColumns + `
+	FROM import_jobs j
+	WHERE j.id = $1 AND j.user_id = $2
+	`
+	job, err := scanImportJob(pg.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (pg *PostgresImportStore) ListImportJobs(userID int) ([]*ImportJob, error) {
+	query := `
+	SELECT ` + importJobColumns + `
+	FROM import_jobs j
+	WHERE j.user_id = $1
+	ORDER BY j.created_at DESC, j.id DESC
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*ImportJob{}
+	for rows.Next() {
+		job, err := scanImportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (pg *PostgresImportStore) ImportedFingerprints(userID int, fingerprints []string) (map[string]bool, error) {
+	imported := map[string]bool{}
+	if len(fingerprints) == 0 {
+		return imported, nil
+	}
+
+	query := `
+	SELECT fingerprint
+	FROM imported_workouts
+	WHERE user_id = $1 AND fingerprint = ANY($2)
+	`
+	rows, err := pg.db.Query(query, userID, nonNilStrings(fingerprints))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fingerprint string
+		err = rows.Scan(&fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		imported[fingerprint] = true
+	}
+	return imported, rows.Err()
+}
+
+func (pg *PostgresImportStore) ImportWorkout(jobID int, fingerprint string, workout *Workout) error {
+	err := workout.ResolveTimes()
+	if err != nil {
+		return err
+	}
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	err = insertWorkout(tx, workout)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO imported_workouts (workout_id, user_id, job_id, fingerprint)
+	VALUES ($1, $2, $3, $4)
+	`
+	_, err = tx.Exec(query, workout.ID, workout.UserID, jobID, fingerprint)
+	if isUniqueViolation(err) {
+		return ErrDuplicateImport
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportWorkout(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	importStore := NewPostgresImportStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	job := &ImportJob{UserID: owner.ID, Source: "strong", Filename: "strong.csv", Status: ImportRunning, Rows: 2, Workouts: 1}
+	require.NoError(t, importStore.CreateImportJob(job))
+	assert.NotZero(t, job.ID)
+
+	workout := &Workout{
+		UserID:      owner.ID,
+		Title:       "Push Day",
+		PerformedAt: time.Date(2024, 3, 4, 18, 2, 0, 0, time.UTC),
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Sets: 2, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		},
+	}
+	require.NoError(t, importStore.ImportWorkout(job.ID, "fp-1", workout))
+	assert.NotZero(t, workout.ID)
+
+	again := &Workout{UserID: owner.ID, Title: "Push Day", PerformedAt: workout.PerformedAt}
+	assert.ErrorIs(t, importStore.ImportWorkout(job.ID, "fp-1", again), ErrDuplicateImport)
+
+	imported, err := importStore.ImportedFingerprints(owner.ID, []string{"fp-1", "fp-2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"fp-1": true}, imported)
+
+	imported, err = importStore.ImportedFingerprints(stranger.ID, []string{"fp-1"})
+	require.NoError(t, err)
+	assert.Empty(t, imported)
+
+	job.Status = ImportCompleted
+	job.Imported, job.Duplicates = 1, 1
+	job.Errors = []ImportRowError{{Row: 3, Message: "reps must be positive"}}
+	require.NoError(t, importStore.FinishImportJob(job))
+
+	fetched, err := importStore.GetImportJob(int64(job.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ImportCompleted, fetched.Status)
+	assert.Equal(t, 1, fetched.Duplicates)
+	assert.Equal(t, job.Errors, fetched.Errors)
+	assert.NotNil(t, fetched.FinishedAt)
+
+	hidden, err := importStore.GetImportJob(int64(job.ID), stranger.ID)
+	require.NoError(t, err)
+	assert.Nil(t, hidden)
+
+	// deleting the workout lets it be imported again
+	require.NoError(t, NewPostgresWorkoutStore(db).DeleteWorkout(int64(workout.ID), owner.ID, workout.Version))
+	imported, err = importStore.ImportedFingerprints(owner.ID, []string{"fp-1"})
+	require.NoError(t, err)
+	assert.Empty(t, imported)
+}
+
+func TestFailRunningImportJobs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	importStore := NewPostgresImportStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	running := &ImportJob{UserID: owner.ID, Source: "strong", Filename: "a.csv", Status: ImportRunning}
+	require.NoError(t, importStore.CreateImportJob(running))
+	finished := &ImportJob{UserID: owner.ID, Source: "strong", Filename: "b.csv", Status: ImportRunning}
+	require.NoError(t, importStore.CreateImportJob(finished))
+	finished.Status = ImportCompleted
+	require.NoError(t, importStore.FinishImportJob(finished))
+
+	failed, err := importStore.FailRunningImportJobs("interrupted")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+
+	fetched, err := importStore.GetImportJob(int64(running.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ImportFailed, fetched.Status)
+	assert.Equal(t, []ImportRowError{{Row: 0, Message: "interrupted"}}, fetched.Errors)
+	assert.NotNil(t, fetched.FinishedAt)
+
+	fetched, err = importStore.GetImportJob(int64(finished.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ImportCompleted, fetched.Status)
+}
@@ -0,0 +1,337 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/rrule"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	OccurrenceCompleted = "completed"
+	OccurrenceSkipped   = "skipped"
+)
+
+// PlannedWorkout schedules training ahead of time: once at StartsAt, or
+// repeatedly following RRule. Exceptions are local dates (YYYY-MM-DD) taken
+// out of the schedule altogether, unlike skipped occurrences which still
+// count against adherence.
+type PlannedWorkout struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"user_id"`
+	TemplateID      *int      `json:"template_id"`
+	Title           string    `json:"title"`
+	Notes           string    `json:"notes"`
+	StartsAt        time.Time `json:"starts_at"`
+	Timezone        string    `json:"timezone"`
+	DurationMinutes *int      `json:"duration_minutes"`
+	RRule           string    `json:"rrule"`
+	Exceptions      []string  `json:"exceptions"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Validate checks the schedule and normalizes the rule to its canonical form.
+func (p *PlannedWorkout) Validate() error {
+	if _, err := p.location(); err != nil {
+		return err
+	}
+	if p.StartsAt.IsZero() {
+		return errors.New("starts_at is required")
+	}
+	if p.DurationMinutes != nil && *p.DurationMinutes < 1 {
+		return errors.New("duration_minutes must be at least 1")
+	}
+	if p.RRule != "" {
+		rule, err := rrule.Parse(p.RRule)
+		if err != nil {
+			return fmt.Errorf("rrule: %w", err)
+		}
+		p.RRule = rule.String()
+	}
+	for _, date := range p.Exceptions {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return fmt.Errorf("exception %q must be a YYYY-MM-DD date", date)
+		}
+	}
+	slices.Sort(p.Exceptions)
+	p.Exceptions = slices.Compact(p.Exceptions)
+	return nil
+}
+
+func (p *PlannedWorkout) location() (*time.Location, error) {
+	if p.Timezone == "" || p.Timezone == "Local" {
+		return nil, errors.New("timezone must be an IANA time zone name")
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, errors.New("timezone must be an IANA time zone name")
+	}
+	return loc, nil
+}
+
+// Occurrences expands the schedule over the local calendar days from fromDate
+// up to but excluding toDate. Only the year, month and day of both bounds are
+// used; they are read in the plan's own timezone.
+func (p *PlannedWorkout) Occurrences(fromDate, toDate time.Time) ([]time.Time, error) {
+	loc, err := p.location()
+	if err != nil {
+		return nil, err
+	}
+	from := time.Date(fromDate.Year(), fromDate.Month(), fromDate.Day(), 0, 0, 0, 0, loc)
+	to := time.Date(toDate.Year(), toDate.Month(), toDate.Day(), 0, 0, 0, 0, loc)
+	start := p.StartsAt.In(loc)
+
+	var occurrences []time.Time
+	if p.RRule == "" {
+		if !start.Before(from) && start.Before(to) {
+			occurrences = []time.Time{start}
+		}
+	} else {
+		rule, err := rrule.Parse(p.RRule)
+		if err != nil {
+			return nil, err
+		}
+		occurrences = rule.Between(start, from, to)
+	}
+
+	return slices.DeleteFunc(occurrences, func(t time.Time) bool {
+		return slices.Contains(p.Exceptions, t.Format(time.DateOnly))
+	}), nil
+}
+
+// Occurs reports whether the schedule has an occurrence on the local date.
+func (p *PlannedWorkout) Occurs(date time.Time) (bool, error) {
+	occurrences, err := p.Occurrences(date, date.AddDate(0, 0, 1))
+	return len(occurrences) > 0, err
+}
+
+// OccurrenceStatus records what became of one planned occurrence.
+type OccurrenceStatus struct {
+	PlannedWorkoutID int       `json:"planned_workout_id"`
+	Date             string    `json:"date"`
+	Status           string    `json:"status"`
+	WorkoutID        *int      `json:"workout_id"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type PostgresPlanStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPlanStore(db *sql.DB) *PostgresPlanStore {
+	return &PostgresPlanStore{db: db}
+}
+
+type PlanStore interface {
+	CreatePlan(*PlannedWorkout) (*PlannedWorkout, error)
+	GetPlanByID(id int64, userID int) (*PlannedWorkout, error)
+	ListPlans(userID int) ([]*PlannedWorkout, error)
+	UpdatePlan(*PlannedWorkout) error
+	DeletePlan(id int64, userID int) error
+	// ListOccurrenceStatuses returns the recorded outcomes of the user's
+	// occurrences dated within [fromDate, toDate). Trashing the workout an
+	// occurrence was completed with un-completes it, as deleting it always
+	// did: the occurrence is left out, so it shows as planned or missed,
+	// until the workout is restored. Purging the workout forgets it for good.
+	ListOccurrenceStatuses(userID int, fromDate, toDate time.Time) ([]OccurrenceStatus, error)
+	SetOccurrenceStatus(*OccurrenceStatus) error
+	ClearOccurrenceStatus(planID int, date string) error
+}
+
+const planColumns = `p.id, p.user_id, p.template_id, p.title, COALESCE(p.notes, ''), p.starts_at, p.timezone,
+	p.duration_minutes, COALESCE(p.rrule, ''),
+	ARRAY(SELECT to_char(d, 'YYYY-MM-DD') FROM unnest(p.exceptions) AS d ORDER BY d),
+	p.created_at, p.updated_at`
+
+func scanPlan(row rowScanner) (*PlannedWorkout, error) {
+	plan := &PlannedWorkout{}
+	m := pgtype.NewMap()
+	err := row.Scan(
+		&plan.ID,
+		&plan.UserID,
+		&plan.TemplateID,
+		&plan.Title,
+		&plan.Notes,
+		&plan.StartsAt,
+		&plan.Timezone,
+		&plan.DurationMinutes,
+		&plan.RRule,
+		m.SQLScanner(&plan.Exceptions),
+		&plan.CreatedAt,
+		&plan.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (pg *PostgresPlanStore) CreatePlan(plan *PlannedWorkout) (*PlannedWorkout, error) {
+	query := `
+	INSERT INTO planned_workouts (user_id, template_id, title, notes, starts_at, timezone, duration_minutes, rrule, exceptions)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9::text[]::date[])
+	RETURNING id, created_at, updated_at
+	`
+	err := pg.db.QueryRow(query,
+		plan.UserID,
+		plan.TemplateID,
+		plan.Title,
+		plan.Notes,
+		plan.StartsAt,
+		plan.Timezone,
+		plan.DurationMinutes,
+		plan.RRule,
+		nonNilStrings(plan.Exceptions),
+	).Scan(&plan.ID, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	plan.Exceptions = nonNilStrings(plan.Exceptions)
+	return plan, nil
+}
+
+func (pg *PostgresPlanStore) GetPlanByID(id int64, userID int) (*PlannedWorkout, error) {
+	query := `
+	SELECT ` + planColumns + `
+	FROM planned_workouts p
+	WHERE p.id = $1 AND p.user_id = $2
+	`
+	plan, err := scanPlan(pg.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (pg *PostgresPlanStore) ListPlans(userID int) ([]*PlannedWorkout, error) {
+	query := `
+	SELECT ` + planColumns + `
+	FROM planned_workouts p
+	WHERE p.user_id = $1
+	ORDER BY p.starts_at, p.id
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []*PlannedWorkout{}
+	for rows.Next() {
+		plan, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
+
+func (pg *PostgresPlanStore) UpdatePlan(plan *PlannedWorkout) error {
+	query := `
+	UPDATE planned_workouts
+	SET template_id = $1, title = $2, notes = $3, starts_at = $4, timezone = $5, duration_minutes = $6,
+		rrule = NULLIF($7, ''), exceptions = $8::text[]::date[], updated_at = CURRENT_TIMESTAMP
+	WHERE id = $9 AND user_id = $10
+	RETURNING updated_at
+	`
+	return pg.db.QueryRow(query,
+		plan.TemplateID,
+		plan.Title,
+		plan.Notes,
+		plan.StartsAt,
+		plan.Timezone,
+		plan.DurationMinutes,
+		plan.RRule,
+		nonNilStrings(plan.Exceptions),
+		plan.ID,
+		plan.UserID,
+	).Scan(&plan.UpdatedAt)
+}
+
+func (pg *PostgresPlanStore) DeletePlan(id int64, userID int) error {
+	query := `DELETE FROM planned_workouts WHERE id = $1 AND user_id = $2`
+
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (pg *PostgresPlanStore) ListOccurrenceStatuses(userID int, fromDate, toDate time.Time) ([]OccurrenceStatus, error) {
+	query := `
+	SELECT o.planned_workout_id, to_char(o.occurrence_date, 'YYYY-MM-DD'), o.status, o.workout_id, o.updated_at
+	FROM planned_occurrences o
+	INNER JOIN planned_workouts p ON p.id = o.planned_workout_id
+	LEFT JOIN workouts w ON w.id = o.workout_id
+	WHERE p.user_id = $1 AND o.occurrence_date >= $2::date AND o.occurrence_date < $3::date
+		AND (o.workout_id IS NULL OR w.deleted_at IS NULL)
+	ORDER BY o.occurrence_date, o.planned_workout_id
+	`
+	rows, err := pg.db.Query(query, userID, fromDate.Format(time.DateOnly), toDate.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := []OccurrenceStatus{}
+	for rows.Next() {
+		var status OccurrenceStatus
+		err = rows.Scan(&status.PlannedWorkoutID, &status.Date, &status.Status, &status.WorkoutID, &status.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+func (pg *PostgresPlanStore) SetOccurrenceStatus(status *OccurrenceStatus) error {
+	query := `
+	INSERT INTO planned_occurrences (planned_workout_id, occurrence_date, status, workout_id)
+	VALUES ($1, $2::date, $3, $4)
+	ON CONFLICT (planned_workout_id, occurrence_date)
+	DO UPDATE SET status = EXCLUDED.status, workout_id = EXCLUDED.workout_id, updated_at = CURRENT_TIMESTAMP
+	RETURNING updated_at
+	`
+	return pg.db.QueryRow(query, status.PlannedWorkoutID, status.Date, status.Status, status.WorkoutID).Scan(&status.UpdatedAt)
+}
+
+func (pg *PostgresPlanStore) ClearOccurrenceStatus(planID int, date string) error {
+	query := `DELETE FROM planned_occurrences WHERE planned_workout_id = $1 AND occurrence_date = $2::date`
+
+	result, err := pg.db.Exec(query, planID, date)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCalendar(t *testing.T) {
+	plan := &PlannedWorkout{
+		ID:         1,
+		Title:      "Full body",
+		StartsAt:   time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC),
+		Timezone:   "Europe/Berlin",
+		RRule:      "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+		Exceptions: []string{"2025-06-06"},
+	}
+	require.NoError(t, plan.Validate())
+
+	statuses := []OccurrenceStatus{
+		{PlannedWorkoutID: 1, Date: "2025-06-02", Status: OccurrenceCompleted, WorkoutID: IntPtr(10)},
+		{PlannedWorkoutID: 1, Date: "2025-06-04", Status: OccurrenceSkipped},
+	}
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 6, 11, 12, 0, 0, 0, time.UTC)
+
+	occurrences, adherence, err := BuildCalendar([]*PlannedWorkout{plan}, statuses, from, to, now)
+	require.NoError(t, err)
+
+	var got []string
+	for _, occurrence := range occurrences {
+		got = append(got, occurrence.Date+" "+occurrence.Status)
+		assert.Equal(t, 20, occurrence.StartsAt.Hour(), "18:00 UTC is 20:00 in Berlin")
+	}
+	assert.Equal(t, []string{
+		"2025-06-02 completed",
+		"2025-06-04 skipped",
+		"2025-06-09 missed",
+		"2025-06-11 planned",
+		"2025-06-13 planned",
+	}, got)
+
+	assert.Equal(t, 5, adherence.Planned)
+	assert.Equal(t, 1, adherence.Completed)
+	assert.Equal(t, 1, adherence.Skipped)
+	assert.Equal(t, 1, adherence.Missed)
+	assert.Equal(t, 2, adherence.Upcoming)
+	require.NotNil(t, adherence.Rate)
+	assert.Equal(t, 0.333, *adherence.Rate)
+}
+
+func TestPlanOccurrenceStatuses(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	planStore := NewPostgresPlanStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	plan := &PlannedWorkout{
+		UserID:     owner.ID,
+		Title:      "Run club",
+		StartsAt:   time.Date(2025, 6, 3, 7, 0, 0, 0, time.UTC),
+		Timezone:   "UTC",
+		RRule:      "FREQ=WEEKLY;BYDAY=TU",
+		Exceptions: []string{"2025-06-17"},
+	}
+	require.NoError(t, plan.Validate())
+	_, err := planStore.CreatePlan(plan)
+	require.NoError(t, err)
+
+	fetched, err := planStore.GetPlanByID(int64(plan.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2025-06-17"}, fetched.Exceptions)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=TU", fetched.RRule)
+
+	workout, err := workoutStore.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Easy run",
+		PerformedAt:     time.Date(2025, 6, 3, 7, 5, 0, 0, time.UTC),
+		DurationMinutes: 30,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Running", Sets: 1, DurationSeconds: IntPtr(1800), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, planStore.SetOccurrenceStatus(&OccurrenceStatus{
+		PlannedWorkoutID: plan.ID, Date: "2025-06-03", Status: OccurrenceCompleted, WorkoutID: &workout.ID,
+	}))
+	require.NoError(t, planStore.SetOccurrenceStatus(&OccurrenceStatus{
+		PlannedWorkoutID: plan.ID, Date: "2025-06-10", Status: OccurrenceSkipped,
+	}))
+
+	from := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	statuses, err := planStore.ListOccurrenceStatuses(owner.ID, from, to)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, workout.ID, *statuses[0].WorkoutID)
+
+	// trashing the logged workout un-completes the occurrence, restoring it
+	// completes it again and purging it forgets the completion
+	require.NoError(t, workoutStore.DeleteWorkout(int64(workout.ID), owner.ID, workout.Version))
+	statuses, err = planStore.ListOccurrenceStatuses(owner.ID, from, to)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, OccurrenceSkipped, statuses[0].Status)
+
+	require.NoError(t, workoutStore.RestoreWorkout(int64(workout.ID), owner.ID))
+	statuses, err = planStore.ListOccurrenceStatuses(owner.ID, from, to)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, OccurrenceCompleted, statuses[0].Status)
+
+	require.NoError(t, workoutStore.DeleteWorkout(int64(workout.ID), owner.ID, workout.Version))
+	require.NoError(t, workoutStore.PurgeWorkout(int64(workout.ID), owner.ID))
+	statuses, err = planStore.ListOccurrenceStatuses(owner.ID, from, to)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, OccurrenceSkipped, statuses[0].Status)
+
+	require.NoError(t, planStore.ClearOccurrenceStatus(plan.ID, "2025-06-10"))
+	assert.Error(t, planStore.ClearOccurrenceStatus(plan.ID, "2025-06-10"))
+}
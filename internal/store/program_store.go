@@ -0,0 +1,784 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	ProgressionNone       = "none"
+	ProgressionLinear     = "linear"
+	ProgressionPercent1RM = "percent_1rm"
+)
+
+var Progressions = []string{ProgressionNone, ProgressionLinear, ProgressionPercent1RM}
+
+// Program is a multi-week plan. Its days repeat every week unless a day is
+// given for one specific week, which then replaces the repeating day with
+// the same number in that week. Deload weeks scale load and sets down.
+type Program struct {
+	ID                 int          `json:"id"`
+	UserID             int          `json:"user_id"`
+	Name               string       `json:"name"`
+	Description        string       `json:"description"`
+	Public             bool         `json:"is_public"`
+	Weeks              int          `json:"weeks"`
+	DeloadWeeks        []int        `json:"deload_weeks"`
+	DeloadLoadFactor   float64      `json:"deload_load_factor"`
+	DeloadVolumeFactor float64      `json:"deload_volume_factor"`
+	RoundTo            float64      `json:"round_to"`
+	Days               []ProgramDay `json:"days"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+}
+
+// ProgramDay is one training day. Day 1 falls on the weekday the lifter
+// enrolled, day 7 on the weekday before it. A nil Week repeats every week.
+type ProgramDay struct {
+	ID        int               `json:"id"`
+	Week      *int              `json:"week"`
+	Day       int               `json:"day"`
+	Title     string            `json:"title"`
+	Exercises []ProgramExercise `json:"exercises"`
+}
+
+// ProgramExercise prescribes one exercise of a day.
+//
+//   - none repeats BaseWeight, or the last weight logged without one.
+//   - linear adds Increment after every session that hit all prescribed sets
+//     and reps since enrolling, and repeats the load after a miss.
+//   - percent_1rm loads Percentages[i] percent of the lifter's current
+//     estimated 1RM in the i-th training week, cycling, with WaveReps[i] reps
+//     when given; BaseWeight stands in for a missing estimate.
+type ProgramExercise struct {
+	ID              int       `json:"id"`
+	ExerciseID      *int      `json:"exercise_id"`
+	ExerciseName    string    `json:"exercise_name"`
+	OrderIndex      int       `json:"order_index"`
+	Sets            int       `json:"sets"`
+	Reps            *int      `json:"reps"`
+	DurationSeconds *int      `json:"duration_seconds"`
+	Progression     string    `json:"progression"`
+	BaseWeight      *float64  `json:"base_weight"`
+	Increment       *float64  `json:"increment"`
+	Percentages     []float64 `json:"percentages"`
+	WaveReps        []int     `json:"wave_reps"`
+	Notes           string    `json:"notes"`
+}
+
+type Enrollment struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	ProgramID   int       `json:"program_id"`
+	ProgramName string    `json:"program_name"`
+	StartDate   string    `json:"start_date"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ExerciseHistory is what a prescription is based on: every performance of
+// the exercise since the lifter enrolled, oldest first, the last weight
+// logged for it and its best estimated 1RM, all as they stood before the
+// day of the session.
+type ExerciseHistory struct {
+	Performances []records.Performance
+	LastWeight   *float64
+	OneRepMax    *float64
+}
+
+func (p *Program) Validate() error {
+	if p.Weeks < 1 || p.Weeks > 52 {
+		return errors.New("weeks must be between 1 and 52")
+	}
+	for _, week := range p.DeloadWeeks {
+		if week < 1 || week > p.Weeks {
+			return fmt.Errorf("deload week %d is outside the program's %d weeks", week, p.Weeks)
+		}
+	}
+	slices.Sort(p.DeloadWeeks)
+	p.DeloadWeeks = slices.Compact(p.DeloadWeeks)
+	if p.DeloadLoadFactor <= 0 || p.DeloadLoadFactor > 1 || p.DeloadVolumeFactor <= 0 || p.DeloadVolumeFactor > 1 {
+		return errors.New("deload_load_factor and deload_volume_factor must be greater than 0 and at most 1")
+	}
+	if p.RoundTo <= 0 {
+		return errors.New("round_to must be positive")
+	}
+	if len(p.Days) == 0 {
+		return errors.New("a program needs at least one day")
+	}
+
+	slots := map[[2]int]bool{}
+	for i := range p.Days {
+		day := &p.Days[i]
+		if day.Day < 1 || day.Day > 7 {
+			return fmt.Errorf("day %d: day must be between 1 and 7", i+1)
+		}
+		slot := [2]int{0, day.Day}
+		if day.Week != nil {
+			if *day.Week < 1 || *day.Week > p.Weeks {
+				return fmt.Errorf("day %d: week must be between 1 and %d", i+1, p.Weeks)
+			}
+			slot[0] = *day.Week
+		}
+		if slots[slot] {
+			return fmt.Errorf("day %d: another day already uses this week and day", i+1)
+		}
+		slots[slot] = true
+
+		if day.Title == "" || len(day.Title) > 255 {
+			return fmt.Errorf("day %d: title is required and must be at most 255 characters long", i+1)
+		}
+		if len(day.Exercises) == 0 {
+			return fmt.Errorf("day %d: at least one exercise is required", i+1)
+		}
+		for j := range day.Exercises {
+			err := day.Exercises[j].validate()
+			if err != nil {
+				return fmt.Errorf("day %d, exercise %d: %w", i+1, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *ProgramExercise) validate() error {
+	if e.Sets < 1 {
+		return errors.New("sets must be at least 1")
+	}
+	if (e.Reps == nil) == (e.DurationSeconds == nil) {
+		return errors.New("exactly one of reps or duration_seconds is required")
+	}
+	if e.Progression == "" {
+		e.Progression = ProgressionNone
+	}
+	if !slices.Contains(Progressions, e.Progression) {
+		return fmt.Errorf("progression must be one of %v", Progressions)
+	}
+	if e.Progression != ProgressionNone && e.Reps == nil {
+		return fmt.Errorf("%s progression needs reps", e.Progression)
+	}
+	if e.BaseWeight != nil && *e.BaseWeight < 0 {
+		return errors.New("base_weight cannot be negative")
+	}
+
+	switch e.Progression {
+	case ProgressionLinear:
+		if e.Increment == nil || *e.Increment <= 0 {
+			return errors.New("linear progression needs a positive increment")
+		}
+	case ProgressionPercent1RM:
+		if len(e.Percentages) == 0 {
+			return errors.New("percent_1rm progression needs percentages")
+		}
+		for _, pct := range e.Percentages {
+			if pct <= 0 || pct > 120 {
+				return errors.New("percentages must be greater than 0 and at most 120")
+			}
+		}
+		if len(e.WaveReps) > 0 && len(e.WaveReps) != len(e.Percentages) {
+			return errors.New("wave_reps must have one entry per percentage")
+		}
+		for _, reps := range e.WaveReps {
+			if reps < 1 {
+				return errors.New("wave_reps must be at least 1")
+			}
+		}
+	}
+	return nil
+}
+
+// Position locates date within an enrollment that started on start. Both
+// are local calendar dates; only their year, month and day are used. The
+// week can lie before 1 or past the end of the program.
+func Position(start, date time.Time) (week, day int) {
+	days := civilDays(date) - civilDays(start)
+	week = int(math.Floor(float64(days)/7)) + 1
+	day = days - (week-1)*7 + 1
+	return week, day
+}
+
+func civilDays(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func (p *Program) IsDeload(week int) bool {
+	return slices.Contains(p.DeloadWeeks, week)
+}
+
+// trainingWeek counts the non-deload weeks before week, so percentage waves
+// pause over a deload instead of skipping a step.
+func (p *Program) trainingWeek(week int) int {
+	n := 0
+	for w := 1; w < week; w++ {
+		if !p.IsDeload(w) {
+			n++
+		}
+	}
+	return n
+}
+
+// DayFor returns the day scheduled in a week, preferring one written for
+// that specific week, or nil on a rest day.
+func (p *Program) DayFor(week, day int) *ProgramDay {
+	var repeating *ProgramDay
+	for i := range p.Days {
+		d := &p.Days[i]
+		if d.Day != day {
+			continue
+		}
+		if d.Week != nil && *d.Week == week {
+			return d
+		}
+		if d.Week == nil {
+			repeating = d
+		}
+	}
+	return repeating
+}
+
+// Prescribe turns one exercise into the concrete entry for a week of an
+// enrollment that started on start.
+func (p *Program) Prescribe(exercise *ProgramExercise, week int, start time.Time, history ExerciseHistory) WorkoutEntry {
+	sets := exercise.Sets
+	reps := exercise.Reps
+	var weight *float64
+
+	switch exercise.Progression {
+	case ProgressionLinear:
+		weight = exercise.BaseWeight
+		if weight == nil {
+			weight = history.LastWeight
+		}
+		// deload sessions are deliberately light and say nothing about progress
+		for i := len(history.Performances) - 1; i >= 0; i-- {
+			performance := history.Performances[i]
+			performedWeek, _ := Position(start, performance.PerformedAt.In(start.Location()))
+			if p.IsDeload(performedWeek) {
+				continue
+			}
+			top, hit := topWeight(performance, exercise.Sets, *exercise.Reps)
+			if hit {
+				top += *exercise.Increment
+			}
+			weight = &top
+			break
+		}
+	case ProgressionPercent1RM:
+		i := p.trainingWeek(week) % len(exercise.Percentages)
+		oneRepMax := history.OneRepMax
+		if oneRepMax == nil {
+			oneRepMax = exercise.BaseWeight
+		}
+		if oneRepMax != nil {
+			w := *oneRepMax * exercise.Percentages[i] / 100
+			weight = &w
+		}
+		if len(exercise.WaveReps) > 0 {
+			reps = &exercise.WaveReps[i]
+		}
+	default:
+		weight = exercise.BaseWeight
+		if weight == nil {
+			weight = history.LastWeight
+		}
+	}
+
+	if p.IsDeload(week) {
+		sets = max(1, int(math.Ceil(float64(sets)*p.DeloadVolumeFactor)))
+		if weight != nil {
+			w := *weight * p.DeloadLoadFactor
+			weight = &w
+		}
+	}
+	if weight != nil {
+		w := math.Round(math.Round(*weight/p.RoundTo)*p.RoundTo*100) / 100
+		weight = &w
+	}
+
+	return WorkoutEntry{
+		ExerciseID:      exercise.ExerciseID,
+		ExerciseName:    exercise.ExerciseName,
+		Sets:            sets,
+		Reps:            reps,
+		DurationSeconds: exercise.DurationSeconds,
+		Weight:          weight,
+		Notes:           exercise.Notes,
+		OrderIndex:      exercise.OrderIndex,
+		Section:         SectionMain,
+	}
+}
+
+// topWeight returns the heaviest working set of a performance and whether
+// at least sets of its sets reached reps.
+func topWeight(performance records.Performance, sets, reps int) (float64, bool) {
+	var top float64
+	hit := 0
+	for _, set := range performance.Sets {
+		top = max(top, set.Weight)
+		if set.Reps >= reps {
+			hit++
+		}
+	}
+	return top, hit >= sets
+}
+
+type PostgresProgramStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProgramStore(db *sql.DB) *PostgresProgramStore {
+	return &PostgresProgramStore{db: db}
+}
+
+type ProgramStore interface {
+	CreateProgram(*Program) (*Program, error)
+	// GetProgramByID returns one of the user's own programs or a public one.
+	GetProgramByID(id int64, userID int) (*Program, error)
+	ListPrograms(userID int) ([]*Program, error)
+	UpdateProgram(*Program) error
+	DeleteProgram(id int64, userID int) error
+	CreateEnrollment(*Enrollment) (*Enrollment, error)
+	GetEnrollmentByID(id int64, userID int) (*Enrollment, error)
+	ListEnrollments(userID int) ([]*Enrollment, error)
+	DeleteEnrollment(id int64, userID int) error
+	// GetExerciseHistory returns the history of an exercise in [since,
+	// before), so that a session is prescribed the same way whenever it is
+	// asked for, whatever was logged on or after its day.
+	GetExerciseHistory(userID int, ref ExerciseRef, since, before time.Time) (ExerciseHistory, error)
+}
+
+func (pg *PostgresProgramStore) CreateProgram(program *Program) (*Program, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO programs (user_id, name, description, is_public, weeks, deload_weeks, deload_load_factor, deload_volume_factor, round_to)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(query,
+		program.UserID,
+		program.Name,
+		program.Description,
+		program.Public,
+		program.Weeks,
+		nonNilInts(program.DeloadWeeks),
+		program.DeloadLoadFactor,
+		program.DeloadVolumeFactor,
+		program.RoundTo,
+	).Scan(&program.ID, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertProgramDays(tx, program)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return program, nil
+}
+
+const programColumns = `p.id, p.user_id, p.name, COALESCE(p.description, ''), p.is_public, p.weeks, p.deload_weeks,
+	p.deload_load_factor, p.deload_volume_factor, p.round_to, p.created_at, p.updated_at`
+
+func scanProgram(row rowScanner) (*Program, error) {
+	program := &Program{}
+	m := pgtype.NewMap()
+	err := row.Scan(
+		&program.ID,
+		&program.UserID,
+		&program.Name,
+		&program.Description,
+		&program.Public,
+		&program.Weeks,
+		m.SQLScanner(&program.DeloadWeeks),
+		&program.DeloadLoadFactor,
+		&program.DeloadVolumeFactor,
+		&program.RoundTo,
+		&program.CreatedAt,
+		&program.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func (pg *PostgresProgramStore) GetProgramByID(id int64, userID int) (*Program, error) {
+	query := `
+	SELECT ` + programColumns + `
+	FROM programs p
+	WHERE p.id = $1 AND (p.user_id = $2 OR p.is_public)
+	`
+	program, err := scanProgram(pg.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := pg.getProgramDays([]int{program.ID})
+	if err != nil {
+		return nil, err
+	}
+	program.Days = days[program.ID]
+	return program, nil
+}
+
+func (pg *PostgresProgramStore) ListPrograms(userID int) ([]*Program, error) {
+	query := `
+	SELECT ` + programColumns + `
+	FROM programs p
+	WHERE p.user_id = $1 OR p.is_public
+	ORDER BY lower(p.name), p.id
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	programs := []*Program{}
+	ids := []int{}
+	for rows.Next() {
+		program, err := scanProgram(rows)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, program)
+		ids = append(ids, program.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	days, err := pg.getProgramDays(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, program := range programs {
+		program.Days = days[program.ID]
+	}
+	return programs, nil
+}
+
+func (pg *PostgresProgramStore) UpdateProgram(program *Program) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE programs
+	SET name = $1, description = $2, is_public = $3, weeks = $4, deload_weeks = $5, deload_load_factor = $6,
+		deload_volume_factor = $7, round_to = $8, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $9 AND user_id = $10
+	RETURNING updated_at
+	`
+	err = tx.QueryRow(query,
+		program.Name,
+		program.Description,
+		program.Public,
+		program.Weeks,
+		nonNilInts(program.DeloadWeeks),
+		program.DeloadLoadFactor,
+		program.DeloadVolumeFactor,
+		program.RoundTo,
+		program.ID,
+		program.UserID,
+	).Scan(&program.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM program_days WHERE program_id = $1`, program.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertProgramDays(tx, program)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) DeleteProgram(id int64, userID int) error {
+	query := `DELETE FROM programs WHERE id = $1 AND user_id = $2`
+
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func insertProgramDays(tx *sql.Tx, program *Program) error {
+	for i := range program.Days {
+		day := &program.Days[i]
+		err := tx.QueryRow(`
+		INSERT INTO program_days (program_id, week, day, title)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+		`, program.ID, day.Week, day.Day, day.Title).Scan(&day.ID)
+		if err != nil {
+			return err
+		}
+
+		for j := range day.Exercises {
+			exercise := &day.Exercises[j]
+			query := `
+			INSERT INTO program_exercises (program_day_id, exercise_id, exercise_name, order_index, sets, reps, duration_seconds,
+				progression, base_weight, increment, percentages, wave_reps, notes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id
+			`
+			err = tx.QueryRow(query,
+				day.ID,
+				exercise.ExerciseID,
+				exercise.ExerciseName,
+				exercise.OrderIndex,
+				exercise.Sets,
+				exercise.Reps,
+				exercise.DurationSeconds,
+				exercise.Progression,
+				exercise.BaseWeight,
+				exercise.Increment,
+				nonNilFloats(exercise.Percentages),
+				nonNilInts(exercise.WaveReps),
+				exercise.Notes,
+			).Scan(&exercise.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getProgramDays loads the days of many programs, each with its exercises.
+func (pg *PostgresProgramStore) getProgramDays(programIDs []int) (map[int][]ProgramDay, error) {
+	days := make(map[int][]ProgramDay, len(programIDs))
+	if len(programIDs) == 0 {
+		return days, nil
+	}
+
+	query := `
+	SELECT d.program_id, d.id, d.week, d.day, d.title,
+		x.id, x.exercise_id, x.exercise_name, x.order_index, x.sets, x.reps, x.duration_seconds,
+		x.progression, x.base_weight, x.increment, x.percentages, x.wave_reps, COALESCE(x.notes, '')
+	FROM program_days d
+	INNER JOIN program_exercises x ON x.program_day_id = d.id
+	WHERE d.program_id = ANY($1)
+	ORDER BY d.program_id, d.week NULLS FIRST, d.day, x.order_index, x.id
+	`
+	rows, err := pg.db.Query(query, programIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := pgtype.NewMap()
+	for rows.Next() {
+		var programID int
+		var day ProgramDay
+		var exercise ProgramExercise
+		err = rows.Scan(
+			&programID,
+			&day.ID,
+			&day.Week,
+			&day.Day,
+			&day.Title,
+			&exercise.ID,
+			&exercise.ExerciseID,
+			&exercise.ExerciseName,
+			&exercise.OrderIndex,
+			&exercise.Sets,
+			&exercise.Reps,
+			&exercise.DurationSeconds,
+			&exercise.Progression,
+			&exercise.BaseWeight,
+			&exercise.Increment,
+			m.SQLScanner(&exercise.Percentages),
+			m.SQLScanner(&exercise.WaveReps),
+			&exercise.Notes,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		programDays := days[programID]
+		if n := len(programDays); n == 0 || programDays[n-1].ID != day.ID {
+			programDays = append(programDays, day)
+		}
+		last := &programDays[len(programDays)-1]
+		last.Exercises = append(last.Exercises, exercise)
+		days[programID] = programDays
+	}
+	return days, rows.Err()
+}
+
+func (pg *PostgresProgramStore) CreateEnrollment(enrollment *Enrollment) (*Enrollment, error) {
+	query := `
+	INSERT INTO program_enrollments (user_id, program_id, start_date)
+	VALUES ($1, $2, $3::date)
+	RETURNING id, created_at
+	`
+	err := pg.db.QueryRow(query, enrollment.UserID, enrollment.ProgramID, enrollment.StartDate).Scan(&enrollment.ID, &enrollment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+const enrollmentColumns = `e.id, e.user_id, e.program_id, p.name, to_char(e.start_date, 'YYYY-MM-DD'), e.created_at`
+
+func scanEnrollment(row rowScanner) (*Enrollment, error) {
+	enrollment := &Enrollment{}
+	err := row.Scan(
+		&enrollment.ID,
+		&enrollment.UserID,
+		&enrollment.ProgramID,
+		&enrollment.ProgramName,
+		&enrollment.StartDate,
+		&enrollment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (pg *PostgresProgramStore) GetEnrollmentByID(id int64, userID int) (*Enrollment, error) {
+	query := `
+	SELECT ` + enrollmentColumns + `
+	FROM program_enrollments e
+	INNER JOIN programs p ON p.id = e.program_id
+	WHERE e.id = $1 AND e.user_id = $2
+	`
+	enrollment, err := scanEnrollment(pg.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (pg *PostgresProgramStore) ListEnrollments(userID int) ([]*Enrollment, error) {
+	query := `
+	SELECT ` + enrollmentColumns + `
+	FROM program_enrollments e
+	INNER JOIN programs p ON p.id = e.program_id
+	WHERE e.user_id = $1
+	ORDER BY e.start_date DESC, e.id DESC
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []*Enrollment{}
+	for rows.Next() {
+		enrollment, err := scanEnrollment(rows)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, rows.Err()
+}
+
+func (pg *PostgresProgramStore) DeleteEnrollment(id int64, userID int) error {
+	query := `DELETE FROM program_enrollments WHERE id = $1 AND user_id = $2`
+
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (pg *PostgresProgramStore) GetExerciseHistory(userID int, ref ExerciseRef, since, before time.Time) (ExerciseHistory, error) {
+	var history ExerciseHistory
+	var err error
+
+	history.Performances, err = loadPerformances(pg.db, userID, ref, &since, &before)
+	if err != nil {
+		return history, err
+	}
+
+	history.LastWeight, err = lastWeight(pg.db, userID, ref, &before)
+	if err != nil {
+		return history, err
+	}
+
+	// each estimated 1RM record beats the ones before it, so the highest
+	// achieved before the session is the one that stood then
+	condition, arg := ref.condition("r", 2)
+	query := `
+	SELECT MAX(r.value)
+	FROM personal_records r
+	WHERE r.user_id = $1 AND r.record_type = $3 AND r.achieved_at < $4 AND ` + condition
+	err = pg.db.QueryRow(query, userID, arg, records.TypeEstimated1RM, before).Scan(&history.OneRepMax)
+	if err != nil {
+		return history, err
+	}
+	return history, nil
+}
+
+func nonNilInts(s []int) []int {
+	if s == nil {
+		return []int{}
+	}
+	return s
+}
+
+func nonNilFloats(s []float64) []float64 {
+	if s == nil {
+		return []float64{}
+	}
+	return s
+}
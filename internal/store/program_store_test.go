@@ -0,0 +1,151 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramPosition(t *testing.T) {
+	start := time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC)
+
+	week, day := Position(start, start)
+	assert.Equal(t, [2]int{1, 1}, [2]int{week, day})
+
+	week, day = Position(start, time.Date(2025, 6, 10, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, [2]int{1, 7}, [2]int{week, day})
+
+	week, day = Position(start, time.Date(2025, 6, 18, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, [2]int{3, 1}, [2]int{week, day})
+
+	week, _ = Position(start, time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 0, week, "the day before enrolling is not part of the program")
+}
+
+func TestProgramPrescribe(t *testing.T) {
+	program := &Program{
+		Name:               "5/3/1-ish",
+		Weeks:              4,
+		DeloadWeeks:        []int{4},
+		DeloadLoadFactor:   0.6,
+		DeloadVolumeFactor: 0.5,
+		RoundTo:            2.5,
+		Days: []ProgramDay{
+			{Day: 1, Title: "Squat", Exercises: []ProgramExercise{
+				{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), Progression: ProgressionLinear, BaseWeight: FloatPtr(100), Increment: FloatPtr(2.5)},
+			}},
+			{Day: 3, Title: "Press", Exercises: []ProgramExercise{
+				{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(5), Progression: ProgressionPercent1RM,
+					Percentages: []float64{65, 75, 85}, WaveReps: []int{5, 3, 1}},
+			}},
+			{Week: IntPtr(2), Day: 3, Title: "Heavy press", Exercises: []ProgramExercise{
+				{ExerciseName: "Bench Press", Sets: 1, Reps: IntPtr(1), BaseWeight: FloatPtr(90)},
+			}},
+		},
+	}
+	require.NoError(t, program.Validate())
+
+	start := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, program.DayFor(1, 2), "day 2 is a rest day")
+	assert.Equal(t, "Press", program.DayFor(1, 3).Title)
+	assert.Equal(t, "Heavy press", program.DayFor(2, 3).Title)
+
+	squat := &program.Days[0].Exercises[0]
+	session := func(day int, reps ...int) records.Performance {
+		performance := records.Performance{PerformedAt: start.AddDate(0, 0, day)}
+		for _, r := range reps {
+			performance.Sets = append(performance.Sets, records.Set{Weight: 100, Reps: r})
+		}
+		return performance
+	}
+
+	entry := program.Prescribe(squat, 1, start, ExerciseHistory{})
+	assert.Equal(t, 100.0, *entry.Weight, "first session uses the base weight")
+
+	entry = program.Prescribe(squat, 2, start, ExerciseHistory{Performances: []records.Performance{session(0, 5, 5, 5)}})
+	assert.Equal(t, 102.5, *entry.Weight, "all sets hit adds the increment")
+
+	entry = program.Prescribe(squat, 2, start, ExerciseHistory{Performances: []records.Performance{session(0, 5, 5, 3)}})
+	assert.Equal(t, 100.0, *entry.Weight, "a missed set repeats the load")
+
+	entry = program.Prescribe(squat, 4, start, ExerciseHistory{Performances: []records.Performance{session(0, 5, 5, 5)}})
+	assert.Equal(t, 2, entry.Sets, "deload halves the sets, rounding up")
+	assert.Equal(t, 62.5, *entry.Weight, "deload scales 102.5 to 61.5, rounded to 2.5")
+
+	bench := &program.Days[1].Exercises[0]
+	history := ExerciseHistory{OneRepMax: FloatPtr(100)}
+	entry = program.Prescribe(bench, 1, start, history)
+	assert.Equal(t, 65.0, *entry.Weight)
+	assert.Equal(t, 5, *entry.Reps)
+
+	entry = program.Prescribe(bench, 3, start, history)
+	assert.Equal(t, 85.0, *entry.Weight)
+	assert.Equal(t, 1, *entry.Reps)
+
+	entry = program.Prescribe(bench, 4, start, history)
+	assert.Equal(t, 2, entry.Sets)
+	assert.Equal(t, 40.0, *entry.Weight, "the wave wraps to 65%, then the deload takes 60% of it")
+
+	entry = program.Prescribe(bench, 1, start, ExerciseHistory{})
+	assert.Nil(t, entry.Weight, "no estimate and no base weight prescribes no load")
+}
+
+func TestProgramRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	programStore := NewPostgresProgramStore(db)
+	author := createTestUser(t, db, "author")
+	other := createTestUser(t, db, "other")
+
+	program := &Program{
+		UserID:             author.ID,
+		Name:               "Linear",
+		Weeks:              6,
+		DeloadWeeks:        []int{6},
+		DeloadLoadFactor:   0.6,
+		DeloadVolumeFactor: 0.5,
+		RoundTo:            2.5,
+		Days: []ProgramDay{
+			{Day: 1, Title: "A", Exercises: []ProgramExercise{
+				{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), Progression: ProgressionLinear, BaseWeight: FloatPtr(60), Increment: FloatPtr(2.5)},
+			}},
+			{Week: IntPtr(6), Day: 1, Title: "Test day", Exercises: []ProgramExercise{
+				{ExerciseName: "Squat", Sets: 1, Reps: IntPtr(1), Progression: ProgressionPercent1RM, Percentages: []float64{100}},
+			}},
+		},
+	}
+	require.NoError(t, program.Validate())
+	_, err := programStore.CreateProgram(program)
+	require.NoError(t, err)
+
+	fetched, err := programStore.GetProgramByID(int64(program.ID), author.ID)
+	require.NoError(t, err)
+	require.Len(t, fetched.Days, 2)
+	assert.Equal(t, []int{6}, fetched.DeloadWeeks)
+	assert.Equal(t, []float64{100}, fetched.Days[1].Exercises[0].Percentages)
+
+	hidden, err := programStore.GetProgramByID(int64(program.ID), other.ID)
+	require.NoError(t, err)
+	assert.Nil(t, hidden, "private programs are only visible to their author")
+
+	program.Public = true
+	require.NoError(t, programStore.UpdateProgram(program))
+	shared, err := programStore.GetProgramByID(int64(program.ID), other.ID)
+	require.NoError(t, err)
+	require.NotNil(t, shared)
+
+	enrollment, err := programStore.CreateEnrollment(&Enrollment{UserID: other.ID, ProgramID: program.ID, StartDate: "2025-06-02"})
+	require.NoError(t, err)
+	enrollments, err := programStore.ListEnrollments(other.ID)
+	require.NoError(t, err)
+	require.Len(t, enrollments, 1)
+	assert.Equal(t, "Linear", enrollments[0].ProgramName)
+	assert.Equal(t, "2025-06-02", enrollments[0].StartDate)
+
+	assert.Error(t, programStore.DeleteProgram(int64(program.ID), other.ID))
+	require.NoError(t, programStore.DeleteEnrollment(int64(enrollment.ID), other.ID))
+}
@@ -0,0 +1,444 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+	"github.com/fsrn12/fitness_tracker_go/internal/strength"
+)
+
+// ExerciseRef identifies an exercise the way entries do: by catalog id when
+// linked, otherwise by its free-text name compared case-insensitively.
+type ExerciseRef struct {
+	ID   *int
+	Name string
+}
+
+func (r ExerciseRef) key() string {
+	if r.ID != nil {
+		return "id:" + strconv.Itoa(*r.ID)
+	}
+	return "name:" + strings.ToLower(strings.TrimSpace(r.Name))
+}
+
+// condition returns a SQL condition matching the exercise on the given table
+// alias, with its single argument bound to placeholder $n.
+func (r ExerciseRef) condition(alias string, n int) (string, any) {
+	if r.ID != nil {
+		return fmt.Sprintf("%s.exercise_id = $%d", alias, n), *r.ID
+	}
+	return fmt.Sprintf("%[1]s.exercise_id IS NULL AND lower(%[1]s.exercise_name) = lower($%[2]d)", alias, n), strings.TrimSpace(r.Name)
+}
+
+// EntryRefs lists the distinct exercises used by a set of entries.
+func EntryRefs(entries []WorkoutEntry) []ExerciseRef {
+	var refs []ExerciseRef
+	for _, entry := range entries {
+		refs = append(refs, ExerciseRef{ID: entry.ExerciseID, Name: entry.ExerciseName})
+	}
+	return uniqueRefs(refs)
+}
+
+func uniqueRefs(refs []ExerciseRef) []ExerciseRef {
+	seen := map[string]bool{}
+	unique := []ExerciseRef{}
+	for _, ref := range refs {
+		if ref.ID == nil && strings.TrimSpace(ref.Name) == "" {
+			continue
+		}
+		if seen[ref.key()] {
+			continue
+		}
+		seen[ref.key()] = true
+		unique = append(unique, ref)
+	}
+	return unique
+}
+
+type PersonalRecord struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	ExerciseID   *int      `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	WorkoutID    int       `json:"workout_id"`
+	EntryID      *int      `json:"entry_id"`
+	RecordType   string    `json:"record_type"`
+	Value        float64   `json:"value"`
+	Weight       *float64  `json:"weight"`
+	Reps         *int      `json:"reps"`
+	AchievedAt   time.Time `json:"achieved_at"`
+	Current      bool      `json:"current"`
+}
+
+type RecordFilter struct {
+	UserID     int
+	ExerciseID int
+	RecordType string
+	// CurrentOnly hides records that have since been beaten
+	CurrentOnly bool
+}
+
+type PostgresRecordStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRecordStore(db *sql.DB) *PostgresRecordStore {
+	return &PostgresRecordStore{db: db}
+}
+
+type RecordStore interface {
+	// RecomputeForWorkout rebuilds the records of every exercise in the
+	// workout, plus the previous exercises it no longer contains, and returns
+	// the records the workout newly holds.
+	RecomputeForWorkout(userID int, workoutID int, previous []ExerciseRef) ([]*PersonalRecord, error)
+	// RecomputeExercises rebuilds records after workouts have gone to or
+	// come back from the trash.
+	RecomputeExercises(userID int, refs []ExerciseRef) error
+	ListRecords(filter RecordFilter) ([]*PersonalRecord, error)
+}
+
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (pg *PostgresRecordStore) RecomputeForWorkout(userID int, workoutID int, previous []ExerciseRef) ([]*PersonalRecord, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT exercise_id, exercise_name FROM workout_entries WHERE workout_id = $1`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	refs := append([]ExerciseRef{}, previous...)
+	for rows.Next() {
+		var ref ExerciseRef
+		err = rows.Scan(&ref.ID, &ref.Name)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	refs = uniqueRefs(refs)
+	err = lockExercises(tx, userID, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	held, err := recordSignatures(tx, workoutID)
+	if err != nil {
+		return nil, err
+	}
+
+	newRecords := []*PersonalRecord{}
+	for _, ref := range refs {
+		recomputed, err := recomputeExercise(tx, userID, ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range recomputed {
+			if record.WorkoutID == workoutID && !held[record.signature()] {
+				newRecords = append(newRecords, record)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return newRecords, nil
+}
+
+func (pg *PostgresRecordStore) RecomputeExercises(userID int, refs []ExerciseRef) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	refs = uniqueRefs(refs)
+	err = lockExercises(tx, userID, refs)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		_, err = recomputeExercise(tx, userID, ref)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (pg *PostgresRecordStore) ListRecords(filter RecordFilter) ([]*PersonalRecord, error) {
+	conditions := []string{"user_id = $1"}
+	args := []any{filter.UserID}
+	if filter.ExerciseID > 0 {
+		args = append(args, filter.ExerciseID)
+		conditions = append(conditions, fmt.Sprintf("exercise_id = $%d", len(args)))
+	}
+	if filter.RecordType != "" {
+		args = append(args, filter.RecordType)
+		conditions = append(conditions, fmt.Sprintf("record_type = $%d", len(args)))
+	}
+	if filter.CurrentOnly {
+		conditions = append(conditions, "is_current")
+	}
+
+	query := `
+	SELECT id, user_id, exercise_id, exercise_name, workout_id, entry_id, record_type, value, weight, reps, achieved_at, is_current
+	FROM personal_records
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY achieved_at DESC, id DESC
+	`
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*PersonalRecord{}
+	for rows.Next() {
+		record := &PersonalRecord{}
+		err = rows.Scan(
+			&record.ID,
+			&record.UserID,
+			&record.ExerciseID,
+			&record.ExerciseName,
+			&record.WorkoutID,
+			&record.EntryID,
+			&record.RecordType,
+			&record.Value,
+			&record.Weight,
+			&record.Reps,
+			&record.AchievedAt,
+			&record.Current,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, record)
+	}
+	return list, rows.Err()
+}
+
+// signature identifies a record independently of its row id, which changes
+// every time records are recomputed.
+func (r *PersonalRecord) signature() string {
+	weight := ""
+	if r.RecordType == records.TypeMaxReps && r.Weight != nil {
+		weight = strconv.FormatFloat(*r.Weight, 'f', 2, 64)
+	}
+	exercise := ExerciseRef{ID: r.ExerciseID, Name: r.ExerciseName}.key()
+	return fmt.Sprintf("%s|%s|%s|%.2f", r.RecordType, exercise, weight, r.Value)
+}
+
+func recordSignatures(q queryer, workoutID int) (map[string]bool, error) {
+	rows, err := q.Query(`
+	SELECT exercise_id, exercise_name, record_type, value, weight
+	FROM personal_records
+	WHERE workout_id = $1
+	`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	held := map[string]bool{}
+	for rows.Next() {
+		record := &PersonalRecord{}
+		err = rows.Scan(&record.ExerciseID, &record.ExerciseName, &record.RecordType, &record.Value, &record.Weight)
+		if err != nil {
+			return nil, err
+		}
+		held[record.signature()] = true
+	}
+	return held, rows.Err()
+}
+
+// lockExercises takes a lock on each exercise's records for the rest of the
+// transaction. Saves recompute after they commit, so two saves of the same
+// exercise would otherwise interleave their deletes and inserts, leaving
+// duplicate records or ones computed from a history that was already stale.
+// The locks are taken in a fixed order so that recomputes cannot deadlock.
+func lockExercises(q queryer, userID int, refs []ExerciseRef) error {
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		keys = append(keys, ref.key())
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, err := q.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, userID, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recomputeExercise replaces every stored record of one exercise with the
+// result of replaying the user's full history of it.
+func recomputeExercise(q queryer, userID int, ref ExerciseRef) ([]*PersonalRecord, error) {
+	history, err := loadPerformances(q, userID, ref, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	condition, arg := ref.condition("personal_records", 2)
+	_, err = q.Exec(`DELETE FROM personal_records WHERE user_id = $1 AND `+condition, userID, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	detected := records.Detect(history, strength.Epley)
+
+	// the last record of each kind is the one still standing
+	current := map[string]int{}
+	for i, record := range detected {
+		kind := record.Type
+		if record.Type == records.TypeMaxReps {
+			kind += strconv.FormatFloat(*record.Weight, 'f', 2, 64)
+		}
+		current[kind] = i
+	}
+	isCurrent := map[int]bool{}
+	for _, i := range current {
+		isCurrent[i] = true
+	}
+
+	inserted := make([]*PersonalRecord, 0, len(detected))
+	for i, record := range detected {
+		entryID := record.EntryID
+		pr := &PersonalRecord{
+			UserID:       userID,
+			ExerciseID:   ref.ID,
+			ExerciseName: strings.TrimSpace(ref.Name),
+			WorkoutID:    record.WorkoutID,
+			EntryID:      &entryID,
+			RecordType:   record.Type,
+			Value:        record.Value,
+			Weight:       record.Weight,
+			Reps:         record.Reps,
+			AchievedAt:   record.AchievedAt,
+			Current:      isCurrent[i],
+		}
+
+		err = q.QueryRow(`
+		INSERT INTO personal_records (user_id, exercise_id, exercise_name, workout_id, entry_id, record_type, value, weight, reps, achieved_at, is_current)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+		`,
+			pr.UserID,
+			pr.ExerciseID,
+			pr.ExerciseName,
+			pr.WorkoutID,
+			pr.EntryID,
+			pr.RecordType,
+			pr.Value,
+			pr.Weight,
+			pr.Reps,
+			pr.AchievedAt,
+			pr.Current,
+		).Scan(&pr.ID)
+		if err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, pr)
+	}
+	return inserted, nil
+}
+
+// loadPerformances reads the user's history of one exercise as completed
+// working sets, optionally limited to [from, to). Entries logged without a
+// set log contribute their aggregate sets x reps x weight.
+func loadPerformances(q queryer, userID int, ref ExerciseRef, from, to *time.Time) ([]records.Performance, error) {
+	condition, arg := ref.condition("e", 2)
+	args := []any{userID, arg}
+	if from != nil {
+		args = append(args, *from)
+		condition += fmt.Sprintf(" AND w.performed_at >= $%d", len(args))
+	}
+	if to != nil {
+		args = append(args, *to)
+		condition += fmt.Sprintf(" AND w.performed_at < $%d", len(args))
+	}
+
+	query := `
+	SELECT w.id, e.id, w.performed_at, e.sets, e.reps, e.weight,
+		s.id, s.reps, s.weight, s.rpe, s.set_type, s.completed
+	FROM workout_entries e
+	INNER JOIN workouts w ON w.id = e.workout_id
+	LEFT JOIN workout_sets s ON s.entry_id = e.id
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL AND ` + condition + `
+	ORDER BY w.performed_at, w.id, e.order_index, e.id, s.set_index
+	`
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []records.Performance{}
+	var current *records.Performance
+	for rows.Next() {
+		var workoutID, entryID, entrySets int
+		var performedAt time.Time
+		var entryReps *int
+		var entryWeight *float64
+		var setID, setReps *int
+		var setWeight, setRPE *float64
+		var setType *string
+		var setCompleted *bool
+		err = rows.Scan(
+			&workoutID, &entryID, &performedAt, &entrySets, &entryReps, &entryWeight,
+			&setID, &setReps, &setWeight, &setRPE, &setType, &setCompleted,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if current == nil || current.EntryID != entryID {
+			history = append(history, records.Performance{
+				WorkoutID:   workoutID,
+				EntryID:     entryID,
+				PerformedAt: performedAt,
+			})
+			current = &history[len(history)-1]
+
+			if setID == nil && entryReps != nil {
+				for range entrySets {
+					current.Sets = append(current.Sets, records.Set{Weight: valueOrZero(entryWeight), Reps: *entryReps})
+				}
+			}
+		}
+
+		if setID != nil && setReps != nil && *setCompleted && *setType != SetTypeWarmup {
+			current.Sets = append(current.Sets, records.Set{Weight: valueOrZero(setWeight), Reps: *setReps, RPE: setRPE})
+		}
+	}
+	return history, rows.Err()
+}
+
+func valueOrZero(f *float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return *f
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecomputeRecords(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	recordStore := NewPostgresRecordStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	squat := func(day int, weight float64, reps int) *Workout {
+		workout, err := workoutStore.CreateWorkout(&Workout{
+			UserID:          owner.ID,
+			Title:           "Squats",
+			PerformedAt:     time.Date(2025, 2, day, 9, 0, 0, 0, time.UTC),
+			DurationMinutes: 30,
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Back Squat", Sets: 3, Reps: IntPtr(reps), Weight: FloatPtr(weight), OrderIndex: 1},
+			},
+		})
+		require.NoError(t, err)
+		return workout
+	}
+	typesOf := func(list []*PersonalRecord) []string {
+		var types []string
+		for _, record := range list {
+			types = append(types, record.RecordType)
+		}
+		return types
+	}
+
+	// reps at a weight are not a record until they beat an earlier set
+	firstRecords := []string{records.TypeMaxWeight, records.TypeMaxVolume, records.TypeEstimated1RM}
+
+	first := squat(1, 100, 5)
+	created, err := recordStore.RecomputeForWorkout(owner.ID, first.ID, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, firstRecords, typesOf(created))
+
+	second := squat(8, 110, 5)
+	created, err = recordStore.RecomputeForWorkout(owner.ID, second.ID, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, firstRecords, typesOf(created))
+
+	// saving again without changes reports nothing new
+	created, err = recordStore.RecomputeForWorkout(owner.ID, second.ID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, created)
+
+	// deleting the heavier session hands the records back to the first one
+	previous := EntryRefs(second.Entries)
+	require.NoError(t, workoutStore.DeleteWorkout(int64(second.ID), owner.ID, second.Version))
+	require.NoError(t, recordStore.RecomputeExercises(owner.ID, previous))
+
+	current, err := recordStore.ListRecords(RecordFilter{UserID: owner.ID, CurrentOnly: true})
+	require.NoError(t, err)
+	require.NotEmpty(t, current)
+	for _, record := range current {
+		assert.Equal(t, first.ID, record.WorkoutID)
+	}
+
+	// one more rep at 100kg beats the first session's set
+	third := squat(15, 100, 6)
+	created, err = recordStore.RecomputeForWorkout(owner.ID, third.ID, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{records.TypeMaxReps, records.TypeMaxVolume, records.TypeEstimated1RM}, typesOf(created))
+}
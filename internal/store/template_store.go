@@ -0,0 +1,404 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrDuplicateTemplate = errors.New("a template with this name already exists")
+
+type WorkoutTemplate struct {
+	ID          int             `json:"id"`
+	UserID      int             `json:"user_id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Entries     []TemplateEntry `json:"entries"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// TemplateEntry is one planned exercise of a template. Like workout entries
+// it targets either reps or a duration, never both.
+type TemplateEntry struct {
+	ID                    int      `json:"id"`
+	ExerciseID            *int     `json:"exercise_id"`
+	ExerciseName          string   `json:"exercise_name"`
+	TargetSets            int      `json:"target_sets"`
+	TargetReps            *int     `json:"target_reps"`
+	TargetDurationSeconds *int     `json:"target_duration_seconds"`
+	TargetWeight          *float64 `json:"target_weight"`
+	Notes                 string   `json:"notes"`
+	OrderIndex            int      `json:"order_index"`
+}
+
+func (e *TemplateEntry) Validate() error {
+	if e.TargetSets < 1 {
+		return errors.New("target_sets must be at least 1")
+	}
+	if (e.TargetReps == nil) == (e.TargetDurationSeconds == nil) {
+		return errors.New("exactly one of target_reps or target_duration_seconds is required")
+	}
+	if e.TargetReps != nil && *e.TargetReps < 1 {
+		return errors.New("target_reps must be at least 1")
+	}
+	if e.TargetDurationSeconds != nil && *e.TargetDurationSeconds < 1 {
+		return errors.New("target_duration_seconds must be at least 1")
+	}
+	if e.TargetWeight != nil && *e.TargetWeight < 0 {
+		return errors.New("target_weight cannot be negative")
+	}
+	return nil
+}
+
+// TemplateFromWorkout captures what was done in a workout as targets: the
+// working sets, reps or duration and the top weight of every entry. Cardio
+// entries logged by distance alone have no target a template can hold and
+// are left out.
+func TemplateFromWorkout(workout *Workout) *WorkoutTemplate {
+	template := &WorkoutTemplate{
+		UserID:      workout.UserID,
+		Name:        workout.Title,
+		Description: workout.Description,
+		Entries:     []TemplateEntry{},
+	}
+	for _, entry := range workout.Entries {
+		if entry.Reps == nil && entry.DurationSeconds == nil {
+			continue
+		}
+		template.Entries = append(template.Entries, TemplateEntry{
+			ExerciseID:            entry.ExerciseID,
+			ExerciseName:          entry.ExerciseName,
+			TargetSets:            max(entry.Sets, 1),
+			TargetReps:            entry.Reps,
+			TargetDurationSeconds: entry.DurationSeconds,
+			TargetWeight:          entry.Weight,
+			Notes:                 entry.Notes,
+			OrderIndex:            entry.OrderIndex,
+		})
+	}
+	return template
+}
+
+// Instantiate turns the template into an unsaved workout. lastWeights holds
+// the weight last used for each entry, aligned with Entries; a nil weight
+// falls back to the template's target.
+func (t *WorkoutTemplate) Instantiate(performedAt time.Time, lastWeights []*float64) *Workout {
+	workout := &Workout{
+		UserID:      t.UserID,
+		Title:       t.Name,
+		Description: t.Description,
+		PerformedAt: performedAt,
+		Entries:     []WorkoutEntry{},
+	}
+	for i, entry := range t.Entries {
+		weight := entry.TargetWeight
+		if i < len(lastWeights) && lastWeights[i] != nil {
+			weight = lastWeights[i]
+		}
+		workout.Entries = append(workout.Entries, WorkoutEntry{
+			ExerciseID:      entry.ExerciseID,
+			ExerciseName:    entry.ExerciseName,
+			Sets:            entry.TargetSets,
+			Reps:            entry.TargetReps,
+			DurationSeconds: entry.TargetDurationSeconds,
+			Weight:          weight,
+			Notes:           entry.Notes,
+			OrderIndex:      entry.OrderIndex,
+			Section:         SectionMain,
+		})
+	}
+	return workout
+}
+
+type PostgresTemplateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTemplateStore(db *sql.DB) *PostgresTemplateStore {
+	return &PostgresTemplateStore{db: db}
+}
+
+type TemplateStore interface {
+	CreateTemplate(*WorkoutTemplate) (*WorkoutTemplate, error)
+	GetTemplateByID(id int64, userID int) (*WorkoutTemplate, error)
+	ListTemplates(userID int) ([]*WorkoutTemplate, error)
+	UpdateTemplate(*WorkoutTemplate) error
+	DeleteTemplate(id int64, userID int) error
+	// LastWeights returns, for each exercise, the top weight of the most
+	// recent workout entry that recorded one, or nil if there is none.
+	LastWeights(userID int, refs []ExerciseRef) ([]*float64, error)
+}
+
+func (pg *PostgresTemplateStore) CreateTemplate(template *WorkoutTemplate) (*WorkoutTemplate, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO workout_templates (user_id, name, description)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, updated_at
+	`
+	err = tx.QueryRow(query, template.UserID, template.Name, template.Description).Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateTemplate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertTemplateEntries(tx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+const templateColumns = `t.id, t.user_id, t.name, COALESCE(t.description, ''), t.created_at, t.updated_at`
+
+func scanTemplate(row rowScanner) (*WorkoutTemplate, error) {
+	template := &WorkoutTemplate{}
+	err := row.Scan(
+		&template.ID,
+		&template.UserID,
+		&template.Name,
+		&template.Description,
+		&template.CreatedAt,
+		&template.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (pg *PostgresTemplateStore) GetTemplateByID(id int64, userID int) (*WorkoutTemplate, error) {
+	query := `
+	SELECT ` + templateColumns + `
+	FROM workout_templates t
+	WHERE t.id = $1 AND t.user_id = $2
+	`
+	template, err := scanTemplate(pg.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := pg.getTemplateEntries([]int{template.ID})
+	if err != nil {
+		return nil, err
+	}
+	template.Entries = entries[template.ID]
+	return template, nil
+}
+
+func (pg *PostgresTemplateStore) ListTemplates(userID int) ([]*WorkoutTemplate, error) {
+	query := `
+	SELECT ` + templateColumns + `
+	FROM workout_templates t
+	WHERE t.user_id = $1
+	ORDER BY lower(t.name), t.id
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*WorkoutTemplate{}
+	ids := []int{}
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+		ids = append(ids, template.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := pg.getTemplateEntries(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, template := range templates {
+		template.Entries = entries[template.ID]
+	}
+	return templates, nil
+}
+
+func (pg *PostgresTemplateStore) UpdateTemplate(template *WorkoutTemplate) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE workout_templates
+	SET name = $1, description = $2, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $3 AND user_id = $4
+	RETURNING updated_at
+	`
+	err = tx.QueryRow(query, template.Name, template.Description, template.ID, template.UserID).Scan(&template.UpdatedAt)
+	if isUniqueViolation(err) {
+		return ErrDuplicateTemplate
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM template_entries WHERE template_id = $1`, template.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertTemplateEntries(tx, template)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresTemplateStore) DeleteTemplate(id int64, userID int) error {
+	query := `DELETE FROM workout_templates WHERE id = $1 AND user_id = $2`
+
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (pg *PostgresTemplateStore) LastWeights(userID int, refs []ExerciseRef) ([]*float64, error) {
+	weights := make([]*float64, len(refs))
+	for i, ref := range refs {
+		weight, err := lastWeight(pg.db, userID, ref, nil)
+		if err != nil {
+			return nil, fmt.Errorf("last weight of %s: %w", ref.key(), err)
+		}
+		weights[i] = weight
+	}
+	return weights, nil
+}
+
+// lastWeight returns the top weight of the most recent entry of the exercise
+// that recorded one, or nil. With before set, only workouts performed
+// before it count.
+func lastWeight(q queryer, userID int, ref ExerciseRef, before *time.Time) (*float64, error) {
+	condition, arg := ref.condition("e", 2)
+	args := []any{userID, arg}
+	if before != nil {
+		args = append(args, *before)
+		condition += fmt.Sprintf(" AND w.performed_at < $%d", len(args))
+	}
+	query := `
+	SELECT e.weight
+	FROM workout_entries e
+	INNER JOIN workouts w ON w.id = e.workout_id
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL AND e.weight IS NOT NULL AND ` + condition + `
+	ORDER BY w.performed_at DESC, w.id DESC, e.order_index DESC
+	LIMIT 1
+	`
+	var weight *float64
+	err := q.QueryRow(query, args...).Scan(&weight)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return weight, err
+}
+
+func insertTemplateEntries(tx *sql.Tx, template *WorkoutTemplate) error {
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		query := `
+		INSERT INTO template_entries (template_id, exercise_id, exercise_name, target_sets, target_reps, target_duration_seconds, target_weight, notes, order_index)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+		`
+		err := tx.QueryRow(query,
+			template.ID,
+			entry.ExerciseID,
+			entry.ExerciseName,
+			entry.TargetSets,
+			entry.TargetReps,
+			entry.TargetDurationSeconds,
+			entry.TargetWeight,
+			entry.Notes,
+			entry.OrderIndex,
+		).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pg *PostgresTemplateStore) getTemplateEntries(templateIDs []int) (map[int][]TemplateEntry, error) {
+	entries := make(map[int][]TemplateEntry, len(templateIDs))
+	if len(templateIDs) == 0 {
+		return entries, nil
+	}
+
+	query := `
+	SELECT id, template_id, exercise_id, exercise_name, target_sets, target_reps, target_duration_seconds,
+		target_weight, COALESCE(notes, ''), order_index
+	FROM template_entries
+	WHERE template_id = ANY($1)
+	ORDER BY template_id, order_index, id
+	`
+	rows, err := pg.db.Query(query, templateIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry TemplateEntry
+		var templateID int
+		err = rows.Scan(
+			&entry.ID,
+			&templateID,
+			&entry.ExerciseID,
+			&entry.ExerciseName,
+			&entry.TargetSets,
+			&entry.TargetReps,
+			&entry.TargetDurationSeconds,
+			&entry.TargetWeight,
+			&entry.Notes,
+			&entry.OrderIndex,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries[templateID] = append(entries[templateID], entry)
+	}
+	return entries, rows.Err()
+}
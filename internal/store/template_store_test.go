@@ -0,0 +1,79 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateInstantiate(t *testing.T) {
+	template := &WorkoutTemplate{
+		UserID: 7,
+		Name:   "Push Day A",
+		Entries: []TemplateEntry{
+			{ExerciseName: "Bench Press", TargetSets: 3, TargetReps: IntPtr(5), TargetWeight: FloatPtr(80), OrderIndex: 1},
+			{ExerciseName: "Plank", TargetSets: 2, TargetDurationSeconds: IntPtr(60), OrderIndex: 2},
+		},
+	}
+
+	performedAt := time.Date(2025, 4, 1, 18, 0, 0, 0, time.UTC)
+	workout := template.Instantiate(performedAt, []*float64{FloatPtr(85), nil})
+
+	assert.Equal(t, 7, workout.UserID)
+	assert.Equal(t, "Push Day A", workout.Title)
+	assert.Equal(t, performedAt, workout.PerformedAt)
+	require.Len(t, workout.Entries, 2)
+	assert.Equal(t, 85.0, *workout.Entries[0].Weight, "the last weight used wins over the target")
+	assert.Equal(t, 3, workout.Entries[0].Sets)
+	assert.Nil(t, workout.Entries[1].Weight)
+	assert.Equal(t, 60, *workout.Entries[1].DurationSeconds)
+}
+
+func TestTemplateRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workoutStore := NewPostgresWorkoutStore(db)
+	templateStore := NewPostgresTemplateStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	workout, err := workoutStore.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Push Day A",
+		PerformedAt:     time.Date(2025, 4, 1, 18, 0, 0, 0, time.UTC),
+		DurationMinutes: 60,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(82.5), OrderIndex: 1},
+			{ExerciseName: "Dips", Sets: 3, Reps: IntPtr(10), OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	template, err := templateStore.CreateTemplate(TemplateFromWorkout(workout))
+	require.NoError(t, err)
+
+	_, err = templateStore.CreateTemplate(TemplateFromWorkout(workout))
+	assert.ErrorIs(t, err, ErrDuplicateTemplate)
+
+	fetched, err := templateStore.GetTemplateByID(int64(template.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, fetched.Entries, 2)
+	assert.Equal(t, "Bench Press", fetched.Entries[0].ExerciseName)
+	assert.Equal(t, 82.5, *fetched.Entries[0].TargetWeight)
+
+	hidden, err := templateStore.GetTemplateByID(int64(template.ID), stranger.ID)
+	require.NoError(t, err)
+	assert.Nil(t, hidden)
+
+	weights, err := templateStore.LastWeights(owner.ID, []ExerciseRef{{Name: "bench press"}, {Name: "Dips"}})
+	require.NoError(t, err)
+	require.Len(t, weights, 2)
+	assert.Equal(t, 82.5, *weights[0])
+	assert.Nil(t, weights[1])
+
+	require.NoError(t, templateStore.DeleteTemplate(int64(template.ID), owner.ID))
+	assert.Error(t, templateStore.DeleteTemplate(int64(template.ID), owner.ID))
+}
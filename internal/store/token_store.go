@@ -38,6 +38,9 @@ func (t *PostgresTokenStore) CreateNewToken(userID int, ttl time.Duration, scope
 		return nil, err
 	}
 	err = t.Insert(token)
+	if err != nil {
+		return nil, err
+	}
 
 	return token, nil
 }
@@ -44,6 +44,9 @@ type User struct {
 	Email        string    `json:"email"`
 	PasswordHash password  `json:"_"`
 	Bio          string    `json:"bio"`
+	Timezone     string    `json:"timezone"`
+	Sex          *string   `json:"sex"`
+	BirthDate    *string   `json:"birth_date"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -83,12 +86,12 @@ func (pg *PostgresUserStore) CreateUser(user *User) (*User, error) {
 	defer tx.Rollback()
 
 	query := `
-	INSERT INTO users (username, email, password_hash, bio)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id, created_at, updated_at
+	INSERT INTO users (username, email, password_hash, bio, timezone, sex, birth_date)
+	VALUES ($1, $2, $3, $4, COALESCE(NULLIF($5, ''), 'UTC'), $6, $7)
+	RETURNING id, timezone, created_at, updated_at
 	`
 
-	err = tx.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err = tx.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio, user.Timezone, user.Sex, user.BirthDate).Scan(&user.ID, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +111,7 @@ func (pg *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 	}
 
 	userQuery := `
-	SELECT id, username, email,password_hash, bio, created_at, updated_at
+	SELECT id, username, email, password_hash, bio, timezone, sex, to_char(birth_date, 'YYYY-MM-DD'), created_at, updated_at
 	FROM users
 	WHERE username = $1
 	`
@@ -118,6 +121,9 @@ func (pg *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 		&user.Email,
 		&user.PasswordHash.hash,
 		&user.Bio,
+		&user.Timezone,
+		&user.Sex,
+		&user.BirthDate,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -138,12 +144,12 @@ func (pg *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 	}
 
 	userQuery := `
-	SELECT id, username, email, password_hash, bio, created_at, updated_at
+	SELECT id, username, email, password_hash, bio, timezone, sex, to_char(birth_date, 'YYYY-MM-DD'), created_at, updated_at
 	FROM users
 	WHERE id = $1
 	`
 
-	err := pg.db.QueryRow(userQuery, id).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
+	err := pg.db.QueryRow(userQuery, id).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.Timezone, &user.Sex, &user.BirthDate, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -166,11 +172,11 @@ func (pg *PostgresUserStore) UpdateUser(user *User) error {
 
 	userQuery := `
 	UPDATE users
-	SET username = $1, email = $2, bio = $3, updated_at = CURRENT_TIMESTAMP
-	WHERE id = $4
+	SET username = $1, email = $2, bio = $3, timezone = $4, sex = $5, birth_date = $6, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $7
 	`
 
-	result, err := tx.Exec(userQuery, user.Username, user.Email, user.Bio, user.ID)
+	result, err := tx.Exec(userQuery, user.Username, user.Email, user.Bio, user.Timezone, user.Sex, user.BirthDate, user.ID)
 	if err != nil {
 		return err
 	}
@@ -212,7 +218,7 @@ func (pg *PostgresUserStore) DeleteUser(id int64) error {
 func (s *PostgresUserStore) GetUserToken(scope, plainTextPassword string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(plainTextPassword))
 	query := `
-	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at
+	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.timezone, u.sex, to_char(u.birth_date, 'YYYY-MM-DD'), u.created_at, u.updated_at
 	FROM users u
 	INNER JOIN tokens t ON t.user_id = u.id
 	WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
@@ -226,6 +232,9 @@ func (s *PostgresUserStore) GetUserToken(scope, plainTextPassword string) (*User
 		&user.Email,
 		&user.PasswordHash.hash,
 		&user.Bio,
+		&user.Timezone,
+		&user.Sex,
+		&user.BirthDate,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/calories"
+)
+
+// Where the calories_burned of a workout came from.
+const (
+	// CaloriesMeasured calories were sent by the client or read off a
+	// device file.
+	CaloriesMeasured = "measured"
+	// CaloriesEstimated calories were worked out by EstimateCalories.
+	CaloriesEstimated = "estimated"
+	// CaloriesNone means there are no calories, measured or estimated.
+	CaloriesNone = "none"
+)
+
+// resolveCaloriesSource fills in a source the caller left empty: calories
+// that were given are measured ones.
+func (w *Workout) resolveCaloriesSource() {
+	if w.CaloriesSource != "" {
+		return
+	}
+	if w.CaloriesBurned > 0 {
+		w.CaloriesSource = CaloriesMeasured
+	} else {
+		w.CaloriesSource = CaloriesNone
+	}
+}
+
+// FillCalories keeps the calories the workout was given as measured ones
+// and estimates them otherwise, for the user at the body weight they had;
+// without a weight the workout is left without calories.
+func (w *Workout) FillCalories(user *User, weight *BodyWeight) {
+	if w.CaloriesBurned > 0 && w.CaloriesSource != CaloriesEstimated {
+		w.CaloriesSource = CaloriesMeasured
+		return
+	}
+	if weight == nil {
+		w.CaloriesBurned, w.CaloriesSource = 0, CaloriesNone
+		return
+	}
+	w.EstimateCalories(user.CalorieProfile(weight.WeightKg, w.PerformedAt))
+}
+
+// EstimateCalories replaces the workout's calories with an estimate for the
+// profile, from the workout duration and what each entry did. Without a body
+// weight or a duration to go on the workout is left without calories.
+func (w *Workout) EstimateCalories(p calories.Profile) {
+	seconds := w.DurationMinutes * 60
+	if w.StartedAt != nil && w.EndedAt != nil && w.EndedAt.After(*w.StartedAt) {
+		seconds = int(w.EndedAt.Sub(*w.StartedAt).Seconds())
+	}
+
+	activities := make([]calories.Activity, 0, len(w.Entries))
+	for _, entry := range w.Entries {
+		activities = append(activities, entry.calorieActivity())
+	}
+
+	kcal, ok := calories.Estimate(p, seconds, activities)
+	if !ok {
+		w.CaloriesBurned, w.CaloriesSource = 0, CaloriesNone
+		return
+	}
+	w.CaloriesBurned, w.CaloriesSource = kcal, CaloriesEstimated
+}
+
+// calorieActivity describes the entry to the calories package. Strength
+// entries have no time of their own; timed entries last the durations of
+// their logged sets, or duration_seconds for each set.
+func (e *WorkoutEntry) calorieActivity() calories.Activity {
+	a := calories.Activity{
+		Name: e.ExerciseName,
+		Kind: e.Kind,
+		Sets: e.Sets,
+	}
+	if len(e.SetLog) > 0 {
+		a.Sets = len(e.SetLog)
+	}
+
+	switch e.Kind {
+	case EntryKindCardio:
+		if e.DurationSeconds != nil {
+			a.Seconds = *e.DurationSeconds
+		}
+		if meters := e.DistanceMeters(); meters != nil {
+			a.DistanceMeters = *meters
+		}
+		if e.AvgHeartRate != nil {
+			a.AvgHeartRate = *e.AvgHeartRate
+		}
+	case EntryKindTimed:
+		for _, set := range e.SetLog {
+			if set.DurationSeconds != nil {
+				a.Seconds += *set.DurationSeconds
+			}
+		}
+		if a.Seconds == 0 && e.DurationSeconds != nil {
+			a.Seconds = *e.DurationSeconds * max(e.Sets, 1)
+		}
+	}
+	return a
+}
+
+// CalorieProfile is what calorie estimates need to know about the user, at
+// the body weight they had at the time.
+func (u *User) CalorieProfile(weightKg float64, at time.Time) calories.Profile {
+	p := calories.Profile{WeightKg: weightKg}
+	if u.Sex != nil {
+		p.Sex = *u.Sex
+	}
+	if u.BirthDate != nil {
+		birth, err := time.Parse(time.DateOnly, *u.BirthDate)
+		if err == nil {
+			age := at.Year() - birth.Year()
+			if at.Month() < birth.Month() || at.Month() == birth.Month() && at.Day() < birth.Day() {
+				age--
+			}
+			p.Age = max(age, 0)
+		}
+	}
+	return p
+}
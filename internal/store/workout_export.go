@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// exportBatchSize is how many rows each FETCH pulls from the export cursor.
+const exportBatchSize = 500
+
+// ExportWorkouts streams the user's workouts performed in [from, to), oldest
+// first and complete with their entries and set logs, to fn one at a time.
+// The rows are read through a server-side cursor inside a read-only snapshot,
+// so a whole training history is never held in memory and stays consistent
+// while the user keeps logging. An error from fn stops the export and is
+// returned as is, and cancelling ctx stops it between fetches.
+func (pg *PostgresWorkoutStore) ExportWorkouts(ctx context.Context, userID int, from, to *time.Time, fn func(*Workout) error) error {
+	tx, err := pg.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	DECLARE workout_export NO SCROLL CURSOR FOR
+	SELECT ` + workoutColumns + `,
+		e.id, e.exercise_id, COALESCE(e.exercise_name, ''), COALESCE(e.sets, 0), e.reps, e.duration_seconds, e.weight,
+		COALESCE(e.notes, ''), COALESCE(e.order_index, 0), COALESCE(e.section, ''),
+		e.group_label, e.group_type, e.group_rounds, e.group_rest_seconds,
+		COALESCE(e.kind, ''), e.distance, COALESCE(e.distance_unit, ''), e.elevation_gain_meters,
+		e.avg_heart_rate, e.max_heart_rate, e.avg_cadence, e.avg_power, e.created_at,
+		s.id, COALESCE(s.set_index, 0), COALESCE(s.set_type, ''), s.reps, s.duration_seconds, s.weight,
+		s.rpe, s.rir, COALESCE(s.tempo, ''), s.rest_seconds, COALESCE(s.completed, false)
+	FROM workouts w
+	LEFT JOIN workout_entries e ON e.workout_id = w.id
+	LEFT JOIN workout_sets s ON s.entry_id = e.id
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL
+		AND ($2::timestamptz IS NULL OR w.performed_at >= $2)
+		AND ($3::timestamptz IS NULL OR w.performed_at < $3)
+	ORDER BY w.performed_at, w.id, e.order_index, e.id, s.set_index
+	`
+	_, err = tx.ExecContext(ctx, query, userID, from, to)
+	if err != nil {
+		return err
+	}
+
+	var current *Workout
+	for {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`FETCH FORWARD %d FROM workout_export`, exportBatchSize))
+		if err != nil {
+			return err
+		}
+
+		fetched := 0
+		for rows.Next() {
+			fetched++
+			workout, entry, set, err := scanExportRow(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+
+			if current == nil || current.ID != workout.ID {
+				if current != nil {
+					err = fn(current)
+					if err != nil {
+						rows.Close()
+						return err
+					}
+				}
+				current = workout
+			}
+			if entry == nil {
+				continue
+			}
+
+			n := len(current.Entries)
+			if n == 0 || current.Entries[n-1].ID != entry.ID {
+				current.Entries = append(current.Entries, *entry)
+				n++
+			}
+			if set != nil {
+				current.Entries[n-1].SetLog = append(current.Entries[n-1].SetLog, *set)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if fetched < exportBatchSize {
+			break
+		}
+	}
+
+	if current != nil {
+		err = fn(current)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// scanExportRow reads one row of the export cursor: a workout, the entry of
+// the row if the workout has any, and the set of the row if the entry has a
+// set log.
+func scanExportRow(row rowScanner) (*Workout, *WorkoutEntry, *WorkoutSet, error) {
+	workout := &Workout{}
+	var entry WorkoutEntry
+	var set WorkoutSet
+	var entryID, setID *int
+	var entryCreatedAt *time.Time
+	var groupLabel, groupType *string
+	var groupRounds, groupRest *int
+	err := row.Scan(
+		&workout.ID,
+		&workout.UserID,
+		&workout.Title,
+		&workout.Description,
+		&workout.PerformedAt,
+		&workout.StartedAt,
+		&workout.EndedAt,
+		&workout.DurationMinutes,
+		&workout.CaloriesBurned,
+		&workout.CaloriesSource,
+		&workout.Version,
+		&workout.CreatedAt,
+		&workout.UpdatedAt,
+		&workout.DeletedAt,
+		&entryID,
+		&entry.ExerciseID,
+		&entry.ExerciseName,
+		&entry.Sets,
+		&entry.Reps,
+		&entry.DurationSeconds,
+		&entry.Weight,
+		&entry.Notes,
+		&entry.OrderIndex,
+		&entry.Section,
+		&groupLabel,
+		&groupType,
+		&groupRounds,
+		&groupRest,
+		&entry.Kind,
+		&entry.Distance,
+		&entry.DistanceUnit,
+		&entry.ElevationGainMeters,
+		&entry.AvgHeartRate,
+		&entry.MaxHeartRate,
+		&entry.AvgCadence,
+		&entry.AvgPower,
+		&entryCreatedAt,
+		&setID,
+		&set.SetIndex,
+		&set.SetType,
+		&set.Reps,
+		&set.DurationSeconds,
+		&set.Weight,
+		&set.RPE,
+		&set.RIR,
+		&set.Tempo,
+		&set.RestSeconds,
+		&set.Completed,
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if entryID == nil {
+		return workout, nil, nil, nil
+	}
+
+	entry.ID = *entryID
+	if entryCreatedAt != nil {
+		entry.CreatedAt = *entryCreatedAt
+	}
+	if groupLabel != nil {
+		entry.Group = &EntryGroup{Label: *groupLabel, Type: *groupType, Rounds: *groupRounds, RestSeconds: groupRest}
+	}
+	if setID == nil {
+		return workout, &entry, nil, nil
+	}
+	set.ID = *setID
+	return workout, &entry, &set, nil
+}
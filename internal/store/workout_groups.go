@@ -0,0 +1,128 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	SectionWarmup   = "warmup"
+	SectionMain     = "main"
+	SectionCooldown = "cooldown"
+)
+
+// Sections are listed in the order they have to appear in a workout.
+var Sections = []string{SectionWarmup, SectionMain, SectionCooldown}
+
+const (
+	GroupSuperset = "superset"
+	GroupCircuit  = "circuit"
+)
+
+var GroupTypes = []string{GroupSuperset, GroupCircuit}
+
+// EntryGroup ties entries that are performed back to back, round after
+// round, with rest only between rounds. Every entry of a group repeats the
+// same label, type, rounds and rest.
+type EntryGroup struct {
+	Label       string `json:"label"`
+	Type        string `json:"type"`
+	Rounds      int    `json:"rounds"`
+	RestSeconds *int   `json:"rest_seconds"`
+}
+
+func (g *EntryGroup) Validate() error {
+	if g.Label == "" || len(g.Label) > 16 {
+		return errors.New("group label is required and must be at most 16 characters long")
+	}
+	if !slices.Contains(GroupTypes, g.Type) {
+		return fmt.Errorf("group type must be one of %v", GroupTypes)
+	}
+	if g.Rounds < 1 {
+		return errors.New("group rounds must be at least 1")
+	}
+	if g.RestSeconds != nil && *g.RestSeconds < 0 {
+		return errors.New("group rest_seconds cannot be negative")
+	}
+	return nil
+}
+
+func (g *EntryGroup) same(other *EntryGroup) bool {
+	return g.Type == other.Type && g.Rounds == other.Rounds &&
+		((g.RestSeconds == nil && other.RestSeconds == nil) ||
+			(g.RestSeconds != nil && other.RestSeconds != nil && *g.RestSeconds == *other.RestSeconds))
+}
+
+// ValidateGroups checks the structure of a workout's entries taken in
+// order_index order: sections run warm-up, main, cool-down; the entries of a
+// group are adjacent, lie in one section, agree on the group settings and
+// number at least two. Entries without a section are put in the main one.
+func ValidateGroups(entries []WorkoutEntry) error {
+	for i := range entries {
+		if entries[i].Section == "" {
+			entries[i].Section = SectionMain
+		}
+		if !slices.Contains(Sections, entries[i].Section) {
+			return fmt.Errorf("entry %d: section must be one of %v", i+1, Sections)
+		}
+		if entries[i].Group != nil {
+			if err := entries[i].Group.Validate(); err != nil {
+				return fmt.Errorf("entry %d: %w", i+1, err)
+			}
+		}
+	}
+
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return entries[order[a]].OrderIndex < entries[order[b]].OrderIndex
+	})
+
+	section := 0
+	sizes := map[string]int{}
+	labels := []string{}
+	var previous *WorkoutEntry
+	for _, i := range order {
+		entry := &entries[i]
+		s := slices.Index(Sections, entry.Section)
+		if s < section {
+			return fmt.Errorf("entry %d: the %s section cannot come after the %s section", i+1, entry.Section, Sections[section])
+		}
+		section = s
+
+		if entry.Group == nil {
+			previous = entry
+			continue
+		}
+
+		label := entry.Group.Label
+		continuing := previous != nil && previous.Group != nil && previous.Group.Label == label
+		if sizes[label] > 0 && !continuing {
+			return fmt.Errorf("entry %d: the entries of group %q must be consecutive", i+1, label)
+		}
+		if continuing {
+			if previous.Section != entry.Section {
+				return fmt.Errorf("entry %d: group %q cannot span sections", i+1, label)
+			}
+			if !previous.Group.same(entry.Group) {
+				return fmt.Errorf("entry %d: every entry of group %q must have the same type, rounds and rest_seconds", i+1, label)
+			}
+		}
+		if sizes[label] == 0 {
+			labels = append(labels, label)
+		}
+		sizes[label]++
+		previous = entry
+	}
+
+	for _, label := range labels {
+		if sizes[label] < 2 {
+			return fmt.Errorf("group %q needs at least two entries", label)
+		}
+	}
+	return nil
+}
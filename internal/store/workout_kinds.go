@@ -0,0 +1,173 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	EntryKindStrength = "strength"
+	EntryKindCardio   = "cardio"
+	EntryKindTimed    = "timed"
+)
+
+var EntryKinds = []string{EntryKindStrength, EntryKindCardio, EntryKindTimed}
+
+// distanceUnits maps every accepted distance unit to its length in meters.
+var distanceUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"mi": 1609.344,
+	"yd": 0.9144,
+}
+
+const (
+	minHeartRate = 20
+	maxHeartRate = 250
+)
+
+// hasCardioFields reports whether any of the fields only cardio entries may
+// carry is set.
+func (e *WorkoutEntry) hasCardioFields() bool {
+	return e.Distance != nil || e.ElevationGainMeters != nil || e.AvgHeartRate != nil ||
+		e.MaxHeartRate != nil || e.AvgCadence != nil || e.AvgPower != nil
+}
+
+// ValidateKind checks an entry against the rules of its kind, inferring the
+// kind when the client left it out: cardio when a cardio field is set,
+// strength with reps and timed with only a duration.
+//
+//   - strength entries count reps, never a duration.
+//   - timed holds last a duration, never reps.
+//   - cardio entries cover a distance, a duration or both, never reps.
+//
+// Distance, elevation, heart rate, cadence and power belong to cardio only.
+func (e *WorkoutEntry) ValidateKind() error {
+	if e.Kind == "" {
+		switch {
+		case e.hasCardioFields():
+			e.Kind = EntryKindCardio
+		case e.Reps != nil:
+			e.Kind = EntryKindStrength
+		default:
+			e.Kind = EntryKindTimed
+		}
+	}
+
+	switch e.Kind {
+	case EntryKindStrength:
+		if e.Reps == nil || e.DurationSeconds != nil {
+			return errors.New("strength entries need reps and no duration_seconds")
+		}
+	case EntryKindTimed:
+		if e.DurationSeconds == nil || e.Reps != nil {
+			return errors.New("timed entries need duration_seconds and no reps")
+		}
+	case EntryKindCardio:
+		if e.Reps != nil {
+			return errors.New("cardio entries cannot have reps")
+		}
+		if e.Distance == nil && e.DurationSeconds == nil {
+			return errors.New("cardio entries need a distance, duration_seconds or both")
+		}
+	default:
+		return fmt.Errorf("kind must be one of %v", EntryKinds)
+	}
+
+	if e.Kind != EntryKindCardio {
+		if e.hasCardioFields() {
+			return fmt.Errorf("%s entries cannot have distance, elevation, heart rate, cadence or power", e.Kind)
+		}
+		e.DistanceUnit = ""
+		return nil
+	}
+
+	if e.Distance != nil {
+		if *e.Distance <= 0 {
+			return errors.New("distance must be positive")
+		}
+		if e.DistanceUnit == "" {
+			e.DistanceUnit = "km"
+		}
+		if _, ok := distanceUnits[e.DistanceUnit]; !ok {
+			return fmt.Errorf("distance_unit must be one of %v", DistanceUnits())
+		}
+	} else {
+		e.DistanceUnit = ""
+	}
+	if e.DurationSeconds != nil && *e.DurationSeconds <= 0 {
+		return errors.New("duration_seconds must be positive")
+	}
+	if e.ElevationGainMeters != nil && *e.ElevationGainMeters < 0 {
+		return errors.New("elevation_gain_meters cannot be negative")
+	}
+	for _, hr := range []*int{e.AvgHeartRate, e.MaxHeartRate} {
+		if hr != nil && (*hr < minHeartRate || *hr > maxHeartRate) {
+			return fmt.Errorf("heart rates must be between %d and %d bpm", minHeartRate, maxHeartRate)
+		}
+	}
+	if e.AvgHeartRate != nil && e.MaxHeartRate != nil && *e.MaxHeartRate < *e.AvgHeartRate {
+		return errors.New("max_heart_rate cannot be below avg_heart_rate")
+	}
+	if e.AvgCadence != nil && *e.AvgCadence < 0 {
+		return errors.New("avg_cadence cannot be negative")
+	}
+	if e.AvgPower != nil && *e.AvgPower < 0 {
+		return errors.New("avg_power cannot be negative")
+	}
+	return nil
+}
+
+// DistanceMeters converts the entry's distance to meters, nil without one.
+func (e *WorkoutEntry) DistanceMeters() *float64 {
+	if e.Distance == nil {
+		return nil
+	}
+	factor, ok := distanceUnits[e.DistanceUnit]
+	if !ok {
+		return nil
+	}
+	meters := *e.Distance * factor
+	return &meters
+}
+
+// Pace returns the seconds taken per kilometer and the speed in km/h of a
+// cardio entry that has both a distance and a duration.
+func (e *WorkoutEntry) Pace() (secondsPerKm, kmh *float64) {
+	meters := e.DistanceMeters()
+	if meters == nil || *meters == 0 || e.DurationSeconds == nil || *e.DurationSeconds == 0 {
+		return nil, nil
+	}
+	seconds := float64(*e.DurationSeconds)
+	pace := math.Round(seconds/(*meters/1000)*10) / 10
+	speed := math.Round(*meters/1000/(seconds/3600)*100) / 100
+	return &pace, &speed
+}
+
+// MarshalJSON adds the pace and speed derived from distance and duration to
+// cardio entries, so clients never have to work them out themselves.
+func (e WorkoutEntry) MarshalJSON() ([]byte, error) {
+	type workoutEntry WorkoutEntry
+	out := struct {
+		workoutEntry
+		PaceSecondsPerKm *float64 `json:"pace_seconds_per_km,omitempty"`
+		SpeedKmh         *float64 `json:"speed_kmh,omitempty"`
+	}{workoutEntry: workoutEntry(e)}
+	if e.Kind == EntryKindCardio {
+		out.PaceSecondsPerKm, out.SpeedKmh = e.Pace()
+	}
+	return json.Marshal(out)
+}
+
+// DistanceUnits lists the accepted distance units.
+func DistanceUnits() []string {
+	units := make([]string, 0, len(distanceUnits))
+	for unit := range distanceUnits {
+		units = append(units, unit)
+	}
+	slices.Sort(units)
+	return units
+}
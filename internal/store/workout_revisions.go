@@ -0,0 +1,256 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Every save of a workout records a revision: the workout as saved at that
+// version, who saved it and when. Revisions are written in the transaction
+// of the save, so the history neither misses nor invents a version. Workouts
+// saved before revisions were kept get their first one, for the version
+// they were at, when they are next saved.
+
+type WorkoutRevision struct {
+	WorkoutID    int       `json:"workout_id"`
+	Version      int       `json:"version"`
+	AuthorID     int       `json:"author_id"`
+	RevertedFrom *int      `json:"reverted_from"`
+	CreatedAt    time.Time `json:"created_at"`
+	Snapshot     *Workout  `json:"snapshot,omitempty"`
+}
+
+// recordRevision saves the workout, as saved at workout.Version, as a
+// revision. A revision is dated when its version was saved.
+func recordRevision(tx *sql.Tx, workout *Workout, revertedFrom *int) error {
+	snapshot, err := json.Marshal(workout)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO workout_revisions (workout_id, version, author_id, reverted_from, snapshot, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (workout_id, version) DO NOTHING
+	`
+	_, err = tx.Exec(query, workout.ID, workout.Version, workout.UserID, revertedFrom, snapshot, workout.UpdatedAt)
+	return err
+}
+
+// unrecordedVersion returns the stored workout when the version a save is
+// based on has no revision yet, so that it can be recorded along with the
+// save, and nil otherwise.
+func (pg *PostgresWorkoutStore) unrecordedVersion(workout *Workout) (*Workout, error) {
+	var recorded bool
+	err := pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM workout_revisions WHERE workout_id = $1 AND version = $2)`,
+		workout.ID, workout.Version).Scan(&recorded)
+	if err != nil || recorded {
+		return nil, err
+	}
+
+	stored, err := pg.GetWorkoutByID(int64(workout.ID), workout.UserID)
+	if err != nil || stored == nil || stored.Version != workout.Version {
+		// the save is going to fail on its version check anyway
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (pg *PostgresWorkoutStore) RevertWorkout(workout *Workout, revision int) error {
+	return pg.updateWorkout(workout, &revision)
+}
+
+func (pg *PostgresWorkoutStore) ListRevisions(workoutID int64, userID int) ([]*WorkoutRevision, error) {
+	query := `
+	SELECT r.workout_id, r.version, r.author_id, r.reverted_from, r.created_at
+	FROM workout_revisions r
+	INNER JOIN workouts w ON w.id = r.workout_id
+	WHERE r.workout_id = $1 AND w.user_id = $2 AND w.deleted_at IS NULL
+	ORDER BY r.version DESC
+	`
+	rows, err := pg.db.Query(query, workoutID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*WorkoutRevision{}
+	for rows.Next() {
+		revision := &WorkoutRevision{}
+		err = rows.Scan(&revision.WorkoutID, &revision.Version, &revision.AuthorID, &revision.RevertedFrom, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (pg *PostgresWorkoutStore) GetRevision(workoutID int64, userID int, version int) (*WorkoutRevision, error) {
+	query := `
+	SELECT r.workout_id, r.version, r.author_id, r.reverted_from, r.created_at, r.snapshot
+	FROM workout_revisions r
+	INNER JOIN workouts w ON w.id = r.workout_id
+	WHERE r.workout_id = $1 AND w.user_id = $2 AND w.deleted_at IS NULL AND r.version = $3
+	`
+	revision := &WorkoutRevision{}
+	var snapshot []byte
+	err := pg.db.QueryRow(query, workoutID, userID, version).Scan(
+		&revision.WorkoutID,
+		&revision.Version,
+		&revision.AuthorID,
+		&revision.RevertedFrom,
+		&revision.CreatedAt,
+		&snapshot,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(snapshot, &revision.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// FieldChange is a field whose value differs between two revisions.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// EntryChange is an entry added, removed or changed between two revisions.
+// Added and removed entries come whole; changed ones list their fields.
+type EntryChange struct {
+	EntryID int           `json:"entry_id"`
+	Change  string        `json:"change"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+	Entry   *WorkoutEntry `json:"entry,omitempty"`
+}
+
+const (
+	EntryAdded   = "added"
+	EntryRemoved = "removed"
+	EntryChanged = "changed"
+)
+
+type WorkoutDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Fields  []FieldChange `json:"fields"`
+	Entries []EntryChange `json:"entries"`
+}
+
+// DiffWorkouts lists what changed from one revision of a workout to
+// another. Entries are told apart by id; what the server keeps up to date
+// on its own, such as versions, timestamps and set ids, is left out.
+func DiffWorkouts(from, to *Workout) (*WorkoutDiff, error) {
+	diff := &WorkoutDiff{From: from.Version, To: to.Version, Entries: []EntryChange{}}
+
+	var err error
+	diff.Fields, err = diffFields(from, to, "id", "user_id", "version", "created_at", "updated_at", "deleted_at", "entries")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range to.Entries {
+		i := slices.IndexFunc(from.Entries, func(e WorkoutEntry) bool { return e.ID == entry.ID })
+		if i < 0 {
+			diff.Entries = append(diff.Entries, EntryChange{EntryID: entry.ID, Change: EntryAdded, Entry: &entry})
+			continue
+		}
+		fields, err := diffFields(&from.Entries[i], &entry, "id", "created_at")
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			diff.Entries = append(diff.Entries, EntryChange{EntryID: entry.ID, Change: EntryChanged, Fields: fields})
+		}
+	}
+	for _, entry := range from.Entries {
+		if !slices.ContainsFunc(to.Entries, func(e WorkoutEntry) bool { return e.ID == entry.ID }) {
+			diff.Entries = append(diff.Entries, EntryChange{EntryID: entry.ID, Change: EntryRemoved, Entry: &entry})
+		}
+	}
+	return diff, nil
+}
+
+// diffFields compares two values field by field as they appear in JSON,
+// leaving out the skipped fields, in field order.
+func diffFields(from, to any, skip ...string) ([]FieldChange, error) {
+	a, err := diffableFields(from, skip)
+	if err != nil {
+		return nil, err
+	}
+	b, err := diffableFields(to, skip)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if !sameValue(a[name], b[name]) {
+			changes = append(changes, FieldChange{Field: name, From: a[name], To: b[name]})
+		}
+	}
+	return changes, nil
+}
+
+func diffableFields(v any, skip []string) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range skip {
+		delete(fields, name)
+	}
+	// sets get new ids whenever their entry is saved
+	if sets, ok := fields["set_log"].([]any); ok {
+		for _, set := range sets {
+			if set, ok := set.(map[string]any); ok {
+				delete(set, "id")
+			}
+		}
+	}
+	if _, ok := fields["set_log"]; ok && fields["set_log"] == nil {
+		fields["set_log"] = []any{}
+	}
+	return fields, nil
+}
+
+// sameValue compares decoded JSON values, times by the instant they name
+// whatever offset they were written with.
+func sameValue(a, b any) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	x, okA := a.(string)
+	y, okB := b.(string)
+	if !okA || !okB {
+		return false
+	}
+	s, errA := time.Parse(time.RFC3339Nano, x)
+	t, errB := time.Parse(time.RFC3339Nano, y)
+	return errA == nil && errB == nil && s.Equal(t)
+}
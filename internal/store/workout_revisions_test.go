@@ -0,0 +1,111 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffWorkouts(t *testing.T) {
+	performed := time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	from := &Workout{
+		Version:     1,
+		Title:       "Push",
+		PerformedAt: performed,
+		Entries: []WorkoutEntry{
+			{ID: 1, ExerciseName: "Bench Press", Sets: 1, OrderIndex: 1, SetLog: []WorkoutSet{{ID: 10, Reps: IntPtr(5), Weight: FloatPtr(80)}}},
+			{ID: 2, ExerciseName: "Dips", Sets: 3, Reps: IntPtr(10), OrderIndex: 2},
+		},
+		UpdatedAt: performed,
+	}
+	to := &Workout{
+		Version:     2,
+		Title:       "Push (heavy)",
+		PerformedAt: performed.In(time.FixedZone("CEST", 2*60*60)),
+		Entries: []WorkoutEntry{
+			// saving gave the set a new id but left it as it was
+			{ID: 1, ExerciseName: "Bench Press", Sets: 1, OrderIndex: 1, SetLog: []WorkoutSet{{ID: 11, Reps: IntPtr(5), Weight: FloatPtr(80)}}, Notes: "felt easy"},
+			{ID: 3, ExerciseName: "Push-up", Sets: 2, Reps: IntPtr(20), OrderIndex: 2},
+		},
+		UpdatedAt: performed.Add(time.Hour),
+	}
+
+	diff, err := DiffWorkouts(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, 2, diff.To)
+	assert.Equal(t, []FieldChange{{Field: "title", From: "Push", To: "Push (heavy)"}}, diff.Fields)
+
+	require.Len(t, diff.Entries, 3)
+	assert.Equal(t, EntryChange{EntryID: 1, Change: EntryChanged, Fields: []FieldChange{{Field: "notes", From: "", To: "felt easy"}}}, diff.Entries[0])
+	assert.Equal(t, EntryAdded, diff.Entries[1].Change)
+	assert.Equal(t, "Push-up", diff.Entries[1].Entry.ExerciseName)
+	assert.Equal(t, EntryRemoved, diff.Entries[2].Change)
+	assert.Equal(t, 2, diff.Entries[2].EntryID)
+
+	same, err := DiffWorkouts(from, from)
+	require.NoError(t, err)
+	assert.Empty(t, same.Fields)
+	assert.Empty(t, same.Entries)
+}
+
+func TestWorkoutRevisions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Leg Day",
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	workout.Title = "Leg Day (heavy)"
+	workout.Entries[0].Weight = FloatPtr(110)
+	require.NoError(t, store.UpdateWorkout(workout))
+
+	revisions, err := store.ListRevisions(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Version, "newest first")
+	assert.Equal(t, owner.ID, revisions[0].AuthorID)
+	assert.Nil(t, revisions[0].Snapshot)
+
+	first, err := store.GetRevision(int64(workout.ID), owner.ID, 1)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.Equal(t, "Leg Day", first.Snapshot.Title)
+	assert.Equal(t, 100.0, *first.Snapshot.Entries[0].Weight)
+
+	none, err := store.GetRevision(int64(workout.ID), stranger.ID, 1)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	// a revert is a new revision that says where it came from
+	reverted := *first.Snapshot
+	reverted.Version = workout.Version
+	require.NoError(t, store.RevertWorkout(&reverted, 1))
+	assert.Equal(t, 3, reverted.Version)
+
+	latest, err := store.GetRevision(int64(workout.ID), owner.ID, 3)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	require.NotNil(t, latest.RevertedFrom)
+	assert.Equal(t, 1, *latest.RevertedFrom)
+	assert.Equal(t, "Leg Day", latest.Snapshot.Title)
+	assert.Equal(t, workout.Entries[0].ID, latest.Snapshot.Entries[0].ID, "the entry is kept")
+
+	diff, err := DiffWorkouts(first.Snapshot, latest.Snapshot)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Fields)
+	assert.Empty(t, diff.Entries)
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+)
+
+const (
+	SetTypeWarmup  = "warmup"
+	SetTypeWorking = "working"
+	SetTypeDrop    = "drop"
+	SetTypeFailure = "failure"
+)
+
+var SetTypes = []string{SetTypeWarmup, SetTypeWorking, SetTypeDrop, SetTypeFailure}
+
+// tempo is written as four phases (eccentric, pause, concentric, pause),
+// either run together ("31X0") or dash separated ("3-1-X-0").
+var tempoRegex = regexp.MustCompile(`^[0-9X](-?[0-9X]){3}$`)
+
+type WorkoutSet struct {
+	ID              int      `json:"id"`
+	SetIndex        int      `json:"set_index"`
+	SetType         string   `json:"set_type"`
+	Reps            *int     `json:"reps"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	Weight          *float64 `json:"weight"`
+	RPE             *float64 `json:"rpe"`
+	RIR             *int     `json:"rir"`
+	Tempo           string   `json:"tempo"`
+	RestSeconds     *int     `json:"rest_seconds"`
+	Completed       bool     `json:"completed"`
+}
+
+// UnmarshalJSON defaults omitted fields to a completed working set, which is
+// what a client that only sends reps and weight means.
+func (s *WorkoutSet) UnmarshalJSON(data []byte) error {
+	type workoutSet WorkoutSet
+	set := workoutSet{SetType: SetTypeWorking, Completed: true}
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return err
+	}
+	*s = WorkoutSet(set)
+	return nil
+}
+
+func (s *WorkoutSet) Validate() error {
+	if !slices.Contains(SetTypes, s.SetType) {
+		return fmt.Errorf("set_type must be one of %v", SetTypes)
+	}
+	if (s.Reps == nil) == (s.DurationSeconds == nil) {
+		return errors.New("exactly one of reps or duration_seconds is required")
+	}
+	if s.Reps != nil && *s.Reps < 0 {
+		return errors.New("reps cannot be negative")
+	}
+	if s.DurationSeconds != nil && *s.DurationSeconds < 0 {
+		return errors.New("duration_seconds cannot be negative")
+	}
+	if s.Weight != nil && *s.Weight < 0 {
+		return errors.New("weight cannot be negative")
+	}
+	if s.RPE != nil && (*s.RPE < 1 || *s.RPE > 10 || math.Mod(*s.RPE*2, 1) != 0) {
+		return errors.New("rpe must be between 1 and 10 in steps of 0.5")
+	}
+	if s.RIR != nil && (*s.RIR < 0 || *s.RIR > 10) {
+		return errors.New("rir must be between 0 and 10")
+	}
+	if s.Tempo != "" && !tempoRegex.MatchString(s.Tempo) {
+		return errors.New(`tempo must have four phases such as "3110" or "3-1-X-0"`)
+	}
+	if s.RestSeconds != nil && *s.RestSeconds < 0 {
+		return errors.New("rest_seconds cannot be negative")
+	}
+	return nil
+}
+
+// counts reports whether the set contributes to the entry's working volume.
+func (s *WorkoutSet) counts() bool {
+	return s.Completed && s.SetType != SetTypeWarmup
+}
+
+// SummarizeSets validates the set log of an entry and rewrites the legacy
+// sets/reps/duration_seconds/weight fields from it, so clients that predate
+// set logging keep reading a sensible aggregate: the number of completed
+// working sets, and the reps (or duration) and weight of the top set.
+// Entries without a set log are left untouched.
+func (e *WorkoutEntry) SummarizeSets() error {
+	if len(e.SetLog) == 0 {
+		return nil
+	}
+
+	timed := e.SetLog[0].DurationSeconds != nil
+	for i := range e.SetLog {
+		set := &e.SetLog[i]
+		set.SetIndex = i + 1
+		if err := set.Validate(); err != nil {
+			return fmt.Errorf("set %d: %w", i+1, err)
+		}
+		if (set.DurationSeconds != nil) != timed {
+			return fmt.Errorf("set %d: sets of one entry must all use reps or all use duration_seconds", i+1)
+		}
+	}
+
+	working := []*WorkoutSet{}
+	for i := range e.SetLog {
+		if e.SetLog[i].counts() {
+			working = append(working, &e.SetLog[i])
+		}
+	}
+	// a log of nothing but warm-ups still deserves a summary
+	if len(working) == 0 {
+		for i := range e.SetLog {
+			working = append(working, &e.SetLog[i])
+		}
+	}
+
+	top := working[0]
+	for _, set := range working[1:] {
+		if setWeight(set) > setWeight(top) ||
+			(setWeight(set) == setWeight(top) && setEffort(set) > setEffort(top)) {
+			top = set
+		}
+	}
+
+	e.Sets = len(working)
+	e.Weight = top.Weight
+	if timed {
+		e.Reps = nil
+		e.DurationSeconds = top.DurationSeconds
+	} else {
+		e.Reps = top.Reps
+		e.DurationSeconds = nil
+	}
+	return nil
+}
+
+func setWeight(s *WorkoutSet) float64 {
+	if s.Weight == nil {
+		return 0
+	}
+	return *s.Weight
+}
+
+func setEffort(s *WorkoutSet) int {
+	if s.Reps != nil {
+		return *s.Reps
+	}
+	if s.DurationSeconds != nil {
+		return *s.DurationSeconds
+	}
+	return 0
+}
+
+func insertSets(tx *sql.Tx, entry *WorkoutEntry) error {
+	for i := range entry.SetLog {
+		set := &entry.SetLog[i]
+		query := `
+		INSERT INTO workout_sets (entry_id, set_index, set_type, reps, duration_seconds, weight, rpe, rir, tempo, rest_seconds, completed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11)
+		RETURNING id
+		`
+		err := tx.QueryRow(query,
+			entry.ID,
+			set.SetIndex,
+			set.SetType,
+			set.Reps,
+			set.DurationSeconds,
+			set.Weight,
+			set.RPE,
+			set.RIR,
+			set.Tempo,
+			set.RestSeconds,
+			set.Completed,
+		).Scan(&set.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSetsForEntries loads the set logs of many entries in one query.
+func getSetsForEntries(db *sql.DB, entryIDs []int) (map[int][]WorkoutSet, error) {
+	sets := make(map[int][]WorkoutSet, len(entryIDs))
+	if len(entryIDs) == 0 {
+		return sets, nil
+	}
+
+	query := `
+	SELECT id, entry_id, set_index, set_type, reps, duration_seconds, weight, rpe, rir, COALESCE(tempo, ''), rest_seconds, completed
+	FROM workout_sets
+	WHERE entry_id = ANY($1)
+	ORDER BY entry_id, set_index
+	`
+	rows, err := db.Query(query, entryIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var set WorkoutSet
+		var entryID int
+		err = rows.Scan(
+			&set.ID,
+			&entryID,
+			&set.SetIndex,
+			&set.SetType,
+			&set.Reps,
+			&set.DurationSeconds,
+			&set.Weight,
+			&set.RPE,
+			&set.RIR,
+			&set.Tempo,
+			&set.RestSeconds,
+			&set.Completed,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sets[entryID] = append(sets[entryID], set)
+	}
+	return sets, rows.Err()
+}
@@ -1,28 +1,165 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
 )
 
+// ErrVersionConflict is returned when a workout was saved by someone else
+// since the version a write was based on.
+var ErrVersionConflict = errors.New("workout was modified since it was read")
+
 type Workout struct {
 	ID              int            `json:"id"`
+	UserID          int            `json:"user_id"`
 	Title           string         `json:"title"`
 	Description     string         `json:"description"`
+	PerformedAt     time.Time      `json:"performed_at"`
+	StartedAt       *time.Time     `json:"started_at"`
+	EndedAt         *time.Time     `json:"ended_at"`
 	DurationMinutes int            `json:"duration_minutes"`
 	CaloriesBurned  int            `json:"calories_burned"`
+	CaloriesSource  string         `json:"calories_source"`
+	Version         int            `json:"version"`
 	Entries         []WorkoutEntry `json:"entries"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       *time.Time     `json:"deleted_at,omitempty"`
 }
 
 type WorkoutEntry struct {
-	ID              int      `json:"id"`
-	ExerciseName    string   `json:"exercise_name"`
-	Sets            int      `json:"sets"`
-	Reps            *int     `json:"reps"`
-	DurationSeconds *int     `json:"duration_seconds"`
-	Weight          *float64 `json:"weight"`
-	Notes           string   `json:"notes"`
-	OrderIndex      int      `json:"order_index"`
+	ID                  int          `json:"id"`
+	ExerciseID          *int         `json:"exercise_id"`
+	ExerciseName        string       `json:"exercise_name"`
+	Sets                int          `json:"sets"`
+	Reps                *int         `json:"reps"`
+	DurationSeconds     *int         `json:"duration_seconds"`
+	Weight              *float64     `json:"weight"`
+	Notes               string       `json:"notes"`
+	OrderIndex          int          `json:"order_index"`
+	Kind                string       `json:"kind"`
+	Distance            *float64     `json:"distance"`
+	DistanceUnit        string       `json:"distance_unit"`
+	ElevationGainMeters *float64     `json:"elevation_gain_meters"`
+	AvgHeartRate        *int         `json:"avg_heart_rate"`
+	MaxHeartRate        *int         `json:"max_heart_rate"`
+	AvgCadence          *int         `json:"avg_cadence"`
+	AvgPower            *int         `json:"avg_power"`
+	Section             string       `json:"section"`
+	Group               *EntryGroup  `json:"group"`
+	SetLog              []WorkoutSet `json:"set_log"`
+	CreatedAt           time.Time    `json:"created_at"`
+}
+
+// ResolveTimes fills in the timing fields a client is allowed to omit and
+// rejects inconsistent ones. A missing performed_at falls back to started_at
+// and then to now; a missing duration is derived from started_at/ended_at.
+func (w *Workout) ResolveTimes() error {
+	if w.StartedAt != nil && w.EndedAt != nil {
+		if !w.EndedAt.After(*w.StartedAt) {
+			return errors.New("ended_at must be after started_at")
+		}
+		if w.DurationMinutes == 0 {
+			w.DurationMinutes = int(math.Round(w.EndedAt.Sub(*w.StartedAt).Minutes()))
+		}
+	}
+
+	if w.PerformedAt.IsZero() {
+		if w.StartedAt != nil {
+			w.PerformedAt = *w.StartedAt
+		} else {
+			w.PerformedAt = time.Now()
+		}
+	}
+
+	if w.DurationMinutes < 0 {
+		return errors.New("duration_minutes cannot be negative")
+	}
+	return nil
+}
+
+type WorkoutFilter struct {
+	UserID     int
+	From       *time.Time
+	To         *time.Time
+	Title      string
+	Exercise   string
+	ExerciseID int
+	Sort       string
+	Limit      int
+	Cursor     string
+}
+
+type WorkoutPage struct {
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const (
+	DefaultWorkoutSort  = "-performed_at"
+	DefaultWorkoutLimit = 20
+	MaxWorkoutLimit     = 100
+)
+
+type workoutSort struct {
+	column string
+	desc   bool
+	// key or time extracts the cursor value of a row, depending on the column type
+	key  func(*Workout) string
+	time func(*Workout) time.Time
+}
+
+var workoutSorts = map[string]workoutSort{
+	"performed_at":  {column: "w.performed_at", time: func(w *Workout) time.Time { return w.PerformedAt }},
+	"-performed_at": {column: "w.performed_at", desc: true, time: func(w *Workout) time.Time { return w.PerformedAt }},
+	"created_at":    {column: "w.created_at", time: func(w *Workout) time.Time { return w.CreatedAt }},
+	"-created_at":   {column: "w.created_at", desc: true, time: func(w *Workout) time.Time { return w.CreatedAt }},
+	"title":         {column: "w.title", key: func(w *Workout) string { return w.Title }},
+	"-title":        {column: "w.title", desc: true, key: func(w *Workout) string { return w.Title }},
+}
+
+const workoutColumns = `w.id, w.user_id, w.title, COALESCE(w.description, ''), w.performed_at, w.started_at, w.ended_at,
+	w.duration_minutes, COALESCE(w.calories_burned, 0), w.calories_source, w.version, w.created_at, w.updated_at, w.deleted_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWorkout(row rowScanner) (*Workout, error) {
+	workout := &Workout{}
+	err := row.Scan(
+		&workout.ID,
+		&workout.UserID,
+		&workout.Title,
+		&workout.Description,
+		&workout.PerformedAt,
+		&workout.StartedAt,
+		&workout.EndedAt,
+		&workout.DurationMinutes,
+		&workout.CaloriesBurned,
+		&workout.CaloriesSource,
+		&workout.Version,
+		&workout.CreatedAt,
+		&workout.UpdatedAt,
+		&workout.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return workout, nil
+}
+
+func ValidWorkoutSort(sort string) bool {
+	_, ok := workoutSorts[sort]
+	return ok
 }
 
 type PostgresWorkoutStore struct {
@@ -35,12 +172,42 @@ func NewPostgresWorkoutStore(db *sql.DB) *PostgresWorkoutStore {
 
 type WorkoutStore interface {
 	CreateWorkout(*Workout) (*Workout, error)
-	GetWorkoutByID(id int64) (*Workout, error)
+	GetWorkoutByID(id int64, userID int) (*Workout, error)
+	// UpdateWorkout saves a workout read at workout.Version, moving it to
+	// the next version; ErrVersionConflict means it was saved since.
 	UpdateWorkout(*Workout) error
-	DeleteWorkout(id int64) error
+	// DeleteWorkout moves a workout at the given version to the trash,
+	// returning ErrVersionConflict when it was saved since.
+	DeleteWorkout(id int64, userID int, version int) error
+	ListWorkouts(filter WorkoutFilter) ([]*Workout, *WorkoutPage, error)
+	// ExportWorkouts streams a user's workouts in [from, to) to fn, oldest first.
+	ExportWorkouts(ctx context.Context, userID int, from, to *time.Time, fn func(*Workout) error) error
+	// ListTrash lists a user's deleted workouts, most recently deleted first.
+	ListTrash(userID int) ([]*Workout, error)
+	// RestoreWorkout takes a workout out of the trash; sql.ErrNoRows means
+	// it is not in the trash.
+	RestoreWorkout(id int64, userID int) error
+	// PurgeWorkout permanently deletes a workout in the trash; sql.ErrNoRows
+	// means it is not in the trash.
+	PurgeWorkout(id int64, userID int) error
+	// PurgeTrash permanently deletes every workout deleted before the given
+	// time, returning how many it deleted.
+	PurgeTrash(deletedBefore time.Time) (int64, error)
+	// ListRevisions lists the revisions of a workout, newest first, without
+	// their snapshots.
+	ListRevisions(workoutID int64, userID int) ([]*WorkoutRevision, error)
+	GetRevision(workoutID int64, userID int, version int) (*WorkoutRevision, error)
+	// RevertWorkout saves a workout brought back to an earlier revision as
+	// UpdateWorkout does, recording which revision it came from.
+	RevertWorkout(workout *Workout, revision int) error
 }
 
 func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
+	err := workout.ResolveTimes()
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := pg.db.Begin()
 	if err != nil {
 		return nil, err
@@ -48,117 +215,337 @@ func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error
 
 	defer tx.Rollback()
 
-	query := `INSERT INTO workouts (title, description, duration_minutes, calories_burned)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id
-	`
+	err = insertWorkout(tx, workout)
+	if err != nil {
+		return nil, err
+	}
 
-	err = tx.QueryRow(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
+	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Workout Entries: ", workout.Entries)
 
-	for _, entry := range workout.Entries {
-		query := `
-		INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-		VALUES ($1, $2, $3, $4, $5,$6,$7,$8)
-		RETURNING id
-		`
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
-		if err != nil {
-			return nil, err
-		}
+	return workout, nil
+}
 
-		fmt.Println("Entry ID: ", entry.ID)
+// insertWorkout saves a workout with its entries inside a transaction the
+// caller owns, so other records can be created along with it.
+func insertWorkout(tx *sql.Tx, workout *Workout) error {
+	workout.resolveCaloriesSource()
+	query := `INSERT INTO workouts (user_id, title, description, performed_at, started_at, ended_at, duration_minutes, calories_burned, calories_source)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id, version, created_at, updated_at
+	`
 
+	err := tx.QueryRow(query,
+		workout.UserID,
+		workout.Title,
+		workout.Description,
+		workout.PerformedAt,
+		workout.StartedAt,
+		workout.EndedAt,
+		workout.DurationMinutes,
+		workout.CaloriesBurned,
+		workout.CaloriesSource,
+	).Scan(&workout.ID, &workout.Version, &workout.CreatedAt, &workout.UpdatedAt)
+	if err != nil {
+		return err
 	}
 
-	err = tx.Commit()
+	err = insertEntries(tx, workout)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	fmt.Println("Workout: ", workout)
-	return workout, nil
+	return recordRevision(tx, workout, nil)
 }
 
-func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
-	workout := &Workout{}
+func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64, userID int) (*Workout, error) {
 	workoutQuery := `
-	SELECT id, title, description, duration_minutes, calories_burned
-	FROM workouts
-	WHERE id = $1
+	SELECT ` + workoutColumns + `
+	FROM workouts w
+	WHERE w.id = $1 AND w.user_id = $2 AND w.deleted_at IS NULL
 	`
-	err := pg.db.QueryRow(workoutQuery, id).Scan(
-		&workout.ID,
-		&workout.Title,
-		&workout.Description,
-		&workout.DurationMinutes,
-		&workout.CaloriesBurned)
+	workout, err := scanWorkout(pg.db.QueryRow(workoutQuery, id, userID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 
 	// Now get all entries associated with this workout
-	entryQuery := `
-	SELECT id, exercise_name, sets, reps, duration_seconds,weight, notes, order_index
-	FROM workout_entries
-	WHERE workout_id = $1
-	ORDER BY order_index
+	entries, err := pg.getEntriesForWorkouts([]int{workout.ID})
+	if err != nil {
+		return nil, err
+	}
+	workout.Entries = entries[workout.ID]
+
+	return workout, nil
+}
+
+func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
+	return pg.updateWorkout(workout, nil)
+}
+
+// updateWorkout saves a workout and records the version it moves to as a
+// revision, reverted from the given one if any.
+func (pg *PostgresWorkoutStore) updateWorkout(workout *Workout, revertedFrom *int) error {
+	err := workout.ResolveTimes()
+	if err != nil {
+		return err
+	}
+
+	unrecorded, err := pg.unrecordedVersion(workout)
+	if err != nil {
+		return err
+	}
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if unrecorded != nil {
+		err = recordRevision(tx, unrecorded, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	workout.resolveCaloriesSource()
+	query := `
+	UPDATE workouts
+	SET title = $1, description = $2, performed_at = $3, started_at = $4, ended_at = $5,
+		duration_minutes = $6, calories_burned = $7, calories_source = $8,
+		version = version + 1, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $9 AND user_id = $10 AND version = $11 AND deleted_at IS NULL
+	RETURNING version, updated_at
 	`
-	rows, err := pg.db.Query(entryQuery, id)
+	// the workout keeps the version it was read at until the save is done,
+	// so a failed save can be retried
+	var version int
+	var updatedAt time.Time
+	err = tx.QueryRow(query,
+		workout.Title,
+		workout.Description,
+		workout.PerformedAt,
+		workout.StartedAt,
+		workout.EndedAt,
+		workout.DurationMinutes,
+		workout.CaloriesBurned,
+		workout.CaloriesSource,
+		workout.ID,
+		workout.UserID,
+		workout.Version,
+	).Scan(&version, &updatedAt)
 	if err == sql.ErrNoRows {
-		return nil, nil
+		return versionMismatch(tx, int64(workout.ID), workout.UserID)
+	}
+	if err != nil {
+		return err
 	}
 
+	err = saveEntries(tx, workout)
 	if err != nil {
-		fmt.Println(err)
-		return nil, err
+		return err
 	}
 
-	defer rows.Close()
+	saved := *workout
+	saved.Version, saved.UpdatedAt = version, updatedAt
+	err = recordRevision(tx, &saved, revertedFrom)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	workout.Version, workout.UpdatedAt = version, updatedAt
+	return nil
+}
+
+func insertEntries(tx *sql.Tx, workout *Workout) error {
+	err := prepareEntries(workout.Entries)
+	if err != nil {
+		return err
+	}
 
+	for i := range workout.Entries {
+		err = insertEntry(tx, workout.ID, &workout.Entries[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveEntries brings the stored entries of a workout in line with
+// workout.Entries without recreating the ones that stay: entries carrying
+// the id of one of the workout's entries update it in place, keeping its id
+// and created_at, the others are added, and stored entries no longer listed
+// are deleted.
+func saveEntries(tx *sql.Tx, workout *Workout) error {
+	err := prepareEntries(workout.Entries)
+	if err != nil {
+		return err
+	}
+
+	var stored []int
+	rows, err := tx.Query(`SELECT id FROM workout_entries WHERE workout_id = $1`, workout.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 	for rows.Next() {
-		var entry WorkoutEntry
-		err = rows.Scan(
-			&entry.ID,
-			&entry.ExerciseName,
-			&entry.Sets,
-			&entry.Reps,
-			&entry.DurationSeconds,
-			&entry.Weight,
-			&entry.Notes,
-			&entry.OrderIndex,
-		)
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return err
+		}
+		stored = append(stored, id)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	kept := []int{}
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+		// an id from elsewhere, or listed twice, makes a new entry
+		if !slices.Contains(stored, entry.ID) || slices.Contains(kept, entry.ID) {
+			entry.ID = 0
+			continue
+		}
+		kept = append(kept, entry.ID)
+	}
+
+	_, err = tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1 AND NOT (id = ANY($2))`, workout.ID, kept)
+	if err != nil {
+		return err
+	}
+
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+		if entry.ID == 0 {
+			err = insertEntry(tx, workout.ID, entry)
+		} else {
+			err = updateEntry(tx, workout.ID, entry)
+		}
 		if err != nil {
-			return nil, err
+			return err
 		}
-		workout.Entries = append(workout.Entries, entry)
 	}
+	return nil
+}
 
-	return workout, nil
+// prepareEntries validates entries and fills in what they derive from their
+// set logs before they are saved.
+func prepareEntries(entries []WorkoutEntry) error {
+	err := ValidateGroups(entries)
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		err = entries[i].SummarizeSets()
+		if err != nil {
+			return err
+		}
+		err = entries[i].ValidateKind()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
-	tx, err := pg.db.Begin()
+// entryArgs lists the values of the entry columns written by insertEntry
+// and updateEntry, in order.
+func entryArgs(entry *WorkoutEntry) []any {
+	var groupLabel, groupType *string
+	var groupRounds, groupRest *int
+	if entry.Group != nil {
+		groupLabel, groupType = &entry.Group.Label, &entry.Group.Type
+		groupRounds, groupRest = &entry.Group.Rounds, entry.Group.RestSeconds
+	}
+	return []any{
+		entry.ExerciseID,
+		entry.ExerciseName,
+		entry.Sets,
+		entry.Reps,
+		entry.DurationSeconds,
+		entry.Weight,
+		entry.Notes,
+		entry.OrderIndex,
+		entry.Section,
+		groupLabel,
+		groupType,
+		groupRounds,
+		groupRest,
+		entry.Kind,
+		entry.Distance,
+		entry.DistanceUnit,
+		entry.ElevationGainMeters,
+		entry.AvgHeartRate,
+		entry.MaxHeartRate,
+		entry.AvgCadence,
+		entry.AvgPower,
+	}
+}
+
+func insertEntry(tx *sql.Tx, workoutID int, entry *WorkoutEntry) error {
+	query := `
+	INSERT INTO workout_entries (workout_id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index,
+		section, group_label, group_type, group_rounds, group_rest_seconds,
+		kind, distance, distance_unit, elevation_gain_meters, avg_heart_rate, max_heart_rate, avg_cadence, avg_power)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+		$15, $16, NULLIF($17, ''), $18, $19, $20, $21, $22)
+	RETURNING id, created_at
+	`
+	args := append([]any{workoutID}, entryArgs(entry)...)
+	err := tx.QueryRow(query, args...).Scan(&entry.ID, &entry.CreatedAt)
 	if err != nil {
 		return err
 	}
 
-	defer tx.Rollback()
+	return insertSets(tx, entry)
+}
 
+// updateEntry rewrites an entry in place and replaces its set log.
+func updateEntry(tx *sql.Tx, workoutID int, entry *WorkoutEntry) error {
 	query := `
-UPDATE workouts
-SET title = $1, description=$2, duration_minutes=$3, calories_burned=$4
-WHERE id = $5
-`
-	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	UPDATE workout_entries
+	SET exercise_id = $3, exercise_name = $4, sets = $5, reps = $6, duration_seconds = $7, weight = $8, notes = $9,
+		order_index = $10, section = $11, group_label = $12, group_type = $13, group_rounds = $14, group_rest_seconds = $15,
+		kind = $16, distance = $17, distance_unit = NULLIF($18, ''), elevation_gain_meters = $19,
+		avg_heart_rate = $20, max_heart_rate = $21, avg_cadence = $22, avg_power = $23
+	WHERE id = $1 AND workout_id = $2
+	RETURNING created_at
+	`
+	args := append([]any{entry.ID, workoutID}, entryArgs(entry)...)
+	err := tx.QueryRow(query, args...).Scan(&entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM workout_sets WHERE entry_id = $1`, entry.ID)
+	if err != nil {
+		return err
+	}
+	return insertSets(tx, entry)
+}
+
+func (pg *PostgresWorkoutStore) DeleteWorkout(id int64, userID int, version int) error {
+	query := `
+	UPDATE workouts
+	SET deleted_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND user_id = $2 AND version = $3 AND deleted_at IS NULL
+	`
+
+	result, err := pg.db.Exec(query, id, userID, version)
 	if err != nil {
 		return err
 	}
@@ -167,56 +554,243 @@ WHERE id = $5
 	if err != nil {
 		return err
 	}
+
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return versionMismatch(pg.db, id, userID)
 	}
-	entryQuery := `DELETE FROM workout_entries WHERE workout_id = $1`
-	_, err = tx.Exec(entryQuery, workout.ID)
+
+	return nil
+}
+
+// versionMismatch tells why a write guarded by a version matched no row:
+// ErrVersionConflict when the workout is still there, sql.ErrNoRows when
+// it is gone or in the trash.
+func versionMismatch(q queryer, id int64, userID int) error {
+	var exists bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM workouts WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`, id, userID).Scan(&exists)
 	if err != nil {
 		return err
 	}
-	for _, entry := range workout.Entries {
-		query := `
-		INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-		VALUES ($1, $2, $3, $4, $5,$6,$7,$8)
-		RETURNING id
-		`
+	if exists {
+		return ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
 
-		_, err := tx.Exec(query,
-			workout.ID,
-			entry.ExerciseName,
-			entry.Sets,
-			entry.Reps,
-			entry.DurationSeconds,
-			entry.Weight,
-			entry.Notes,
-			entry.OrderIndex,
-		)
+func (pg *PostgresWorkoutStore) ListWorkouts(filter WorkoutFilter) ([]*Workout, *WorkoutPage, error) {
+	if filter.Sort == "" {
+		filter.Sort = DefaultWorkoutSort
+	}
+	sort, ok := workoutSorts[filter.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported sort %q", filter.Sort)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultWorkoutLimit
+	}
+	if filter.Limit > MaxWorkoutLimit {
+		filter.Limit = MaxWorkoutLimit
+	}
+
+	conditions := []string{"w.user_id = $1", "w.deleted_at IS NULL"}
+	args := []any{filter.UserID}
+	addCondition := func(format string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	if filter.From != nil {
+		addCondition("w.performed_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("w.performed_at < $%d", *filter.To)
+	}
+	if filter.Title != "" {
+		addCondition("w.title ILIKE $%d", "%"+escapeLike(filter.Title)+"%")
+	}
+	if filter.Exercise != "" {
+		addCondition(`EXISTS (
+			SELECT 1 FROM workout_entries e
+			WHERE e.workout_id = w.id AND e.exercise_name ILIKE $%d
+		)`, "%"+escapeLike(filter.Exercise)+"%")
+	}
+	if filter.ExerciseID > 0 {
+		addCondition(`EXISTS (
+			SELECT 1 FROM workout_entries e
+			WHERE e.workout_id = w.id AND e.exercise_id = $%d
+		)`, filter.ExerciseID)
+	}
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor, filter.Sort)
 		if err != nil {
-			return err
+			return nil, nil, err
+		}
+
+		var value any = c.Value
+		if sort.time != nil {
+			t, err := time.Parse(time.RFC3339Nano, c.Value)
+			if err != nil {
+				return nil, nil, ErrInvalidCursor
+			}
+			value = t
 		}
+
+		comparison := ">"
+		if sort.desc {
+			comparison = "<"
+		}
+		addCondition("("+sort.column+", w.id) "+comparison+" ($%d, $%d)", value, c.ID)
+	}
+
+	direction := "ASC"
+	if sort.desc {
+		direction = "DESC"
+	}
+
+	// one extra row tells us whether another page exists
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM workouts w
+	WHERE %s
+	ORDER BY %s %s, w.id %s
+	LIMIT $%d
+	`, workoutColumns, strings.Join(conditions, " AND "), sort.column, direction, direction, len(args))
+
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	for rows.Next() {
+		workout, err := scanWorkout(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	page := &WorkoutPage{Limit: filter.Limit, Sort: filter.Sort}
+	if len(workouts) > filter.Limit {
+		workouts = workouts[:filter.Limit]
+		page.HasMore = true
+
+		last := workouts[len(workouts)-1]
+		c := cursor{Sort: filter.Sort, ID: last.ID}
+		if sort.time != nil {
+			c.Value = sort.time(last).Format(time.RFC3339Nano)
+		} else {
+			c.Value = sort.key(last)
+		}
+		page.NextCursor = encodeCursor(c)
+	}
+
+	ids := make([]int, len(workouts))
+	for i, workout := range workouts {
+		ids[i] = workout.ID
+	}
+	entries, err := pg.getEntriesForWorkouts(ids)
+	if err != nil {
+		return nil, nil, err
 	}
-	return tx.Commit()
+	for _, workout := range workouts {
+		workout.Entries = entries[workout.ID]
+	}
+
+	return workouts, page, nil
 }
 
-func (pg *PostgresWorkoutStore) DeleteWorkout(id int64) error {
-	query := `DELETE from workouts WHERE id = $1`
+// getEntriesForWorkouts loads the entries of every given workout in a single
+// query, keyed by workout id and ordered by order_index.
+func (pg *PostgresWorkoutStore) getEntriesForWorkouts(workoutIDs []int) (map[int][]WorkoutEntry, error) {
+	entries := make(map[int][]WorkoutEntry, len(workoutIDs))
+	if len(workoutIDs) == 0 {
+		return entries, nil
+	}
 
-	result, err := pg.db.Exec(query, id)
+	entryQuery := `
+	SELECT id, workout_id, exercise_id, exercise_name, sets, reps, duration_seconds, weight, COALESCE(notes, ''), order_index,
+		section, group_label, group_type, group_rounds, group_rest_seconds,
+		kind, distance, COALESCE(distance_unit, ''), elevation_gain_meters, avg_heart_rate, max_heart_rate, avg_cadence, avg_power,
+		created_at
+	FROM workout_entries
+	WHERE workout_id = ANY($1)
+	ORDER BY workout_id, order_index
+	`
+	rows, err := pg.db.Query(entryQuery, workoutIDs)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	var entryIDs []int
+	for rows.Next() {
+		var entry WorkoutEntry
+		var workoutID int
+		var groupLabel, groupType *string
+		var groupRounds, groupRest *int
+		err = rows.Scan(
+			&entry.ID,
+			&workoutID,
+			&entry.ExerciseID,
+			&entry.ExerciseName,
+			&entry.Sets,
+			&entry.Reps,
+			&entry.DurationSeconds,
+			&entry.Weight,
+			&entry.Notes,
+			&entry.OrderIndex,
+			&entry.Section,
+			&groupLabel,
+			&groupType,
+			&groupRounds,
+			&groupRest,
+			&entry.Kind,
+			&entry.Distance,
+			&entry.DistanceUnit,
+			&entry.ElevationGainMeters,
+			&entry.AvgHeartRate,
+			&entry.MaxHeartRate,
+			&entry.AvgCadence,
+			&entry.AvgPower,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if groupLabel != nil {
+			entry.Group = &EntryGroup{Label: *groupLabel, Type: *groupType, Rounds: *groupRounds, RestSeconds: groupRest}
+		}
+		entries[workoutID] = append(entries[workoutID], entry)
+		entryIDs = append(entryIDs, entry.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
+	sets, err := getSetsForEntries(pg.db, entryIDs)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for _, workoutEntries := range entries {
+		for i := range workoutEntries {
+			workoutEntries[i].SetLog = sets[workoutEntries[i].ID]
+		}
 	}
 
-	fmt.Println("Item Deleted Successfully")
+	return entries, nil
+}
 
-	return nil
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
 }
@@ -1,8 +1,12 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
@@ -21,18 +25,30 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("migrating test db error: %v\n", err)
 	}
 
-	_, err = db.Exec(`TRUNCATE workouts, workout_entries CASCADE`)
+	_, err = db.Exec(`TRUNCATE users, workouts, workout_entries CASCADE`)
 	if err != nil {
 		t.Fatalf("migrating test db error: %v\n", err)
 	}
 	return db
 }
 
+func createTestUser(t *testing.T, db *sql.DB, username string) *User {
+	user := &User{Username: username, Email: username + "@example.com"}
+	err := user.PasswordHash.Set("Password1!")
+	require.NoError(t, err)
+
+	_, err = NewPostgresUserStore(db).CreateUser(user)
+	require.NoError(t, err)
+	return user
+}
+
 func TestCreateWorkout(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
 	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
 
 	tests := []struct {
 		name    string
@@ -108,6 +124,7 @@ func TestCreateWorkout(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			tt.workout.UserID = owner.ID
 			createdWorkout, err := store.CreateWorkout(tt.workout)
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -120,9 +137,14 @@ func TestCreateWorkout(t *testing.T) {
 			assert.Equal(t, tt.workout.DurationMinutes, createdWorkout.DurationMinutes)
 			assert.Equal(t, tt.workout.CaloriesBurned, createdWorkout.CaloriesBurned)
 
-			retrieved, err := store.GetWorkoutByID(int64(createdWorkout.ID))
+			retrieved, err := store.GetWorkoutByID(int64(createdWorkout.ID), owner.ID)
 			require.NoError(t, err)
 			assert.Equal(t, createdWorkout.ID, retrieved.ID)
+			assert.Equal(t, owner.ID, retrieved.UserID)
+
+			foreign, err := store.GetWorkoutByID(int64(createdWorkout.ID), stranger.ID)
+			require.NoError(t, err)
+			assert.Nil(t, foreign)
 			assert.Equal(t, len(tt.workout.Entries), len(retrieved.Entries))
 
 			for i := range retrieved.Entries {
@@ -136,6 +158,538 @@ func TestCreateWorkout(t *testing.T) {
 	}
 }
 
+func TestWorkoutOwnership(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Leg Day",
+		DurationMinutes: 45,
+	})
+	require.NoError(t, err)
+
+	workout.UserID = stranger.ID
+	workout.Title = "Hijacked"
+	err = store.UpdateWorkout(workout)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	err = store.DeleteWorkout(int64(workout.ID), stranger.ID, workout.Version)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved)
+	assert.Equal(t, "Leg Day", retrieved.Title)
+	assert.Equal(t, 1, retrieved.Version)
+
+	// a save moves the workout to the next version...
+	retrieved.Title = "Leg Day (heavy)"
+	require.NoError(t, store.UpdateWorkout(retrieved))
+	assert.Equal(t, 2, retrieved.Version)
+
+	// ...so edits and deletes based on the version before are refused
+	workout.UserID = owner.ID
+	err = store.UpdateWorkout(workout)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	err = store.DeleteWorkout(int64(workout.ID), owner.ID, 1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	err = store.DeleteWorkout(int64(workout.ID), owner.ID, retrieved.Version)
+	require.NoError(t, err)
+}
+
+func TestWorkoutTrash(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Leg Day",
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteWorkout(int64(workout.ID), owner.ID, workout.Version))
+
+	// a deleted workout is out of every read but the trash
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+	listed, _, err := store.ListWorkouts(WorkoutFilter{UserID: owner.ID})
+	require.NoError(t, err)
+	assert.Empty(t, listed)
+	assert.ErrorIs(t, store.UpdateWorkout(workout), sql.ErrNoRows)
+	assert.ErrorIs(t, store.DeleteWorkout(int64(workout.ID), owner.ID, workout.Version), sql.ErrNoRows)
+
+	trash, err := store.ListTrash(owner.ID)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.NotNil(t, trash[0].DeletedAt)
+	assert.Len(t, trash[0].Entries, 1)
+
+	assert.ErrorIs(t, store.RestoreWorkout(int64(workout.ID), stranger.ID), sql.ErrNoRows)
+	require.NoError(t, store.RestoreWorkout(int64(workout.ID), owner.ID))
+	retrieved, err = store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved)
+	assert.Nil(t, retrieved.DeletedAt)
+	assert.Len(t, retrieved.Entries, 1)
+
+	// only workouts in the trash can be purged
+	assert.ErrorIs(t, store.PurgeWorkout(int64(workout.ID), owner.ID), sql.ErrNoRows)
+	require.NoError(t, store.DeleteWorkout(int64(workout.ID), owner.ID, retrieved.Version))
+	require.NoError(t, store.PurgeWorkout(int64(workout.ID), owner.ID))
+	trash, err = store.ListTrash(owner.ID)
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+
+	// the purge job only takes what was deleted before the cutoff
+	kept, err := store.CreateWorkout(&Workout{UserID: owner.ID, Title: "Recent", DurationMinutes: 30})
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteWorkout(int64(kept.ID), owner.ID, kept.Version))
+	purged, err := store.PurgeTrash(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = store.PurgeTrash(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+func TestListWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	for _, title := range []string{"Push A", "Pull A", "Legs A", "Push B", "Pull B"} {
+		_, err := store.CreateWorkout(&Workout{
+			UserID:          owner.ID,
+			Title:           title,
+			DurationMinutes: 30,
+			Entries: []WorkoutEntry{
+				{ExerciseName: title + " Press", Sets: 3, Reps: IntPtr(8), OrderIndex: 1},
+			},
+		})
+		require.NoError(t, err)
+	}
+	_, err := store.CreateWorkout(&Workout{UserID: stranger.ID, Title: "Push C", DurationMinutes: 30})
+	require.NoError(t, err)
+
+	var titles []string
+	filter := WorkoutFilter{UserID: owner.ID, Sort: "title", Limit: 2}
+	for {
+		workouts, page, err := store.ListWorkouts(filter)
+		require.NoError(t, err)
+		for _, workout := range workouts {
+			titles = append(titles, workout.Title)
+			assert.Len(t, workout.Entries, 1)
+		}
+		if !page.HasMore {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"Legs A", "Pull A", "Pull B", "Push A", "Push B"}, titles)
+
+	workouts, _, err := store.ListWorkouts(WorkoutFilter{UserID: owner.ID, Title: "push"})
+	require.NoError(t, err)
+	assert.Len(t, workouts, 2)
+
+	_, _, err = store.ListWorkouts(WorkoutFilter{UserID: owner.ID, Sort: "-title", Cursor: filter.Cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestWorkoutResolveTimes(t *testing.T) {
+	start := time.Date(2025, 3, 1, 7, 30, 0, 0, time.FixedZone("CET", 3600))
+	end := start.Add(62*time.Minute + 40*time.Second)
+
+	workout := &Workout{StartedAt: &start, EndedAt: &end}
+	require.NoError(t, workout.ResolveTimes())
+	assert.Equal(t, 63, workout.DurationMinutes)
+	assert.True(t, workout.PerformedAt.Equal(start))
+
+	explicit := &Workout{StartedAt: &start, EndedAt: &end, DurationMinutes: 45}
+	require.NoError(t, explicit.ResolveTimes())
+	assert.Equal(t, 45, explicit.DurationMinutes)
+
+	backwards := &Workout{StartedAt: &end, EndedAt: &start}
+	assert.Error(t, backwards.ResolveTimes())
+
+	undated := &Workout{DurationMinutes: 20}
+	require.NoError(t, undated.ResolveTimes())
+	assert.False(t, undated.PerformedAt.IsZero())
+}
+
+func TestSummarizeSets(t *testing.T) {
+	entry := WorkoutEntry{
+		ExerciseName: "Back Squat",
+		SetLog: []WorkoutSet{
+			{SetType: SetTypeWarmup, Reps: IntPtr(10), Weight: FloatPtr(60), Completed: true},
+			{SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(120), RPE: FloatPtr(8), Completed: true},
+			{SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(130), RPE: FloatPtr(9.5), Completed: true},
+			{SetType: SetTypeFailure, Reps: IntPtr(2), Weight: FloatPtr(140), Completed: false},
+			{SetType: SetTypeDrop, Reps: IntPtr(8), Weight: FloatPtr(100), Tempo: "3-1-X-0", Completed: true},
+		},
+	}
+
+	require.NoError(t, entry.SummarizeSets())
+	assert.Equal(t, 3, entry.Sets)
+	assert.Equal(t, 5, *entry.Reps)
+	assert.Equal(t, 130.0, *entry.Weight)
+	assert.Nil(t, entry.DurationSeconds)
+	assert.Equal(t, 5, entry.SetLog[4].SetIndex)
+
+	mixed := WorkoutEntry{SetLog: []WorkoutSet{
+		{SetType: SetTypeWorking, Reps: IntPtr(5), Completed: true},
+		{SetType: SetTypeWorking, DurationSeconds: IntPtr(30), Completed: true},
+	}}
+	assert.Error(t, mixed.SummarizeSets())
+
+	badRPE := WorkoutEntry{SetLog: []WorkoutSet{{SetType: SetTypeWorking, Reps: IntPtr(5), RPE: FloatPtr(8.3)}}}
+	assert.Error(t, badRPE.SummarizeSets())
+
+	var set WorkoutSet
+	require.NoError(t, json.Unmarshal([]byte(`{"reps": 5, "weight": 100}`), &set))
+	assert.Equal(t, SetTypeWorking, set.SetType)
+	assert.True(t, set.Completed)
+}
+
+func TestWorkoutSetsRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Pyramid",
+		DurationMinutes: 40,
+		Entries: []WorkoutEntry{{
+			ExerciseName: "Barbell Bench Press",
+			OrderIndex:   1,
+			SetLog: []WorkoutSet{
+				{SetType: SetTypeWorking, Reps: IntPtr(10), Weight: FloatPtr(60), RestSeconds: IntPtr(90), Completed: true},
+				{SetType: SetTypeWorking, Reps: IntPtr(8), Weight: FloatPtr(70), RIR: IntPtr(2), Completed: true},
+				{SetType: SetTypeWorking, Reps: IntPtr(6), Weight: FloatPtr(80), RPE: FloatPtr(9), Completed: true},
+			},
+		}},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 1)
+	entry := retrieved.Entries[0]
+	assert.Equal(t, 3, entry.Sets)
+	assert.Equal(t, 6, *entry.Reps)
+	assert.Equal(t, 80.0, *entry.Weight)
+	require.Len(t, entry.SetLog, 3)
+	assert.Equal(t, 90, *entry.SetLog[0].RestSeconds)
+	assert.Equal(t, 2, *entry.SetLog[1].RIR)
+	assert.Equal(t, 9.0, *entry.SetLog[2].RPE)
+}
+
+func TestValidateGroups(t *testing.T) {
+	superset := func(label string) *EntryGroup {
+		return &EntryGroup{Label: label, Type: GroupSuperset, Rounds: 3, RestSeconds: IntPtr(90)}
+	}
+	entry := func(order int, section string, group *EntryGroup) WorkoutEntry {
+		return WorkoutEntry{ExerciseName: "Curl", Sets: 3, Reps: IntPtr(10), OrderIndex: order, Section: section, Group: group}
+	}
+
+	valid := []WorkoutEntry{
+		entry(3, SectionMain, superset("A")),
+		entry(1, "", nil),
+		entry(2, SectionMain, superset("A")),
+		entry(4, SectionCooldown, nil),
+	}
+	require.NoError(t, ValidateGroups(valid))
+	assert.Equal(t, SectionMain, valid[1].Section, "a missing section means main")
+
+	tests := []struct {
+		name    string
+		entries []WorkoutEntry
+	}{
+		{"unknown section", []WorkoutEntry{entry(1, "finisher", nil)}},
+		{"warm-up after main", []WorkoutEntry{entry(1, SectionMain, nil), entry(2, SectionWarmup, nil)}},
+		{"single entry group", []WorkoutEntry{entry(1, SectionMain, superset("A"))}},
+		{"interleaved group", []WorkoutEntry{
+			entry(1, SectionMain, superset("A")), entry(2, SectionMain, nil), entry(3, SectionMain, superset("A")),
+		}},
+		{"group across sections", []WorkoutEntry{entry(1, SectionWarmup, superset("A")), entry(2, SectionMain, superset("A"))}},
+		{"mismatched rounds", []WorkoutEntry{
+			entry(1, SectionMain, superset("A")),
+			entry(2, SectionMain, &EntryGroup{Label: "A", Type: GroupSuperset, Rounds: 4, RestSeconds: IntPtr(90)}),
+		}},
+		{"unknown group type", []WorkoutEntry{
+			entry(1, SectionMain, &EntryGroup{Label: "A", Type: "giant", Rounds: 1}),
+			entry(2, SectionMain, &EntryGroup{Label: "A", Type: "giant", Rounds: 1}),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, ValidateGroups(tt.entries))
+		})
+	}
+}
+
+func TestWorkoutGroupsRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	circuit := &EntryGroup{Label: "C", Type: GroupCircuit, Rounds: 5, RestSeconds: IntPtr(120)}
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Metcon",
+		DurationMinutes: 30,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Rowing", Sets: 1, DurationSeconds: IntPtr(300), OrderIndex: 1, Section: SectionWarmup},
+			{ExerciseName: "Kettlebell Swing", Sets: 5, Reps: IntPtr(15), OrderIndex: 2, Group: circuit},
+			{ExerciseName: "Push-up", Sets: 5, Reps: IntPtr(10), OrderIndex: 3, Group: circuit},
+			{ExerciseName: "Air Squat", Sets: 5, Reps: IntPtr(20), OrderIndex: 4, Group: circuit},
+		},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 4)
+	assert.Equal(t, SectionWarmup, retrieved.Entries[0].Section)
+	assert.Nil(t, retrieved.Entries[0].Group)
+	assert.Equal(t, SectionMain, retrieved.Entries[1].Section)
+	assert.Equal(t, circuit, retrieved.Entries[3].Group)
+
+	retrieved.Entries = retrieved.Entries[:2]
+	assert.Error(t, store.UpdateWorkout(retrieved), "a circuit of one is rejected")
+
+	retrieved.Entries[1].Group = nil
+	require.NoError(t, store.UpdateWorkout(retrieved))
+	updated, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, updated.Entries, 2)
+	assert.Nil(t, updated.Entries[1].Group)
+}
+
+func TestUpdateWorkoutKeepsEntries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Push",
+		DurationMinutes: 60,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(80), OrderIndex: 1},
+			{ExerciseName: "Dips", Sets: 3, Reps: IntPtr(10), OrderIndex: 2},
+			{ExerciseName: "Triceps Pushdown", Sets: 3, Reps: IntPtr(12), OrderIndex: 3},
+		},
+	})
+	require.NoError(t, err)
+	bench, dips := workout.Entries[0], workout.Entries[1]
+
+	// drop the pushdowns, edit the dips, move them first and add flyes
+	edited := *workout
+	edited.Entries = []WorkoutEntry{dips, bench, {ExerciseName: "Cable Fly", Sets: 3, Reps: IntPtr(15)}}
+	edited.Entries[0].Reps = IntPtr(12)
+	renumber := func(entries []WorkoutEntry) {
+		for i := range entries {
+			entries[i].OrderIndex = i + 1
+		}
+	}
+	renumber(edited.Entries)
+	require.NoError(t, store.UpdateWorkout(&edited))
+
+	updated, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, updated.Entries, 3)
+	assert.Equal(t, dips.ID, updated.Entries[0].ID)
+	assert.Equal(t, 12, *updated.Entries[0].Reps)
+	assert.True(t, dips.CreatedAt.Equal(updated.Entries[0].CreatedAt))
+	assert.Equal(t, bench.ID, updated.Entries[1].ID)
+	assert.Equal(t, "Cable Fly", updated.Entries[2].ExerciseName)
+	assert.NotContains(t, []int{bench.ID, dips.ID, workout.Entries[2].ID}, updated.Entries[2].ID)
+
+	// ids of entries from another workout are not taken over
+	other, err := store.CreateWorkout(&Workout{UserID: owner.ID, Title: "Other", DurationMinutes: 10})
+	require.NoError(t, err)
+	other.Entries = []WorkoutEntry{updated.Entries[0]}
+	require.NoError(t, store.UpdateWorkout(other))
+	assert.NotEqual(t, dips.ID, other.Entries[0].ID)
+	unchanged, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Len(t, unchanged.Entries, 3)
+}
+
+func TestValidateKind(t *testing.T) {
+	run := WorkoutEntry{
+		ExerciseName:    "Running",
+		Sets:            1,
+		DurationSeconds: IntPtr(3000),
+		Distance:        FloatPtr(10),
+		AvgHeartRate:    IntPtr(152),
+		MaxHeartRate:    IntPtr(171),
+	}
+	require.NoError(t, run.ValidateKind())
+	assert.Equal(t, EntryKindCardio, run.Kind)
+	assert.Equal(t, "km", run.DistanceUnit, "distance defaults to kilometers")
+
+	pace, speed := run.Pace()
+	assert.Equal(t, 300.0, *pace)
+	assert.Equal(t, 12.0, *speed)
+
+	data, err := json.Marshal(run)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"pace_seconds_per_km":300`)
+	assert.Contains(t, string(data), `"speed_kmh":12`)
+
+	mile := WorkoutEntry{Kind: EntryKindCardio, Distance: FloatPtr(1), DistanceUnit: "mi", DurationSeconds: IntPtr(480)}
+	require.NoError(t, mile.ValidateKind())
+	pace, _ = mile.Pace()
+	assert.Equal(t, 298.3, *pace)
+
+	squat := WorkoutEntry{Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100)}
+	require.NoError(t, squat.ValidateKind())
+	assert.Equal(t, EntryKindStrength, squat.Kind)
+	data, err = json.Marshal(squat)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "pace_seconds_per_km")
+
+	plank := WorkoutEntry{Sets: 3, DurationSeconds: IntPtr(60)}
+	require.NoError(t, plank.ValidateKind())
+	assert.Equal(t, EntryKindTimed, plank.Kind)
+
+	tests := []struct {
+		name  string
+		entry WorkoutEntry
+	}{
+		{"strength without reps", WorkoutEntry{Kind: EntryKindStrength, DurationSeconds: IntPtr(60)}},
+		{"timed with reps", WorkoutEntry{Kind: EntryKindTimed, Reps: IntPtr(5), DurationSeconds: IntPtr(60)}},
+		{"cardio with reps", WorkoutEntry{Kind: EntryKindCardio, Reps: IntPtr(5), Distance: FloatPtr(1)}},
+		{"cardio without distance or duration", WorkoutEntry{Kind: EntryKindCardio, AvgPower: IntPtr(200)}},
+		{"strength with heart rate", WorkoutEntry{Kind: EntryKindStrength, Reps: IntPtr(5), AvgHeartRate: IntPtr(120)}},
+		{"unknown unit", WorkoutEntry{Distance: FloatPtr(5), DistanceUnit: "furlong"}},
+		{"max below average", WorkoutEntry{Distance: FloatPtr(5), AvgHeartRate: IntPtr(150), MaxHeartRate: IntPtr(140)}},
+		{"unknown kind", WorkoutEntry{Kind: "yoga", DurationSeconds: IntPtr(60)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.entry.ValidateKind())
+		})
+	}
+}
+
+func TestCardioEntryRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+
+	workout, err := store.CreateWorkout(&Workout{
+		UserID:          owner.ID,
+		Title:           "Hill repeats",
+		DurationMinutes: 50,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Running", Sets: 1, Distance: FloatPtr(8.5), DistanceUnit: "km", DurationSeconds: IntPtr(2805),
+				ElevationGainMeters: FloatPtr(240), AvgHeartRate: IntPtr(158), MaxHeartRate: IntPtr(183), AvgCadence: IntPtr(172), OrderIndex: 1},
+			{ExerciseName: "Swimming", Sets: 1, Distance: FloatPtr(400), DistanceUnit: "m", OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	retrieved, err := store.GetWorkoutByID(int64(workout.ID), owner.ID)
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 2)
+	run := retrieved.Entries[0]
+	assert.Equal(t, EntryKindCardio, run.Kind)
+	assert.Equal(t, 8.5, *run.Distance)
+	assert.Equal(t, 240.0, *run.ElevationGainMeters)
+	assert.Equal(t, 183, *run.MaxHeartRate)
+	pace, _ := run.Pace()
+	assert.Equal(t, 330.0, *pace)
+
+	swim := retrieved.Entries[1]
+	assert.Equal(t, "m", swim.DistanceUnit)
+	assert.Nil(t, swim.DurationSeconds)
+}
+
+func TestExportWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 18, 0, 0, 0, time.UTC) }
+	workouts := []*Workout{
+		{UserID: owner.ID, Title: "Push", PerformedAt: day(3), Entries: []WorkoutEntry{
+			{ExerciseName: "Barbell Bench Press", OrderIndex: 1, SetLog: []WorkoutSet{
+				{SetType: SetTypeWarmup, Reps: IntPtr(10), Weight: FloatPtr(40), Completed: true},
+				{SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(80), Completed: true},
+			}},
+			{ExerciseName: "Dips", Sets: 3, Reps: IntPtr(12), OrderIndex: 2},
+		}},
+		{UserID: owner.ID, Title: "Rest day walk", PerformedAt: day(1)},
+		{UserID: owner.ID, Title: "Too late", PerformedAt: day(20)},
+		{UserID: stranger.ID, Title: "Not mine", PerformedAt: day(2)},
+	}
+	for _, workout := range workouts {
+		_, err := store.CreateWorkout(workout)
+		require.NoError(t, err)
+	}
+
+	from, to := day(1), day(10)
+	var exported []*Workout
+	err := store.ExportWorkouts(context.Background(), owner.ID, &from, &to, func(w *Workout) error {
+		exported = append(exported, w)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, exported, 2)
+
+	assert.Equal(t, "Rest day walk", exported[0].Title)
+	assert.Empty(t, exported[0].Entries)
+
+	push := exported[1]
+	assert.Equal(t, "Push", push.Title)
+	require.Len(t, push.Entries, 2)
+	require.Len(t, push.Entries[0].SetLog, 2)
+	assert.Equal(t, SetTypeWarmup, push.Entries[0].SetLog[0].SetType)
+	assert.Equal(t, 80.0, *push.Entries[0].SetLog[1].Weight)
+	assert.Equal(t, "Dips", push.Entries[1].ExerciseName)
+	assert.Empty(t, push.Entries[1].SetLog)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = store.ExportWorkouts(context.Background(), owner.ID, nil, nil, func(w *Workout) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
 func IntPtr(i int) *int {
 	return &i
 }
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Deleting a workout moves it to the trash: deleted_at is set and every read
+// but the ones below leaves it out. It can be restored as it was until it is
+// purged, which deletes it and everything hanging off it for good.
+
+func (pg *PostgresWorkoutStore) ListTrash(userID int) ([]*Workout, error) {
+	query := `
+	SELECT ` + workoutColumns + `
+	FROM workouts w
+	WHERE w.user_id = $1 AND w.deleted_at IS NOT NULL
+	ORDER BY w.deleted_at DESC, w.id DESC
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	var ids []int
+	for rows.Next() {
+		workout, err := scanWorkout(rows)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+		ids = append(ids, workout.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := pg.getEntriesForWorkouts(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, workout := range workouts {
+		workout.Entries = entries[workout.ID]
+	}
+	return workouts, nil
+}
+
+func (pg *PostgresWorkoutStore) RestoreWorkout(id int64, userID int) error {
+	query := `
+	UPDATE workouts
+	SET deleted_at = NULL
+	WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+	`
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresWorkoutStore) PurgeWorkout(id int64, userID int) error {
+	query := `DELETE FROM workouts WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
+	result, err := pg.db.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresWorkoutStore) PurgeTrash(deletedBefore time.Time) (int64, error) {
+	result, err := pg.db.Exec(`DELETE FROM workouts WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,173 @@
+// Package strength estimates one-rep maxes from submaximal sets and turns a
+// lifter's history into an estimated-1RM trend.
+package strength
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+)
+
+type Formula string
+
+const (
+	FormulaEpley    Formula = "epley"
+	FormulaBrzycki  Formula = "brzycki"
+	FormulaLombardi Formula = "lombardi"
+	// FormulaRPE reads the RPE chart; sets without an RPE are assumed to be
+	// taken to failure (RPE 10)
+	FormulaRPE Formula = "rpe"
+)
+
+var Formulas = []Formula{FormulaEpley, FormulaBrzycki, FormulaLombardi, FormulaRPE}
+
+// MaxReps is the highest rep count any formula is trusted with.
+const MaxReps = 12
+
+var ErrOutOfRange = errors.New("set is outside the range the formula can estimate")
+
+func ParseFormula(s string) (Formula, error) {
+	for _, f := range Formulas {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown formula %q, expected one of %v", s, Formulas)
+}
+
+// Epley is weight x (1 + reps/30), with a single rep being the max itself.
+func Epley(weight float64, reps int) float64 {
+	if reps == 1 {
+		return weight
+	}
+	return weight * (1 + float64(reps)/30)
+}
+
+// Brzycki is weight x 36 / (37 - reps).
+func Brzycki(weight float64, reps int) float64 {
+	return weight * 36 / (37 - float64(reps))
+}
+
+// Lombardi is weight x reps^0.10.
+func Lombardi(weight float64, reps int) float64 {
+	return weight * math.Pow(float64(reps), 0.10)
+}
+
+// rpeChart holds the percentage of 1RM for a set, indexed by half-steps of
+// reps + (10 - RPE): 1 rep at RPE 10 is index 0, 1 rep at RPE 9.5 index 1,
+// 1 rep at RPE 9 and 2 reps at RPE 10 both index 2, and so on. This is the
+// widely used RTS chart, which is constant along those diagonals.
+var rpeChart = []float64{
+	100.0, 97.8, 95.5, 93.9, 92.2, 90.7, 89.2, 87.8, 86.3, 85.0, 83.7,
+	82.4, 81.1, 79.9, 78.6, 77.4, 76.2, 75.1, 73.9, 72.3, 70.7, 69.4,
+	68.0, 66.7, 65.3, 64.0, 62.6, 61.3, 59.9, 58.6, 57.4,
+}
+
+// PercentOfMax returns the fraction of 1RM a set of reps at the given RPE
+// represents, for 1-12 reps and RPE 6-10 in half steps.
+func PercentOfMax(reps int, rpe float64) (float64, error) {
+	if reps < 1 || reps > MaxReps || rpe < 6 || rpe > 10 || math.Mod(rpe*2, 1) != 0 {
+		return 0, ErrOutOfRange
+	}
+	index := int(math.Round((float64(reps-1) + (10 - rpe)) * 2))
+	return rpeChart[index] / 100, nil
+}
+
+// Estimate applies formula to one set. rpe is only used by FormulaRPE.
+func Estimate(formula Formula, weight float64, reps int, rpe *float64) (float64, error) {
+	if weight <= 0 || reps < 1 || reps > MaxReps {
+		return 0, ErrOutOfRange
+	}
+
+	switch formula {
+	case FormulaEpley:
+		return Epley(weight, reps), nil
+	case FormulaBrzycki:
+		return Brzycki(weight, reps), nil
+	case FormulaLombardi:
+		return Lombardi(weight, reps), nil
+	case FormulaRPE:
+		effort := 10.0
+		if rpe != nil {
+			effort = *rpe
+		}
+		pct, err := PercentOfMax(reps, effort)
+		if err != nil {
+			return 0, err
+		}
+		return weight / pct, nil
+	default:
+		return 0, fmt.Errorf("unknown formula %q", formula)
+	}
+}
+
+const (
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// Point is the best estimated 1RM within one day or week. Date is the first
+// day of the bucket in the requested location; weeks start on Monday.
+type Point struct {
+	Date      string   `json:"date"`
+	E1RM      float64  `json:"e1rm"`
+	Weight    float64  `json:"weight"`
+	Reps      int      `json:"reps"`
+	RPE       *float64 `json:"rpe,omitempty"`
+	WorkoutID int      `json:"workout_id"`
+}
+
+// Trend reduces a history to its best estimated 1RM per bucket, oldest first.
+// Sets the formula cannot estimate are skipped.
+func Trend(history []records.Performance, formula Formula, bucket string, loc *time.Location) ([]Point, error) {
+	if bucket != BucketDay && bucket != BucketWeek {
+		return nil, fmt.Errorf("bucket must be %q or %q", BucketDay, BucketWeek)
+	}
+
+	best := map[string]Point{}
+	for _, performance := range history {
+		date := bucketStart(performance.PerformedAt.In(loc), bucket)
+		for _, set := range performance.Sets {
+			e1rm, err := Estimate(formula, set.Weight, set.Reps, set.RPE)
+			if errors.Is(err, ErrOutOfRange) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			e1rm = math.Round(e1rm*10) / 10
+			if current, ok := best[date]; ok && current.E1RM >= e1rm {
+				continue
+			}
+			best[date] = Point{
+				Date:      date,
+				E1RM:      e1rm,
+				Weight:    set.Weight,
+				Reps:      set.Reps,
+				RPE:       set.RPE,
+				WorkoutID: performance.WorkoutID,
+			}
+		}
+	}
+
+	points := make([]Point, 0, len(best))
+	for _, point := range best {
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+	return points, nil
+}
+
+func bucketStart(t time.Time, bucket string) string {
+	if bucket == BucketWeek {
+		// Go weeks start on Sunday; shift so Monday is day zero
+		offset := (int(t.Weekday()) + 6) % 7
+		t = t.AddDate(0, 0, -offset)
+	}
+	return t.Format(time.DateOnly)
+}
@@ -0,0 +1,89 @@
+package strength
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsrn12/fitness_tracker_go/internal/records"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rpe(v float64) *float64 {
+	return &v
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		formula Formula
+		weight  float64
+		reps    int
+		rpe     *float64
+		want    float64
+	}{
+		{formula: FormulaEpley, weight: 100, reps: 1, want: 100},
+		{formula: FormulaEpley, weight: 100, reps: 5, want: 116.67},
+		{formula: FormulaBrzycki, weight: 100, reps: 5, want: 112.5},
+		{formula: FormulaLombardi, weight: 100, reps: 5, want: 117.46},
+		{formula: FormulaRPE, weight: 100, reps: 5, rpe: rpe(8), want: 100 / 0.811},
+		{formula: FormulaRPE, weight: 100, reps: 3, want: 100 / 0.922},
+		{formula: FormulaRPE, weight: 100, reps: 1, rpe: rpe(9.5), want: 100 / 0.978},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.formula), func(t *testing.T) {
+			got, err := Estimate(tt.formula, tt.weight, tt.reps, tt.rpe)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 0.01)
+		})
+	}
+
+	_, err := Estimate(FormulaEpley, 100, 15, nil)
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	_, err = Estimate(FormulaRPE, 100, 5, rpe(5))
+	assert.ErrorIs(t, err, ErrOutOfRange)
+}
+
+func TestPercentOfMaxDiagonals(t *testing.T) {
+	// 2 reps at RPE 10 and 1 rep at RPE 9 leave the same reps in reserve
+	a, err := PercentOfMax(2, 10)
+	require.NoError(t, err)
+	b, err := PercentOfMax(1, 9)
+	require.NoError(t, err)
+	assert.Equal(t, a, b)
+
+	low, err := PercentOfMax(12, 6)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.574, low, 0.0001)
+}
+
+func TestTrend(t *testing.T) {
+	at := func(day, hour int) time.Time { return time.Date(2025, 3, day, hour, 0, 0, 0, time.UTC) }
+	history := []records.Performance{
+		{WorkoutID: 1, PerformedAt: at(3, 18), Sets: []records.Set{{Weight: 100, Reps: 5}, {Weight: 105, Reps: 3}}},
+		{WorkoutID: 2, PerformedAt: at(5, 18), Sets: []records.Set{{Weight: 110, Reps: 2}}},
+		{WorkoutID: 3, PerformedAt: at(10, 23), Sets: []records.Set{{Weight: 90, Reps: 20}}},
+	}
+
+	daily, err := Trend(history, FormulaEpley, BucketDay, time.UTC)
+	require.NoError(t, err)
+	require.Len(t, daily, 2)
+	assert.Equal(t, "2025-03-03", daily[0].Date)
+	assert.Equal(t, 116.7, daily[0].E1RM)
+	assert.Equal(t, 1, daily[0].WorkoutID)
+
+	weekly, err := Trend(history, FormulaEpley, BucketWeek, time.UTC)
+	require.NoError(t, err)
+	require.Len(t, weekly, 1)
+	assert.Equal(t, "2025-03-03", weekly[0].Date)
+	assert.Equal(t, 2, weekly[0].WorkoutID)
+
+	// 23:00 UTC on Sunday the 9th is already Monday the 10th in Tokyo
+	tokyo := time.FixedZone("JST", 9*3600)
+	history[2].Sets = []records.Set{{Weight: 90, Reps: 5}}
+	history[2].PerformedAt = at(9, 23)
+	weekly, err = Trend(history, FormulaEpley, BucketWeek, tokyo)
+	require.NoError(t, err)
+	require.Len(t, weekly, 2)
+	assert.Equal(t, "2025-03-10", weekly[1].Date)
+}
@@ -9,6 +9,9 @@ import (
 
 const (
 	ScopeAuth = "authentication"
+	// ScopeFeed tokens are embedded in calendar feed URLs, which calendar
+	// apps fetch without any other credentials. They grant nothing else.
+	ScopeFeed = "feed"
 )
 
 type Token struct {
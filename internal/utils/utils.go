@@ -3,8 +3,12 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -37,3 +41,82 @@ func GetParamID(r *http.Request) (int64, error) {
 
 	return id, nil
 }
+
+func ReadString(qs url.Values, key string, defaultValue string) string {
+	s := strings.TrimSpace(qs.Get(key))
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+func ReadInt(qs url.Values, key string, defaultValue int) (int, error) {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer value", key)
+	}
+	return i, nil
+}
+
+// ReadTimeRange parses the from/to query parameters. Both accept RFC 3339
+// timestamps or plain YYYY-MM-DD dates; a plain date starts at midnight in
+// loc, and a plain "to" date covers that whole day. The returned range is
+// half-open: from <= t < to.
+func ReadTimeRange(qs url.Values, loc *time.Location) (from, to *time.Time, err error) {
+	from, _, err = readTime(qs, "from", loc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	to, dateOnly, err := readTime(qs, "to", loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if to != nil && dateOnly {
+		endOfDay := to.AddDate(0, 0, 1)
+		to = &endOfDay
+	}
+
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+func readTime(qs url.Values, key string, loc *time.Location) (*time.Time, bool, error) {
+	s := qs.Get(key)
+	if s == "" {
+		return nil, false, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, false, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return &t, true, nil
+	}
+	return nil, false, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", key)
+}
+
+// ETag formats a version number as a strong entity tag.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// MatchETag reports whether an If-Match header lists the entity tag. Tags
+// compare strongly, as RFC 9110 requires for If-Match: "*" matches any tag
+// and weak tags match none.
+func MatchETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
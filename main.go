@@ -1,19 +1,25 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	"time"
+	_ "time/tzdata" // time zone names must resolve even on hosts without zoneinfo
 
 	"github.com/fsrn12/fitness_tracker_go/internal/app"
+	"github.com/fsrn12/fitness_tracker_go/internal/middleware"
 	"github.com/fsrn12/fitness_tracker_go/internal/routes"
 )
 
 func main() {
 
 	var port int
+	var trashRetention, idempotencyTTL time.Duration
 	flag.IntVar(&port, "port", 8080, "backend server port")
+	flag.DurationVar(&trashRetention, "trash-retention", 30*24*time.Hour, "how long deleted workouts stay in the trash before they are purged")
+	flag.DurationVar(&idempotencyTTL, "idempotency-ttl", middleware.DefaultIdempotencyTTL, "how long an Idempotency-Key is remembered")
 	flag.Parse()
 
 	app, err := app.NewApplication()
@@ -22,6 +28,11 @@ func main() {
 	}
 
 	defer app.DB.Close() // it will run after everything else
+
+	app.Idempotency.TTL = idempotencyTTL
+	app.FailInterruptedImports()
+	go app.PurgeTrash(context.Background(), trashRetention, time.Hour)
+	go app.DeleteExpiredIdempotencyKeys(context.Background(), time.Hour)
 	// app.Logger.Printf("Server is running on port :%d", port)
 
 	// http.HandleFunc("/heath", app.HealthCheck)
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- Workouts created before ownership existed are handed to the oldest account,
+-- which is the owner of every single-user install this API has run as so far.
+UPDATE workouts
+SET user_id = (SELECT id FROM users ORDER BY id LIMIT 1)
+WHERE user_id IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- With no users at all there is nobody to hand the workouts to. Stop rather
+-- than drop them: create an account and run the migration again.
+DO $$
+BEGIN
+  IF EXISTS (SELECT 1 FROM workouts WHERE user_id IS NULL) THEN
+    RAISE EXCEPTION 'workouts exist but no user does to own them; create a user and migrate again';
+  END IF;
+END
+$$;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE workouts ALTER COLUMN user_id SET NOT NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workouts_user_id ON workouts(user_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_id;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS user_id;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workouts_user_created ON workouts(user_id, created_at DESC, id DESC);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_created;
+-- +goose StatementEnd
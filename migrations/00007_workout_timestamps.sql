@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts
+  ADD COLUMN IF NOT EXISTS performed_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN IF NOT EXISTS ended_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- the best guess for a workout logged before performed_at existed is the moment it was logged
+UPDATE workouts SET performed_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE performed_at IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE workouts
+  ALTER COLUMN performed_at SET DEFAULT CURRENT_TIMESTAMP,
+  ALTER COLUMN performed_at SET NOT NULL,
+  ADD CONSTRAINT valid_workout_times CHECK (
+    started_at IS NULL OR ended_at IS NULL OR ended_at > started_at
+  );
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workouts_user_performed ON workouts(user_id, performed_at DESC, id DESC);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_performed;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE workouts
+  DROP CONSTRAINT IF EXISTS valid_workout_times,
+  DROP COLUMN IF EXISTS ended_at,
+  DROP COLUMN IF EXISTS started_at,
+  DROP COLUMN IF EXISTS performed_at;
+-- +goose StatementEnd
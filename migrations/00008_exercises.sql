@@ -0,0 +1,106 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS exercises (
+  id BIGSERIAL PRIMARY KEY,
+  -- NULL for the built-in library, otherwise the user owning a custom exercise
+  user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  aliases TEXT[] NOT NULL DEFAULT '{}',
+  primary_muscles TEXT[] NOT NULL DEFAULT '{}',
+  secondary_muscles TEXT[] NOT NULL DEFAULT '{}',
+  equipment VARCHAR(32) NOT NULL DEFAULT 'none',
+  movement_type VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_builtin_name ON exercises (lower(name)) WHERE user_id IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_user_name ON exercises (user_id, lower(name)) WHERE user_id IS NOT NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+INSERT INTO exercises (name, aliases, primary_muscles, secondary_muscles, equipment, movement_type) VALUES
+  ('Barbell Bench Press', '{"bench press","bench","bb bench","flat bench","bp"}', '{chest}', '{triceps,shoulders}', 'barbell', 'push'),
+  ('Incline Barbell Bench Press', '{"incline bench","incline bench press","incline bb bench"}', '{chest}', '{shoulders,triceps}', 'barbell', 'push'),
+  ('Dumbbell Bench Press', '{"db bench","db bench press","dumbbell press"}', '{chest}', '{triceps,shoulders}', 'dumbbell', 'push'),
+  ('Incline Dumbbell Press', '{"incline db press","incline dumbbell bench press"}', '{chest}', '{shoulders,triceps}', 'dumbbell', 'push'),
+  ('Dumbbell Fly', '{"db fly","dumbbell flye","chest fly"}', '{chest}', '{shoulders}', 'dumbbell', 'isolation'),
+  ('Cable Crossover', '{"cable fly","cable flye"}', '{chest}', '{shoulders}', 'cable', 'isolation'),
+  ('Push-Up', '{"push up","pushup","press up"}', '{chest}', '{triceps,shoulders,abs}', 'bodyweight', 'push'),
+  ('Dip', '{"dips","chest dip","tricep dip"}', '{chest,triceps}', '{shoulders}', 'bodyweight', 'push'),
+  ('Overhead Press', '{"ohp","military press","standing press","barbell shoulder press","shoulder press"}', '{shoulders}', '{triceps}', 'barbell', 'push'),
+  ('Dumbbell Shoulder Press', '{"db shoulder press","seated dumbbell press"}', '{shoulders}', '{triceps}', 'dumbbell', 'push'),
+  ('Lateral Raise', '{"side raise","db lateral raise","dumbbell lateral raise"}', '{shoulders}', '{}', 'dumbbell', 'isolation'),
+  ('Face Pull', '{"face pulls","cable face pull"}', '{shoulders}', '{traps,back}', 'cable', 'pull'),
+  ('Triceps Pushdown', '{"pushdown","tricep pushdown","cable pushdown","rope pushdown"}', '{triceps}', '{}', 'cable', 'isolation'),
+  ('Skull Crusher', '{"skullcrusher","lying triceps extension","ez bar skull crusher"}', '{triceps}', '{}', 'barbell', 'isolation'),
+  ('Overhead Triceps Extension', '{"overhead extension","french press"}', '{triceps}', '{}', 'dumbbell', 'isolation'),
+  ('Close-Grip Bench Press', '{"close grip bench","cgbp"}', '{triceps}', '{chest,shoulders}', 'barbell', 'push'),
+  ('Barbell Curl', '{"bb curl","biceps curl","curl","ez bar curl"}', '{biceps}', '{forearms}', 'barbell', 'isolation'),
+  ('Dumbbell Curl', '{"db curl","dumbbell biceps curl","alternating curl"}', '{biceps}', '{forearms}', 'dumbbell', 'isolation'),
+  ('Hammer Curl', '{"hammer curls","db hammer curl"}', '{biceps,forearms}', '{}', 'dumbbell', 'isolation'),
+  ('Preacher Curl', '{"preacher curls","scott curl"}', '{biceps}', '{}', 'barbell', 'isolation'),
+  ('Pull-Up', '{"pull up","pullup","chin up","chin-up"}', '{lats}', '{biceps,back}', 'bodyweight', 'pull'),
+  ('Lat Pulldown', '{"pulldown","lat pull down","cable pulldown"}', '{lats}', '{biceps,back}', 'cable', 'pull'),
+  ('Barbell Row', '{"bent over row","bb row","pendlay row","bent-over row"}', '{back,lats}', '{biceps,lower_back}', 'barbell', 'pull'),
+  ('Dumbbell Row', '{"db row","one arm row","single arm dumbbell row"}', '{back,lats}', '{biceps}', 'dumbbell', 'pull'),
+  ('Seated Cable Row', '{"cable row","seated row"}', '{back,lats}', '{biceps}', 'cable', 'pull'),
+  ('T-Bar Row', '{"t bar row","landmine row"}', '{back}', '{lats,biceps}', 'barbell', 'pull'),
+  ('Barbell Shrug', '{"shrug","shrugs","bb shrug"}', '{traps}', '{forearms}', 'barbell', 'isolation'),
+  ('Deadlift', '{"conventional deadlift","barbell deadlift","dl"}', '{hamstrings,glutes,lower_back}', '{back,traps,forearms,quadriceps}', 'barbell', 'hinge'),
+  ('Sumo Deadlift', '{"sumo dl","sumo"}', '{glutes,hamstrings,adductors}', '{quadriceps,lower_back}', 'barbell', 'hinge'),
+  ('Romanian Deadlift', '{"rdl","romanian dl","stiff leg deadlift"}', '{hamstrings,glutes}', '{lower_back}', 'barbell', 'hinge'),
+  ('Hip Thrust', '{"barbell hip thrust","glute bridge"}', '{glutes}', '{hamstrings}', 'barbell', 'hinge'),
+  ('Kettlebell Swing', '{"kb swing","swings"}', '{glutes,hamstrings}', '{lower_back,shoulders}', 'kettlebell', 'hinge'),
+  ('Back Squat', '{"squat","squats","barbell squat","bb squat","high bar squat","low bar squat"}', '{quadriceps,glutes}', '{hamstrings,lower_back}', 'barbell', 'squat'),
+  ('Front Squat', '{"front squats","barbell front squat"}', '{quadriceps}', '{glutes,abs}', 'barbell', 'squat'),
+  ('Goblet Squat', '{"goblet squats","db goblet squat","kb goblet squat"}', '{quadriceps,glutes}', '{abs}', 'dumbbell', 'squat'),
+  ('Leg Press', '{"machine leg press","45 degree leg press"}', '{quadriceps,glutes}', '{hamstrings}', 'machine', 'squat'),
+  ('Bulgarian Split Squat', '{"bss","split squat","rear foot elevated split squat"}', '{quadriceps,glutes}', '{hamstrings}', 'dumbbell', 'lunge'),
+  ('Walking Lunge', '{"lunges","lunge","db lunge"}', '{quadriceps,glutes}', '{hamstrings}', 'dumbbell', 'lunge'),
+  ('Leg Extension', '{"leg extensions","quad extension"}', '{quadriceps}', '{}', 'machine', 'isolation'),
+  ('Leg Curl', '{"hamstring curl","lying leg curl","seated leg curl"}', '{hamstrings}', '{}', 'machine', 'isolation'),
+  ('Standing Calf Raise', '{"calf raise","calf raises"}', '{calves}', '{}', 'machine', 'isolation'),
+  ('Plank', '{"front plank","planks"}', '{abs}', '{obliques,shoulders}', 'bodyweight', 'core'),
+  ('Hanging Leg Raise', '{"leg raise","hanging knee raise"}', '{abs}', '{obliques}', 'bodyweight', 'core'),
+  ('Crunch', '{"crunches","sit up","sit-up"}', '{abs}', '{}', 'bodyweight', 'core'),
+  ('Russian Twist', '{"russian twists"}', '{obliques}', '{abs}', 'bodyweight', 'core'),
+  ('Farmer''s Carry', '{"farmers walk","farmer carry","farmers carry"}', '{forearms,traps}', '{abs}', 'dumbbell', 'carry'),
+  ('Running', '{"run","jog","jogging","treadmill"}', '{quadriceps,calves}', '{hamstrings,glutes}', 'none', 'cardio'),
+  ('Cycling', '{"bike","biking","stationary bike","spin"}', '{quadriceps}', '{hamstrings,calves,glutes}', 'none', 'cardio'),
+  ('Rowing Machine', '{"rower","erg","rowing","indoor rowing"}', '{back,quadriceps}', '{biceps,hamstrings}', 'machine', 'cardio'),
+  ('Jump Rope', '{"skipping","skipping rope"}', '{calves}', '{shoulders}', 'none', 'cardio'),
+  ('Burpee', '{"burpees"}', '{full_body}', '{}', 'bodyweight', 'cardio')
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE workout_entries ADD COLUMN IF NOT EXISTS exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workout_entries_exercise_id ON workout_entries(exercise_id);
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- Link existing entries to the built-in library by comparing names and aliases
+-- with case, punctuation and spacing removed. Entries that match nothing keep
+-- their free-text name and a NULL exercise_id.
+UPDATE workout_entries e
+SET exercise_id = x.id, exercise_name = x.name
+FROM exercises x
+WHERE e.exercise_id IS NULL
+  AND x.user_id IS NULL
+  AND (
+    regexp_replace(lower(x.name), '[^a-z0-9]+', '', 'g') = regexp_replace(lower(e.exercise_name), '[^a-z0-9]+', '', 'g')
+    OR regexp_replace(lower(e.exercise_name), '[^a-z0-9]+', '', 'g') IN (
+      SELECT regexp_replace(lower(a), '[^a-z0-9]+', '', 'g') FROM unnest(x.aliases) a
+    )
+  );
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries DROP COLUMN IF EXISTS exercise_id;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE exercises;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_sets (
+  id BIGSERIAL PRIMARY KEY,
+  entry_id BIGINT NOT NULL REFERENCES workout_entries(id) ON DELETE CASCADE,
+  set_index INTEGER NOT NULL,
+  set_type VARCHAR(16) NOT NULL DEFAULT 'working',
+  reps INTEGER,
+  duration_seconds INTEGER,
+  weight DECIMAL(5, 2),
+  rpe DECIMAL(3, 1),
+  rir INTEGER,
+  tempo VARCHAR(16),
+  rest_seconds INTEGER,
+  completed BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT unique_set_index UNIQUE (entry_id, set_index),
+  CONSTRAINT valid_set_type CHECK (set_type IN ('warmup', 'working', 'drop', 'failure')),
+  CONSTRAINT valid_workout_set CHECK (
+    (
+      reps IS NOT NULL OR duration_seconds IS NOT NULL
+    ) AND (
+      reps IS NULL OR duration_seconds IS NULL
+    )
+  ),
+  CONSTRAINT valid_set_effort CHECK (
+    (rpe IS NULL OR (rpe >= 1 AND rpe <= 10)) AND (rir IS NULL OR rir >= 0)
+  ),
+  CONSTRAINT valid_set_rest CHECK (rest_seconds IS NULL OR rest_seconds >= 0)
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_workout_sets_entry_id ON workout_sets(entry_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_sets;
+-- +goose StatementEnd
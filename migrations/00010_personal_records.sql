@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS personal_records (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  exercise_id BIGINT REFERENCES exercises(id) ON DELETE CASCADE,
+  exercise_name VARCHAR(255) NOT NULL,
+  workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+  entry_id BIGINT REFERENCES workout_entries(id) ON DELETE CASCADE,
+  record_type VARCHAR(32) NOT NULL,
+  value DECIMAL(10, 2) NOT NULL,
+  weight DECIMAL(5, 2),
+  reps INTEGER,
+  achieved_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  -- false once a later performance has beaten this record
+  is_current BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT valid_record_type CHECK (record_type IN ('max_weight', 'max_reps', 'max_volume', 'estimated_1rm'))
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_personal_records_user_exercise ON personal_records(user_id, exercise_id, lower(exercise_name));
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_personal_records_workout_id ON personal_records(workout_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE personal_records;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+-- IANA zone name used to decide which day or week a workout falls in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
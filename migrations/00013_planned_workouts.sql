@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS planned_workouts (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  template_id BIGINT REFERENCES workout_templates(id) ON DELETE SET NULL,
+  title VARCHAR(255) NOT NULL,
+  notes TEXT,
+  -- DTSTART; recurrences keep its wall-clock time in the plan's timezone
+  starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+  duration_minutes INTEGER,
+  -- RFC 5545 RRULE value, NULL for a one-off session
+  rrule TEXT,
+  -- EXDATE: local dates removed from the schedule
+  exceptions DATE[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT valid_planned_duration CHECK (duration_minutes IS NULL OR duration_minutes > 0)
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_planned_workouts_user_id ON planned_workouts(user_id);
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- what became of one occurrence; occurrences without a row are still pending or were missed
+CREATE TABLE IF NOT EXISTS planned_occurrences (
+  id BIGSERIAL PRIMARY KEY,
+  planned_workout_id BIGINT NOT NULL REFERENCES planned_workouts(id) ON DELETE CASCADE,
+  occurrence_date DATE NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  -- deleting the logged workout turns the occurrence back into a pending or missed one
+  workout_id BIGINT REFERENCES workouts(id) ON DELETE CASCADE,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT unique_planned_occurrence UNIQUE (planned_workout_id, occurrence_date),
+  CONSTRAINT valid_occurrence_status CHECK (
+    (status = 'completed' AND workout_id IS NOT NULL) OR (status = 'skipped' AND workout_id IS NULL)
+  )
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_planned_occurrences_workout_id ON planned_occurrences(workout_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE planned_occurrences;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE planned_workouts;
+-- +goose StatementEnd
@@ -0,0 +1,100 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS programs (
+  id BIGSERIAL PRIMARY KEY,
+  -- the coach who wrote the program
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  description TEXT,
+  -- public programs can be enrolled in by every user
+  is_public BOOLEAN NOT NULL DEFAULT FALSE,
+  weeks INTEGER NOT NULL,
+  deload_weeks INTEGER[] NOT NULL DEFAULT '{}',
+  deload_load_factor DOUBLE PRECISION NOT NULL DEFAULT 0.6,
+  deload_volume_factor DOUBLE PRECISION NOT NULL DEFAULT 0.5,
+  -- prescribed loads are rounded to the nearest multiple of this
+  round_to DECIMAL(4, 2) NOT NULL DEFAULT 2.5,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT valid_program_weeks CHECK (weeks BETWEEN 1 AND 52),
+  CONSTRAINT valid_program_deload CHECK (
+    deload_load_factor > 0 AND deload_load_factor <= 1 AND deload_volume_factor > 0 AND deload_volume_factor <= 1
+  ),
+  CONSTRAINT valid_program_rounding CHECK (round_to > 0)
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_programs_user_id ON programs(user_id);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS program_days (
+  id BIGSERIAL PRIMARY KEY,
+  program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+  -- NULL repeats the day every week; a numbered week overrides it for that week only
+  week INTEGER,
+  -- 1 is the weekday the enrollment started on, 7 the day before it
+  day INTEGER NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  CONSTRAINT valid_program_day CHECK (day BETWEEN 1 AND 7 AND (week IS NULL OR week >= 1))
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS idx_program_days_slot ON program_days (program_id, COALESCE(week, 0), day);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS program_exercises (
+  id BIGSERIAL PRIMARY KEY,
+  program_day_id BIGINT NOT NULL REFERENCES program_days(id) ON DELETE CASCADE,
+  exercise_id BIGINT REFERENCES exercises(id) ON DELETE SET NULL,
+  exercise_name VARCHAR(255) NOT NULL,
+  order_index INTEGER NOT NULL,
+  sets INTEGER NOT NULL,
+  reps INTEGER,
+  duration_seconds INTEGER,
+  progression VARCHAR(16) NOT NULL DEFAULT 'none',
+  -- starting load for none/linear, the fallback 1RM for percent_1rm
+  base_weight DECIMAL(6, 2),
+  -- linear: load added after every session that hit all prescribed reps
+  increment DECIMAL(5, 2),
+  -- percent_1rm: one percentage (and optionally rep target) per training week, cycling
+  percentages DOUBLE PRECISION[] NOT NULL DEFAULT '{}',
+  wave_reps INTEGER[] NOT NULL DEFAULT '{}',
+  notes TEXT,
+  CONSTRAINT valid_program_exercise CHECK (
+    sets > 0 AND (
+      reps IS NOT NULL OR duration_seconds IS NOT NULL
+    ) AND (
+      reps IS NULL OR duration_seconds IS NULL
+    )
+  ),
+  CONSTRAINT valid_progression CHECK (progression IN ('none', 'linear', 'percent_1rm'))
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_program_exercises_day_id ON program_exercises(program_day_id);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS program_enrollments (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+  start_date DATE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_program_enrollments_user_id ON program_enrollments(user_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE program_enrollments;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE program_exercises;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE program_days;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE programs;
+-- +goose StatementEnd
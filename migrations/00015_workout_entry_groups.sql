@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+  ADD COLUMN section VARCHAR(16) NOT NULL DEFAULT 'main',
+  -- entries sharing a label within a workout are performed together
+  ADD COLUMN group_label VARCHAR(16),
+  ADD COLUMN group_type VARCHAR(16),
+  ADD COLUMN group_rounds INTEGER,
+  ADD COLUMN group_rest_seconds INTEGER,
+  ADD CONSTRAINT valid_entry_section CHECK (section IN ('warmup', 'main', 'cooldown')),
+  ADD CONSTRAINT valid_entry_group CHECK (
+    (
+      group_label IS NULL AND group_type IS NULL AND group_rounds IS NULL AND group_rest_seconds IS NULL
+    ) OR (
+      group_label IS NOT NULL AND group_type IN ('superset', 'circuit') AND group_rounds >= 1
+      AND (group_rest_seconds IS NULL OR group_rest_seconds >= 0)
+    )
+  );
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+  DROP CONSTRAINT valid_entry_group,
+  DROP CONSTRAINT valid_entry_section,
+  DROP COLUMN group_rest_seconds,
+  DROP COLUMN group_rounds,
+  DROP COLUMN group_type,
+  DROP COLUMN group_label,
+  DROP COLUMN section;
+-- +goose StatementEnd
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+  ADD COLUMN IF NOT EXISTS kind VARCHAR(16) NOT NULL DEFAULT 'strength',
+  ADD COLUMN IF NOT EXISTS distance DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS distance_unit VARCHAR(4),
+  ADD COLUMN IF NOT EXISTS elevation_gain_meters DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS avg_heart_rate INTEGER,
+  ADD COLUMN IF NOT EXISTS max_heart_rate INTEGER,
+  ADD COLUMN IF NOT EXISTS avg_cadence INTEGER,
+  ADD COLUMN IF NOT EXISTS avg_power INTEGER;
+-- +goose StatementEnd
+-- +goose StatementBegin
+UPDATE workout_entries SET kind = 'timed' WHERE kind = 'strength' AND reps IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- the constraints are dropped before they are added so that a rerun of the
+-- migration does not fail on them
+ALTER TABLE workout_entries
+  DROP CONSTRAINT IF EXISTS valid_workout_entry,
+  DROP CONSTRAINT IF EXISTS valid_entry_kind,
+  DROP CONSTRAINT IF EXISTS valid_cardio_fields,
+  DROP CONSTRAINT IF EXISTS valid_entry_distance,
+  ADD CONSTRAINT valid_entry_kind CHECK (
+    (kind = 'strength' AND reps IS NOT NULL AND duration_seconds IS NULL)
+    OR (kind = 'timed' AND duration_seconds IS NOT NULL AND reps IS NULL)
+    OR (kind = 'cardio' AND reps IS NULL AND (distance IS NOT NULL OR duration_seconds IS NOT NULL))
+  ),
+  -- distance, elevation, heart rate, cadence and power only describe cardio
+  ADD CONSTRAINT valid_cardio_fields CHECK (
+    kind = 'cardio' OR (
+      distance IS NULL AND elevation_gain_meters IS NULL AND avg_heart_rate IS NULL
+      AND max_heart_rate IS NULL AND avg_cadence IS NULL AND avg_power IS NULL
+    )
+  ),
+  ADD CONSTRAINT valid_entry_distance CHECK (
+    (distance IS NULL AND distance_unit IS NULL)
+    OR (distance > 0 AND distance_unit IN ('m', 'km', 'mi', 'yd'))
+  );
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM workout_entries WHERE kind = 'cardio' AND duration_seconds IS NULL;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+  DROP CONSTRAINT IF EXISTS valid_entry_distance,
+  DROP CONSTRAINT IF EXISTS valid_cardio_fields,
+  DROP CONSTRAINT IF EXISTS valid_entry_kind,
+  DROP CONSTRAINT IF EXISTS valid_workout_entry,
+  DROP COLUMN IF EXISTS avg_power,
+  DROP COLUMN IF EXISTS avg_cadence,
+  DROP COLUMN IF EXISTS max_heart_rate,
+  DROP COLUMN IF EXISTS avg_heart_rate,
+  DROP COLUMN IF EXISTS elevation_gain_meters,
+  DROP COLUMN IF EXISTS distance_unit,
+  DROP COLUMN IF EXISTS distance,
+  DROP COLUMN IF EXISTS kind,
+  ADD CONSTRAINT valid_workout_entry CHECK (
+    (
+      reps IS NOT NULL OR duration_seconds IS NOT NULL
+    ) AND (
+      reps IS NULL OR duration_seconds IS NULL
+    )
+  );
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS activity_tracks (
+  id BIGSERIAL PRIMARY KEY,
+  workout_id BIGINT NOT NULL UNIQUE REFERENCES workouts(id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  format VARCHAR(8) NOT NULL,
+  filename VARCHAR(255) NOT NULL,
+  -- the uploaded file exactly as received, so it can be downloaded again
+  raw BYTEA NOT NULL,
+  -- totals, splits and sensor stats worked out on import
+  summary JSONB NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT valid_track_format CHECK (format IN ('gpx', 'tcx'))
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_activity_tracks_user_id ON activity_tracks(user_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE activity_tracks;
+-- +goose StatementEnd
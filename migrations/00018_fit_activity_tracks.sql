@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE activity_tracks DROP CONSTRAINT valid_track_format;
+ALTER TABLE activity_tracks ADD CONSTRAINT valid_track_format CHECK (format IN ('gpx', 'tcx', 'fit'));
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM activity_tracks WHERE format = 'fit';
+ALTER TABLE activity_tracks DROP CONSTRAINT valid_track_format;
+ALTER TABLE activity_tracks ADD CONSTRAINT valid_track_format CHECK (format IN ('gpx', 'tcx'));
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS import_jobs (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  source VARCHAR(16) NOT NULL,
+  filename VARCHAR(255) NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'running',
+  total_rows INTEGER NOT NULL DEFAULT 0,
+  total_workouts INTEGER NOT NULL DEFAULT 0,
+  imported_workouts INTEGER NOT NULL DEFAULT 0,
+  duplicate_workouts INTEGER NOT NULL DEFAULT 0,
+  failed_workouts INTEGER NOT NULL DEFAULT 0,
+  -- rows that could not be read or saved, as [{"row": 12, "message": "..."}]
+  errors JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  finished_at TIMESTAMP WITH TIME ZONE,
+  CONSTRAINT valid_import_source CHECK (source IN ('strong', 'hevy', 'fitnotes')),
+  CONSTRAINT valid_import_status CHECK (status IN ('running', 'completed', 'failed'))
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_import_jobs_user_id ON import_jobs(user_id, created_at DESC);
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- imported_workouts remembers where a workout came from; the fingerprint
+-- catches the same workout being imported twice, from one app or another
+CREATE TABLE IF NOT EXISTS imported_workouts (
+  workout_id BIGINT PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  job_id BIGINT REFERENCES import_jobs(id) ON DELETE SET NULL,
+  fingerprint CHAR(64) NOT NULL,
+  UNIQUE (user_id, fingerprint)
+);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE imported_workouts;
+DROP TABLE import_jobs;
+-- +goose StatementEnd
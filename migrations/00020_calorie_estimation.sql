@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- sex and birth date let heart-rate based calorie estimates be used
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS sex VARCHAR(8) CHECK (sex IN ('male', 'female')),
+  ADD COLUMN IF NOT EXISTS birth_date DATE;
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS body_weights (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  weight_kg DOUBLE PRECISION NOT NULL CHECK (weight_kg > 0 AND weight_kg < 1000),
+  measured_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_body_weights_user_measured ON body_weights (user_id, measured_at DESC);
+-- +goose StatementEnd
+-- +goose StatementBegin
+-- measured when the client sent the calories, estimated when the server
+-- worked them out and none when neither was possible
+ALTER TABLE workouts
+  ADD COLUMN IF NOT EXISTS calories_source VARCHAR(16) NOT NULL DEFAULT 'measured'
+    CHECK (calories_source IN ('measured', 'estimated', 'none'));
+-- +goose StatementEnd
+-- +goose StatementBegin
+UPDATE workouts SET calories_source = 'none' WHERE COALESCE(calories_burned, 0) = 0;
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS calories_source;
+-- +goose StatementEnd
+-- +goose StatementBegin
+DROP TABLE IF EXISTS body_weights;
+-- +goose StatementEnd
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS birth_date, DROP COLUMN IF EXISTS sex;
+-- +goose StatementEnd
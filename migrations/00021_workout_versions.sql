@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- version counts the saves of a workout; clients send back the version they
+-- read so that a stale edit is refused instead of overwriting a newer one
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
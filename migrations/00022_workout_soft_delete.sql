@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- deleted_at marks a workout as moved to the trash; it stays restorable
+-- until it is purged, by the user or once the retention period is over
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_workouts_deleted_at ON workouts (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_deleted_at;
+ALTER TABLE workouts DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- one row per saved version of a workout, holding the workout as saved;
+-- reverted_from is the version a revert brought back
+CREATE TABLE IF NOT EXISTS workout_revisions (
+  workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+  version INTEGER NOT NULL,
+  author_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reverted_from INTEGER,
+  snapshot JSONB NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (workout_id, version)
+);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS workout_revisions;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the Idempotency-Key of a write, the fingerprint of the request that first
+-- used it and, once that request is done, the response to replay to retries;
+-- status_code is NULL while the first request is still in progress
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  key VARCHAR(255) NOT NULL,
+  fingerprint CHAR(64) NOT NULL,
+  status_code INTEGER,
+  response_header JSONB,
+  response_body BYTEA,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (user_id, key)
+);
+-- +goose StatementEnd
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd